@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -85,10 +86,58 @@ func main() {
 		validator = &MockValidator{}
 	}
 
-	hub := session.NewHub(validator)
+	hubConfig := session.LoadHubConfigFromEnv()
+	// PERMISSIONS_FILE replaces role permissions without recompiling. An
+	// invalid file stops startup rather than running with the wrong matrix.
+	if path := os.Getenv("PERMISSIONS_FILE"); path != "" {
+		permissions, err := session.LoadPermissionMatrix(path)
+		if err != nil {
+			slog.Error("Failed to load permission matrix", "error", err)
+			return
+		}
+		hubConfig.Room.EventPermissions = permissions
+		slog.Info("Loaded permission matrix", "path", path)
+	}
+	// Every hub records handler latency into one histogram, scraped at /metrics.
+	handlerLatency := session.NewHandlerLatencyHistogram()
+	// The endpoints share one linker, so a user's connections to each of them
+	// can be correlated and, with END_LINKED_SESSIONS, end together.
+	hubOptions := []session.HubOption{
+		session.WithHubConfig(hubConfig),
+		session.WithHandlerMetrics(handlerLatency),
+		session.WithSessionLinker(session.NewSessionLinker()),
+	}
+	// REDIS_ADDR shares rooms with other instances over Redis pub/sub. Each
+	// endpoint's rooms use their own topics, named after the endpoint.
+	onBus := func(string) session.HubOption { return func(*session.HubConfig) {} }
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		bus := session.NewRedisRoomBus(session.RedisBusConfig{
+			Addr:     addr,
+			Password: os.Getenv("REDIS_PASSWORD"),
+		})
+		defer bus.Close()
+		onBus = func(endpoint string) session.HubOption { return session.WithRoomBus(bus, endpoint) }
+		slog.Info("Sharing rooms over Redis", "addr", addr)
+	}
+	hub := session.NewHub(validator, append(hubOptions, onBus("hub"))...)
+
+	// Feature endpoints only process the events belonging to their feature.
+	zoomHub := session.NewHub(validator, append(hubOptions, onBus("zoom"), session.WithAllowedEvents(session.MediaEndpointEvents()))...)
+	screenshareHub := session.NewHub(validator, append(hubOptions, onBus("screenshare"), session.WithAllowedEvents(session.ScreenshareEndpointEvents()))...)
+	chatHub := session.NewHub(validator, append(hubOptions, onBus("chat"), session.WithAllowedEvents(session.ChatEndpointEvents()))...)
 
 	// --- Set up Server ---
 	router := gin.Default()
+	// Only the proxies in TRUSTED_PROXIES may set X-Forwarded-For. Otherwise
+	// any client could choose the IP its connection limits are counted under.
+	var trustedProxies []string
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		trustedProxies = strings.Split(proxies, ",")
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		slog.Error("Invalid TRUSTED_PROXIES", "error", err)
+		return
+	}
 	// Cors
 	config := cors.DefaultConfig()
 	allowedOrigins := session.GetAllowedOriginsFromEnv("ALLOWED_ORIGINS", []string{"http://localhost:3000"})
@@ -102,7 +151,38 @@ func main() {
 	wsGroup := router.Group("/ws")
 	{
 		wsGroup.GET("/hub/:roomId", hub.ServeWs)
+		wsGroup.GET("/zoom/:roomId", zoomHub.ServeWs)
+		wsGroup.GET("/screenshare/:roomId", screenshareHub.ServeWs)
+		wsGroup.GET("/chat/:roomId", chatHub.ServeWs)
 	}
+	// Chat export and presence cover a meeting's connections to every endpoint.
+	hubs := []*session.Hub{hub, zoomHub, screenshareHub, chatHub}
+	router.GET("/rooms/:roomId/chat", session.ServeChatExport(hubs...))
+	router.GET("/users/:clientId/rooms", session.ServePresence(hubs...))
+	router.GET("/metrics", gin.WrapH(handlerLatency))
+
+	// Operator endpoints act on every hub. Disabled unless ADMIN_TOKEN is set.
+	router.POST("/admin/announce", session.ServeAnnouncement(os.Getenv("ADMIN_TOKEN"), hubs...))
+	router.POST("/admin/drain", session.ServeDrain(os.Getenv("ADMIN_TOKEN"), hubs...))
+	namedHubs := map[string]*session.Hub{
+		"hub":         hub,
+		"zoom":        zoomHub,
+		"screenshare": screenshareHub,
+		"chat":        chatHub,
+	}
+	router.GET("/admin/snapshot", session.ServeSnapshot(os.Getenv("ADMIN_TOKEN"), namedHubs))
+	router.GET("/admin/events/:hub/:roomId", session.ServeEventHistory(os.Getenv("ADMIN_TOKEN"), namedHubs))
+
+	// SIGUSR1 also starts draining, for deploy tooling without HTTP access.
+	drain := make(chan os.Signal, 1)
+	signal.Notify(drain, syscall.SIGUSR1)
+	go func() {
+		for range drain {
+			for _, h := range hubs {
+				h.Drain()
+			}
+		}
+	}()
 
 	// Start the server.
 	srv := &http.Server{
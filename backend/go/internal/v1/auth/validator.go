@@ -17,6 +17,9 @@ type CustomClaims struct {
     Scope string `json:"scope"`
     Name  string `json:"name,omitempty"`
     Email string `json:"email,omitempty"`
+    // Optional profile metadata. Tokens without these claims leave them empty.
+    Picture  string `json:"picture,omitempty"`  // Avatar URL, from the standard OIDC picture claim
+    Pronouns string `json:"pronouns,omitempty"` // Custom claim set by the identity provider
     jwt.RegisteredClaims
 }
 
@@ -0,0 +1,160 @@
+// Package session - admin.go
+//
+// This file implements operator-only HTTP endpoints for a Hub.
+//
+// Authentication:
+// Admin endpoints are guarded by a shared admin token supplied as a Bearer
+// Authorization header. The token is compared in constant time. An empty
+// configured token disables the endpoint entirely rather than leaving it open.
+package session
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// announcementRequest is the body accepted by the announcement endpoint.
+type announcementRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
+// ServeAnnouncement returns a handler that pushes a system announcement to
+// every client connected to any of the given hubs.
+//
+// Parameters:
+//   - adminToken: The shared secret callers must present
+//   - hubs: The hubs whose clients receive the announcement
+//
+// Responses:
+//   - 404 Not Found if no admin token is configured.
+//   - 401 Unauthorized if the token is missing or wrong.
+//   - 400 Bad Request if the body has no message.
+//   - 204 No Content once the announcement has been broadcast.
+func ServeAnnouncement(adminToken string, hubs ...*Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorizeAdmin(c, adminToken) {
+			return
+		}
+
+		var req announcementRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "message is required"})
+			return
+		}
+
+		for _, h := range hubs {
+			h.BroadcastToAll(EventSystemAnnouncement, SystemAnnouncementPayload{Message: req.Message})
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ServeDrain returns a handler that puts the given hubs into draining mode, so
+// the node stops accepting new connections ahead of a rolling deploy while
+// existing meetings continue. See Hub.Drain.
+//
+// Parameters:
+//   - adminToken: The shared secret callers must present
+//   - hubs: The hubs to drain
+//
+// Responses:
+//   - 404 Not Found if no admin token is configured.
+//   - 401 Unauthorized if the token is missing or wrong.
+//   - 204 No Content once every hub is draining.
+func ServeDrain(adminToken string, hubs ...*Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorizeAdmin(c, adminToken) {
+			return
+		}
+
+		for _, h := range hubs {
+			h.Drain()
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ServeSnapshot returns a handler that responds with a consistent snapshot of
+// every room on the given hubs, keyed by hub name. See Hub.Snapshot.
+//
+// Parameters:
+//   - adminToken: The shared secret callers must present
+//   - hubs: The hubs to snapshot, keyed by the name used in the response
+//
+// Responses:
+//   - 404 Not Found if no admin token is configured.
+//   - 401 Unauthorized if the token is missing or wrong.
+//   - 200 OK with a JSON object mapping each hub name to its HubSnapshot.
+func ServeSnapshot(adminToken string, hubs map[string]*Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorizeAdmin(c, adminToken) {
+			return
+		}
+
+		snapshots := make(map[string]HubSnapshot, len(hubs))
+		for name, h := range hubs {
+			snapshots[name] = h.Snapshot()
+		}
+		c.JSON(http.StatusOK, snapshots)
+	}
+}
+
+// ServeEventHistory returns a handler that responds with the recent routed
+// messages of one room, for investigating a complaint about it. The hub and
+// room are named by the :hub and :roomId path parameters. See
+// RoomConfig.EventHistorySize.
+//
+// Parameters:
+//   - adminToken: The shared secret callers must present
+//   - hubs: The hubs whose rooms may be inspected, keyed by the name used in the path
+//
+// Responses:
+//   - 404 Not Found if no admin token is configured, the hub or room is
+//     unknown, or the hub keeps no event history.
+//   - 401 Unauthorized if the token is missing or wrong.
+//   - 200 OK with a JSON array of RoutedEvent, oldest first.
+func ServeEventHistory(adminToken string, hubs map[string]*Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorizeAdmin(c, adminToken) {
+			return
+		}
+
+		h, ok := hubs[c.Param("hub")]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "hub not found"})
+			return
+		}
+		if h.config.Room.EventHistorySize <= 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "event history is disabled"})
+			return
+		}
+		events, ok := h.EventHistory(RoomIdType(c.Param("roomId")))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+			return
+		}
+		c.JSON(http.StatusOK, events)
+	}
+}
+
+// authorizeAdmin checks the request's Bearer token against the admin token and
+// writes the error response if it does not match.
+//
+// Returns:
+//   - true if the request may proceed
+func authorizeAdmin(c *gin.Context, adminToken string) bool {
+	if adminToken == "" {
+		c.Status(http.StatusNotFound)
+		return false
+	}
+
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+		return false
+	}
+	return true
+}
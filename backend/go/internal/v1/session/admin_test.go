@@ -0,0 +1,187 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Social-Media/backend/go/internal/v1/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcastToAll(t *testing.T) {
+	// populate creates rooms on the hub, each with a host and a waiting client.
+	populate := func(hub *Hub, roomIds ...RoomIdType) []*Client {
+		var clients []*Client
+		for _, id := range roomIds {
+			room, _ := hub.getOrCreateRoom(id)
+			host := newTestClient(ClientIdType(string(id) + "-host"))
+			waiting := newTestClient(ClientIdType(string(id) + "-waiting"))
+			room.handleClientConnect(host)
+			room.handleClientConnect(waiting)
+			clients = append(clients, host, waiting)
+		}
+		return clients
+	}
+
+	// assertAnnounced checks every client received exactly the announcement.
+	assertAnnounced := func(t *testing.T, clients []*Client, message string) {
+		t.Helper()
+		for _, c := range clients {
+			require.Len(t, c.send, 1, "client %s should receive the announcement", c.ID)
+			var msg struct {
+				Event   Event                     `json:"event"`
+				Payload SystemAnnouncementPayload `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			assert.Equal(t, EventSystemAnnouncement, msg.Event)
+			assert.Equal(t, message, msg.Payload.Message)
+		}
+	}
+
+	t.Run("every client in every room receives the announcement", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		clients := populate(hub, "room-1", "room-2", "room-3")
+
+		hub.BroadcastToAll(EventSystemAnnouncement, SystemAnnouncementPayload{Message: "Maintenance at 10pm"})
+
+		assertAnnounced(t, clients, "Maintenance at 10pm")
+	})
+
+	t.Run("does not deadlock against concurrent room removal", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		clients := populate(hub, "room-1", "room-2")
+		room, _ := hub.getOrCreateRoom("room-3")
+		leaver := newTestClient("leaver")
+		room.handleClientConnect(leaver)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			room.handleClientDisconnect(leaver) // triggers hub.removeRoom
+		}()
+		hub.BroadcastToAll(EventSystemAnnouncement, SystemAnnouncementPayload{Message: "hello"})
+		<-done
+
+		assertAnnounced(t, clients, "hello")
+	})
+
+	t.Run("admin endpoint requires the admin token", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		hubs := []*Hub{NewTestHub(nil), NewTestHub(nil)}
+		clients := append(populate(hubs[0], "room-1"), populate(hubs[1], "room-2")...)
+
+		router := gin.New()
+		router.POST("/admin/announce", ServeAnnouncement("secret", hubs...))
+		post := func(token, body string) int {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/admin/announce", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, post("wrong", `{"message":"hi"}`))
+		assert.Equal(t, http.StatusBadRequest, post("secret", `{}`))
+		for _, c := range clients {
+			assert.Empty(t, c.send, "rejected requests should not broadcast")
+		}
+
+		assert.Equal(t, http.StatusNoContent, post("secret", `{"message":"hi"}`))
+		assertAnnounced(t, clients, "hi")
+	})
+
+	t.Run("admin endpoint is disabled without a token", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/admin/announce", ServeAnnouncement("", NewTestHub(nil)))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/admin/announce", strings.NewReader(`{"message":"hi"}`))
+		req.Header.Set("Authorization", "Bearer ")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestDrain(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(t *testing.T, hub *Hub) string {
+		t.Helper()
+		router := gin.New()
+		router.GET("/ws/:roomId", hub.ServeWs)
+		server := httptest.NewServer(router)
+		t.Cleanup(server.Close)
+		return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/room-1?token=valid"
+	}
+	claims := func(subject string) *auth.CustomClaims {
+		return &auth.CustomClaims{Name: subject, RegisteredClaims: jwt.RegisteredClaims{Subject: subject}}
+	}
+
+	t.Run("new connections are refused but existing ones keep working", func(t *testing.T) {
+		validator := &MockValidator{ClaimsToReturn: claims("host-1")}
+		hub := NewTestHub(validator)
+		url := serve(t, hub)
+
+		existing, _, err := websocket.DefaultDialer.Dial(url, nil)
+		require.NoError(t, err)
+		defer existing.Close()
+
+		hub.Drain()
+		assert.True(t, hub.IsDraining())
+
+		validator.ClaimsToReturn = claims("late-1")
+		_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		require.Error(t, err)
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+		// The existing connection is still served by its room
+		require.NoError(t, existing.WriteMessage(websocket.TextMessage, []byte("not json")))
+		require.NoError(t, existing.SetReadDeadline(time.Now().Add(time.Second)))
+		var msg struct {
+			Event   Event        `json:"event"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, existing.ReadJSON(&msg))
+		assert.Equal(t, ErrorCodeParse, msg.Payload.Code)
+
+		hub.mu.Lock()
+		room := hub.rooms["room-1"]
+		hub.mu.Unlock()
+		require.NotNil(t, room)
+		room.mu.RLock()
+		assert.Contains(t, room.hosts, ClientIdType("host-1"))
+		room.mu.RUnlock()
+	})
+
+	t.Run("admin endpoint requires the admin token", func(t *testing.T) {
+		hubs := []*Hub{NewTestHub(nil), NewTestHub(nil)}
+		router := gin.New()
+		router.POST("/admin/drain", ServeDrain("secret", hubs...))
+		post := func(token string) int {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/admin/drain", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, post("wrong"))
+		assert.False(t, hubs[0].IsDraining())
+
+		assert.Equal(t, http.StatusNoContent, post("secret"))
+		for _, h := range hubs {
+			assert.True(t, h.IsDraining())
+		}
+	})
+}
@@ -0,0 +1,199 @@
+// Package session - backoff.go
+//
+// This file implements the reconnect backoff enforced by Hub.ServeWs. A client
+// stuck in a reconnect loop, such as one retrying with an expired token, would
+// otherwise repeat token validation and upgrade work as fast as it can dial.
+//
+// Algorithm:
+// Failed connection attempts are counted per source IP and, once the token
+// has been validated, per subject. A subject is refused after its first
+// failure; an IP only after HubConfig.ReconnectBackoffIPFailures, since many
+// users behind one NAT share it. Once refused, the key waits ReconnectBackoff
+// doubled for each further failure, capped at ReconnectBackoffMax, and
+// attempts in that time are answered with 429 Too Many Requests and a
+// Retry-After header. A key with no failure for longer than
+// ReconnectBackoffMax after its refusal ends starts over.
+//
+// Clearing:
+// The counts for a connection's IP and subject are cleared once it has stayed
+// open for HubConfig.ReconnectStableAfter, not when it is admitted, so a
+// client that is admitted and then dropped straight away keeps backing off.
+//
+// Source IPs:
+// The IP is gin's Context.ClientIP, which only honors X-Forwarded-For from
+// proxies trusted with Engine.SetTrustedProxies. Trust only the deployment's
+// own proxies, or every client can pick the IP it is counted under.
+package session
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// reconnectBackoffSweepInterval is how often keys whose streak has lapsed are
+// forgotten, bounding memory to the sources failing recently.
+const reconnectBackoffSweepInterval = time.Minute
+
+// failureStreak is one key's run of consecutive failed connection attempts.
+type failureStreak struct {
+	failures     int       // Consecutive failures
+	blockedUntil time.Time // Attempts before this are refused
+}
+
+// reconnectBackoff tracks failed connection attempts by key.
+//
+// Thread Safety: All methods are safe for concurrent use.
+type reconnectBackoff struct {
+	mu        sync.Mutex
+	clock     clock.PassiveClock
+	base      time.Duration // Refusal after the first failure past allowed
+	max       time.Duration // Longest refusal
+	allowed   int           // Consecutive failures tolerated before the first refusal
+	streaks   map[string]*failureStreak
+	lastSweep time.Time
+}
+
+// newReconnectBackoff returns a backoff that refuses a key after its
+// threshold-th consecutive failure, starting at base and doubling up to
+// maxDelay, or nil if base is not positive. A maxDelay below base is raised
+// to base, and a threshold below one refuses after the first failure.
+func newReconnectBackoff(base, maxDelay time.Duration, threshold int, clk clock.PassiveClock) *reconnectBackoff {
+	if base <= 0 {
+		return nil
+	}
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	return &reconnectBackoff{
+		clock:     clk,
+		base:      base,
+		max:       max(maxDelay, base),
+		allowed:   max(threshold, 1) - 1,
+		streaks:   make(map[string]*failureStreak),
+		lastSweep: clk.Now(),
+	}
+}
+
+// ipBackoffKey and subjectBackoffKey keep IPs and subjects, which are both
+// free-form strings, apart in one map.
+func ipBackoffKey(ip string) string                 { return "ip:" + ip }
+func subjectBackoffKey(subject ClientIdType) string { return "subject:" + string(subject) }
+
+// retryAfter reports how long key must still wait before its next attempt.
+//
+// Returns:
+//   - The remaining wait, or zero if the key may connect now
+func (b *reconnectBackoff) retryAfter(key string) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	b.sweep(now)
+	if streak, ok := b.streaks[key]; ok && now.Before(streak.blockedUntil) {
+		return streak.blockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// fail records a failed attempt against each key and, once it is past the
+// tolerated failures, extends its refusal.
+func (b *reconnectBackoff) fail(keys ...string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	b.sweep(now)
+	for _, key := range keys {
+		streak, ok := b.streaks[key]
+		if !ok || now.Sub(streak.blockedUntil) > b.max {
+			streak = &failureStreak{}
+			b.streaks[key] = streak
+		}
+		streak.failures++
+		if streak.failures > b.allowed {
+			streak.blockedUntil = now.Add(b.delay(streak.failures - b.allowed))
+		} else {
+			// Not refused yet, but the streak lapses as if it had been
+			streak.blockedUntil = now
+		}
+	}
+}
+
+// reset forgets the failures of each key.
+func (b *reconnectBackoff) reset(keys ...string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range keys {
+		delete(b.streaks, key)
+	}
+}
+
+// delay returns the refusal after the given number of consecutive failures
+// past those tolerated.
+func (b *reconnectBackoff) delay(failures int) time.Duration {
+	factor := math.Pow(2, float64(failures-1))
+	if factor >= float64(b.max/b.base) {
+		return b.max
+	}
+	return time.Duration(factor) * b.base
+}
+
+// sweep forgets streaks whose refusal ended more than the longest refusal
+// ago. It runs at most once per reconnectBackoffSweepInterval. The caller
+// must hold b.mu.
+func (b *reconnectBackoff) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < reconnectBackoffSweepInterval {
+		return
+	}
+	b.lastSweep = now
+	for key, streak := range b.streaks {
+		if now.Sub(streak.blockedUntil) > b.max {
+			delete(b.streaks, key)
+		}
+	}
+}
+
+// resetBackoffWhenStable clears the failure counts of an admitted
+// connection's IP and subject once it has stayed open for
+// HubConfig.ReconnectStableAfter, or straight away if that is zero. Nothing is
+// cleared if the connection closes first.
+//
+// Parameters:
+//   - client: The admitted client; its context ends when the connection closes
+//   - ipKey, subjectKey: The connection's backoff keys
+func (h *Hub) resetBackoffWhenStable(client *Client, ipKey, subjectKey string) {
+	if h.ipReconnects == nil && h.reconnects == nil {
+		return
+	}
+	reset := func() {
+		h.ipReconnects.reset(ipKey)
+		h.reconnects.reset(subjectKey)
+	}
+	stableAfter := h.config.ReconnectStableAfter
+	if stableAfter <= 0 {
+		reset()
+		return
+	}
+	timer := h.config.Room.Clock.AfterFunc(stableAfter, reset)
+	context.AfterFunc(client.context(), func() { timer.Stop() })
+}
+
+// retryAfterSeconds formats a wait for the Retry-After header, which counts
+// whole seconds, rounding up so clients never retry early.
+func retryAfterSeconds(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
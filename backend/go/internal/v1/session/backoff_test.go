@@ -0,0 +1,187 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Social-Media/backend/go/internal/v1/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	testclock "k8s.io/utils/clock/testing"
+)
+
+// tokenValidatorFunc adapts a function to TokenValidator.
+type tokenValidatorFunc func(tokenString string) (*auth.CustomClaims, error)
+
+func (f tokenValidatorFunc) ValidateToken(tokenString string) (*auth.CustomClaims, error) {
+	return f(tokenString)
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// newServer accepts the token "good" and rejects every other one. Unless
+	// configure says otherwise, an IP backs off after one failure and is
+	// cleared as soon as a connection is admitted.
+	newServer := func(t *testing.T, configure ...func(*HubConfig)) (*httptest.Server, *testclock.FakeClock) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultHubConfig()
+		config.Room.Clock = fakeClock
+		config.ReconnectBackoff = time.Second
+		config.ReconnectBackoffMax = 5 * time.Second
+		config.ReconnectBackoffIPFailures = 1
+		config.ReconnectStableAfter = 0
+		for _, f := range configure {
+			f(&config)
+		}
+		hub := NewHubWithConfig(tokenValidatorFunc(func(token string) (*auth.CustomClaims, error) {
+			if token != "good" {
+				return nil, assert.AnError
+			}
+			return &auth.CustomClaims{Name: "Alice", RegisteredClaims: jwt.RegisteredClaims{Subject: "alice"}}, nil
+		}), config)
+		router := gin.New()
+		router.GET("/ws/:roomId", hub.ServeWs)
+		server := httptest.NewServer(router)
+		t.Cleanup(server.Close)
+		return server, fakeClock
+	}
+
+	// dial connects with the token and returns the connection, if one was
+	// made, and the status and Retry-After header.
+	dial := func(t *testing.T, server *httptest.Server, token string) (*websocket.Conn, int, string) {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/room-1?token=" + token
+		conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			require.NotNil(t, resp, "dial failed without a response: %v", err)
+			return nil, resp.StatusCode, resp.Header.Get("Retry-After")
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn, resp.StatusCode, ""
+	}
+
+	// connect dials with the token and returns the status and Retry-After header.
+	connect := func(t *testing.T, server *httptest.Server, token string) (int, string) {
+		_, status, retryAfter := dial(t, server, token)
+		return status, retryAfter
+	}
+
+	t.Run("repeated failures back off for longer each time", func(t *testing.T) {
+		server, fakeClock := newServer(t)
+
+		status, _ := connect(t, server, "bad")
+		assert.Equal(t, http.StatusUnauthorized, status)
+		status, retryAfter := connect(t, server, "good")
+		assert.Equal(t, http.StatusTooManyRequests, status, "Even a good token waits out the backoff")
+		assert.Equal(t, "1", retryAfter)
+
+		fakeClock.Step(time.Second)
+		status, _ = connect(t, server, "bad")
+		assert.Equal(t, http.StatusUnauthorized, status)
+		_, retryAfter = connect(t, server, "bad")
+		assert.Equal(t, "2", retryAfter)
+
+		fakeClock.Step(2 * time.Second)
+		connect(t, server, "bad")
+		fakeClock.Step(4 * time.Second)
+		connect(t, server, "bad")
+		_, retryAfter = connect(t, server, "bad")
+		assert.Equal(t, "5", retryAfter, "The wait is capped")
+	})
+
+	t.Run("a successful connection clears the failures", func(t *testing.T) {
+		server, fakeClock := newServer(t)
+		for _, wait := range []time.Duration{time.Second, 2 * time.Second} {
+			connect(t, server, "bad")
+			fakeClock.Step(wait)
+		}
+
+		status, _ := connect(t, server, "good")
+		require.Equal(t, http.StatusSwitchingProtocols, status)
+
+		connect(t, server, "bad")
+		_, retryAfter := connect(t, server, "bad")
+		assert.Equal(t, "1", retryAfter, "The next failure starts a new streak")
+	})
+
+	t.Run("a streak lapses after a quiet period", func(t *testing.T) {
+		server, fakeClock := newServer(t)
+		connect(t, server, "bad")
+		fakeClock.Step(time.Second)
+		connect(t, server, "bad")
+
+		fakeClock.Step(time.Minute)
+		connect(t, server, "bad")
+		_, retryAfter := connect(t, server, "bad")
+		assert.Equal(t, "1", retryAfter)
+	})
+
+	t.Run("an IP may fail several times before it backs off", func(t *testing.T) {
+		server, _ := newServer(t, func(config *HubConfig) { config.ReconnectBackoffIPFailures = 3 })
+
+		for range 3 {
+			status, _ := connect(t, server, "bad")
+			assert.Equal(t, http.StatusUnauthorized, status, "Users sharing an IP are not refused for one another's mistakes")
+		}
+		status, retryAfter := connect(t, server, "bad")
+		assert.Equal(t, http.StatusTooManyRequests, status)
+		assert.Equal(t, "1", retryAfter, "The wait starts from ReconnectBackoff")
+	})
+
+	t.Run("failures are cleared only once a connection is stable", func(t *testing.T) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultHubConfig()
+		config.Room.Clock = fakeClock
+		config.ReconnectBackoff = time.Second
+		config.ReconnectBackoffMax = time.Minute
+		config.ReconnectStableAfter = 10 * time.Second
+		hub := NewHubWithConfig(&MockValidator{}, config)
+		ipKey, subjectKey := ipBackoffKey("192.0.2.1"), subjectBackoffKey("alice")
+
+		// admit starts the stability timer for a connection, as ServeWs does.
+		admit := func() *Client {
+			client := newTestClient("alice")
+			client.ctx, client.cancel = context.WithCancel(context.Background())
+			hub.resetBackoffWhenStable(client, ipKey, subjectKey)
+			return client
+		}
+
+		hub.reconnects.fail(subjectKey)
+		fakeClock.Step(time.Second)
+		dropped := admit()
+		dropped.cancelContext()
+		require.Eventually(t, func() bool { return !fakeClock.HasWaiters() }, time.Second, 5*time.Millisecond)
+		fakeClock.Step(10 * time.Second)
+		hub.reconnects.fail(subjectKey)
+		assert.Equal(t, 2*time.Second, hub.reconnects.retryAfter(subjectKey), "A connection that dropped early cleared nothing")
+
+		fakeClock.Step(2 * time.Second)
+		admit()
+		fakeClock.Step(10 * time.Second)
+		require.Eventually(t, func() bool {
+			hub.reconnects.mu.Lock()
+			defer hub.reconnects.mu.Unlock()
+			return len(hub.reconnects.streaks) == 0
+		}, time.Second, 5*time.Millisecond, "A stable connection clears the streak")
+		hub.reconnects.fail(subjectKey)
+		assert.Equal(t, time.Second, hub.reconnects.retryAfter(subjectKey), "The next failure starts a new streak")
+	})
+
+	t.Run("zero backoff is disabled", func(t *testing.T) {
+		hub := NewHubWithConfig(&MockValidator{ErrorToReturn: assert.AnError}, DefaultHubConfig())
+		router := gin.New()
+		router.GET("/ws/:roomId", hub.ServeWs)
+		for range 5 {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws/room-1?token=bad", nil))
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+// Package session - bus.go
+//
+// This file defines the RoomBus extension point, which lets several hub
+// instances serve the same meeting. A room lives in one process, so clients
+// connected to different backend instances each see their own copy of it;
+// the bus carries every broadcast from one copy to the others, which deliver
+// it to their own clients.
+//
+// Scope:
+// Besides broadcasts, the copies exchange their rosters, messages addressed
+// to one client, such as WebRTC signaling, and host actions on clients of
+// another copy; see remote.go. Chat history and room settings stay per copy.
+//
+// Topics:
+// Copies of a room meet on a topic named by RoomConfig.BusNamespace and the
+// room id. Hubs serving different endpoints, such as zoom and chat, hold
+// rooms with the same ids, so each endpoint needs its own namespace when they
+// share a bus; the same endpoint on every instance uses the same one.
+//
+// Loop Prevention:
+// Every room copy publishes with an origin unique to it and ignores messages
+// carrying its own origin, so a broadcast is delivered locally once, by the
+// room that made it, and never echoed back.
+//
+// Provided Implementations:
+//   - MemoryRoomBus: Connects hubs within one process (tests and single-binary deployments)
+//   - RedisRoomBus: Connects hubs in any number of processes over Redis pub/sub; see redisbus.go
+package session
+
+import (
+	"crypto/rand"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"k8s.io/utils/set"
+)
+
+// RoomBus carries room messages between hub instances. Publish is called
+// while the room lock is held and must not block; subscription handlers are
+// called without any room lock and may block briefly.
+type RoomBus interface {
+	// Publish sends msg to every subscriber of msg.Topic, including ones in
+	// the publishing process.
+	Publish(msg BusMessage)
+
+	// Subscribe calls handler with every message published on topic until
+	// the returned function is called.
+	Subscribe(topic string, handler func(BusMessage)) (unsubscribe func())
+}
+
+// BusKind says what a BusMessage carries.
+type BusKind string
+
+const (
+	BusKindBroadcast     BusKind = ""               // A broadcast for every copy's clients in Roles
+	BusKindDirect        BusKind = "direct"         // A message for the client To only
+	BusKindAction        BusKind = "action"         // A host action, by From, on the client To
+	BusKindRoster        BusKind = "roster"         // The publishing copy's clients, in Members
+	BusKindRosterRequest BusKind = "roster_request" // A new copy asking the others for their rosters
+)
+
+// BusMessage is one message carried between room copies.
+type BusMessage struct {
+	Kind    BusKind      `json:"kind,omitempty"`    // What the message carries
+	Topic   string       `json:"topic"`             // The room's topic; see busTopic
+	Origin  string       `json:"origin"`            // The publishing room copy; see newBusOrigin
+	RoomId  RoomIdType   `json:"roomId"`            // The room the message belongs to
+	Event   Event        `json:"event,omitempty"`   // The event, for the receiver's visibility rules
+	Roles   []RoleType   `json:"roles,omitempty"`   // Roles addressed, sorted; empty for everyone
+	To      ClientIdType `json:"to,omitempty"`      // The client a direct message or action is for
+	From    *BusMember   `json:"from,omitempty"`    // The host taking an action
+	Members []BusMember  `json:"members,omitempty"` // The publishing copy's clients, for a roster
+	Data    []byte       `json:"data,omitempty"`    // The encoded Message, delivered to clients as is
+}
+
+// BusMember is one client in a room copy's roster.
+type BusMember struct {
+	ClientInfo
+	Role       RoleType `json:"role"`
+	Sharing    bool     `json:"sharing,omitempty"`    // Sharing its screen, whatever its role
+	HandRaised bool     `json:"handRaised,omitempty"` // Waiting to speak
+}
+
+// busTopic names the topic shared by the copies of a room: the namespace,
+// if any, then the room id.
+func busTopic(namespace string, roomId RoomIdType) string {
+	if namespace == "" {
+		return string(roomId)
+	}
+	return namespace + ":" + string(roomId)
+}
+
+// newBusOrigin returns an identifier for a room copy that is unique across
+// processes.
+func newBusOrigin() string {
+	return rand.Text()
+}
+
+// busRoles converts a broadcast's role set to its wire form.
+func busRoles(roles set.Set[RoleType]) []RoleType {
+	if roles == nil {
+		return nil
+	}
+	list := roles.UnsortedList()
+	slices.Sort(list)
+	return list
+}
+
+// MemoryRoomBus is a RoomBus connecting hubs in the same process. Each
+// subscription has its own queue and goroutine, so publishing never blocks
+// and messages reach each subscriber in publish order. Messages are dropped,
+// with a warning, when a subscriber's queue is full.
+type MemoryRoomBus struct {
+	mu         sync.Mutex
+	subs       map[string]map[*busSubscription]struct{}
+	bufferSize int
+}
+
+// busSubscription is one MemoryRoomBus subscriber's queue.
+type busSubscription struct {
+	queue chan BusMessage
+}
+
+// NewMemoryRoomBus creates a MemoryRoomBus whose subscribers each queue up to
+// bufferSize undelivered messages.
+func NewMemoryRoomBus(bufferSize int) *MemoryRoomBus {
+	return &MemoryRoomBus{
+		subs:       make(map[string]map[*busSubscription]struct{}),
+		bufferSize: bufferSize,
+	}
+}
+
+// Publish queues msg for every subscriber of its topic without blocking.
+func (b *MemoryRoomBus) Publish(msg BusMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs[msg.Topic] {
+		select {
+		case sub.queue <- msg:
+		default:
+			slog.Warn("Room bus subscriber queue full, dropping message", "RoomId", msg.RoomId, "event", msg.Event)
+		}
+	}
+}
+
+// Subscribe delivers messages on topic to handler on a new goroutine until
+// the returned function is called. Messages still queued then are discarded.
+func (b *MemoryRoomBus) Subscribe(topic string, handler func(BusMessage)) func() {
+	sub := &busSubscription{queue: make(chan BusMessage, b.bufferSize)}
+	b.mu.Lock()
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[*busSubscription]struct{})
+	}
+	b.subs[topic][sub] = struct{}{}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case msg := <-sub.queue:
+				handler(msg)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[topic], sub)
+			if len(b.subs[topic]) == 0 {
+				delete(b.subs, topic)
+			}
+			b.mu.Unlock()
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoomBus(t *testing.T) {
+	// newHubs creates two hubs sharing one bus, as two backend instances would.
+	newHubs := func() (*Hub, *Hub) {
+		bus := NewMemoryRoomBus(16)
+		config := DefaultHubConfig()
+		config.Room.Bus = bus
+		return NewHubWithConfig(&MockValidator{}, config), NewHubWithConfig(&MockValidator{}, config)
+	}
+
+	// events returns the events c was sent within a short wait.
+	events := func(c *Client) []Event {
+		var got []Event
+		for {
+			select {
+			case raw := <-c.send:
+				var msg wireMessage
+				if json.Unmarshal(raw, &msg) == nil {
+					got = append(got, msg.Event)
+				}
+			case <-time.After(50 * time.Millisecond):
+				return got
+			}
+		}
+	}
+
+	t.Run("broadcasts reach clients of the same room on another hub once", func(t *testing.T) {
+		hubA, hubB := newHubs()
+		roomA, _ := hubA.getOrCreateRoom("meeting")
+		roomB, _ := hubB.getOrCreateRoom("meeting")
+		defer roomA.close()
+		defer roomB.close()
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		roomA.handleClientConnect(alice)
+		roomB.handleClientConnect(bob)
+
+		roomA.router(context.Background(), alice, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: alice.info(), ChatContent: "hello from A"}})
+
+		assert.Equal(t, []Event{EventAddChat}, events(bob), "The chat crosses to the other hub")
+		assert.Equal(t, []Event{EventAddChat}, events(alice), "The sender's hub does not deliver it twice")
+	})
+
+	t.Run("role-addressed broadcasts keep their audience", func(t *testing.T) {
+		hubA, hubB := newHubs()
+		roomA, _ := hubA.getOrCreateRoom("meeting")
+		roomB, _ := hubB.getOrCreateRoom("meeting")
+		defer roomA.close()
+		defer roomB.close()
+		hostB := newTestClient("host-b")
+		waitingB := newTestClient("waiting-b")
+		roomB.handleClientConnect(hostB)
+		roomB.handleClientConnect(waitingB)
+		events(hostB)
+		events(waitingB)
+
+		roomA.mu.Lock()
+		roomA.broadcast(context.Background(), EventSetWelcome, SetWelcomePayload{Message: "hi"}, HasHostPermission())
+		roomA.mu.Unlock()
+
+		assert.Equal(t, []Event{EventSetWelcome}, events(hostB))
+		assert.Empty(t, events(waitingB))
+	})
+
+	t.Run("other rooms and closed rooms receive nothing", func(t *testing.T) {
+		hubA, hubB := newHubs()
+		roomA, _ := hubA.getOrCreateRoom("meeting")
+		other, _ := hubB.getOrCreateRoom("other-meeting")
+		closed, _ := hubB.getOrCreateRoom("meeting")
+		defer roomA.close()
+		defer other.close()
+		otherClient := newTestClient("other")
+		closedClient := newTestClient("closed")
+		other.handleClientConnect(otherClient)
+		closed.handleClientConnect(closedClient)
+		closed.close()
+
+		roomA.mu.Lock()
+		roomA.broadcast(context.Background(), EventReaction, ReactionPayload{Emoji: "👍"}, nil)
+		roomA.mu.Unlock()
+
+		assert.Empty(t, events(otherClient))
+		assert.Empty(t, events(closedClient))
+	})
+
+	t.Run("rooms of other endpoints with the same id receive nothing", func(t *testing.T) {
+		bus := NewMemoryRoomBus(16)
+		zoomHub := NewHub(&MockValidator{}, WithRoomBus(bus, "zoom"))
+		otherZoomHub := NewHub(&MockValidator{}, WithRoomBus(bus, "zoom"))
+		chatHub := NewHub(&MockValidator{}, WithRoomBus(bus, "chat"))
+		zoomRoom, _ := zoomHub.getOrCreateRoom("meeting")
+		otherZoomRoom, _ := otherZoomHub.getOrCreateRoom("meeting")
+		chatRoom, _ := chatHub.getOrCreateRoom("meeting")
+		defer zoomRoom.close()
+		defer otherZoomRoom.close()
+		defer chatRoom.close()
+		zoomClient := newTestClient("zoom-client")
+		chatClient := newTestClient("chat-client")
+		otherZoomRoom.handleClientConnect(zoomClient)
+		chatRoom.handleClientConnect(chatClient)
+		events(zoomClient)
+		events(chatClient)
+
+		zoomRoom.mu.Lock()
+		zoomRoom.broadcast(context.Background(), EventReaction, ReactionPayload{Emoji: "👍"}, nil)
+		zoomRoom.mu.Unlock()
+
+		assert.Equal(t, []Event{EventReaction}, events(zoomClient), "The same endpoint on another instance receives it")
+		assert.Empty(t, events(chatClient), "The chat endpoint's room of the same id does not")
+	})
+}
+
+func TestMemoryRoomBus(t *testing.T) {
+	bus := NewMemoryRoomBus(4)
+	received := make(chan BusMessage, 4)
+	unsubscribe := bus.Subscribe("zoom:room", func(msg BusMessage) { received <- msg })
+
+	bus.Publish(BusMessage{Topic: "zoom:room", RoomId: "room", Event: EventAddChat})
+	bus.Publish(BusMessage{Topic: "chat:room", RoomId: "room", Event: EventAddChat})
+	select {
+	case msg := <-received:
+		assert.Equal(t, "zoom:room", msg.Topic)
+	case <-time.After(time.Second):
+		require.Fail(t, "subscriber did not receive the message")
+	}
+
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(BusMessage{Topic: "zoom:room", RoomId: "room", Event: EventAddChat})
+	select {
+	case <-received:
+		assert.Fail(t, "an unsubscribed handler should receive nothing")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Empty(t, bus.subs)
+}
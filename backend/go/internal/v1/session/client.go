@@ -24,10 +24,16 @@ package session
 
 import (
 	"container/list"
+	"context"
 	"encoding/json"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/set"
 )
 
 // --- Connection and Room Interfaces ---
@@ -70,8 +76,8 @@ type wsConnection interface {
 // providing full room functionality including state management,
 // permission checking, and message broadcasting.
 type Roomer interface {
-	router(c *Client, data any)       // Route incoming messages to appropriate handlers
-	handleClientDisconnect(c *Client) // Handle client disconnection cleanup
+	router(ctx context.Context, c *Client, data any) // Route incoming messages to appropriate handlers
+	handleClientDisconnect(c *Client)                // Handle client disconnection cleanup
 }
 
 // Client represents a single user's connection to a video conference room.
@@ -98,15 +104,57 @@ type Roomer interface {
 // The client communicates with its room through the Roomer interface,
 // enabling clean separation of concerns and easier testing.
 type Client struct {
-	conn             wsConnection    // WebSocket connection for real-time communication
-	send             chan []byte     // Buffered channel for outgoing messages
-	room             Roomer          // Room interface for business logic operations
-	ID               ClientIdType    // Unique identifier from JWT token
-	DisplayName      DisplayNameType // Human-readable name for UI display
-	Role             RoleType        // Current permission level in the room
-	drawOrderElement *list.Element   // Position reference in room draw order queues
+	conn             wsConnection     // WebSocket connection for real-time communication
+	send             chan []byte      // Buffered channel for outgoing messages
+	room             Roomer           // Room interface for business logic operations; see currentRoom
+	roomMu           sync.Mutex       // Guards room, which Hub.MoveClient changes while the pumps run
+	ID               ClientIdType     // Unique identifier from JWT token
+	DisplayName      DisplayNameType  // Human-readable name for UI display
+	AvatarURL        string           // Profile picture URL from JWT claims, empty if absent
+	Pronouns         string           // Pronouns from JWT claims, empty if absent
+	Role             RoleType         // Current permission level in the room
+	drawOrderElement *list.Element    // Position reference in room draw order queues
+	leaving          bool             // Set by the room when the client announced an intentional leave
+	kicked           bool             // Set by the room when a host removed the client
+	refused          bool             // Set by the room when it turned the connection away on joining
+	closeNotice      chan closeNotice // Final message for writePump to deliver before closing, if any
+	done             chan struct{}    // Closed when readPump exits, so writePump stops too
+	joinSeq          uint64           // Order in which the client joined its room, for stable rosters
+
+	// Lives as long as the connection: cancelled when either pump exits or the
+	// room closes an idle connection. Nil for clients without a connection.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Parse error replies are rate limited so a misbehaving client cannot
+	// turn a flood of garbage into a flood of responses. Only readPump
+	// touches these fields.
+	parseErrorWindowStart time.Time // Start of the current rate limit window
+	parseErrorReplies     int       // Replies sent in the current window
+
+	// Room-enforced limits. These are only touched while the room's lock is held.
+	chatWindowStart time.Time   // Start of the current chat rate limit window
+	chatCount       int         // Chat messages sent in the current window
+	idleTimer       clock.Timer // Closes the connection after the room's idle timeout
+	waitingTimer    clock.Timer // Times out the client if it is not admitted from waiting
+
+	lastWaitingRequest time.Time    // When this client's last waiting request was forwarded to hosts
+	recentEvents       recentEvents // Messages sent recently, for duplicate suppression
+
+	// Counts sendError calls, so duplicate suppression can tell whether a
+	// handler rejected a message. Errors may be sent off the room lock.
+	errorsSent atomic.Uint64
+
+	// Hosts that have approved this waiting client so far
+	admitApprovals set.Set[ClientIdType]
 }
 
+// Parse error reply limits applied per client in readPump.
+const (
+	maxParseErrorReplies  = 5                // Replies allowed per window
+	parseErrorReplyWindow = 10 * time.Second // Length of the rate limit window
+)
+
 // readPump continuously processes incoming WebSocket messages from the client.
 // This method runs in its own goroutine and handles the complete message lifecycle
 // from reception through routing to the appropriate room handlers.
@@ -119,7 +167,8 @@ type Client struct {
 //
 // Error Handling:
 //   - Connection errors trigger graceful disconnection and room cleanup
-//   - JSON unmarshaling errors are logged but don't close the connection
+//   - JSON unmarshaling errors are logged, answered with a rate-limited
+//     EventError, and don't close the connection
 //   - Unexpected close errors are logged with additional detail
 //
 // Cleanup Guarantee:
@@ -132,8 +181,12 @@ type Client struct {
 // of the client's bidirectional communication channel.
 func (c *Client) readPump() {
 	defer func() {
-		c.room.handleClientDisconnect(c)
+		c.cancelContext()
+		c.currentRoom().handleClientDisconnect(c)
 		c.conn.Close()
+		if c.done != nil {
+			close(c.done)
+		}
 	}()
 
 	for {
@@ -148,10 +201,15 @@ func (c *Client) readPump() {
 		var msg Message
 		if err := json.Unmarshal(rawMessage, &msg); err != nil {
 			slog.Warn("Failed to unmarshal message", "ClientId", c.ID, "error", err)
+			if c.allowParseErrorReply(time.Now()) {
+				c.sendError(ErrorCodeParse, "message is not valid JSON", "")
+			}
 			continue
 		}
 
-		c.room.router(c, msg)
+		ctx, cancel := context.WithCancel(c.context())
+		c.currentRoom().router(ctx, c, msg)
+		cancel()
 	}
 }
 
@@ -176,18 +234,183 @@ func (c *Client) readPump() {
 //
 // Connection Cleanup:
 // The defer statement guarantees the WebSocket connection is closed when
-// the method exits, regardless of the exit condition (channel close or error).
+// the method exits, regardless of the exit condition (channel close, error,
+// or readPump exiting).
 //
 // Concurrency:
 // This method is designed to run as a goroutine and handles the write side
 // of the client's bidirectional communication channel. It coordinates with
 // readPump to provide full-duplex communication.
 func (c *Client) writePump() {
+	defer c.cancelContext()
 	defer c.conn.Close()
-	for message := range c.send {
-		if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			slog.Error("error writing message", "error", err)
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				slog.Error("error writing message", "error", err)
+				return
+			}
+		case notice := <-c.closeNotice:
+			c.writeCloseNotice(notice)
+			return
+		case <-c.done:
+			// The connection is gone; the send channel is never closed
+			// because rooms and the hub may still be sending to it.
 			return
 		}
 	}
 }
+
+// context returns the client's connection context, or a background context
+// for clients created without a connection.
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// currentRoom returns the room the client belongs to. It may change between
+// calls if the hub moves the client to another room.
+//
+// Thread Safety: Safe to call with or without a room lock held.
+func (c *Client) currentRoom() Roomer {
+	c.roomMu.Lock()
+	defer c.roomMu.Unlock()
+	return c.room
+}
+
+// setRoom records the room the client belongs to.
+//
+// Thread Safety: Safe to call with or without a room lock held. When moving
+// a client, the caller holds the locks of both rooms, so a room that sees the
+// change under its lock knows the client has left it.
+func (c *Client) setRoom(room Roomer) {
+	c.roomMu.Lock()
+	defer c.roomMu.Unlock()
+	c.room = room
+}
+
+// movedFrom reports whether the client has been moved out of room.
+// Clients created without a room, as in tests, are never considered moved.
+func (c *Client) movedFrom(room Roomer) bool {
+	current := c.currentRoom()
+	return current != nil && current != room
+}
+
+// cancelContext cancels the client's connection context, aborting any work
+// still running on behalf of its messages. It is safe to call more than once.
+func (c *Client) cancelContext() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// closeNoticeTimeout bounds how long writing a close notice may block on a
+// client that has stopped reading.
+const closeNoticeTimeout = time.Second
+
+// closeNotice is a final message and the close frame to follow it.
+type closeNotice struct {
+	msg    []byte // Encoded message written before the close frame
+	code   int    // WebSocket close code, such as websocket.ClosePolicyViolation
+	reason string // Close reason; at most 123 bytes
+}
+
+// closeWithNotice closes the client's connection after delivering msg, so the
+// client learns why it was disconnected instead of seeing a bare drop. The
+// write pump writes the notice, then a close frame with code and reason, then
+// closes the connection. Only the first notice is delivered.
+//
+// Clients without a write pump, as in tests, have the notice queued on their
+// send channel and their connection closed directly.
+//
+// Thread Safety: Safe to call from any goroutine; never blocks.
+func (c *Client) closeWithNotice(msg []byte, code int, reason string) {
+	if c.closeNotice == nil {
+		select {
+		case c.send <- msg:
+		default:
+		}
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		return
+	}
+	select {
+	case c.closeNotice <- closeNotice{msg: msg, code: code, reason: reason}:
+	default:
+		// A close is already pending
+	}
+}
+
+// writeCloseNotice writes a close notice and its close frame. Called only from
+// writePump, which owns the connection's write side.
+func (c *Client) writeCloseNotice(notice closeNotice) {
+	if d, ok := c.conn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		d.SetWriteDeadline(time.Now().Add(closeNoticeTimeout))
+	}
+	if err := c.conn.WriteMessage(websocket.TextMessage, notice.msg); err != nil {
+		slog.Warn("Failed to write close notice", "ClientId", c.ID, "error", err)
+		return
+	}
+	if err := c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(notice.code, notice.reason)); err != nil {
+		slog.Warn("Failed to write close frame", "ClientId", c.ID, "error", err)
+	}
+}
+
+// allowParseErrorReply reports whether another parse error reply may be sent,
+// counting it against the current rate limit window if so.
+//
+// Parameters:
+//   - now: The current time
+//
+// Returns:
+//   - true if the reply is within the limit
+func (c *Client) allowParseErrorReply(now time.Time) bool {
+	if now.Sub(c.parseErrorWindowStart) >= parseErrorReplyWindow {
+		c.parseErrorWindowStart = now
+		c.parseErrorReplies = 0
+	}
+	if c.parseErrorReplies >= maxParseErrorReplies {
+		return false
+	}
+	c.parseErrorReplies++
+	return true
+}
+
+// sendError queues an EventError for this client without blocking.
+// If the client's send channel is full the error is dropped and logged.
+//
+// Parameters:
+//   - code: Machine-readable reason for the rejection
+//   - message: Human-readable description for debugging
+//   - event: The rejected event, or empty if it could not be determined
+func (c *Client) sendError(code ErrorCode, message string, event Event) {
+	msg, err := json.Marshal(Message{Event: EventError, Payload: ErrorPayload{Code: code, Message: message, Event: event}})
+	if err != nil {
+		slog.Error("Failed to marshal error payload", "ClientId", c.ID, "error", err)
+		return
+	}
+	c.errorsSent.Add(1)
+	select {
+	case c.send <- msg:
+	default:
+		slog.Warn("Failed to send error to client - channel full", "ClientId", c.ID, "code", code)
+	}
+}
+
+// info returns the client's public identity and profile, as shown in rosters
+// and join notifications.
+func (c *Client) info() ClientInfo {
+	return ClientInfo{
+		ClientId:    c.ID,
+		DisplayName: c.DisplayName,
+		AvatarURL:   c.AvatarURL,
+		Pronouns:    c.Pronouns,
+	}
+}
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"sync"
@@ -85,7 +86,7 @@ func newMockRoom() *MockRoom {
 
 // router processes an incoming Message from a Client and sends it to the handledMessage channel.
 // It acquires a lock to ensure thread-safe access to the handledMessage channel.
-func (m *MockRoom) router(c *Client, data any) {
+func (m *MockRoom) router(ctx context.Context, c *Client, data any) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.handledMessage <- data.(Message)
@@ -125,6 +126,22 @@ func TestClient_readPump(t *testing.T) {
 		close(mockConn.ReadMessages)
 	})
 
+	t.Run("should cancel the connection context on exit", func(t *testing.T) {
+		mockConn := newMockConn()
+		mockRoom := newMockRoom()
+		client := &Client{conn: mockConn, room: mockRoom, ID: "test-user"}
+		client.ctx, client.cancel = context.WithCancel(context.Background())
+
+		go client.readPump()
+		close(mockConn.ReadMessages)
+
+		select {
+		case <-client.context().Done():
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("readPump should cancel the connection context when it exits")
+		}
+	})
+
 	t.Run("should continue on json unmarshal error", func(t *testing.T) {
 		mockConn := newMockConn()
 		mockRoom := newMockRoom()
@@ -149,6 +166,65 @@ func TestClient_readPump(t *testing.T) {
 		close(mockConn.ReadMessages)
 	})
 
+	t.Run("should reply with a parse error for invalid JSON", func(t *testing.T) {
+		mockConn := newMockConn()
+		mockRoom := newMockRoom()
+		client := &Client{conn: mockConn, room: mockRoom, ID: "test-user", send: make(chan []byte, 10)}
+
+		go client.readPump()
+		defer close(mockConn.ReadMessages)
+
+		mockConn.ReadMessages <- []byte("{not_json}")
+
+		select {
+		case msgBytes := <-client.send:
+			var msg struct {
+				Event   Event        `json:"event"`
+				Payload ErrorPayload `json:"payload"`
+			}
+			assert.NoError(t, json.Unmarshal(msgBytes, &msg))
+			assert.Equal(t, EventError, msg.Event)
+			assert.Equal(t, ErrorCodeParse, msg.Payload.Code)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("timed out waiting for parse error reply")
+		}
+	})
+
+	t.Run("should rate limit parse error replies", func(t *testing.T) {
+		mockConn := newMockConn()
+		mockRoom := newMockRoom()
+		client := &Client{conn: mockConn, room: mockRoom, ID: "test-user", send: make(chan []byte, 20)}
+
+		go client.readPump()
+		defer close(mockConn.ReadMessages)
+
+		for i := 0; i < maxParseErrorReplies*2; i++ {
+			mockConn.ReadMessages <- []byte("{not_json}")
+		}
+
+		// A valid message afterwards proves every invalid one was consumed
+		msgBytes, _ := json.Marshal(Message{Event: EventAddChat})
+		mockConn.ReadMessages <- msgBytes
+		select {
+		case <-mockRoom.handledMessage:
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("readPump should have continued after parse errors")
+		}
+
+		assert.Len(t, client.send, maxParseErrorReplies, "Only a limited number of parse errors should be answered")
+	})
+
+	t.Run("parse error limit resets after the window", func(t *testing.T) {
+		client := &Client{}
+		start := time.Now()
+
+		for i := 0; i < maxParseErrorReplies; i++ {
+			assert.True(t, client.allowParseErrorReply(start))
+		}
+		assert.False(t, client.allowParseErrorReply(start.Add(time.Second)))
+		assert.True(t, client.allowParseErrorReply(start.Add(parseErrorReplyWindow)))
+	})
+
 	t.Run("should exit and clean up on read error", func(t *testing.T) {
 		mockConn := newMockConn()
 		mockConn.ReadError = errors.New("network error")
@@ -0,0 +1,666 @@
+// Package session - config.go
+//
+// This file defines the configuration applied to every room created by a Hub.
+// Configuration covers both tunable limits and injected dependencies, so that
+// deployments can customize room behavior without changing handler code.
+//
+// Defaults:
+// DefaultRoomConfig returns a configuration that matches the behavior of a
+// room created with NewRoom, so callers only need to override what they change.
+package session
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/utils/clock"
+	"k8s.io/utils/set"
+)
+
+// RoomConfig holds the settings and dependencies applied to a room on creation.
+type RoomConfig struct {
+	// EventSink receives a copy of every durable event the room broadcasts.
+	// It must not block; wrap slow sinks in an AsyncEventSink.
+	EventSink EventSink
+
+	// DurableEvents are the broadcast events worth persisting, and the only
+	// ones mirrored to EventSink. Ephemeral events such as reactions and ICE
+	// candidates are delivered to clients but never recorded. Nil applies
+	// DefaultDurableEvents; an empty set records nothing.
+	DurableEvents set.Set[Event]
+
+	// Bus shares the room's broadcasts with copies of the room on other hub
+	// instances, so clients connected to different instances can meet; see
+	// bus.go. Nil keeps broadcasts local.
+	Bus RoomBus
+
+	// BusNamespace separates this hub's rooms on Bus from those of hubs
+	// serving other endpoints, such as "zoom" or "chat". Every instance of
+	// the same endpoint must use the same namespace; see bus.go.
+	BusNamespace string
+
+	// ChatStore persists chat messages before they are delivered. It is
+	// called without the room lock and must honor its context; see store.go.
+	// Nil persists nothing.
+	ChatStore ChatStore
+
+	// TranscriptStore persists captions after they are relayed; see store.go.
+	// Nil persists nothing.
+	TranscriptStore TranscriptStore
+
+	// StoreTimeout bounds each ChatStore and TranscriptStore call. A chat
+	// message whose save times out is not delivered. Zero leaves calls
+	// bounded only by the sender's connection and the room's lifetime.
+	StoreTimeout time.Duration
+
+	// Tracer records a span per routed message and child spans around
+	// storage, broadcasts and the event sink; see tracing.go. Nil records
+	// nothing.
+	Tracer Tracer
+
+	// HandlerMetrics records how long each event handler runs while holding
+	// the room lock; see metrics.go. Nil records nothing.
+	HandlerMetrics HandlerMetrics
+
+	// Moderation checks chat content with an external service before it is
+	// delivered; see moderation.go. Nil delivers chat unchecked.
+	Moderation ModerationClient
+
+	// ModerationFailOpen delivers chat unchecked when the Moderation service
+	// returns an error. By default such messages are rejected.
+	ModerationFailOpen bool
+
+	// ConnectionObserver is told when clients connect to and disconnect from
+	// the room. It must not block; see observer.go. Nil ignores them.
+	ConnectionObserver ConnectionObserver
+
+	// OnClose receives a room's final statistics once the room has emptied and
+	// been removed, so they can be persisted. A room that is rejoined before
+	// removal completes is not reported until it empties again. It runs
+	// without the hub or room lock. Nil discards them.
+	OnClose func(RoomStats)
+
+	// Clock drives every room timer. Tests inject a fake clock for determinism.
+	// Timer callbacks must not call back into the clock, since fake clocks run
+	// them synchronously while holding their own lock.
+	Clock clock.WithTickerAndDelayedExecution
+
+	// CandidateBatchWindow coalesces ICE candidates sent to the same target within
+	// the window into a single EventCandidateBatch message. Zero forwards each
+	// candidate individually.
+	CandidateBatchWindow time.Duration
+
+	// ActiveSpeakerInterval is the shortest time between EventActiveSpeakers
+	// broadcasts. Speaking changes reported sooner are coalesced into one
+	// broadcast at the end of the interval. Zero broadcasts every change.
+	ActiveSpeakerInterval time.Duration
+
+	// DisableWaitingGuard turns off the router's waiting room guard, which
+	// answers any event from a waiting client other than the waitingEvents
+	// with ErrorCodeNotAdmitted. Without it, waiting clients are limited by
+	// eventPermissions alone and refused events are dropped silently.
+	DisableWaitingGuard bool
+
+	// GlareDetection tracks unanswered offers per peer pair. When two peers offer
+	// each other at the same time, only the impolite peer's offer is forwarded and
+	// the polite peer receives EventGlareDetected.
+	GlareDetection bool
+
+	// HandlerLog controls how successful handler calls are logged.
+	HandlerLog HandlerLogConfig
+
+	// MaxParticipants caps the number of hosts and participants admitted to the
+	// room. Hosts cannot admit waiting users once the room is full. Zero is unlimited.
+	MaxParticipants int
+
+	// MaxChatHistory is the number of chat messages retained per room.
+	MaxChatHistory int
+
+	// MaxChatHistoryAge evicts chat messages once they are this old, by their
+	// server timestamp, in addition to the MaxChatHistory limit; whichever is
+	// stricter applies. Zero keeps messages regardless of age.
+	MaxChatHistoryAge time.Duration
+
+	// MaxTranscript is the number of captions retained per room.
+	MaxTranscript int
+
+	// ChatRateLimit is the number of chat messages each client may send per
+	// minute. Messages over the limit are rejected with ErrorCodeRateLimited.
+	// Zero is unlimited.
+	ChatRateLimit int
+
+	// MaxRaisedHands caps how many hands may be raised at once. Further raises
+	// are rejected with ErrorCodeHandQueueFull until a hand is lowered. Zero is
+	// unlimited.
+	MaxRaisedHands int
+
+	// EventHistorySize keeps the last this many routed messages per room,
+	// with their sender, time and outcome, for support to inspect through
+	// ServeEventHistory; see replay.go. Zero keeps no history.
+	EventHistorySize int
+
+	// EventHistoryPayloads also records each message's payload in the event
+	// history. Payloads can hold chat text and SDP, so this is off by default.
+	EventHistoryPayloads bool
+
+	// MaxConcurrentQueries bounds how many query events (see queryEvents) a
+	// room handles at once under its read lock. A writer waits for every
+	// reader already holding the lock, so a burst of large history fetches
+	// would otherwise hold up each mutation in the room behind all of them.
+	// Further queries wait for a slot before taking the lock. Zero is
+	// unlimited.
+	MaxConcurrentQueries int
+
+	// IdleTimeout closes a client's connection when it sends no messages for
+	// this long. Zero disables the timeout.
+	IdleTimeout time.Duration
+
+	// WaitingApprovals is the number of distinct hosts that must accept a waiting
+	// client before it is admitted, capped at the number of hosts present. Zero
+	// or one admits on the first host's approval, clearing the request for
+	// every other host.
+	WaitingApprovals int
+
+	// WaitingRequestInterval suppresses repeated EventRequestWaiting messages from
+	// the same waiting client: only the first request, or one sent after the
+	// interval has passed, is forwarded to hosts. Zero forwards every request.
+	WaitingRequestInterval time.Duration
+
+	// DuplicateEventWindow drops a message identical to one the same client
+	// sent this recently, such as a double-clicked raise hand; see dedup.go.
+	// Zero delivers every message.
+	DuplicateEventWindow time.Duration
+
+	// DuplicateEvents are the events DuplicateEventWindow applies to. Nil
+	// applies DefaultDuplicateEvents.
+	DuplicateEvents set.Set[Event]
+
+	// WaitingTimeout disconnects a waiting client that is neither admitted nor
+	// denied within this long, sending it EventWaitingTimeout first. Zero waits forever.
+	WaitingTimeout time.Duration
+
+	// HostClientIds designates the organizers of each scheduled meeting, keyed
+	// by room id. In a room with designated hosts, only those clients become
+	// hosts on joining and everyone else waits for admission, regardless of
+	// join order. In a room without an entry, the first joiner hosts.
+	HostClientIds map[RoomIdType][]ClientIdType
+
+	// WebinarMode admits joiners as spectators who can watch but not speak.
+	// A spectator may ask to speak, and a host may grant or revoke that right.
+	WebinarMode bool
+
+	// ReconnectWindow restores the previous role of an admitted client whose
+	// connection dropped if it reconnects within this long, so a brief network
+	// blip does not send it back to the waiting room. A participant whose
+	// place was taken while it was away waits as usual. Zero disables this.
+	ReconnectWindow time.Duration
+
+	// HostReclaimWindow lets a host who transferred the host role undo the
+	// transfer with EventReclaimHost for this long afterwards. Once it passes,
+	// only a current host can hand the role back. Zero disables undo.
+	HostReclaimWindow time.Duration
+
+	// UniqueDisplayNames disambiguates a joiner whose display name is already in
+	// use in the room by appending a suffix, such as "Alex (2)". Client ids are
+	// unaffected and remain the authoritative identity.
+	UniqueDisplayNames bool
+
+	// SingleHostConnection refuses a second connection from a subject that is
+	// already hosting the room, such as a second browser tab, so one person
+	// cannot make conflicting host decisions from two places. The refused
+	// connection is sent ErrorCodeHostConnected and closed.
+	SingleHostConnection bool
+
+	// WelcomeMessage is sent to every client as it is admitted, and to the
+	// host who creates the room, as EventWelcome. Hosts can replace it with
+	// EventSetWelcome. It is sanitized and cut to 500 characters; empty sends
+	// nothing.
+	WelcomeMessage string
+
+	// CodecPolicy is sent to every client as it is admitted, and to the host
+	// who creates the room, as EventCodecPolicy, so clients can restrict and
+	// order the codecs in their SDP. The zero value sends nothing.
+	CodecPolicy CodecPolicy
+
+	// MuteOnJoin admits participants muted: they are sent EventForceMute on
+	// admission and are not treated as unmuted until they report, with
+	// EventMediaState, that they unmuted. Hosts are not muted.
+	MuteOnJoin bool
+
+	// SilentJoinLeave sets Notify to false on every join and leave
+	// announcement, so clients update their rosters without playing a chime.
+	SilentJoinLeave bool
+
+	// JoinLeaveNotifyLimit sets Notify to false on join and leave announcements
+	// while more than this many clients are admitted, since chimes in a large
+	// room are constant. Zero never suppresses them.
+	JoinLeaveNotifyLimit int
+
+	// HostlessGracePeriod promotes the longest-waiting client to host when the
+	// room has had waiting users but no host for this long, so that a room whose
+	// designated hosts never arrive, or whose hosts all left, can still admit
+	// anyone. Zero disables promotion.
+	HostlessGracePeriod time.Duration
+
+	// Features switches meeting features on or off for the room, such as
+	// disabling chat for a webinar. Nil enables every feature.
+	Features *RoomFeatures
+
+	// AllowedReactions lists the emoji clients may send with EventReaction.
+	// Nil applies DefaultAllowedReactions; an empty set blocks every reaction.
+	AllowedReactions set.Set[string]
+
+	// HiddenEvents excludes roles from send-to-all broadcasts of an event, such
+	// as keeping disconnect notices from waiting users. Nil applies
+	// DefaultHiddenEvents; an empty map hides nothing.
+	HiddenEvents map[Event]set.Set[RoleType]
+
+	// MaxPayloadSizes bounds the encoded size, in bytes, of each event's
+	// payload, so chat and reactions can be held far below the size an SDP
+	// offer needs. Oversized payloads are rejected with
+	// ErrorCodePayloadTooLarge. Events not listed are unbounded. Nil applies
+	// DefaultMaxPayloadSizes; an empty map bounds nothing.
+	MaxPayloadSizes map[Event]int
+
+	// EventPermissions replaces the table of which roles may send each event,
+	// such as one read by LoadPermissionMatrix. Events missing from it are
+	// unknown to the room and rejected. Nil applies DefaultEventPermissions.
+	EventPermissions map[Event]set.Set[RoleType]
+
+	// AllowedEvents scopes a hub to one endpoint's feature set, such as
+	// ChatEndpointEvents. Other events are rejected with ErrorCodeEventNotAllowed.
+	// Nil allows every event.
+	AllowedEvents set.Set[Event]
+}
+
+// RoomFeatures switches individual meeting features on or off. Events for a
+// disabled feature are rejected with ErrorCodeFeatureDisabled.
+type RoomFeatures struct {
+	// ChatEnabled allows sending, deleting and fetching chat messages.
+	ChatEnabled bool `json:"chatEnabled"`
+
+	// ScreenshareEnabled allows requesting and granting screen sharing.
+	ScreenshareEnabled bool `json:"screenshareEnabled"`
+
+	// WaitingRoomEnabled holds joiners for host admission. When disabled,
+	// joiners are admitted directly as participants unless the room is full.
+	WaitingRoomEnabled bool `json:"waitingRoomEnabled"`
+
+	// RemoteControlEnabled lets viewers ask a screen sharer for control of the
+	// shared screen. It has no effect while ScreenshareEnabled is off.
+	RemoteControlEnabled bool `json:"remoteControlEnabled"`
+
+	// ReactionsEnabled allows sending floating emoji reactions.
+	ReactionsEnabled bool `json:"reactionsEnabled"`
+}
+
+// DefaultRoomFeatures returns a RoomFeatures with every feature enabled.
+func DefaultRoomFeatures() RoomFeatures {
+	return RoomFeatures{
+		ChatEnabled:          true,
+		ScreenshareEnabled:   true,
+		WaitingRoomEnabled:   true,
+		RemoteControlEnabled: true,
+		ReactionsEnabled:     true,
+	}
+}
+
+// allows reports whether the event belongs to an enabled feature. Events that
+// are not tied to a feature are always allowed.
+func (f RoomFeatures) allows(event Event) bool {
+	switch event {
+	case EventAddChat, EventDeleteChat, EventDeleteUserChats, EventGetRecentChats, EventGetChatsByRange,
+		EventPinChat, EventUnpinChat:
+		return f.ChatEnabled
+	case EventRequestScreenshare, EventAcceptScreenshare, EventDenyScreenshare, EventStopScreenshare:
+		return f.ScreenshareEnabled
+	case EventRequestRemoteControl, EventGrantRemoteControl, EventDenyRemoteControl:
+		return f.ScreenshareEnabled && f.RemoteControlEnabled
+	case EventReaction:
+		return f.ReactionsEnabled
+	default:
+		return true
+	}
+}
+
+// DefaultAllowedReactions returns the standard reaction set.
+func DefaultAllowedReactions() set.Set[string] {
+	return set.New("👍", "❤️", "😂", "😮", "😢", "👏")
+}
+
+// DefaultMaxPayloadSizes returns the standard per-event payload bounds. Each
+// leaves room for the sender's client info around the event's own content,
+// such as a chat message of up to 1000 characters. WebRTC offers and answers
+// carry SDP of unpredictable size and are left unbounded.
+func DefaultMaxPayloadSizes() map[Event]int {
+	return map[Event]int{
+		EventAddChat:         8 << 10,
+		EventReaction:        1 << 10,
+		EventRaiseHand:       1 << 10,
+		EventLowerHand:       1 << 10,
+		EventCandidate:       4 << 10,
+		EventCaption:         8 << 10,
+		EventConnectionStats: 1 << 10,
+		EventSetWelcome:      8 << 10,
+	}
+}
+
+// HandlerLogConfig controls the per-call handler log line, which is emitted for
+// every chat message and ICE candidate and can flood logs in busy rooms.
+// Failed handler calls are always logged at Error level regardless of these settings.
+type HandlerLogConfig struct {
+	// Logger receives handler log lines, and the hub's summary of each room it
+	// removes. Nil uses slog.Default().
+	Logger *slog.Logger
+
+	// SuccessLevel is the level for successful handler calls. The zero value is Info.
+	SuccessLevel slog.Level
+
+	// SuccessSampleRate logs one in every N successful handler calls per room.
+	// Zero or one logs every call.
+	SuccessSampleRate int
+}
+
+// logger returns the configured logger, falling back to the process default.
+func (c HandlerLogConfig) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+// HubConfig holds settings that apply to a Hub as a whole, together with the
+// RoomConfig applied to every room the hub creates.
+type HubConfig struct {
+	// Room is applied to every room the hub creates.
+	Room RoomConfig
+
+	// EnableCompression negotiates per-message deflate with clients that support
+	// it. Compression trades server CPU for bandwidth on large messages such as
+	// room state, chat history and candidate batches.
+	EnableCompression bool
+
+	// MaxRooms caps how many rooms the hub holds at once, bounding its memory.
+	// Connections that would create another room are refused with 503 Service
+	// Unavailable; rooms that already exist can still be joined. Zero is
+	// unlimited.
+	MaxRooms int
+
+	// MaxRoomsPerUser caps how many rooms on the hub one authenticated subject
+	// may be connected to at once. Connections to further rooms are refused
+	// with 429 Too Many Requests. Zero is unlimited.
+	MaxRoomsPerUser int
+
+	// ConnectRatePerIP is the number of connection attempts each source IP may
+	// make per minute, after an initial burst of ConnectBurstPerIP. Attempts over
+	// the limit are refused with 429 Too Many Requests before the upgrade. The
+	// source IP is gin's Context.ClientIP, which honors X-Forwarded-For only
+	// from proxies trusted with Engine.SetTrustedProxies. Zero is unlimited.
+	ConnectRatePerIP int
+
+	// ConnectBurstPerIP is the number of connection attempts a source IP may make
+	// at once before ConnectRatePerIP applies. Values below one allow one.
+	ConnectBurstPerIP int
+
+	// ReconnectBackoff is how long a subject must wait after a failed
+	// connection attempt, or a source IP after ReconnectBackoffIPFailures of
+	// them, before trying again. Each further consecutive failure doubles the
+	// wait, up to ReconnectBackoffMax. Attempts during the wait are refused
+	// with 429 Too Many Requests and a Retry-After header. Zero disables the
+	// backoff; see backoff.go.
+	ReconnectBackoff time.Duration
+
+	// ReconnectBackoffMax caps the wait imposed by ReconnectBackoff. Values
+	// below ReconnectBackoff use ReconnectBackoff.
+	ReconnectBackoffMax time.Duration
+
+	// ReconnectBackoffIPFailures is how many consecutive failed attempts a
+	// source IP may make before it backs off. Users behind one NAT share an
+	// IP, so this is well above the single failure a subject is allowed.
+	// Values below one use one.
+	ReconnectBackoffIPFailures int
+
+	// ReconnectStableAfter is how long a connection must stay open before it
+	// clears the failure counts of its IP and subject, so a client admitted
+	// and dropped straight away keeps backing off. Zero clears them on
+	// admission.
+	ReconnectStableAfter time.Duration
+
+	// PresenceVisible reports whether viewer may see which rooms subject is in
+	// through ServePresence, such as when viewer follows subject. Users may
+	// always see their own presence. Nil hides everyone else's; PublicPresence
+	// shows it to everyone.
+	PresenceVisible func(viewer, subject ClientIdType) bool
+
+	// FallbackDisplayName names clients whose token carries neither a name nor
+	// an email, in place of their opaque subject. Nil uses GuestDisplayName.
+	FallbackDisplayName func(subject ClientIdType) DisplayNameType
+
+	// MaxDisplayNameLength is the most characters kept from a display name;
+	// longer names are cut. Values below one use DefaultMaxDisplayNameLength.
+	MaxDisplayNameLength int
+
+	// Sessions links connections that pass the same "session" query parameter
+	// with the same token subject; see linked.go. Share one linker between
+	// the hubs serving a meeting's endpoints. Nil links nothing.
+	Sessions *SessionLinker
+
+	// EndLinkedSessions closes a client's linked connections on every hub
+	// sharing Sessions when the client leaves intentionally or is kicked.
+	EndLinkedSessions bool
+}
+
+// DefaultMaxDisplayNameLength is the display-name cap used when
+// HubConfig.MaxDisplayNameLength is unset.
+const DefaultMaxDisplayNameLength = 64
+
+// DefaultHubConfig returns the configuration used by NewHub.
+func DefaultHubConfig() HubConfig {
+	return HubConfig{
+		Room:                       DefaultRoomConfig(),
+		FallbackDisplayName:        GuestDisplayName,
+		MaxDisplayNameLength:       DefaultMaxDisplayNameLength,
+		ReconnectBackoffIPFailures: 20,
+		ReconnectStableAfter:       30 * time.Second,
+	}
+}
+
+// GuestDisplayName names an anonymous client "Guest" followed by four digits
+// derived from its subject, so the same user keeps the same name when they
+// reconnect. Different subjects may share a name.
+func GuestDisplayName(subject ClientIdType) DisplayNameType {
+	h := fnv.New32a()
+	h.Write([]byte(subject))
+	return DisplayNameType(fmt.Sprintf("Guest %04d", h.Sum32()%10000))
+}
+
+// LoadHubConfigFromEnv builds a HubConfig from environment variables, loading
+// room settings with LoadRoomConfigFromEnv.
+//
+// Environment Variables:
+//   - WS_COMPRESSION: "true" to negotiate per-message deflate
+//   - END_LINKED_SESSIONS: "true" to close a user's linked connections when one leaves or is kicked
+//   - MAX_ROOMS: Rooms the hub holds at once (0 = unlimited)
+//   - MAX_ROOMS_PER_USER: Rooms one subject may be connected to at once (0 = unlimited)
+//   - CONNECT_RATE_PER_IP: Connection attempts per source IP per minute (0 = unlimited)
+//   - CONNECT_BURST_PER_IP: Connection attempts per source IP allowed at once
+//   - RECONNECT_BACKOFF_SECONDS: Wait after a failed connection attempt (0 = disabled)
+//   - RECONNECT_BACKOFF_MAX_SECONDS: Longest wait after repeated failed attempts
+//   - RECONNECT_BACKOFF_IP_FAILURES: Failed attempts a source IP may make before it backs off
+//   - RECONNECT_STABLE_SECONDS: Seconds a connection must stay open to clear its failures (0 = on admission)
+//   - MAX_DISPLAY_NAME_LENGTH: Characters kept from a display name
+//   - PUBLIC_PRESENCE: "true" to let any authenticated user look up anyone's presence
+//   - Everything read by LoadRoomConfigFromEnv
+//
+// Returns:
+//   - HubConfig with environment overrides applied
+func LoadHubConfigFromEnv() HubConfig {
+	config := DefaultHubConfig()
+	config.Room = LoadRoomConfigFromEnv()
+	config.EnableCompression = boolFromEnv("WS_COMPRESSION", config.EnableCompression)
+	config.EndLinkedSessions = boolFromEnv("END_LINKED_SESSIONS", config.EndLinkedSessions)
+	config.MaxRooms = intFromEnv("MAX_ROOMS", config.MaxRooms, 0)
+	config.MaxRoomsPerUser = intFromEnv("MAX_ROOMS_PER_USER", config.MaxRoomsPerUser, 0)
+	config.ConnectRatePerIP = intFromEnv("CONNECT_RATE_PER_IP", config.ConnectRatePerIP, 0)
+	config.ConnectBurstPerIP = intFromEnv("CONNECT_BURST_PER_IP", config.ConnectBurstPerIP, 0)
+	config.ReconnectBackoff = time.Duration(intFromEnv("RECONNECT_BACKOFF_SECONDS", int(config.ReconnectBackoff/time.Second), 0)) * time.Second
+	config.ReconnectBackoffMax = time.Duration(intFromEnv("RECONNECT_BACKOFF_MAX_SECONDS", int(config.ReconnectBackoffMax/time.Second), 0)) * time.Second
+	config.ReconnectBackoffIPFailures = intFromEnv("RECONNECT_BACKOFF_IP_FAILURES", config.ReconnectBackoffIPFailures, 1)
+	config.ReconnectStableAfter = time.Duration(intFromEnv("RECONNECT_STABLE_SECONDS", int(config.ReconnectStableAfter/time.Second), 0)) * time.Second
+	config.MaxDisplayNameLength = intFromEnv("MAX_DISPLAY_NAME_LENGTH", config.MaxDisplayNameLength, 1)
+	if boolFromEnv("PUBLIC_PRESENCE", false) {
+		config.PresenceVisible = PublicPresence
+	}
+	return config
+}
+
+// DefaultRoomConfig returns the configuration used by NewRoom and NewHub.
+func DefaultRoomConfig() RoomConfig {
+	return RoomConfig{
+		EventSink:          NoopEventSink{},
+		ChatStore:          NoopChatStore{},
+		TranscriptStore:    NoopTranscriptStore{},
+		Tracer:             NoopTracer{},
+		HandlerMetrics:     NoopHandlerMetrics{},
+		ConnectionObserver: NoopConnectionObserver{},
+		Clock:              clock.RealClock{},
+		MaxChatHistory:     100,
+		MaxTranscript:      200,
+
+		MaxConcurrentQueries: 4,
+
+		WaitingRequestInterval: 10 * time.Second,
+		DuplicateEventWindow:   500 * time.Millisecond,
+		ActiveSpeakerInterval:  250 * time.Millisecond,
+		StoreTimeout:           5 * time.Second,
+	}
+}
+
+// LoadRoomConfigFromEnv builds a RoomConfig from environment variables,
+// starting from DefaultRoomConfig. Missing variables keep their defaults;
+// malformed or out-of-range values are logged and also keep their defaults,
+// so a typo in deployment configuration never prevents the server starting.
+//
+// Environment Variables:
+//   - MAX_PARTICIPANTS: Maximum hosts and participants per room (0 = unlimited)
+//   - MAX_CHAT_HISTORY: Chat messages retained per room (must be positive)
+//   - MAX_CHAT_HISTORY_AGE_SECONDS: Seconds a chat message is retained for (0 = no age limit)
+//   - MAX_TRANSCRIPT: Captions retained per room (must be positive)
+//   - CHAT_RATE_LIMIT: Chat messages per client per minute (0 = unlimited)
+//   - MAX_RAISED_HANDS: Hands that may be raised at once per room (0 = unlimited)
+//   - MAX_CONCURRENT_QUERIES: Query events handled at once per room (0 = unlimited)
+//   - EVENT_HISTORY_SIZE: Routed messages kept per room for support (0 = none)
+//   - EVENT_HISTORY_PAYLOADS: "true" to include payloads in the event history
+//   - IDLE_TIMEOUT_SECONDS: Seconds of client inactivity before disconnect (0 = disabled)
+//   - WAITING_TIMEOUT_SECONDS: Seconds a client may wait for admission (0 = disabled)
+//   - WAITING_REQUEST_INTERVAL_SECONDS: Minimum seconds between forwarded waiting requests (0 = no deduplication)
+//   - DUPLICATE_EVENT_WINDOW_MS: Window in which repeated identical requests are dropped (0 = keep all)
+//   - CANDIDATE_BATCH_WINDOW_MS: ICE candidate batching window (0 = disabled)
+//   - ACTIVE_SPEAKER_INTERVAL_MS: Shortest time between active speaker broadcasts (0 = every change)
+//   - DISABLE_WAITING_GUARD: "true" to limit waiting clients by role permissions alone
+//   - GLARE_DETECTION: "true" to resolve simultaneous WebRTC offers
+//   - UNIQUE_DISPLAY_NAMES: "true" to suffix duplicate display names
+//   - SINGLE_HOST_CONNECTION: "true" to refuse a host's second connection to a room
+//   - WELCOME_MESSAGE: Message sent to each client as it is admitted (empty = none)
+//   - AUDIO_CODECS: Comma-separated audio codecs the room allows, most preferred first (empty = any)
+//   - VIDEO_CODECS: Comma-separated video codecs the room allows, most preferred first (empty = any)
+//   - MUTE_ON_JOIN: "true" to admit participants muted
+//   - SILENT_JOIN_LEAVE: "true" to ask clients not to chime on joins and leaves
+//   - JOIN_LEAVE_NOTIFY_LIMIT: Admitted clients above which joins and leaves are silent (0 = never)
+//   - HOSTLESS_GRACE_SECONDS: Seconds a room may have waiting users but no host before one is promoted (0 = disabled)
+//   - HOST_RECLAIM_WINDOW_SECONDS: Seconds a host may undo a host transfer for (0 = disabled)
+//   - RECONNECT_WINDOW_SECONDS: Seconds a dropped client may reconnect in and keep its role (0 = disabled)
+//   - STORE_TIMEOUT_SECONDS: Seconds each chat or transcript store call may take (0 = unbounded)
+//
+// Returns:
+//   - RoomConfig with environment overrides applied
+func LoadRoomConfigFromEnv() RoomConfig {
+	config := DefaultRoomConfig()
+	config.MaxParticipants = intFromEnv("MAX_PARTICIPANTS", config.MaxParticipants, 0)
+	config.MaxChatHistory = intFromEnv("MAX_CHAT_HISTORY", config.MaxChatHistory, 1)
+	config.MaxChatHistoryAge = time.Duration(intFromEnv("MAX_CHAT_HISTORY_AGE_SECONDS", int(config.MaxChatHistoryAge/time.Second), 0)) * time.Second
+	config.MaxTranscript = intFromEnv("MAX_TRANSCRIPT", config.MaxTranscript, 1)
+	config.ChatRateLimit = intFromEnv("CHAT_RATE_LIMIT", config.ChatRateLimit, 0)
+	config.MaxRaisedHands = intFromEnv("MAX_RAISED_HANDS", config.MaxRaisedHands, 0)
+	config.MaxConcurrentQueries = intFromEnv("MAX_CONCURRENT_QUERIES", config.MaxConcurrentQueries, 0)
+	config.EventHistorySize = intFromEnv("EVENT_HISTORY_SIZE", config.EventHistorySize, 0)
+	config.EventHistoryPayloads = boolFromEnv("EVENT_HISTORY_PAYLOADS", config.EventHistoryPayloads)
+	config.IdleTimeout = time.Duration(intFromEnv("IDLE_TIMEOUT_SECONDS", int(config.IdleTimeout/time.Second), 0)) * time.Second
+	config.WaitingTimeout = time.Duration(intFromEnv("WAITING_TIMEOUT_SECONDS", int(config.WaitingTimeout/time.Second), 0)) * time.Second
+	config.WaitingRequestInterval = time.Duration(intFromEnv("WAITING_REQUEST_INTERVAL_SECONDS", int(config.WaitingRequestInterval/time.Second), 0)) * time.Second
+	config.DuplicateEventWindow = time.Duration(intFromEnv("DUPLICATE_EVENT_WINDOW_MS", int(config.DuplicateEventWindow/time.Millisecond), 0)) * time.Millisecond
+	config.CandidateBatchWindow = time.Duration(intFromEnv("CANDIDATE_BATCH_WINDOW_MS", int(config.CandidateBatchWindow/time.Millisecond), 0)) * time.Millisecond
+	config.ActiveSpeakerInterval = time.Duration(intFromEnv("ACTIVE_SPEAKER_INTERVAL_MS", int(config.ActiveSpeakerInterval/time.Millisecond), 0)) * time.Millisecond
+	config.DisableWaitingGuard = boolFromEnv("DISABLE_WAITING_GUARD", config.DisableWaitingGuard)
+	config.GlareDetection = boolFromEnv("GLARE_DETECTION", config.GlareDetection)
+	config.UniqueDisplayNames = boolFromEnv("UNIQUE_DISPLAY_NAMES", config.UniqueDisplayNames)
+	config.SingleHostConnection = boolFromEnv("SINGLE_HOST_CONNECTION", config.SingleHostConnection)
+	if welcome, ok := os.LookupEnv("WELCOME_MESSAGE"); ok {
+		config.WelcomeMessage = welcome
+	}
+	config.CodecPolicy.Audio = listFromEnv("AUDIO_CODECS", config.CodecPolicy.Audio)
+	config.CodecPolicy.Video = listFromEnv("VIDEO_CODECS", config.CodecPolicy.Video)
+	config.MuteOnJoin = boolFromEnv("MUTE_ON_JOIN", config.MuteOnJoin)
+	config.SilentJoinLeave = boolFromEnv("SILENT_JOIN_LEAVE", config.SilentJoinLeave)
+	config.JoinLeaveNotifyLimit = intFromEnv("JOIN_LEAVE_NOTIFY_LIMIT", config.JoinLeaveNotifyLimit, 0)
+	config.HostReclaimWindow = time.Duration(intFromEnv("HOST_RECLAIM_WINDOW_SECONDS", int(config.HostReclaimWindow/time.Second), 0)) * time.Second
+	config.ReconnectWindow = time.Duration(intFromEnv("RECONNECT_WINDOW_SECONDS", int(config.ReconnectWindow/time.Second), 0)) * time.Second
+	config.StoreTimeout = time.Duration(intFromEnv("STORE_TIMEOUT_SECONDS", int(config.StoreTimeout/time.Second), 0)) * time.Second
+	config.HostlessGracePeriod = time.Duration(intFromEnv("HOSTLESS_GRACE_SECONDS", int(config.HostlessGracePeriod/time.Second), 0)) * time.Second
+	return config
+}
+
+// intFromEnv reads an integer environment variable that must be at least min.
+// Unset variables return the default silently; invalid ones log a warning.
+func intFromEnv(name string, def, min int) int {
+	raw, ok := os.LookupEnv(name)
+	if !ok || strings.TrimSpace(raw) == "" {
+		return def
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		slog.Warn("Ignoring malformed integer environment variable", "name", name, "value", raw, "default", def)
+		return def
+	}
+	if value < min {
+		slog.Warn("Ignoring out-of-range environment variable", "name", name, "value", value, "min", min, "default", def)
+		return def
+	}
+	return value
+}
+
+// listFromEnv reads a comma-separated environment variable, trimming each
+// entry and dropping empty ones. Unset or blank variables return the default.
+func listFromEnv(name string, def []string) []string {
+	raw, ok := os.LookupEnv(name)
+	if !ok || strings.TrimSpace(raw) == "" {
+		return def
+	}
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// boolFromEnv reads a boolean environment variable.
+// Unset variables return the default silently; invalid ones log a warning.
+func boolFromEnv(name string, def bool) bool {
+	raw, ok := os.LookupEnv(name)
+	if !ok || strings.TrimSpace(raw) == "" {
+		return def
+	}
+	value, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		slog.Warn("Ignoring malformed boolean environment variable", "name", name, "value", raw, "default", def)
+		return def
+	}
+	return value
+}
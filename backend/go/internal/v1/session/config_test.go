@@ -0,0 +1,78 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLoadRoomConfigFromEnv tests parsing of room limits from the environment
+func TestLoadRoomConfigFromEnv(t *testing.T) {
+	t.Run("should parse valid values", func(t *testing.T) {
+		t.Setenv("MAX_PARTICIPANTS", "25")
+		t.Setenv("MAX_CHAT_HISTORY", "500")
+		t.Setenv("MAX_CHAT_HISTORY_AGE_SECONDS", "3600")
+		t.Setenv("CHAT_RATE_LIMIT", "30")
+		t.Setenv("IDLE_TIMEOUT_SECONDS", "300")
+		t.Setenv("CANDIDATE_BATCH_WINDOW_MS", "20")
+		t.Setenv("GLARE_DETECTION", "true")
+		t.Setenv("DISABLE_WAITING_GUARD", "true")
+		t.Setenv("HOSTLESS_GRACE_SECONDS", "120")
+		t.Setenv("RECONNECT_WINDOW_SECONDS", "45")
+		t.Setenv("MAX_RAISED_HANDS", "10")
+		t.Setenv("MAX_CONCURRENT_QUERIES", "2")
+		t.Setenv("EVENT_HISTORY_SIZE", "200")
+		t.Setenv("EVENT_HISTORY_PAYLOADS", "true")
+		t.Setenv("AUDIO_CODECS", "opus")
+		t.Setenv("VIDEO_CODECS", " VP8, ,H264 ")
+
+		config := LoadRoomConfigFromEnv()
+
+		assert.Equal(t, 25, config.MaxParticipants)
+		assert.Equal(t, 500, config.MaxChatHistory)
+		assert.Equal(t, time.Hour, config.MaxChatHistoryAge)
+		assert.Equal(t, 30, config.ChatRateLimit)
+		assert.Equal(t, 5*time.Minute, config.IdleTimeout)
+		assert.Equal(t, 20*time.Millisecond, config.CandidateBatchWindow)
+		assert.True(t, config.GlareDetection)
+		assert.True(t, config.DisableWaitingGuard)
+		assert.Equal(t, 2*time.Minute, config.HostlessGracePeriod)
+		assert.Equal(t, 45*time.Second, config.ReconnectWindow)
+		assert.Equal(t, 10, config.MaxRaisedHands)
+		assert.Equal(t, 2, config.MaxConcurrentQueries)
+		assert.Equal(t, 200, config.EventHistorySize)
+		assert.True(t, config.EventHistoryPayloads)
+		assert.Equal(t, CodecPolicy{Audio: []string{"opus"}, Video: []string{"VP8", "H264"}}, config.CodecPolicy)
+	})
+
+	t.Run("should use defaults when values are missing", func(t *testing.T) {
+		config := LoadRoomConfigFromEnv()
+		defaults := DefaultRoomConfig()
+
+		assert.Equal(t, defaults.MaxParticipants, config.MaxParticipants)
+		assert.Equal(t, defaults.MaxChatHistory, config.MaxChatHistory)
+		assert.Equal(t, defaults.ChatRateLimit, config.ChatRateLimit)
+		assert.Equal(t, defaults.IdleTimeout, config.IdleTimeout)
+		assert.False(t, config.GlareDetection)
+		assert.True(t, config.CodecPolicy.IsZero())
+		assert.Zero(t, config.EventHistorySize)
+	})
+
+	t.Run("should fall back to defaults for invalid values", func(t *testing.T) {
+		t.Setenv("MAX_PARTICIPANTS", "lots")
+		t.Setenv("MAX_CHAT_HISTORY", "0")
+		t.Setenv("CHAT_RATE_LIMIT", "-5")
+		t.Setenv("IDLE_TIMEOUT_SECONDS", "1.5")
+		t.Setenv("GLARE_DETECTION", "sometimes")
+
+		config := LoadRoomConfigFromEnv()
+		defaults := DefaultRoomConfig()
+
+		assert.Equal(t, defaults.MaxParticipants, config.MaxParticipants)
+		assert.Equal(t, defaults.MaxChatHistory, config.MaxChatHistory)
+		assert.Equal(t, defaults.ChatRateLimit, config.ChatRateLimit)
+		assert.Equal(t, defaults.IdleTimeout, config.IdleTimeout)
+		assert.False(t, config.GlareDetection)
+	})
+}
@@ -0,0 +1,129 @@
+// Package session - dedup.go
+//
+// This file implements duplicate event suppression: a double-clicked raise
+// hand or a repeated screenshare request arriving within
+// RoomConfig.DuplicateEventWindow of an identical one is dropped before it
+// reaches its handler, so it changes nothing and broadcasts nothing.
+//
+// Identity:
+// Two messages are duplicates when they come from the same client with the
+// same event and name the same target client. Other payload fields are not
+// compared, which is why only events listed in DuplicateEvents, whose repeats
+// are never meaningful, are suppressed. Chat, reactions and signaling are not.
+//
+// Rejections:
+// A message its handler answers with an error, such as a raise hand refused
+// because the queue is full, is forgotten, so retrying is never suppressed.
+//
+// Memory:
+// Each client remembers its recentEventCapacity most recent (event, target)
+// pairs, evicting the least recently seen.
+package session
+
+import (
+	"slices"
+	"time"
+
+	"k8s.io/utils/set"
+)
+
+// recentEventCapacity is how many distinct (event, target) pairs each client
+// remembers for duplicate suppression.
+const recentEventCapacity = 8
+
+// DefaultDuplicateEvents returns the events whose rapid repeats are suppressed:
+// requests and host decisions that a double click would otherwise repeat.
+func DefaultDuplicateEvents() set.Set[Event] {
+	return set.New(
+		EventRaiseHand, EventLowerHand,
+		EventRequestScreenshare, EventAcceptScreenshare, EventDenyScreenshare,
+		EventRequestSpeak, EventGrantSpeak, EventRevokeSpeak,
+		EventAcceptWaiting, EventDenyWaiting,
+	)
+}
+
+// recentEventKey identifies a message for duplicate suppression.
+type recentEventKey struct {
+	event  Event
+	target ClientIdType
+}
+
+// recentEvent is a message a client sent recently.
+type recentEvent struct {
+	key recentEventKey
+	at  time.Time
+}
+
+// recentEvents is a small LRU of the messages a client sent recently, most
+// recent first.
+type recentEvents []recentEvent
+
+// seen records a message sent at now and reports whether an identical one was
+// sent less than window earlier. A suppressed duplicate does not extend the
+// window, so a client repeating a request is heard again once it passes.
+//
+// Recording a message forgets other events for the same target, so undoing
+// an action, such as lowering a hand, lets the client redo it at once.
+func (e *recentEvents) seen(key recentEventKey, now time.Time, window time.Duration) bool {
+	for _, recent := range *e {
+		if recent.key == key && now.Sub(recent.at) < window {
+			return true
+		}
+	}
+	kept := slices.DeleteFunc(*e, func(recent recentEvent) bool { return recent.key.target == key.target })
+	*e = append(recentEvents{{key: key, at: now}}, kept...)
+	if len(*e) > recentEventCapacity {
+		*e = (*e)[:recentEventCapacity]
+	}
+	return false
+}
+
+// forget removes a recorded message so an identical one is not suppressed.
+func (e *recentEvents) forget(key recentEventKey) {
+	*e = slices.DeleteFunc(*e, func(recent recentEvent) bool { return recent.key == key })
+}
+
+// checkDuplicateEvent reports whether a message repeats one the client sent
+// within the room's DuplicateEventWindow, recording it if not.
+//
+// A message the handler rejects should not suppress the client's retry, so
+// the caller runs the returned forget function once the handler returns; it
+// drops the record if the handler sent the client an error.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's write lock is already held.
+//
+// Parameters:
+//   - client: The sender
+//   - msg: The message, whose payload has already been type checked
+//
+// Returns:
+//   - bool: true if the message should be dropped
+//   - func(): Call after handling a message that was not dropped
+func (r *Room) checkDuplicateEvent(client *Client, msg Message) (bool, func()) {
+	window := r.config.DuplicateEventWindow
+	if window <= 0 {
+		return false, func() {}
+	}
+	events := r.config.DuplicateEvents
+	if events == nil {
+		events = DefaultDuplicateEvents()
+	}
+	if !events.Has(msg.Event) || queryEvents.Has(msg.Event) {
+		return false, func() {}
+	}
+
+	// Every suppressible payload embeds ClientInfo naming its target
+	target, _ := assertPayload[ClientInfo](msg.Payload)
+	key := recentEventKey{event: msg.Event, target: target.ClientId}
+	if client.recentEvents.seen(key, r.config.Clock.Now(), window) {
+		return true, nil
+	}
+
+	errorsBefore := client.errorsSent.Load()
+	return false, func() {
+		if client.errorsSent.Load() != errorsBefore {
+			client.recentEvents.forget(key)
+		}
+	}
+}
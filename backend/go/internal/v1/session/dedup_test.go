@@ -0,0 +1,153 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	testclock "k8s.io/utils/clock/testing"
+)
+
+func TestDuplicateEventSuppression(t *testing.T) {
+	setup := func() (*Room, *testclock.FakeClock, *Client, *Client) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		room := NewTestRoom("test-room", nil)
+		room.config.Clock = fakeClock
+		room.config.DuplicateEventWindow = 500 * time.Millisecond
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+		for len(alice.send) > 0 {
+			<-alice.send
+		}
+		return room, fakeClock, alice, bob
+	}
+
+	raise := func(room *Room, c *Client) {
+		room.router(context.Background(), c, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(c.info())})
+	}
+	lower := func(room *Room, c *Client) {
+		room.router(context.Background(), c, Message{Event: EventLowerHand, Payload: LowerHandPayload(c.info())})
+	}
+
+	// received drains a client's messages and returns their events
+	received := func(t *testing.T, c *Client) []Event {
+		t.Helper()
+		var events []Event
+		for len(c.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			events = append(events, msg.Event)
+		}
+		return events
+	}
+
+	t.Run("a double raise hand changes state and broadcasts once", func(t *testing.T) {
+		room, _, alice, bob := setup()
+
+		raise(room, bob)
+		raise(room, bob)
+
+		assert.Equal(t, []Event{EventRaiseHand}, received(t, alice))
+		assert.Equal(t, 1, room.handDrawOrderQueue.Len())
+	})
+
+	t.Run("repeats after the window are delivered", func(t *testing.T) {
+		room, fakeClock, alice, bob := setup()
+
+		raise(room, bob)
+		fakeClock.Step(500 * time.Millisecond)
+		raise(room, bob)
+
+		assert.Equal(t, []Event{EventRaiseHand, EventRaiseHand}, received(t, alice))
+	})
+
+	t.Run("suppressed repeats do not extend the window", func(t *testing.T) {
+		room, fakeClock, alice, bob := setup()
+
+		raise(room, bob)
+		fakeClock.Step(300 * time.Millisecond)
+		raise(room, bob)
+		fakeClock.Step(300 * time.Millisecond)
+		raise(room, bob)
+
+		assert.Equal(t, []Event{EventRaiseHand, EventRaiseHand}, received(t, alice))
+	})
+
+	t.Run("undoing an action allows redoing it at once", func(t *testing.T) {
+		room, _, alice, bob := setup()
+
+		raise(room, bob)
+		lower(room, bob)
+		raise(room, bob)
+
+		assert.Equal(t, []Event{EventRaiseHand, EventLowerHand, EventRaiseHand}, received(t, alice))
+		assert.Contains(t, room.raisingHand, bob.ID)
+	})
+
+	t.Run("different clients and targets are independent", func(t *testing.T) {
+		room, _, alice, bob := setup()
+
+		raise(room, bob)
+		raise(room, alice)
+
+		assert.Equal(t, []Event{EventRaiseHand, EventRaiseHand}, received(t, alice))
+	})
+
+	t.Run("retrying a rejected message is not suppressed", func(t *testing.T) {
+		room, _, alice, bob := setup()
+		room.config.MaxRaisedHands = 1
+		raise(room, alice)
+		received(t, bob)
+
+		raise(room, bob)
+		raise(room, bob)
+
+		var codes []ErrorCode
+		for len(bob.send) > 0 {
+			var msg struct {
+				Event   Event        `json:"event"`
+				Payload ErrorPayload `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(<-bob.send, &msg))
+			codes = append(codes, msg.Payload.Code)
+		}
+		assert.Equal(t, []ErrorCode{ErrorCodeHandQueueFull, ErrorCodeHandQueueFull}, codes, "Both attempts should reach the handler")
+	})
+
+	t.Run("events outside the suppressed set are never dropped", func(t *testing.T) {
+		room, _, alice, bob := setup()
+
+		for range 2 {
+			room.router(context.Background(), bob, Message{Event: EventReaction, Payload: ReactionPayload{Emoji: "👍"}})
+		}
+
+		assert.Equal(t, []Event{EventReaction, EventReaction}, received(t, alice))
+	})
+
+	t.Run("a zero window delivers everything", func(t *testing.T) {
+		room, _, alice, bob := setup()
+		room.config.DuplicateEventWindow = 0
+
+		raise(room, bob)
+		raise(room, bob)
+
+		assert.Equal(t, []Event{EventRaiseHand, EventRaiseHand}, received(t, alice))
+	})
+}
+
+func TestRecentEvents(t *testing.T) {
+	now := time.Now()
+	var recent recentEvents
+
+	for i := range recentEventCapacity + 2 {
+		assert.False(t, recent.seen(recentEventKey{event: EventRaiseHand, target: ClientIdType(rune('a' + i))}, now, time.Second))
+	}
+	assert.Len(t, recent, recentEventCapacity, "The oldest entries are evicted")
+	assert.True(t, recent.seen(recentEventKey{event: EventRaiseHand, target: ClientIdType(rune('a' + recentEventCapacity + 1))}, now, time.Second))
+	assert.False(t, recent.seen(recentEventKey{event: EventRaiseHand, target: "a"}, now, time.Second), "Evicted entries are forgotten")
+}
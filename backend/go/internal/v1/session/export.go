@@ -0,0 +1,154 @@
+// Package session - export.go
+//
+// This file implements the REST endpoint for exporting a room's chat history,
+// used for compliance records and user downloads.
+//
+// Access Control:
+// Exports contain every message in the room, so they are restricted to
+// authenticated clients who are currently hosts of the room.
+//
+// Endpoints:
+// A meeting's feature endpoints each run their own hub, so its chat may be
+// spread across rooms with the same id on several hubs, such as /ws/hub and
+// /ws/chat. The export merges them, and hosting the room on any of the hubs
+// is enough to export all of it.
+//
+// Formats:
+//   - JSON (default): An array of ChatInfo objects, oldest first
+//   - CSV (?format=csv): A header row followed by timestamp,clientId,displayName,content
+//     rows, with content containing commas, quotes or newlines escaped per RFC 4180
+//
+// Data Source:
+// Chat history is read from the room's in-memory history, which holds the most
+// recent RoomConfig.MaxChatHistory messages.
+package session
+
+import (
+	"cmp"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chatExportHeader is the header row of CSV chat exports.
+var chatExportHeader = []string{"timestamp", "clientId", "displayName", "content"}
+
+// ServeChatExport returns a handler that gives a room's full chat history, from
+// every one of the given hubs, to one of its hosts. The token may be supplied
+// as a Bearer Authorization header or, to match ServeWs, as the token query
+// parameter. The first hub authenticates the request.
+//
+// Parameters:
+//   - hubs: The hubs serving the meeting's endpoints; at least one
+//
+// Responses:
+//   - 401 Unauthorized if the token is missing or invalid.
+//   - 404 Not Found if the room does not exist on any hub.
+//   - 403 Forbidden if the caller is not a host of the room on any hub.
+//   - 400 Bad Request if the format is not json or csv.
+//   - 200 OK with the chat history, oldest first, otherwise.
+func ServeChatExport(hubs ...*Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := requestToken(c)
+		if tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token not provided"})
+			return
+		}
+
+		claims, err := hubs[0].validator.ValidateToken(tokenString)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "csv" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+			return
+		}
+
+		roomId := RoomIdType(c.Param("roomId"))
+		subject := ClientIdType(claims.Subject)
+		var found, isHost bool
+		chats := []ChatInfo{}
+		for _, h := range hubs {
+			h.mu.Lock()
+			room, ok := h.rooms[roomId]
+			h.mu.Unlock()
+			if !ok {
+				continue
+			}
+			found = true
+
+			room.mu.RLock()
+			if _, ok := room.hosts[subject]; ok {
+				isHost = true
+			}
+			chats = append(chats, room.getChatHistory()...)
+			room.mu.RUnlock()
+		}
+
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+			return
+		}
+		if !isHost {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only hosts may export chat"})
+			return
+		}
+		slices.SortStableFunc(chats, func(a, b ChatInfo) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+
+		if format == "csv" {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", `attachment; filename="chat.csv"`)
+			c.Status(http.StatusOK)
+			if err := writeChatCSV(c.Writer, chats); err != nil {
+				c.Error(err)
+			}
+			return
+		}
+		c.JSON(http.StatusOK, chats)
+	}
+}
+
+// requestToken returns the token from a Bearer Authorization header, falling
+// back to the token query parameter used by ServeWs. It is empty if neither is set.
+func requestToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return c.Query("token")
+}
+
+// writeChatCSV writes chat messages as CSV rows preceded by chatExportHeader.
+//
+// Parameters:
+//   - w: Destination for the CSV output
+//   - chats: Messages to write, in order
+//
+// Returns:
+//   - error: Any error from the underlying writer
+func writeChatCSV(w io.Writer, chats []ChatInfo) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(chatExportHeader); err != nil {
+		return err
+	}
+	for _, chat := range chats {
+		record := []string{
+			strconv.FormatInt(int64(chat.Timestamp), 10),
+			string(chat.ClientId),
+			string(chat.DisplayName),
+			string(chat.ChatContent),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Social-Media/backend/go/internal/v1/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeChatExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	chats := []ChatInfo{
+		{ClientInfo: ClientInfo{ClientId: "host1", DisplayName: "Host"}, ChatId: "c1", Timestamp: 1000, ChatContent: "hello"},
+		{ClientInfo: ClientInfo{ClientId: "p1", DisplayName: "Smith, Jane"}, ChatId: "c2", Timestamp: 2000, ChatContent: "one, two, \"three\"\nfour"},
+	}
+
+	// newExportHub returns a hub with one room hosted by host1 containing chats,
+	// authenticating every request as the given subject.
+	newExportHub := func(subject string) *Hub {
+		hub := NewTestHub(&MockValidator{ClaimsToReturn: &auth.CustomClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+		}})
+		room, _ := hub.getOrCreateRoom("room1")
+		room.handleClientConnect(newTestClientWithName("host1", "Host"))
+		participant := newTestClientWithName("p1", "Smith, Jane")
+		room.addParticipant(participant)
+		for _, chat := range chats {
+			room.addChat(chat)
+		}
+		return hub
+	}
+
+	serve := func(hub *Hub, target string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.GET("/rooms/:roomId/chat", ServeChatExport(hub))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer valid-token")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should export chat as JSON by default", func(t *testing.T) {
+		w := serve(newExportHub("host1"), "/rooms/room1/chat")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var got []ChatInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, chats, got)
+	})
+
+	t.Run("should export chat as CSV with escaped content", func(t *testing.T) {
+		w := serve(newExportHub("host1"), "/rooms/room1/chat?format=csv")
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+		assert.Contains(t, w.Body.String(), `"one, two, ""three""`+"\nfour\"", "Content should be quoted per RFC 4180")
+
+		records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"timestamp", "clientId", "displayName", "content"},
+			{"1000", "host1", "Host", "hello"},
+			{"2000", "p1", "Smith, Jane", "one, two, \"three\"\nfour"},
+		}, records)
+	})
+
+	t.Run("should merge chat from every hub", func(t *testing.T) {
+		validator := &MockValidator{ClaimsToReturn: &auth.CustomClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "host1"},
+		}}
+		hub := newExportHub("host1")
+		chatHub := NewTestHub(validator)
+		room, _ := chatHub.getOrCreateRoom("room1")
+		room.handleClientConnect(newTestClientWithName("someone-else", "Other"))
+		sentOnChat := ChatInfo{ClientInfo: ClientInfo{ClientId: "p1", DisplayName: "Smith, Jane"}, ChatId: "c3", Timestamp: 1500, ChatContent: "from /ws/chat"}
+		room.addChat(sentOnChat)
+
+		router := gin.New()
+		router.GET("/rooms/:roomId/chat", ServeChatExport(hub, chatHub))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/rooms/room1/chat", nil)
+		req.Header.Set("Authorization", "Bearer valid-token")
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, "Hosting the room on one hub is enough")
+		var got []ChatInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, []ChatInfo{chats[0], sentOnChat, chats[1]}, got, "Messages are merged oldest first")
+	})
+
+	t.Run("should reject non-hosts", func(t *testing.T) {
+		w := serve(newExportHub("p1"), "/rooms/room1/chat")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("should return not found for unknown rooms", func(t *testing.T) {
+		w := serve(newExportHub("host1"), "/rooms/missing/chat")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should reject unknown formats", func(t *testing.T) {
+		w := serve(newExportHub("host1"), "/rooms/room1/chat?format=xml")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should reject invalid tokens", func(t *testing.T) {
+		hub := NewTestHub(&MockValidator{ErrorToReturn: errors.New("invalid")})
+		w := serve(hub, "/rooms/room1/chat")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
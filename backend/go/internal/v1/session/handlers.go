@@ -23,39 +23,66 @@
 package session
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"slices"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/utils/set"
 )
 
 // logHelper provides consistent logging for handler operations.
-// This utility function logs successful handler calls and payload marshalling failures
+// This utility method logs successful handler calls and payload marshalling failures
 // with structured logging fields for debugging and monitoring.
 //
 // Log Levels:
-//   - Info: Successful handler execution
-//   - Error: Payload marshalling failures that prevent handler execution
+//   - Configurable (Info by default): Successful handler execution, sampled
+//     according to the room's HandlerLogConfig
+//   - Error: Payload marshalling failures that prevent handler execution, never sampled
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held, since it advances the sampling counter.
 //
 // Parameters:
 //   - ok: Whether the payload was successfully marshalled
 //   - ClientId: The ID of the client making the request
 //   - methodName: The name of the handler method being called
-//   - RoomId: The ID of the room where the operation is taking place
-func logHelper(ok bool, ClientId ClientIdType, methodName string, RoomId RoomIdType) {
+func (r *Room) logHelper(ok bool, ClientId ClientIdType, methodName string) {
+	logger := r.config.HandlerLog.logger()
 	if ok {
-		slog.Info("Client called method in room",
+		if !r.sampleHandlerLog() {
+			return
+		}
+		logger.Log(context.Background(), r.config.HandlerLog.SuccessLevel, "Client called method in room",
 			"ClientId", ClientId,
-			"RoomId", RoomId,
+			"RoomId", r.ID,
 			"methodName", methodName,
 		)
 	} else {
-		slog.Error("Client called method in room and payload failed to marshall. Aborting request.",
+		logger.Error("Client called method in room and payload failed to marshall. Aborting request.",
 			"ClientId", ClientId,
-			"RoomId", RoomId,
+			"RoomId", r.ID,
 			"methodName", methodName,
 		)
 	}
 }
 
+// sampleHandlerLog reports whether the current successful handler call should be logged.
+// With a sample rate of N, the first of every N calls is logged.
+//
+// Thread Safety: Safe under either the room's read or write lock, since
+// query handlers run under the read lock.
+func (r *Room) sampleHandlerLog() bool {
+	rate := r.config.HandlerLog.SuccessSampleRate
+	if rate <= 1 {
+		return true
+	}
+	n := r.handlerLogCalls.Add(1) - 1
+	return n%uint64(rate) == 0
+}
+
 // assertPayload is a generic helper function for type-safe payload validation.
 // This function attempts to cast the incoming payload to the expected type,
 // returning both the cast result and a boolean indicating success.
@@ -64,6 +91,12 @@ func logHelper(ok bool, ClientId ClientIdType, methodName string, RoomId RoomIdT
 // This function provides compile-time type safety for payload handling while
 // allowing runtime validation of the actual payload structure.
 //
+// Wire Payloads:
+// Messages read from a WebSocket are decoded into Message with an untyped
+// payload, so JSON objects arrive as map[string]any rather than as T. Such
+// payloads are converted to T through a JSON round trip. Unknown fields are
+// ignored; fields of the wrong JSON type fail the assertion.
+//
 // Usage Example:
 //
 //	payload, ok := assertPayload[AddChatPayload](rawPayload)
@@ -79,8 +112,234 @@ func logHelper(ok bool, ClientId ClientIdType, methodName string, RoomId RoomIdT
 //   - T: The payload cast to the expected type (zero value if assertion fails)
 //   - bool: Whether the type assertion was successful
 func assertPayload[T any](payload any) (T, bool) {
-	p, ok := payload.(T)
-	return p, ok
+	if p, ok := payload.(T); ok {
+		return p, true
+	}
+
+	var p T
+	fields, ok := payload.(map[string]any)
+	if !ok {
+		return p, false
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return p, false
+	}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		var zero T
+		return zero, false
+	}
+	return p, true
+}
+
+// rejectSelfTarget guards handlers that change another client's role. The
+// router already limits these events to hosts, but the target comes from the
+// payload, so an actor naming itself could otherwise escalate its own role if
+// a permission or state check were ever loosened. Self-targeted requests are
+// logged and answered with ErrorCodeSelfTarget.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Returns:
+//   - true if the request targets the actor and was rejected
+func (r *Room) rejectSelfTarget(actor *Client, target ClientIdType, event Event) bool {
+	if target != actor.ID {
+		return false
+	}
+	slog.Warn("Rejected self-targeted role change", "ClientId", actor.ID, "event", event, "RoomId", r.ID)
+	actor.sendError(ErrorCodeSelfTarget, "cannot target yourself", event)
+	return true
+}
+
+// handleActiveSpeaker records a client's report that it started or stopped
+// speaking, as detected from its local audio level, and tells the room who is
+// speaking.
+//
+// Rate Limiting:
+// Reports that do not change the client's state are ignored, so a client
+// repeating its level detector's output costs nothing. Changes are broadcast
+// as EventActiveSpeakers at most once per RoomConfig.ActiveSpeakerInterval;
+// changes within the interval are coalesced into one broadcast at its end.
+//
+// Parameters:
+//   - client: The client reporting its own speaking state
+//   - event: The event type (should be EventActiveSpeaker)
+//   - payload: The raw payload containing the Speaking flag
+func (r *Room) handleActiveSpeaker(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[ActiveSpeakerPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	if r.setSpeaking(client.ID, p.Speaking) {
+		r.publishActiveSpeakers(ctx)
+	}
+}
+
+// handleMediaState records a client's report that it turned its microphone or
+// camera on or off, and tells the room. The recorded state is what newly
+// admitted clients receive in their media state snapshot.
+//
+// Reports that do not change the client's state are ignored, so a client
+// repeating its state costs nothing.
+//
+// Parameters:
+//   - client: The client reporting its own media state
+//   - event: The event type (should be EventMediaState)
+//   - payload: The raw payload containing the Unmuted and CameraOn flags
+func (r *Room) handleMediaState(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[MediaStatePayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	_, unmuted := r.unmuted[client.ID]
+	_, cameraOn := r.cameraOn[client.ID]
+	if p.Unmuted == unmuted && p.CameraOn == cameraOn {
+		return
+	}
+	if p.Unmuted {
+		r.unmuted[client.ID] = client
+	} else {
+		delete(r.unmuted, client.ID)
+	}
+	if p.CameraOn {
+		r.cameraOn[client.ID] = client
+	} else {
+		delete(r.cameraOn, client.ID)
+	}
+
+	state := MediaStatePayload{ClientInfo: client.info(), Unmuted: p.Unmuted, CameraOn: p.CameraOn}
+	r.broadcast(ctx, event, state, HasSpectatorPermission())
+}
+
+// handleCaption relays a caption of the sender's speech, transcribed on their
+// device, to everyone admitted to the room, and records it in the room's
+// transcript.
+//
+// Stamping:
+// The speaker is always the sender and the timestamp is the server's, so a
+// client cannot attribute words to someone else or reorder the transcript.
+//
+// Persistence:
+// The last RoomConfig.MaxTranscript captions are kept in memory, and each is
+// queued for the room's TranscriptStore after it is relayed, so a slow store
+// never holds up the room; see store.go.
+//
+// Parameters:
+//   - client: The speaker
+//   - event: The event type (should be EventCaption)
+//   - payload: The raw payload containing the caption text
+func (r *Room) handleCaption(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[CaptionPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+	if err := p.Validate(); err != nil {
+		slog.Warn("Invalid caption payload", "ClientId", client.ID, "RoomId", r.ID, "error", err)
+		return
+	}
+
+	caption := CaptionPayload{
+		ClientInfo: client.info(),
+		Text:       p.Text,
+		Timestamp:  Timestamp(r.config.Clock.Now().UnixMilli()),
+	}
+	r.addCaption(caption)
+	r.broadcast(ctx, event, caption, HasSpectatorPermission())
+	r.queueCaption(caption)
+}
+
+// handleSpotlight lets a host make one participant the main view for
+// everyone. The spotlight is included in the room state and cleared
+// automatically when the participant leaves or is returned to the audience.
+//
+// Validation:
+// The target must be a host, participant or screensharer currently in the
+// room; spectators and waiting clients publish no video to show. Otherwise the
+// host is sent ErrorCodeClientNotFound. Spotlighting the client already in the
+// spotlight is a no-op.
+//
+// Parameters:
+//   - client: The host setting the spotlight
+//   - event: The event type (should be EventSpotlight)
+//   - payload: The raw payload naming the participant in ClientId
+func (r *Room) handleSpotlight(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[SpotlightPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	target, exists := r.findPeer(p.ClientId)
+	if !exists || target.Role == RoleTypeSpectator {
+		slog.Warn("Attempted to spotlight a client that is not a participant", "TargetClientId", p.ClientId, "HostId", client.ID, "RoomId", r.ID)
+		client.sendError(ErrorCodeClientNotFound, "participant not found", event)
+		return
+	}
+	if r.spotlight == target.ID {
+		return
+	}
+	r.spotlight = target.ID
+	r.broadcast(ctx, event, SpotlightPayload(target.info()), HasSpectatorPermission())
+}
+
+// handleClearSpotlight lets a host return everyone to their own choice of
+// main view. Clearing when nobody is spotlighted is a no-op.
+//
+// Parameters:
+//   - client: The host clearing the spotlight
+//   - event: The event type (should be EventClearSpotlight)
+//   - payload: Ignored
+func (r *Room) handleClearSpotlight(ctx context.Context, client *Client, event Event, payload any) {
+	r.logHelper(true, client.ID, GetFuncName())
+	r.clearSpotlight(ctx, r.spotlight)
+}
+
+// handleConnectionStats records the sender's latest connection statistics for
+// hosts to read with EventConnectionReport. Nothing is broadcast, and each
+// report replaces the client's previous one, so a client reporting every few
+// seconds for a whole meeting holds one entry.
+//
+// Parameters:
+//   - client: The client reporting its own statistics
+//   - event: The event type (should be EventConnectionStats)
+//   - payload: The raw payload containing the statistics
+func (r *Room) handleConnectionStats(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[ConnectionStatsPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+	if err := p.Validate(); err != nil {
+		slog.Warn("Invalid connection stats payload", "ClientId", client.ID, "RoomId", r.ID, "error", err)
+		return
+	}
+
+	p.ClientInfo = client.info()
+	p.Timestamp = Timestamp(r.config.Clock.Now().UnixMilli())
+	r.setConnectionStats(p)
+}
+
+// handleConnectionReport sends the requesting host the latest connection
+// statistics of every client still in the room, to help diagnose who is
+// having call quality problems. The payload is ignored.
+//
+// Thread Safety: This is a query event handled under the room's read lock.
+//
+// Parameters:
+//   - client: The host requesting the report
+//   - event: The event type (should be EventConnectionReport)
+//   - payload: Ignored
+func (r *Room) handleConnectionReport(ctx context.Context, client *Client, event Event, payload any) {
+	r.logHelper(true, client.ID, GetFuncName())
+
+	report := ConnectionReportPayload{Stats: r.connectionReport()}
+	r.replyTo(client, EventConnectionReport, report)
 }
 
 // handleAddChat processes requests to add new chat messages to the room.
@@ -101,6 +360,11 @@ func assertPayload[T any](payload any) (T, bool) {
 // The message is broadcast to all clients with participant-level permissions,
 // ensuring only active meeting participants can see chat messages.
 //
+// Moderation and Persistence:
+// When the room has a ModerationClient or a ChatStore, the message is checked,
+// saved and published on another goroutine after this handler returns, so the
+// room lock is not held while waiting on them; see moderation.go and store.go.
+//
 // Error Handling:
 // Validation failures are logged but don't crash the handler. Invalid
 // requests are silently dropped to prevent error message spam.
@@ -109,9 +373,9 @@ func assertPayload[T any](payload any) (T, bool) {
 //   - client: The client sending the chat message
 //   - event: The event type (should be EventAddChat)
 //   - payload: The raw payload containing chat message data
-func (r *Room) handleAddChat(client *Client, event Event, payload any) {
+func (r *Room) handleAddChat(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[AddChatPayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
@@ -122,8 +386,72 @@ func (r *Room) handleAddChat(client *Client, event Event, payload any) {
 		return
 	}
 
+	if !r.allowChat(client) {
+		slog.Warn("Chat rate limit exceeded", "ClientId", client.ID, "RoomId", r.ID)
+		client.sendError(ErrorCodeRateLimited, "too many chat messages", event)
+		return
+	}
+
+	if r.config.Moderation == nil && !r.persistsChat() {
+		r.publishChat(ctx, client, event, p)
+		return
+	}
+
+	// Check and save off the room lock. The message context ends when this
+	// handler returns, so the work keeps its values but is cancelled by the
+	// connection instead.
+	chatCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(client.context(), cancel)
+	go func() {
+		defer cancel()
+		defer stop()
+		if r.config.Moderation != nil && !r.moderateChat(chatCtx, client, event, p) {
+			return
+		}
+		r.saveChat(chatCtx, client, event, p)
+	}()
+}
+
+// publishChat stamps a validated chat message and broadcasts it to the room
+// straight away, for rooms with no moderation or store to wait for.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) publishChat(ctx context.Context, client *Client, event Event, p AddChatPayload) {
+	p = r.stampChat(p)
+
+	// Encode before storing so history never holds a message nobody received
+	rawMsg, err := marshalMessage(event, p)
+	if err != nil {
+		r.config.HandlerLog.logger().Error("Rejected chat message: payload cannot be marshaled to JSON", "ClientId", client.ID, "RoomId", r.ID, "error", err)
+		return
+	}
+	r.deliverChat(ctx, event, p, rawMsg)
+}
+
+// stampChat assigns a chat message its server id and timestamp.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) stampChat(p AddChatPayload) AddChatPayload {
+	// The server assigns ids so they are unique and cannot spoof another
+	// client's message; any client-supplied id is discarded. Clients learn the
+	// id from the broadcast and use it to delete the message.
+	p.ChatId = r.nextChatId()
+
+	// Order history by when the server saw each message, not the client's clock
+	p.Timestamp = r.nextChatTimestamp()
+	return p
+}
+
+// deliverChat adds a stamped chat message to history and broadcasts it.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) deliverChat(ctx context.Context, event Event, p AddChatPayload, rawMsg []byte) {
 	r.addChat(p)
-	r.broadcast(event, p, HasParticipantPermission())
+	r.totalChats++
+	r.broadcastRaw(ctx, event, p, rawMsg, HasParticipantPermission())
 }
 
 // handleDeleteChat processes requests to remove chat messages from the room history.
@@ -132,32 +460,123 @@ func (r *Room) handleAddChat(client *Client, event Event, payload any) {
 //
 // Operation Flow:
 //  1. Validate payload structure
-//  2. Remove message from chat history using ChatId
-//  3. Broadcast deletion event to all participants
+//  2. Look up the message and check the requester may delete it
+//  3. Remove message from chat history using ChatId
+//  4. Broadcast deletion event to all participants
 //
 // Permissions:
-// Only participants and above can delete chat messages. The actual authorization
-// for who can delete which messages should be implemented at the business logic level.
+// Only participants and above can send the event. Hosts may delete any
+// message; everyone else only messages they authored, judged by the author
+// the server stamped on the stored message. Other requests are answered with
+// ErrorCodeNotAuthor, and requests for messages no longer in the history with
+// ErrorCodeChatNotFound.
 //
 // Broadcasting:
 // The deletion event is broadcast to all participants so their UIs can
 // update to reflect the removed message.
 //
-// Note: This handler doesn't implement sender verification - it assumes
-// that higher-level permission checks ensure appropriate access control.
-//
 // Parameters:
 //   - client: The client requesting the deletion
 //   - event: The event type (should be EventDeleteChat)
 //   - payload: The raw payload containing the ChatId to delete
-func (r *Room) handleDeleteChat(client *Client, event Event, payload any) {
+func (r *Room) handleDeleteChat(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[DeleteChatPayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
+	stored, found := r.findChat(p.ChatId)
+	if !found {
+		client.sendError(ErrorCodeChatNotFound, "message not found", event)
+		return
+	}
+	if stored.ClientId != client.ID && client.Role != RoleTypeHost {
+		slog.Warn("Refused to delete another client's message", "ClientId", client.ID, "AuthorId", stored.ClientId, "ChatId", p.ChatId, "RoomId", r.ID)
+		client.sendError(ErrorCodeNotAuthor, "only hosts can delete other people's messages", event)
+		return
+	}
 	r.deleteChat(p)
-	r.broadcast(event, p, HasParticipantPermission())
+	r.broadcast(ctx, event, p, HasParticipantPermission())
+}
+
+// handleDeleteUserChats lets a host remove every message one client has sent,
+// such as when moderating spam. The target need not still be in the room.
+//
+// Broadcasting:
+// A single notice listing the removed ChatIds is broadcast to participants,
+// rather than one delete_chat per message. Nothing is broadcast when the
+// client has no messages in the history.
+//
+// Parameters:
+//   - client: The host requesting the deletion
+//   - event: The event type (should be EventDeleteUserChats)
+//   - payload: The raw payload naming the author in ClientId
+func (r *Room) handleDeleteUserChats(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[DeleteUserChatsPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	removed := r.deleteUserChats(p.ClientId)
+	if len(removed) == 0 {
+		slog.Info("No chat messages to delete for client", "TargetClientId", p.ClientId, "HostId", client.ID, "RoomId", r.ID)
+		return
+	}
+
+	slog.Info("Deleted chat messages for client", "TargetClientId", p.ClientId, "count", len(removed), "HostId", client.ID, "RoomId", r.ID)
+	r.broadcast(ctx, event, DeleteUserChatsPayload{ClientInfo: ClientInfo{ClientId: p.ClientId}, ChatIds: removed}, HasParticipantPermission())
+}
+
+// handlePinChat lets a host pin a message to the top of the chat. Pinned ids
+// are included in the room state, and a message is unpinned automatically
+// when it is deleted or leaves the history.
+//
+// Validation:
+// The message must still be in the room's history; otherwise the host is sent
+// ErrorCodeChatNotFound. Pinning a message that is already pinned is a no-op.
+//
+// Parameters:
+//   - client: The host pinning the message
+//   - event: The event type (should be EventPinChat)
+//   - payload: The raw payload naming the message in ChatId
+func (r *Room) handlePinChat(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[PinChatPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	if !r.hasChat(p.ChatId) {
+		slog.Warn("Attempted to pin a message that is not in the history", "ChatId", p.ChatId, "HostId", client.ID, "RoomId", r.ID)
+		client.sendError(ErrorCodeChatNotFound, "message not found", event)
+		return
+	}
+	if !r.pinChat(p.ChatId) {
+		return
+	}
+	r.broadcast(ctx, event, PinChatPayload{ClientInfo: client.info(), ChatId: p.ChatId}, HasParticipantPermission())
+}
+
+// handleUnpinChat lets a host unpin a message. Unpinning a message that is not
+// pinned is a no-op.
+//
+// Parameters:
+//   - client: The host unpinning the message
+//   - event: The event type (should be EventUnpinChat)
+//   - payload: The raw payload naming the message in ChatId
+func (r *Room) handleUnpinChat(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[UnpinChatPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	if !r.pinnedChats.Has(p.ChatId) {
+		return
+	}
+	r.pinnedChats.Delete(p.ChatId)
+	r.broadcast(ctx, event, UnpinChatPayload{ClientInfo: client.info(), ChatId: p.ChatId}, HasParticipantPermission())
 }
 
 // handleGetRecentChats processes requests for chat history retrieval.
@@ -187,9 +606,9 @@ func (r *Room) handleDeleteChat(client *Client, event Event, payload any) {
 //   - client: The client requesting chat history
 //   - event: The event type (should be EventGetRecentChats)
 //   - payload: The raw payload containing request parameters
-func (r *Room) handleGetRecentChats(client *Client, event Event, payload any) {
+func (r *Room) handleGetRecentChats(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[GetRecentChatsPayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
@@ -197,15 +616,27 @@ func (r *Room) handleGetRecentChats(client *Client, event Event, payload any) {
 	recentChats := r.getRecentChats(p)
 
 	// Send the recent chats directly to the requesting client
-	if msg, err := json.Marshal(Message{Event: EventGetRecentChats, Payload: recentChats}); err == nil {
-		select {
-		case client.send <- msg:
-		default:
-			slog.Warn("Failed to send recent chats to client - channel full", "ClientId", client.ID, "RoomId", r.ID)
-		}
-	} else {
-		slog.Error("Failed to marshal recent chats", "error", err, "ClientId", client.ID, "RoomId", r.ID)
+	r.replyTo(client, EventGetRecentChats, recentChats)
+}
+
+// handleGetChatsByRange sends the requester the chat messages sent within a
+// time range, so clients can jump to a point in a long meeting. Like
+// handleGetRecentChats, the result goes only to the requesting client.
+//
+// Parameters:
+//   - client: The client requesting chat history
+//   - event: The event type (should be EventGetChatsByRange)
+//   - payload: The raw payload containing the requested range
+func (r *Room) handleGetChatsByRange(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[GetChatsByRangePayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
 	}
+
+	chats := r.getChatsByRange(p.FromTimestamp, p.ToTimestamp)
+
+	r.replyTo(client, event, chats)
 }
 
 // handleRaiseHand processes requests for participants to raise their hands.
@@ -233,14 +664,19 @@ func (r *Room) handleGetRecentChats(client *Client, event Event, payload any) {
 //   - client: The client raising their hand
 //   - event: The event type (should be EventRaiseHand)
 //   - payload: The raw payload containing hand raise information
-func (r *Room) handleRaiseHand(client *Client, event Event, payload any) {
+func (r *Room) handleRaiseHand(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[RaiseHandPayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
+	if _, raised := r.raisingHand[p.ClientId]; !raised && r.isHandQueueFull() {
+		slog.Warn("Rejected raise hand - hand queue full", "ClientId", client.ID, "RoomId", r.ID, "limit", r.config.MaxRaisedHands)
+		client.sendError(ErrorCodeHandQueueFull, "hand queue full", event)
+		return
+	}
 	r.raiseHand(p)
-	r.broadcast(event, p, HasParticipantPermission())
+	r.broadcast(ctx, event, p, HasParticipantPermission())
 }
 
 // handleLowerHand processes requests for participants to lower their hands.
@@ -268,14 +704,58 @@ func (r *Room) handleRaiseHand(client *Client, event Event, payload any) {
 //   - client: The client lowering their hand
 //   - event: The event type (should be EventLowerHand)
 //   - payload: The raw payload containing hand lower information
-func (r *Room) handleLowerHand(client *Client, event Event, payload any) {
+func (r *Room) handleLowerHand(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[LowerHandPayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
 	r.lowerHand(p)
-	r.broadcast(event, p, HasParticipantPermission())
+	r.broadcast(ctx, event, p, HasParticipantPermission())
+}
+
+// handleLowerAllHands lets a host clear the whole hand queue at once, such as
+// after a Q&A round. Every raised hand is lowered and the room is told who was
+// lowered, in the order they raised their hands. Clearing an empty queue is a
+// no-op.
+//
+// Parameters:
+//   - client: The host clearing the queue
+//   - event: The event type (should be EventLowerAllHands)
+//   - payload: Ignored
+func (r *Room) handleLowerAllHands(ctx context.Context, client *Client, event Event, payload any) {
+	r.logHelper(true, client.ID, GetFuncName())
+	lowered := r.lowerAllHands()
+	if len(lowered) == 0 {
+		return
+	}
+	r.broadcast(ctx, event, LowerAllHandsPayload{ClientInfo: client.info(), Lowered: clientInfos(lowered)}, HasParticipantPermission())
+}
+
+// handleReaction broadcasts a floating emoji reaction to the room.
+// Reactions are not stored, so late joiners never see them.
+//
+// Allowlist:
+// Only emoji in RoomConfig.AllowedReactions are relayed, so clients cannot use
+// reactions to push arbitrary strings to everyone's screen. Anything else is
+// rejected with ErrorCodeReactionBlocked.
+//
+// Parameters:
+//   - client: The client reacting
+//   - event: The event type (should be EventReaction)
+//   - payload: The raw payload containing the emoji
+func (r *Room) handleReaction(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[ReactionPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+	if !r.allowsReaction(p.Emoji) {
+		slog.Warn("Rejected reaction not on allowlist", "ClientId", client.ID, "RoomId", r.ID)
+		client.sendError(ErrorCodeReactionBlocked, "reaction not allowed", event)
+		return
+	}
+	r.broadcast(ctx, event, ReactionPayload{ClientInfo: client.info(), Emoji: p.Emoji}, nil)
 }
 
 // handleRequestWaiting processes requests from clients to join the waiting room.
@@ -295,7 +775,8 @@ func (r *Room) handleLowerHand(client *Client, event Event, payload any) {
 // Broadcasting:
 // The request is broadcast only to hosts, as they are the only ones
 // who can approve or deny waiting room requests. Regular participants
-// don't need to see these requests.
+// don't need to see these requests. Repeated requests from the same client
+// within RoomConfig.WaitingRequestInterval are dropped rather than re-broadcast.
 //
 // Use Cases:
 //   - New clients joining a moderated meeting
@@ -306,13 +787,25 @@ func (r *Room) handleLowerHand(client *Client, event Event, payload any) {
 //   - client: The client requesting to join the waiting room
 //   - event: The event type (should be EventRequestWaiting)
 //   - payload: The raw payload containing waiting request information
-func (r *Room) handleRequestWaiting(client *Client, event Event, payload any) {
+func (r *Room) handleRequestWaiting(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[RequestWaitingPayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
-	r.broadcast(event, p, HasHostPermission())
+
+	// Drop repeats within the dedup window so a client cannot flood hosts
+	now := r.config.Clock.Now()
+	interval := r.config.WaitingRequestInterval
+	if interval > 0 && !client.lastWaitingRequest.IsZero() && now.Sub(client.lastWaitingRequest) < interval {
+		slog.Debug("Suppressed repeated waiting request", "ClientId", client.ID, "RoomId", r.ID)
+		return
+	}
+	client.lastWaitingRequest = now
+
+	// Hosts see the identity and profile from the client's token, not the payload
+	p = client.info()
+	r.broadcast(ctx, event, p, HasHostPermission())
 }
 
 // handleAcceptWaiting processes host decisions to accept clients from the waiting room.
@@ -334,10 +827,14 @@ func (r *Room) handleRequestWaiting(client *Client, event Event, payload any) {
 // Host Authority:
 // Only hosts can accept waiting clients, maintaining meeting control
 // and preventing unauthorized admissions by regular participants.
+// By default the first host's approval admits the client; RoomConfig.WaitingApprovals
+// can require several hosts to agree. Once admitted, further accepts from other
+// hosts find no waiting client and are ignored, so double-admits cannot happen.
 //
 // Broadcasting:
 // The acceptance is broadcast to all clients (nil permission set)
-// so everyone can see the new participant join the meeting.
+// so everyone can see the new participant join the meeting. The broadcast is
+// a ParticipantJoinedPayload whose Notify hint follows notifyJoinLeave.
 //
 // Error Handling:
 // If the target client doesn't exist in the waiting room, the request
@@ -347,26 +844,44 @@ func (r *Room) handleRequestWaiting(client *Client, event Event, payload any) {
 //   - client: The host accepting the waiting client
 //   - event: The event type (should be EventAcceptWaiting)
 //   - payload: The raw payload containing the client ID to accept
-func (r *Room) handleAcceptWaiting(client *Client, event Event, payload any) {
+func (r *Room) handleAcceptWaiting(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[AcceptWaitingPayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
-	if !ok {
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok || r.rejectSelfTarget(client, p.ClientId, event) {
 		return
 	}
 
 	// Security check: Only accept requests for clients that are actually waiting
 	waitingClient, exists := r.waiting[p.ClientId]
+	if !exists && r.forwardAction(client, p.ClientId, event, p) {
+		return
+	}
 	if !exists {
 		slog.Warn("Attempted to accept non-waiting client", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
 		return
 	}
 
+	if r.admittedRole() != RoleTypeSpectator && r.isRoomFull() {
+		slog.Warn("Cannot accept waiting client - room is full", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		client.sendError(ErrorCodeRoomFull, "room is at capacity", event)
+		return
+	}
+
+	if !r.approveWaiting(waitingClient, client) {
+		slog.Info("Recorded host approval for waiting client", "TargetClientId", p.ClientId, "ApprovedByHostId", client.ID, "RoomId", r.ID)
+		return
+	}
+
 	if waitingClient != nil {
-		r.deleteWaiting(waitingClient)
-		r.addParticipant(waitingClient)
+		if err := r.transitionRole(waitingClient, RoleTypeWaiting, r.admittedRole()); err != nil {
+			slog.Error("Failed to accept waiting client", "error", err, "TargetClientId", p.ClientId, "RoomId", r.ID)
+			return
+		}
 		slog.Info("Client accepted from waiting room", "AcceptedClientId", waitingClient.ID, "AcceptedByHostId", client.ID, "RoomId", r.ID)
+		r.sendAdmissionNotices(waitingClient)
+		p = waitingClient.info()
 	}
-	r.broadcast(event, p, nil)
+	r.broadcast(ctx, event, ParticipantJoinedPayload{ClientInfo: p, Notify: r.notifyJoinLeave()}, nil)
 }
 
 // handleDenyWaiting processes host decisions to deny clients from the waiting room.
@@ -376,9 +891,14 @@ func (r *Room) handleAcceptWaiting(client *Client, event Event, payload any) {
 // Operation Flow:
 //  1. Validate the denial request payload
 //  2. Find the target client in the waiting room
-//  3. Remove them from the waiting room if found
+//  3. Remove them from the waiting room
 //  4. Broadcast the denial to waiting room participants
 //
+// Idempotency:
+// A denial for a client that is no longer waiting, such as a double-click or
+// a second host deciding at the same time, is logged and ignored, so the
+// denial is broadcast once.
+//
 // Client Removal:
 // When a client is denied, they are completely removed from the waiting
 // room and must make a new request if they want to try joining again.
@@ -400,25 +920,120 @@ func (r *Room) handleAcceptWaiting(client *Client, event Event, payload any) {
 //   - client: The host denying the waiting client
 //   - event: The event type (should be EventDenyWaiting)
 //   - payload: The raw payload containing the client ID to deny
-func (r *Room) handleDenyWaiting(client *Client, event Event, payload any) {
+func (r *Room) handleDenyWaiting(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[DenyWaitingPayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
-	// Find the waiting client to deny
-	var waitingClient *Client
-	for _, c := range r.waiting {
-		if c.ID == p.ClientId {
-			waitingClient = c
-			break
-		}
+	// A repeated denial, or one racing another host's decision, finds the
+	// client already gone and changes nothing
+	waitingClient, exists := r.waiting[p.ClientId]
+	if !exists && r.forwardAction(client, p.ClientId, event, p) {
+		return
+	}
+	if !exists {
+		slog.Info("Ignored denial of a client that is not waiting", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		return
 	}
 
-	if waitingClient != nil {
-		r.deleteWaiting(waitingClient)
+	r.deleteWaiting(waitingClient)
+	r.broadcast(ctx, event, p, HasWaitingPermission())
+}
+
+// handleRequestSpeak forwards a webinar spectator's request to speak to the hosts.
+// The payload is rebuilt from the sender's own identity so that a spectator
+// cannot ask on behalf of someone else.
+//
+// Parameters:
+//   - client: The spectator asking to speak
+//   - event: The event type (should be EventRequestSpeak)
+//   - payload: The raw payload containing the spectator's information
+func (r *Room) handleRequestSpeak(ctx context.Context, client *Client, event Event, payload any) {
+	_, ok := assertPayload[RequestSpeakPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+	r.broadcast(ctx, event, RequestSpeakPayload(client.info()), HasHostPermission())
+}
+
+// handleGrantSpeak promotes a spectator to participant so they can publish
+// audio and video, chat and raise their hand. Everyone in the room is told so
+// that clients can start negotiating media with the new speaker.
+//
+// Parameters:
+//   - client: The host granting the request
+//   - event: The event type (should be EventGrantSpeak)
+//   - payload: The raw payload containing the spectator's ID
+func (r *Room) handleGrantSpeak(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[GrantSpeakPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok || r.rejectSelfTarget(client, p.ClientId, event) {
+		return
+	}
+
+	spectator, exists := r.spectators[p.ClientId]
+	if !exists {
+		slog.Warn("Attempted to grant speaking to a non-spectator", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		return
+	}
+	if r.isRoomFull() {
+		slog.Warn("Cannot grant speaking - room is full", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		client.sendError(ErrorCodeRoomFull, "room is at capacity", event)
+		return
+	}
+	if err := r.transitionRole(spectator, RoleTypeSpectator, RoleTypeParticipant); err != nil {
+		slog.Error("Failed to grant speaking", "error", err, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		return
+	}
+	slog.Info("Spectator granted speaking", "TargetClientId", spectator.ID, "GrantedByHostId", client.ID, "RoomId", r.ID)
+	r.broadcast(ctx, event, GrantSpeakPayload(spectator.info()), nil)
+}
+
+// handleRevokeSpeak returns a speaker to the audience. A speaker who is sharing
+// their screen stops sharing as well, and a raised hand is lowered, since
+// spectators cannot lower it themselves. Hosts cannot be demoted this way.
+//
+// Parameters:
+//   - client: The host revoking speaking rights
+//   - event: The event type (should be EventRevokeSpeak)
+//   - payload: The raw payload containing the speaker's ID
+func (r *Room) handleRevokeSpeak(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[RevokeSpeakPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	var speaker *Client
+	from := RoleTypeParticipant
+	if s, exists := r.sharingScreen[p.ClientId]; exists {
+		speaker, from = s, RoleTypeScreenshare
+	} else if s, exists := r.participants[p.ClientId]; exists {
+		speaker = s
+	} else {
+		slog.Warn("Attempted to revoke speaking from a non-speaker", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		return
+	}
+	_, handRaised := r.raisingHand[speaker.ID]
+	if err := r.transitionRole(speaker, from, RoleTypeSpectator); err != nil {
+		slog.Error("Failed to revoke speaking", "error", err, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		return
+	}
+	slog.Info("Speaker returned to the audience", "TargetClientId", speaker.ID, "RevokedByHostId", client.ID, "RoomId", r.ID)
+	r.broadcast(ctx, event, RevokeSpeakPayload(speaker.info()), nil)
+
+	// The demotion lowered the speaker's raised hand
+	if handRaised {
+		r.broadcast(ctx, EventLowerHand, LowerHandPayload(speaker.info()), HasParticipantPermission())
+	}
+	r.clearSpotlight(ctx, speaker.ID)
+
+	// Spectators cannot report speaking, so clear the state for them
+	if r.setSpeaking(speaker.ID, false) {
+		r.publishActiveSpeakers(ctx)
 	}
-	r.broadcast(event, p, HasWaitingPermission())
 }
 
 // handleRequestScreenshare processes participant requests to share their screen.
@@ -441,6 +1056,11 @@ func (r *Room) handleDenyWaiting(client *Client, event Event, payload any) {
 // for screenshare approvals. Regular participants don't need to see
 // these requests unless they become hosts.
 //
+// Hosts:
+// A host needs nobody's approval, so its request starts the share at once:
+// it is recorded as sharing while keeping the host role (see addScreenshare)
+// and is answered with EventAcceptScreenshare.
+//
 // Use Cases:
 //   - Presentations during meetings
 //   - Collaborative work sessions
@@ -451,13 +1071,19 @@ func (r *Room) handleDenyWaiting(client *Client, event Event, payload any) {
 //   - client: The participant requesting to share screen
 //   - event: The event type (should be EventRequestScreenshare)
 //   - payload: The raw payload containing screenshare request information
-func (r *Room) handleRequestScreenshare(client *Client, event Event, payload any) {
+func (r *Room) handleRequestScreenshare(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[RequestScreensharePayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
-	r.broadcast(event, p, HasHostPermission())
+	if client.Role == RoleTypeHost {
+		r.addScreenshare(client)
+		r.replyTo(client, EventAcceptScreenshare, AcceptScreensharePayload(client.info()))
+		return
+	}
+	delete(r.deniedScreenshare, client.ID)
+	r.broadcast(ctx, event, p, HasHostPermission())
 }
 
 // handleAcceptScreenshare processes host decisions to approve screenshare requests.
@@ -482,7 +1108,8 @@ func (r *Room) handleRequestScreenshare(client *Client, event Event, payload any
 // Error Handling:
 //   - JSON marshalling errors are logged but don't crash the handler
 //   - Non-existent participants are handled gracefully
-//   - Channel send failures are managed with direct channel operations
+//   - A repeated acceptance finds the client already sharing and is ignored
+//   - A full channel drops the notification rather than blocking the room
 //
 // Security:
 // Only the specific requesting participant receives the acceptance
@@ -492,32 +1119,31 @@ func (r *Room) handleRequestScreenshare(client *Client, event Event, payload any
 //   - client: The host accepting the screenshare request
 //   - event: The event type (should be EventAcceptScreenshare)
 //   - payload: The raw payload containing the participant ID to approve
-func (r *Room) handleAcceptScreenshare(client *Client, event Event, payload any) {
+func (r *Room) handleAcceptScreenshare(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[AcceptScreensharePayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
-	if !ok {
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok || r.rejectSelfTarget(client, p.ClientId, event) {
 		return
 	}
-	// Find the client to accept for screenshare
-	var requestingClient *Client
-	for _, c := range r.participants {
-		if c.ID == p.ClientId {
-			requestingClient = c
-			break
+	// Find the client to accept for screenshare. A repeated acceptance finds
+	// the client already sharing and changes nothing.
+	requestingClient, exists := r.participants[p.ClientId]
+	if !exists {
+		if _, sharing := r.sharingScreen[p.ClientId]; sharing {
+			slog.Info("Ignored screenshare acceptance for a client already sharing", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		} else {
+			slog.Warn("Attempted to accept screenshare for a non-participant", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
 		}
+		return
 	}
 
-	if requestingClient != nil {
-		r.addScreenshare(requestingClient)
+	if err := r.transitionRole(requestingClient, RoleTypeParticipant, RoleTypeScreenshare); err != nil {
+		slog.Error("Failed to grant screenshare", "error", err, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		return
 	}
+	delete(r.deniedScreenshare, requestingClient.ID)
 
-	if msg, err := json.Marshal(Message{Event: event, Payload: p}); err == nil {
-		if requestingClient != nil {
-			requestingClient.send <- msg
-		}
-	} else {
-		slog.Error("Failed to marshal payload for AcceptScreenshare", "error", err)
-	}
+	r.replyTo(requestingClient, event, p)
 }
 
 // handleDenyScreenshare processes host decisions to deny screenshare requests.
@@ -542,8 +1168,11 @@ func (r *Room) handleAcceptScreenshare(client *Client, event Event, payload any)
 //
 // Error Handling:
 //   - JSON marshalling errors are logged but don't prevent the broadcast
-//   - Non-existent participants are handled gracefully in the loop
-//   - Channel send operations use direct sends for immediate delivery
+//   - Targets that are not participants are logged and ignored
+//   - A repeated denial of the same request is ignored; a new request from
+//     the participant can be denied again
+//   - A full channel drops the direct notification rather than blocking the
+//     room; the broadcast to hosts still goes out
 //
 // Host Awareness:
 // The broadcast to hosts ensures all meeting moderators are aware
@@ -553,25 +1182,263 @@ func (r *Room) handleAcceptScreenshare(client *Client, event Event, payload any)
 //   - client: The host denying the screenshare request
 //   - event: The event type (should be EventDenyScreenshare)
 //   - payload: The raw payload containing the participant ID to deny
-func (r *Room) handleDenyScreenshare(client *Client, event Event, payload any) {
+func (r *Room) handleDenyScreenshare(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[DenyScreensharePayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
 
-	if msg, err := json.Marshal(Message{Event: event, Payload: p}); err == nil {
-		// Find the client who requested screenshare to notify them of denial
-		for _, c := range r.participants {
-			if c.ID == p.ClientId {
-				c.send <- msg
-				break
-			}
+	// Only a participant's pending request can be denied. A repeated denial,
+	// or one arriving after another host accepted, changes nothing.
+	requestingClient, exists := r.participants[p.ClientId]
+	if !exists {
+		slog.Warn("Attempted to deny screenshare for a non-participant", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		return
+	}
+	if r.deniedScreenshare.Has(requestingClient.ID) {
+		slog.Info("Ignored repeated screenshare denial", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		return
+	}
+	if r.deniedScreenshare == nil {
+		r.deniedScreenshare = set.New[ClientIdType]()
+	}
+	r.deniedScreenshare.Insert(requestingClient.ID)
+
+	// Notify the client who requested screenshare of the denial
+	r.replyTo(requestingClient, event, p)
+	r.broadcast(ctx, event, p, HasHostPermission())
+}
+
+// handleStopScreenshare ends a screen share. A sharer stops its own share; a
+// host may stop anyone's. The sharer returns to the participant role through
+// transitionRole, so it is back in participants with a fresh draw order
+// element, receives EventRoleChanged, and is included in participant
+// broadcasts again. A sharing host simply stops sharing and stays a host. The
+// room is then told whose share stopped.
+//
+// Error Handling:
+//   - A sharer always stops its own share; the payload's ClientId is only
+//     read for hosts
+//   - A target that is not sharing is rejected with ErrorCodeNotSharing
+//
+// Parameters:
+//   - client: The sharer, or a host stopping a share
+//   - event: The event type (should be EventStopScreenshare)
+//   - payload: The raw payload naming the client whose share to stop
+func (r *Room) handleStopScreenshare(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[StopScreensharePayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+	targetId := p.ClientId
+	if client.Role != RoleTypeHost {
+		targetId = client.ID
+	}
+	if host, sharing := r.sharingHosts[targetId]; sharing {
+		r.deleteScreenshare(host)
+		r.broadcast(ctx, event, StopScreensharePayload(host.info()), nil)
+		return
+	}
+	sharer, sharing := r.sharingScreen[targetId]
+	if !sharing {
+		client.sendError(ErrorCodeNotSharing, "client is not sharing its screen", event)
+		return
+	}
+
+	if err := r.transitionRole(sharer, RoleTypeScreenshare, RoleTypeParticipant); err != nil {
+		slog.Error("Failed to stop screenshare", "error", err, "RequestingClientId", client.ID, "TargetClientId", targetId, "RoomId", r.ID)
+		return
+	}
+	r.broadcast(ctx, event, StopScreensharePayload(sharer.info()), nil)
+}
+
+// handleLeave processes a client's announcement that it is leaving the room intentionally.
+// The client is marked as leaving and its connection is closed; the normal
+// disconnect path then removes it and broadcasts EventParticipantLeft instead
+// of EventDisconnect, so UIs can animate a clean exit differently from a drop.
+//
+// The payload carries no required data and is ignored.
+//
+// Parameters:
+//   - client: The client that is leaving
+//   - event: The event type (should be EventLeave)
+//   - payload: Unused
+func (r *Room) handleLeave(ctx context.Context, client *Client, event Event, payload any) {
+	r.logHelper(true, client.ID, GetFuncName())
+	client.leaving = true
+	if client.conn != nil {
+		client.conn.Close()
+	}
+}
+
+// handleKick removes a client from the room at a host's request. The client is
+// taken out of every role at once, so it can send nothing further, and the
+// removal is broadcast to those remaining.
+//
+// Delivery:
+// The removed client is sent EventKicked through closeWithNotice, which has its
+// write pump write the notice and then a policy-violation close frame before
+// closing. The client therefore always learns it was kicked before the
+// connection drops. The normal disconnect path runs afterwards but announces
+// nothing further.
+//
+// Hosts cannot be kicked, and a host cannot kick itself.
+//
+// Parameters:
+//   - client: The host removing the client
+//   - event: The event type (should be EventKick)
+//   - payload: The raw payload naming the client to remove
+func (r *Room) handleKick(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[KickPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	target, exists := r.findMember(p.ClientId)
+	if !exists && r.forwardAction(client, p.ClientId, event, p) {
+		return
+	}
+	if !exists || target.Role == RoleTypeHost {
+		slog.Warn("Attempted to kick a client that is absent or a host", "RequestingClientId", client.ID, "TargetClientId", p.ClientId, "RoomId", r.ID)
+		return
+	}
+
+	notice := KickPayload{ClientInfo: target.info(), Reason: p.Reason}
+	msg, err := marshalMessage(EventKicked, notice)
+	if err != nil {
+		slog.Error("Failed to marshal kick notice", "error", err, "TargetClientId", target.ID, "RoomId", r.ID)
+		return
+	}
+
+	target.kicked = true
+	r.disconnectClient(target)
+	slog.Info("Client kicked from room", "TargetClientId", target.ID, "KickedByHostId", client.ID, "RoomId", r.ID)
+	r.broadcast(ctx, event, notice, nil)
+	target.closeWithNotice(msg, websocket.ClosePolicyViolation, "kicked")
+}
+
+// handleSetPolicy replaces the room's participant policy and tells everyone,
+// so clients can hide controls the host has disabled. The router enforces the
+// policy on every later message, including from clients admitted afterwards,
+// who receive it in their media state snapshot.
+//
+// Parameters:
+//   - client: The host changing the policy
+//   - event: The event type (should be EventSetPolicy)
+//   - payload: The raw payload containing the new policy
+func (r *Room) handleSetPolicy(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[SetPolicyPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+	r.policy = p.ParticipantPolicy
+	slog.Info("Participant policy changed", "ClientId", client.ID, "RoomId", r.ID, "policy", r.policy)
+	r.broadcast(ctx, event, SetPolicyPayload{ClientInfo: client.info(), ParticipantPolicy: r.policy}, nil)
+}
+
+// handleSetWelcome processes a host replacing the room's welcome message.
+// The message is sanitized before it is stored, and the stored text is
+// broadcast to hosts so every host's settings show the same message. Clients
+// already in the room are not sent the new message; it applies to the next
+// client admitted. An empty message turns the welcome off.
+//
+// Parameters:
+//   - client: The host changing the message
+//   - event: The event type (should be EventSetWelcome)
+//   - payload: The new message
+func (r *Room) handleSetWelcome(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[SetWelcomePayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+	r.welcome = sanitizeNotice(p.Message, maxWelcomeMessageLength)
+	slog.Info("Welcome message changed", "ClientId", client.ID, "RoomId", r.ID, "length", len(r.welcome))
+	r.broadcast(ctx, event, SetWelcomePayload{ClientInfo: client.info(), Message: r.welcome}, HasHostPermission())
+}
+
+// handleTransferHost hands the host role to a participant, and makes the host
+// who sent it a participant. The room is told who holds the role now and,
+// when HostReclaimWindow is set, until when the previous host may undo it.
+//
+// Parameters:
+//   - client: The host giving up the role
+//   - event: The event type (should be EventTransferHost)
+//   - payload: The participant to make host
+func (r *Room) handleTransferHost(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[TransferHostPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok || r.rejectSelfTarget(client, p.ClientId, event) {
+		return
+	}
+
+	target, exists := r.participants[p.ClientId]
+	if !exists {
+		client.sendError(ErrorCodeClientNotFound, "the host role can only be transferred to a participant", event)
+		return
+	}
+	if err := r.transitionRole(target, RoleTypeParticipant, RoleTypeHost); err != nil {
+		slog.Error("Failed to promote host transfer target", "error", err, "TargetClientId", target.ID, "RoomId", r.ID)
+		return
+	}
+	if err := r.transitionRole(client, RoleTypeHost, RoleTypeParticipant); err != nil {
+		slog.Error("Failed to demote transferring host", "error", err, "ClientId", client.ID, "RoomId", r.ID)
+	}
+
+	now := r.config.Clock.Now()
+	r.lastTransfer = &hostTransfer{from: client.ID, to: target.ID, at: now}
+	broadcast := TransferHostPayload{ClientInfo: target.info(), PreviousHostId: client.ID}
+	if r.config.HostReclaimWindow > 0 {
+		broadcast.ReclaimUntil = Timestamp(now.Add(r.config.HostReclaimWindow).UnixMilli())
+	}
+	slog.Info("Host role transferred", "PreviousHostId", client.ID, "NewHostId", target.ID, "RoomId", r.ID)
+	r.broadcast(ctx, event, broadcast, nil)
+}
+
+// handleReclaimHost undoes the room's latest host transfer at the request of
+// the host who made it, provided HostReclaimWindow has not passed. The sender
+// becomes host again and the client it transferred to returns to
+// participant, if it still holds the role. Anyone else, or a request after
+// the window, is answered with ErrorCodeReclaimExpired; a current host can
+// still transfer the role back as usual.
+//
+// Parameters:
+//   - client: The previous host
+//   - event: The event type (should be EventReclaimHost)
+//   - payload: Unused
+func (r *Room) handleReclaimHost(ctx context.Context, client *Client, event Event, payload any) {
+	r.logHelper(true, client.ID, GetFuncName())
+
+	transfer := r.lastTransfer
+	if transfer == nil || transfer.from != client.ID || r.config.HostReclaimWindow <= 0 {
+		client.sendError(ErrorCodeReclaimExpired, "there is no host transfer to undo", event)
+		return
+	}
+	if r.config.Clock.Since(transfer.at) > r.config.HostReclaimWindow {
+		r.lastTransfer = nil
+		client.sendError(ErrorCodeReclaimExpired, "the time to undo the transfer has passed; ask a host to transfer the role back", event)
+		return
+	}
+	if err := r.transitionRole(client, client.Role, RoleTypeHost); err != nil {
+		slog.Error("Failed to restore previous host", "error", err, "ClientId", client.ID, "RoomId", r.ID)
+		return
+	}
+	r.lastTransfer = nil
+
+	reclaimed := ReclaimHostPayload{ClientInfo: client.info()}
+	if demoted, ok := r.hosts[transfer.to]; ok {
+		if err := r.transitionRole(demoted, RoleTypeHost, RoleTypeParticipant); err != nil {
+			slog.Error("Failed to demote host transfer target", "error", err, "TargetClientId", demoted.ID, "RoomId", r.ID)
+		} else {
+			reclaimed.DemotedClientId = demoted.ID
 		}
-	} else {
-		slog.Error("Failed to marshal payload for DenyScreenshare", "error", err)
 	}
-	r.broadcast(event, p, HasHostPermission())
+	slog.Info("Host transfer undone", "ClientId", client.ID, "DemotedClientId", reclaimed.DemotedClientId, "RoomId", r.ID)
+	r.broadcast(ctx, event, reclaimed, nil)
 }
 
 // --- WebRTC Signaling Handlers ---
@@ -596,6 +1463,8 @@ func (r *Room) handleDenyScreenshare(client *Client, event Event, payload any) {
 //   - Only participants can send offers (waiting users cannot)
 //   - Target client must exist in the room
 //   - Both clients must have appropriate permissions
+//   - The sender is stamped from the connection, so a client cannot pose as
+//     another peer; only TargetClientId is taken from the payload
 //
 // Use Cases:
 //   - Initiating video/audio calls between participants
@@ -606,33 +1475,23 @@ func (r *Room) handleDenyScreenshare(client *Client, event Event, payload any) {
 //   - client: The client sending the WebRTC offer
 //   - event: The event type (should be EventOffer)
 //   - payload: The raw payload containing SDP offer and target client ID
-func (r *Room) handleWebRTCOffer(client *Client, event Event, payload any) {
+func (r *Room) handleWebRTCOffer(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[WebRTCOfferPayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
 
-	// Find the target client to send the offer to
-	var targetClient *Client
-	for _, c := range r.participants {
-		if c.ID == p.TargetClientId {
-			targetClient = c
-			break
-		}
-	}
-
-	// Also check hosts in case the target is a host
-	if targetClient == nil {
-		for _, c := range r.hosts {
-			if c.ID == p.TargetClientId {
-				targetClient = c
-				break
-			}
+	// Find the target client among everyone who can signal
+	targetClient, found := r.findPeer(p.TargetClientId)
+	if !found {
+		// A client of another copy of the room is reached over the bus
+		if _, remote := r.remotePeer(p.TargetClientId); remote {
+			p.ClientInfo = client.info()
+			p.Seq = r.nextSignalSeq(peerRoute{from: client.ID, to: p.TargetClientId})
+			r.sendDirect(p.TargetClientId, event, p)
+			return
 		}
-	}
-
-	if targetClient == nil {
 		slog.Warn("WebRTC offer target client not found",
 			"SourceClientId", client.ID,
 			"TargetClientId", p.TargetClientId,
@@ -640,23 +1499,16 @@ func (r *Room) handleWebRTCOffer(client *Client, event Event, payload any) {
 		return
 	}
 
-	// Forward the offer directly to the target client
-	if msg, err := json.Marshal(Message{Event: event, Payload: p}); err == nil {
-		select {
-		case targetClient.send <- msg:
-			slog.Info("WebRTC offer forwarded successfully",
-				"SourceClientId", client.ID,
-				"TargetClientId", p.TargetClientId,
-				"RoomId", r.ID)
-		default:
-			slog.Warn("Failed to forward WebRTC offer - target client channel full",
-				"SourceClientId", client.ID,
-				"TargetClientId", p.TargetClientId,
-				"RoomId", r.ID)
-		}
-	} else {
-		slog.Error("Failed to marshal WebRTC offer", "error", err, "ClientId", client.ID, "RoomId", r.ID)
+	p.ClientInfo = client.info()
+
+	// Resolve simultaneous offers between the same pair when enabled
+	if r.config.GlareDetection && !r.trackOffer(client, targetClient) {
+		return
 	}
+	p.Seq = r.sequenceSignal(peerRoute{from: client.ID, to: targetClient.ID})
+
+	// Forward the offer directly to the target client
+	r.forwardTo(client.ID, targetClient, event, p)
 }
 
 // handleWebRTCAnswer processes WebRTC answers responding to connection offers.
@@ -678,37 +1530,29 @@ func (r *Room) handleWebRTCOffer(client *Client, event Event, payload any) {
 //   - JSON marshalling errors are logged but don't crash the handler
 //   - Channel full scenarios are handled gracefully
 //
+// Like offers, the answer's sender is stamped from the connection.
+//
 // Parameters:
 //   - client: The client sending the WebRTC answer
 //   - event: The event type (should be EventAnswer)
 //   - payload: The raw payload containing SDP answer and target client ID
-func (r *Room) handleWebRTCAnswer(client *Client, event Event, payload any) {
+func (r *Room) handleWebRTCAnswer(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[WebRTCAnswerPayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
 
-	// Find the target client to send the answer to (original offer sender)
-	var targetClient *Client
-	for _, c := range r.participants {
-		if c.ID == p.TargetClientId {
-			targetClient = c
-			break
-		}
-	}
-
-	// Also check hosts
-	if targetClient == nil {
-		for _, c := range r.hosts {
-			if c.ID == p.TargetClientId {
-				targetClient = c
-				break
-			}
+	// Find the target client among everyone who can signal
+	targetClient, found := r.findPeer(p.TargetClientId)
+	if !found {
+		// A client of another copy of the room is reached over the bus
+		if _, remote := r.remotePeer(p.TargetClientId); remote {
+			p.ClientInfo = client.info()
+			p.Seq = r.nextSignalSeq(peerRoute{from: client.ID, to: p.TargetClientId})
+			r.sendDirect(p.TargetClientId, event, p)
+			return
 		}
-	}
-
-	if targetClient == nil {
 		slog.Warn("WebRTC answer target client not found",
 			"SourceClientId", client.ID,
 			"TargetClientId", p.TargetClientId,
@@ -716,23 +1560,13 @@ func (r *Room) handleWebRTCAnswer(client *Client, event Event, payload any) {
 		return
 	}
 
+	// The target's offer to this client is no longer in flight
+	delete(r.pendingOffers, peerRoute{from: targetClient.ID, to: client.ID})
+	p.ClientInfo = client.info()
+	p.Seq = r.sequenceSignal(peerRoute{from: client.ID, to: targetClient.ID})
+
 	// Forward the answer directly to the target client
-	if msg, err := json.Marshal(Message{Event: event, Payload: p}); err == nil {
-		select {
-		case targetClient.send <- msg:
-			slog.Info("WebRTC answer forwarded successfully",
-				"SourceClientId", client.ID,
-				"TargetClientId", p.TargetClientId,
-				"RoomId", r.ID)
-		default:
-			slog.Warn("Failed to forward WebRTC answer - target client channel full",
-				"SourceClientId", client.ID,
-				"TargetClientId", p.TargetClientId,
-				"RoomId", r.ID)
-		}
-	} else {
-		slog.Error("Failed to marshal WebRTC answer", "error", err, "ClientId", client.ID, "RoomId", r.ID)
-	}
+	r.forwardTo(client.ID, targetClient, event, p)
 }
 
 // handleWebRTCCandidate processes ICE candidates for WebRTC connectivity.
@@ -755,37 +1589,33 @@ func (r *Room) handleWebRTCAnswer(client *Client, event Event, payload any) {
 //  3. Forward the candidate directly to the target client
 //  4. Continue until connection is established or fails
 //
+// Batching:
+// When CandidateBatchWindow is configured, candidates are queued per sender and
+// target and delivered together once the window elapses (see queueCandidate).
+// Any other signaling on the same route flushes the queue first, so batching
+// never lets a later offer or answer overtake earlier candidates.
+//
 // Parameters:
 //   - client: The client sending the ICE candidate
 //   - event: The event type (should be EventCandidate)
 //   - payload: The raw payload containing ICE candidate data and target client ID
-func (r *Room) handleWebRTCCandidate(client *Client, event Event, payload any) {
+func (r *Room) handleWebRTCCandidate(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[WebRTCCandidatePayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
 
-	// Find the target client to send the candidate to
-	var targetClient *Client
-	for _, c := range r.participants {
-		if c.ID == p.TargetClientId {
-			targetClient = c
-			break
-		}
-	}
-
-	// Also check hosts
-	if targetClient == nil {
-		for _, c := range r.hosts {
-			if c.ID == p.TargetClientId {
-				targetClient = c
-				break
-			}
+	// Find the target client among everyone who can signal
+	targetClient, found := r.findPeer(p.TargetClientId)
+	if !found {
+		// A client of another copy of the room is reached over the bus
+		if _, remote := r.remotePeer(p.TargetClientId); remote {
+			p.ClientInfo = client.info()
+			p.Seq = r.nextSignalSeq(peerRoute{from: client.ID, to: p.TargetClientId})
+			r.sendDirect(p.TargetClientId, event, p)
+			return
 		}
-	}
-
-	if targetClient == nil {
 		slog.Warn("WebRTC candidate target client not found",
 			"SourceClientId", client.ID,
 			"TargetClientId", p.TargetClientId,
@@ -793,24 +1623,19 @@ func (r *Room) handleWebRTCCandidate(client *Client, event Event, payload any) {
 		return
 	}
 
-	// Forward the candidate directly to the target client
-	if msg, err := json.Marshal(Message{Event: event, Payload: p}); err == nil {
-		select {
-		case targetClient.send <- msg:
-			// Debug level logging for candidates since there can be many
-			slog.Debug("WebRTC candidate forwarded",
-				"SourceClientId", client.ID,
-				"TargetClientId", p.TargetClientId,
-				"RoomId", r.ID)
-		default:
-			slog.Warn("Failed to forward WebRTC candidate - target client channel full",
-				"SourceClientId", client.ID,
-				"TargetClientId", p.TargetClientId,
-				"RoomId", r.ID)
-		}
-	} else {
-		slog.Error("Failed to marshal WebRTC candidate", "error", err, "ClientId", client.ID, "RoomId", r.ID)
+	// Stamped before batching so batched candidates keep their send order
+	// and carry their real sender
+	p.ClientInfo = client.info()
+	p.Seq = r.nextSignalSeq(peerRoute{from: client.ID, to: targetClient.ID})
+
+	// Coalesce bursts of candidates into batches when throttling is enabled
+	if r.config.CandidateBatchWindow > 0 {
+		r.queueCandidate(client, p)
+		return
 	}
+
+	// Forward the candidate directly to the target client
+	r.forwardTo(client.ID, targetClient, event, p)
 }
 
 // handleWebRTCRenegotiate processes requests to renegotiate WebRTC connections.
@@ -838,56 +1663,359 @@ func (r *Room) handleWebRTCCandidate(client *Client, event Event, payload any) {
 //   - client: The client requesting renegotiation
 //   - event: The event type (should be EventRenegotiate)
 //   - payload: The raw payload containing renegotiation request and target client ID
-func (r *Room) handleWebRTCRenegotiate(client *Client, event Event, payload any) {
+func (r *Room) handleWebRTCRenegotiate(ctx context.Context, client *Client, event Event, payload any) {
 	p, ok := assertPayload[WebRTCRenegotiatePayload](payload)
-	logHelper(ok, client.ID, GetFuncName(), r.ID)
+	r.logHelper(ok, client.ID, GetFuncName())
 	if !ok {
 		return
 	}
 
-	// Find the target client to send the renegotiation request to
-	var targetClient *Client
-	for _, c := range r.participants {
-		if c.ID == p.TargetClientId {
-			targetClient = c
-			break
+	// Find the target client among everyone who can signal
+	targetClient, found := r.findPeer(p.TargetClientId)
+	if !found {
+		// A client of another copy of the room is reached over the bus
+		if _, remote := r.remotePeer(p.TargetClientId); remote {
+			p.ClientInfo = client.info()
+			p.Seq = r.nextSignalSeq(peerRoute{from: client.ID, to: p.TargetClientId})
+			r.sendDirect(p.TargetClientId, event, p)
+			return
 		}
+		slog.Warn("WebRTC renegotiate target client not found",
+			"SourceClientId", client.ID,
+			"TargetClientId", p.TargetClientId,
+			"RoomId", r.ID)
+		return
 	}
 
-	// Also check hosts
-	if targetClient == nil {
-		for _, c := range r.hosts {
-			if c.ID == p.TargetClientId {
-				targetClient = c
-				break
-			}
-		}
+	p.ClientInfo = client.info()
+	p.Seq = r.sequenceSignal(peerRoute{from: client.ID, to: targetClient.ID})
+
+	// Forward the renegotiation request directly to the target client
+	r.forwardTo(client.ID, targetClient, event, p)
+}
+
+// handleVideoPause forwards a pause or resume video request to its target.
+// A client that is not rendering a peer's video (e.g. the tile is off screen)
+// asks that peer to stop sending it, and asks again to resume when visible.
+// This is signaling-only: the server forwards the request and tracks nothing.
+//
+// The sender is stamped from the connection rather than trusted from the
+// payload, so a client cannot pause another client's video on someone else's behalf.
+//
+// Parameters:
+//   - client: The client that is not rendering (or is again rendering) the video
+//   - event: EventPauseVideo or EventResumeVideo
+//   - payload: Should be VideoPausePayload with the target's client ID
+func (r *Room) handleVideoPause(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[VideoPausePayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
 	}
 
-	if targetClient == nil {
-		slog.Warn("WebRTC renegotiate target client not found",
+	targetClient, found := r.findPeer(p.TargetClientId)
+	if !found {
+		// A client of another copy of the room is reached over the bus
+		if _, remote := r.remotePeer(p.TargetClientId); remote {
+			p.ClientInfo = client.info()
+			r.sendDirect(p.TargetClientId, event, p)
+			return
+		}
+		slog.Warn("Video pause target client not found",
 			"SourceClientId", client.ID,
 			"TargetClientId", p.TargetClientId,
 			"RoomId", r.ID)
 		return
 	}
 
-	// Forward the renegotiation request directly to the target client
-	if msg, err := json.Marshal(Message{Event: event, Payload: p}); err == nil {
-		select {
-		case targetClient.send <- msg:
-			slog.Info("WebRTC renegotiation request forwarded",
-				"SourceClientId", client.ID,
-				"TargetClientId", p.TargetClientId,
-				"Reason", p.Reason,
-				"RoomId", r.ID)
-		default:
-			slog.Warn("Failed to forward WebRTC renegotiation - target client channel full",
-				"SourceClientId", client.ID,
-				"TargetClientId", p.TargetClientId,
-				"RoomId", r.ID)
-		}
-	} else {
-		slog.Error("Failed to marshal WebRTC renegotiation", "error", err, "ClientId", client.ID, "RoomId", r.ID)
+	p.ClientInfo = client.info()
+	r.forwardTo(client.ID, targetClient, event, p)
+}
+
+// handleIsPresent tells the requester whether a client is still in the call,
+// and its role if so, so the requester can avoid sending an offer to a peer
+// that has already left. Waiting clients count as absent, since they cannot
+// take part in signaling.
+//
+// Thread Safety: This is a query event handled under the room's read lock.
+//
+// Parameters:
+//   - client: The client asking
+//   - event: The event type (should be EventIsPresent)
+//   - payload: Should be IsPresentPayload naming the client asked about
+func (r *Room) handleIsPresent(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[IsPresentPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
 	}
+
+	reply := IsPresentPayload{ClientId: p.ClientId}
+	if peer, found := r.findPeer(p.ClientId); found {
+		reply.Present = true
+		reply.Role = peer.Role
+	} else if member, found := r.remotePeer(p.ClientId); found {
+		reply.Present = true
+		reply.Role = member.Role
+	}
+	r.replyTo(client, event, reply)
+}
+
+// handleRemoteControl relays a request for control of a shared screen from a
+// viewer to the sharer, and the sharer's grant or denial back to the viewer.
+// The server forwards the messages only; the clients carry the control itself.
+//
+// Validation:
+// A request must name a client that is sharing its screen, and an answer must
+// come from one, whether in the screenshare role or a host; otherwise the sender receives ErrorCodeNotSharing. A target
+// that is not in the call, or is the sender, is answered with
+// ErrorCodeClientNotFound. The sender is stamped from the connection, so an
+// answer cannot pose as a different sharer.
+//
+// Parameters:
+//   - client: The viewer requesting control, or the sharer answering
+//   - event: EventRequestRemoteControl, EventGrantRemoteControl or EventDenyRemoteControl
+//   - payload: Should be RemoteControlPayload with the other client's ID
+func (r *Room) handleRemoteControl(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[RemoteControlPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	targetClient, found := r.findPeer(p.TargetClientId)
+	member, remote := r.remotePeer(p.TargetClientId)
+	if (!found && !remote) || targetClient == client {
+		client.sendError(ErrorCodeClientNotFound, "no such client in the call", event)
+		return
+	}
+
+	sharing := r.isSharing(client.ID)
+	if event == EventRequestRemoteControl {
+		sharing = (found && r.isSharing(targetClient.ID)) || (!found && member.Sharing)
+	}
+	if !sharing {
+		slog.Warn("Refused remote control for a client that is not sharing",
+			"event", event, "SourceClientId", client.ID, "TargetClientId", p.TargetClientId, "RoomId", r.ID)
+		client.sendError(ErrorCodeNotSharing, "remote control needs a client sharing its screen", event)
+		return
+	}
+
+	p.ClientInfo = client.info()
+	if !found {
+		r.sendDirect(p.TargetClientId, event, p)
+		return
+	}
+	r.forwardTo(client.ID, targetClient, event, p)
+}
+
+// --- Validation Mode ---
+
+// payloadValidator is implemented by payload types that carry their own
+// business-rule validation, such as ChatInfo.
+type payloadValidator interface {
+	Validate() error
+}
+
+// checkPayload asserts that a payload has the type T expected by a handler and,
+// if rules is set and T implements payloadValidator, runs its business-rule validation.
+// It performs no state changes and is safe to call from the validation mode.
+func checkPayload[T any](payload any, rules bool) error {
+	p, ok := assertPayload[T](payload)
+	if !ok {
+		return fmt.Errorf("payload does not match expected type %T", p)
+	}
+	if !rules {
+		return nil
+	}
+	if v, ok := any(p).(payloadValidator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// checkPayloadSize rejects a payload whose encoding is larger than the room
+// allows for its event. Payloads arrive already decoded, so they are
+// re-encoded to measure them; one that cannot be encoded is left for
+// checkEventPayload to reject.
+//
+// Returns:
+//   - error: Describing the limit, or nil if the payload fits or is unbounded
+func (r *Room) checkPayloadSize(event Event, payload any) error {
+	limit, ok := r.maxPayloadSizes[event]
+	if !ok {
+		return nil
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	if len(raw) > limit {
+		return fmt.Errorf("%s payload is %d bytes; the limit is %d", event, len(raw), limit)
+	}
+	return nil
+}
+
+// checkEventPayload checks a payload against the type the event's handler expects.
+// When rules is false only the type is checked; the router uses this to reject
+// malformed payloads before dispatch without changing what the handlers accept.
+func checkEventPayload(event Event, payload any, rules bool) error {
+	switch event {
+	case EventAddChat:
+		return checkPayload[AddChatPayload](payload, rules)
+	case EventDeleteChat:
+		return checkPayload[DeleteChatPayload](payload, rules)
+	case EventDeleteUserChats:
+		return checkPayload[DeleteUserChatsPayload](payload, rules)
+	case EventPinChat, EventUnpinChat:
+		return checkPayload[PinChatPayload](payload, rules)
+	case EventGetRecentChats:
+		return checkPayload[GetRecentChatsPayload](payload, rules)
+	case EventGetChatsByRange:
+		return checkPayload[GetChatsByRangePayload](payload, rules)
+	case EventRequestSpeak:
+		return checkPayload[RequestSpeakPayload](payload, rules)
+	case EventGrantSpeak:
+		return checkPayload[GrantSpeakPayload](payload, rules)
+	case EventRevokeSpeak:
+		return checkPayload[RevokeSpeakPayload](payload, rules)
+	case EventRaiseHand:
+		return checkPayload[RaiseHandPayload](payload, rules)
+	case EventLowerHand:
+		return checkPayload[LowerHandPayload](payload, rules)
+	case EventLowerAllHands:
+		return nil
+	case EventReaction:
+		return checkPayload[ReactionPayload](payload, rules)
+	case EventActiveSpeaker:
+		return checkPayload[ActiveSpeakerPayload](payload, rules)
+	case EventMediaState:
+		return checkPayload[MediaStatePayload](payload, rules)
+	case EventCaption:
+		return checkPayload[CaptionPayload](payload, rules)
+	case EventSpotlight:
+		return checkPayload[SpotlightPayload](payload, rules)
+	case EventClearSpotlight:
+		return nil
+	case EventConnectionStats:
+		return checkPayload[ConnectionStatsPayload](payload, rules)
+	case EventConnectionReport:
+		return nil
+	case EventRequestWaiting:
+		return checkPayload[RequestWaitingPayload](payload, rules)
+	case EventAcceptWaiting:
+		return checkPayload[AcceptWaitingPayload](payload, rules)
+	case EventDenyWaiting:
+		return checkPayload[DenyWaitingPayload](payload, rules)
+	case EventRequestScreenshare:
+		return checkPayload[RequestScreensharePayload](payload, rules)
+	case EventAcceptScreenshare:
+		return checkPayload[AcceptScreensharePayload](payload, rules)
+	case EventDenyScreenshare:
+		return checkPayload[DenyScreensharePayload](payload, rules)
+	case EventStopScreenshare:
+		return checkPayload[StopScreensharePayload](payload, rules)
+	case EventRequestRemoteControl, EventGrantRemoteControl, EventDenyRemoteControl:
+		return checkPayload[RemoteControlPayload](payload, rules)
+	case EventOffer:
+		return checkPayload[WebRTCOfferPayload](payload, rules)
+	case EventAnswer:
+		return checkPayload[WebRTCAnswerPayload](payload, rules)
+	case EventCandidate:
+		return checkPayload[WebRTCCandidatePayload](payload, rules)
+	case EventRenegotiate:
+		return checkPayload[WebRTCRenegotiatePayload](payload, rules)
+	case EventPauseVideo, EventResumeVideo:
+		return checkPayload[VideoPausePayload](payload, rules)
+	case EventIsPresent:
+		return checkPayload[IsPresentPayload](payload, rules)
+	case EventLeave:
+		return nil
+	case EventKick:
+		return checkPayload[KickPayload](payload, rules)
+	case EventSetPolicy:
+		return checkPayload[SetPolicyPayload](payload, rules)
+	case EventSetWelcome:
+		return checkPayload[SetWelcomePayload](payload, rules)
+	case EventTransferHost:
+		return checkPayload[TransferHostPayload](payload, rules)
+	case EventReclaimHost:
+		return nil
+	case EventRestoreSession:
+		return checkPayload[RestoreSessionPayload](payload, rules)
+	case EventValidate:
+		return checkPayload[ValidatePayload](payload, rules)
+	default:
+		return fmt.Errorf("event %q cannot be validated", event)
+	}
+}
+
+// handleRestoreSession answers a reconnected client with its role, the room
+// state and recent chats in one message, so it can rebuild its view without
+// several round trips that could each see a different room. The client's role
+// was already restored when it reconnected if it was within ReconnectWindow.
+//
+// A draft the client sends is handed back only if the client may still chat,
+// so the UI knows whether to put it back in the composer.
+//
+// Side Effects:
+// None. This is a query event handled under the read lock.
+//
+// Parameters:
+//   - client: The reconnected client
+//   - event: The event type (should be EventRestoreSession)
+//   - payload: The request, with any unsent draft
+func (r *Room) handleRestoreSession(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[RestoreSessionPayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	capabilities := r.capabilities(client.Role)
+	restored := SessionRestoredPayload{
+		Role:         client.Role,
+		Capabilities: capabilities,
+		State:        r.roomState(),
+		RecentChats:  []AddChatPayload{},
+	}
+	if r.features.allows(EventGetRecentChats) {
+		restored.RecentChats = r.getRecentChats(GetRecentChatsPayload{})
+	}
+	if slices.Contains(capabilities, EventAddChat) {
+		restored.Draft = p.Draft
+	}
+
+	r.replyTo(client, event, restored)
+}
+
+// handleValidate processes dry-run requests used by client developers to debug payloads.
+// The wrapped message goes through the router's checks (see checkEvent) and the
+// target handler's payload validation, and the outcome is sent back to the
+// requester only.
+//
+// Side Effects:
+// None. The wrapped message is never dispatched, so room state is not mutated
+// and nothing is broadcast to other clients.
+//
+// Parameters:
+//   - client: The client requesting the validation
+//   - event: The event type (should be EventValidate)
+//   - payload: The wrapped Message to validate
+func (r *Room) handleValidate(ctx context.Context, client *Client, event Event, payload any) {
+	p, ok := assertPayload[ValidatePayload](payload)
+	r.logHelper(ok, client.ID, GetFuncName())
+	if !ok {
+		return
+	}
+
+	result := ValidationResultPayload{Event: p.Event, Ok: true}
+	if p.Event == EventValidate {
+		result.Errors = append(result.Errors, "validation requests cannot be nested")
+	} else if rejection := r.checkEvent(client.Role, p.Event, p.Payload, true); rejection != nil {
+		result.Errors = append(result.Errors, rejection.err.Error())
+	}
+	result.Ok = len(result.Errors) == 0
+
+	r.replyTo(client, EventValidationResult, result)
 }
@@ -1,13 +1,20 @@
 package session
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	testclock "k8s.io/utils/clock/testing"
 	"k8s.io/utils/set"
 )
 
@@ -43,7 +50,7 @@ func TestHandleAddChat(t *testing.T) {
 
 		// Test that router processes the message without panic
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic for valid chat message")
 
 		// Verify chat was added (check chat history length)
@@ -74,7 +81,7 @@ func TestHandleAddChat(t *testing.T) {
 
 		// Should not panic but should not add the message
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic even with invalid data")
 
 		// Chat should not be added due to validation failure
@@ -103,11 +110,59 @@ func TestHandleAddChat(t *testing.T) {
 		initialChatCount := room.chatHistory.Len()
 
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic with empty content")
 
 		assert.Equal(t, initialChatCount, room.chatHistory.Len(), "Empty chat should not be added")
 	})
+
+	t.Run("server assigns unique ChatIds and echoes them", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+
+		// Both clients claim the same id; neither claim is trusted
+		for i := range 5 {
+			room.router(context.Background(), alice, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: alice.info(), ChatId: "chat-1", ChatContent: ChatContent(fmt.Sprintf("alice %d", i))}})
+			room.router(context.Background(), bob, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: bob.info(), ChatId: "chat-1", ChatContent: ChatContent(fmt.Sprintf("bob %d", i))}})
+		}
+
+		require.Equal(t, 10, room.chatHistory.Len())
+		stored := make(map[ChatId]bool)
+		for e := room.chatHistory.Front(); e != nil; e = e.Next() {
+			id := e.Value.(ChatInfo).ChatId
+			assert.NotEqual(t, ChatId("chat-1"), id, "The client's id is overwritten")
+			assert.NotEmpty(t, id)
+			stored[id] = true
+		}
+		assert.Len(t, stored, 10, "Every message gets a distinct id")
+
+		broadcast := make(map[ChatId]bool)
+		for len(bob.send) > 0 {
+			var msg struct {
+				Event   Event    `json:"event"`
+				Payload ChatInfo `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(<-bob.send, &msg))
+			if msg.Event == EventAddChat {
+				broadcast[msg.Payload.ChatId] = true
+			}
+		}
+		assert.Equal(t, stored, broadcast, "The broadcast carries the stored ids")
+	})
+
+	t.Run("omitted ChatId is accepted", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		alice := newTestClientWithName("alice", "Alice")
+		room.addParticipant(alice)
+
+		room.router(context.Background(), alice, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: alice.info(), ChatContent: "no id"}})
+
+		require.Equal(t, 1, room.chatHistory.Len())
+		assert.NotEmpty(t, room.chatHistory.Front().Value.(ChatInfo).ChatId)
+	})
 }
 
 // TestHandleDeleteChat tests the chat message deletion handler
@@ -135,7 +190,7 @@ func TestHandleDeleteChat(t *testing.T) {
 		}
 
 		addMsg := Message{Event: EventAddChat, Payload: addPayload}
-		room.router(addClient, addMsg)
+		room.router(context.Background(), addClient, addMsg)
 
 		initialChatCount := room.chatHistory.Len()
 		require.True(t, initialChatCount > 0, "Chat should be added first")
@@ -154,12 +209,203 @@ func TestHandleDeleteChat(t *testing.T) {
 		deleteMsg := Message{Event: EventDeleteChat, Payload: deletePayload}
 
 		assert.NotPanics(t, func() {
-			room.router(client, deleteMsg)
+			room.router(context.Background(), client, deleteMsg)
 		}, "Router should not panic for delete chat")
 	})
 }
 
 // TestHandleGetRecentChats tests the chat history retrieval handler
+func TestHandleDeleteUserChats(t *testing.T) {
+	// setup fills a room's history with messages from a spammer and from alice.
+	setup := func() (*Room, *Client, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host", "Host")
+		spammer := newTestClientWithName("spammer", "Spammer")
+		alice := newTestClientWithName("alice", "Alice")
+		room.addHost(host)
+		room.addParticipant(spammer)
+		room.addParticipant(alice)
+		for i, sender := range []*Client{spammer, alice, spammer, spammer, alice} {
+			room.router(context.Background(), sender, Message{Event: EventAddChat, Payload: AddChatPayload{
+				ClientInfo:  sender.info(),
+				ChatContent: ChatContent(fmt.Sprintf("message %d", i)),
+			}})
+		}
+		for _, c := range []*Client{host, spammer, alice} {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+		return room, host, spammer, alice
+	}
+
+	authors := func(room *Room) []ClientIdType {
+		var ids []ClientIdType
+		for e := room.chatHistory.Front(); e != nil; e = e.Next() {
+			ids = append(ids, e.Value.(AddChatPayload).ClientId)
+		}
+		return ids
+	}
+
+	deleteSpam := Message{Event: EventDeleteUserChats, Payload: DeleteUserChatsPayload{ClientInfo: ClientInfo{ClientId: "spammer"}}}
+
+	t.Run("removes every message from the client and keeps the rest", func(t *testing.T) {
+		room, host, _, alice := setup()
+
+		room.router(context.Background(), host, deleteSpam)
+
+		assert.Equal(t, []ClientIdType{"alice", "alice"}, authors(room))
+
+		require.Len(t, alice.send, 1, "A single bulk notice should be broadcast")
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-alice.send, &msg))
+		assert.Equal(t, EventDeleteUserChats, msg.Event)
+		var notice DeleteUserChatsPayload
+		require.NoError(t, json.Unmarshal(msg.Payload, &notice))
+		assert.Equal(t, ClientIdType("spammer"), notice.ClientId)
+		assert.Equal(t, []ChatId{"1", "3", "4"}, notice.ChatIds)
+	})
+
+	t.Run("a client with no messages changes nothing", func(t *testing.T) {
+		room, host, _, alice := setup()
+
+		room.router(context.Background(), host, Message{Event: EventDeleteUserChats, Payload: DeleteUserChatsPayload{ClientInfo: ClientInfo{ClientId: "nobody"}}})
+
+		assert.Len(t, authors(room), 5)
+		assert.Empty(t, alice.send, "Nothing should be broadcast")
+	})
+
+	t.Run("participants cannot bulk delete", func(t *testing.T) {
+		room, _, _, alice := setup()
+
+		room.router(context.Background(), alice, deleteSpam)
+
+		assert.Len(t, authors(room), 5)
+	})
+}
+
+func TestPinnedChats(t *testing.T) {
+	// setup gives a room a host, a participant and three messages with ids 1-3.
+	setup := func() (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host", "Host")
+		alice := newTestClientWithName("alice", "Alice")
+		room.addHost(host)
+		room.addParticipant(alice)
+		for i := range 3 {
+			room.router(context.Background(), alice, Message{Event: EventAddChat, Payload: AddChatPayload{
+				ClientInfo:  alice.info(),
+				ChatContent: ChatContent(fmt.Sprintf("message %d", i)),
+			}})
+		}
+		for _, c := range []*Client{host, alice} {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+		return room, host, alice
+	}
+
+	pin := func(room *Room, host *Client, event Event, chatId ChatId) {
+		room.router(context.Background(), host, Message{Event: event, Payload: PinChatPayload{ChatId: chatId}})
+	}
+
+	lastEvent := func(t *testing.T, c *Client) wireMessage {
+		t.Helper()
+		var last []byte
+		for len(c.send) > 0 {
+			last = <-c.send
+		}
+		require.NotNil(t, last, "%s received nothing", c.ID)
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(last, &msg))
+		return msg
+	}
+
+	t.Run("pinning is broadcast and shown in the room state", func(t *testing.T) {
+		room, host, alice := setup()
+
+		pin(room, host, EventPinChat, "3")
+		pin(room, host, EventPinChat, "1")
+
+		msg := lastEvent(t, alice)
+		require.Equal(t, EventPinChat, msg.Event)
+		var notice PinChatPayload
+		require.NoError(t, json.Unmarshal(msg.Payload, &notice))
+		assert.Equal(t, PinChatPayload{ClientInfo: host.info(), ChatId: "1"}, notice)
+
+		assert.Equal(t, []ChatId{"1", "3"}, room.getRoomState().PinnedChatIds, "Pinned ids are listed oldest first")
+	})
+
+	t.Run("pinning twice broadcasts once", func(t *testing.T) {
+		room, host, alice := setup()
+
+		pin(room, host, EventPinChat, "2")
+		pin(room, host, EventPinChat, "2")
+
+		assert.Len(t, alice.send, 1)
+		assert.Equal(t, []ChatId{"2"}, room.getRoomState().PinnedChatIds)
+	})
+
+	t.Run("unknown messages cannot be pinned", func(t *testing.T) {
+		room, host, alice := setup()
+
+		pin(room, host, EventPinChat, "42")
+
+		msg := lastEvent(t, host)
+		require.Equal(t, EventError, msg.Event)
+		var rejection ErrorPayload
+		require.NoError(t, json.Unmarshal(msg.Payload, &rejection))
+		assert.Equal(t, ErrorCodeChatNotFound, rejection.Code)
+		assert.Empty(t, alice.send)
+		assert.Empty(t, room.getRoomState().PinnedChatIds)
+	})
+
+	t.Run("unpinning is broadcast", func(t *testing.T) {
+		room, host, alice := setup()
+		pin(room, host, EventPinChat, "2")
+
+		pin(room, host, EventUnpinChat, "2")
+
+		assert.Equal(t, EventUnpinChat, lastEvent(t, alice).Event)
+		assert.Empty(t, room.getRoomState().PinnedChatIds)
+
+		pin(room, host, EventUnpinChat, "2")
+		assert.Empty(t, alice.send, "Unpinning a message that is not pinned broadcasts nothing")
+	})
+
+	t.Run("participants cannot pin", func(t *testing.T) {
+		room, _, alice := setup()
+
+		pin(room, alice, EventPinChat, "1")
+
+		assert.Empty(t, room.getRoomState().PinnedChatIds)
+	})
+
+	t.Run("deleted messages are unpinned", func(t *testing.T) {
+		room, host, alice := setup()
+		pin(room, host, EventPinChat, "1")
+		pin(room, host, EventPinChat, "2")
+
+		room.router(context.Background(), alice, Message{Event: EventDeleteChat, Payload: DeleteChatPayload{ClientInfo: alice.info(), ChatId: "1"}})
+		assert.Equal(t, []ChatId{"2"}, room.getRoomState().PinnedChatIds)
+
+		room.router(context.Background(), host, Message{Event: EventDeleteUserChats, Payload: DeleteUserChatsPayload{ClientInfo: ClientInfo{ClientId: alice.ID}}})
+		assert.Empty(t, room.getRoomState().PinnedChatIds)
+	})
+
+	t.Run("messages evicted from the history are unpinned", func(t *testing.T) {
+		room, host, alice := setup()
+		room.maxChatHistoryLength = 3
+		pin(room, host, EventPinChat, "1")
+
+		room.router(context.Background(), alice, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: alice.info(), ChatContent: "one more"}})
+
+		assert.Empty(t, room.getRoomState().PinnedChatIds)
+		assert.Empty(t, room.pinnedChats, "Evicted ids should not linger")
+	})
+}
+
 func TestHandleGetRecentChats(t *testing.T) {
 	t.Run("should send recent chats successfully", func(t *testing.T) {
 		room := NewTestRoom("test-room", nil)
@@ -178,7 +424,7 @@ func TestHandleGetRecentChats(t *testing.T) {
 			Timestamp:   1234567890,
 			ChatContent: "First message",
 		}
-		room.router(client, Message{Event: EventAddChat, Payload: chatPayload1})
+		room.router(context.Background(), client, Message{Event: EventAddChat, Payload: chatPayload1})
 
 		chatPayload2 := AddChatPayload{
 			ClientInfo: ClientInfo{
@@ -189,7 +435,7 @@ func TestHandleGetRecentChats(t *testing.T) {
 			Timestamp:   1234567891,
 			ChatContent: "Second message",
 		}
-		room.router(client, Message{Event: EventAddChat, Payload: chatPayload2})
+		room.router(context.Background(), client, Message{Event: EventAddChat, Payload: chatPayload2})
 
 		require.True(t, room.chatHistory.Len() >= 2, "Should have chat history")
 
@@ -212,7 +458,7 @@ func TestHandleGetRecentChats(t *testing.T) {
 		msg := Message{Event: EventGetRecentChats, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic for get recent chats")
 
 		// Check if client received the chat history
@@ -240,7 +486,7 @@ func TestHandleGetRecentChats(t *testing.T) {
 		msg := Message{Event: EventGetRecentChats, Payload: "invalid"}
 
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic with invalid payload")
 	})
 
@@ -266,12 +512,207 @@ func TestHandleGetRecentChats(t *testing.T) {
 
 		// This should trigger the "channel full" warning path
 		assert.NotPanics(t, func() {
-			room.handleGetRecentChats(client, EventGetRecentChats, payload)
+			room.handleGetRecentChats(context.Background(), client, EventGetRecentChats, payload)
 		}, "handleGetRecentChats should not panic when client channel is full")
 	})
 }
 
 // TestHandleRequestWaiting tests the waiting room request handler
+func TestChatServerTimestamps(t *testing.T) {
+	start := time.UnixMilli(1_700_000_000_000)
+	fakeClock := testclock.NewFakeClock(start)
+	config := DefaultRoomConfig()
+	config.Clock = fakeClock
+	room := NewRoomWithConfig("test-room", config, nil)
+	client := newTestClientWithName("participant1", "Test User")
+	room.addParticipant(client)
+
+	send := func(id ChatId, clientTimestamp Timestamp) {
+		room.router(context.Background(), client, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+			ChatId:      id,
+			Timestamp:   clientTimestamp,
+			ChatContent: "message",
+		}})
+	}
+
+	send("chat-1", 9_999_999_999_999) // Far in the future
+	fakeClock.Step(time.Second)
+	send("chat-2", 1) // Far in the past
+	fakeClock.SetTime(start.Add(-time.Minute))
+	send("chat-3", 0) // Server clock stepped backwards
+
+	history := room.getChatHistory()
+	require.Len(t, history, 3)
+	assert.Equal(t, Timestamp(start.UnixMilli()), history[0].Timestamp, "Client timestamps are ignored")
+	assert.Equal(t, Timestamp(start.Add(time.Second).UnixMilli()), history[1].Timestamp)
+	assert.Equal(t, history[1].Timestamp, history[2].Timestamp, "Timestamps never go backwards")
+
+	// Broadcasts carry the server timestamp too
+	var msg struct {
+		Payload ChatInfo `json:"payload"`
+	}
+	require.NotEmpty(t, client.send)
+	require.NoError(t, json.Unmarshal(<-client.send, &msg))
+	assert.Equal(t, history[0].Timestamp, msg.Payload.Timestamp)
+}
+
+func TestChatHistoryAge(t *testing.T) {
+	setup := func(maxAge time.Duration, maxCount int) (*Room, *testclock.FakeClock, *Client) {
+		fakeClock := testclock.NewFakeClock(time.UnixMilli(1_700_000_000_000))
+		config := DefaultRoomConfig()
+		config.Clock = fakeClock
+		config.MaxChatHistoryAge = maxAge
+		config.MaxChatHistory = maxCount
+		room := NewRoomWithConfig("test-room", config, nil)
+		client := newTestClientWithName("participant1", "Test User")
+		room.addParticipant(client)
+		return room, fakeClock, client
+	}
+
+	send := func(room *Room, c *Client, content ChatContent) {
+		room.router(context.Background(), c, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  c.info(),
+			ChatContent: content,
+		}})
+	}
+
+	contents := func(room *Room) []ChatContent {
+		room.mu.RLock()
+		defer room.mu.RUnlock()
+		var got []ChatContent
+		for _, chat := range room.getChatHistory() {
+			got = append(got, chat.ChatContent)
+		}
+		return got
+	}
+
+	t.Run("messages are evicted once they reach the age limit", func(t *testing.T) {
+		room, fakeClock, client := setup(time.Hour, 100)
+
+		send(room, client, "old")
+		fakeClock.Step(30 * time.Minute)
+		send(room, client, "recent")
+		assert.Equal(t, []ChatContent{"old", "recent"}, contents(room))
+
+		fakeClock.Step(31 * time.Minute)
+		assert.Equal(t, []ChatContent{"recent"}, contents(room), "The sweep should evict without another message arriving")
+
+		fakeClock.Step(30 * time.Minute)
+		assert.Empty(t, contents(room))
+	})
+
+	t.Run("adding a message evicts expired ones", func(t *testing.T) {
+		room, fakeClock, client := setup(time.Hour, 100)
+		send(room, client, "old")
+
+		// Move the clock without firing the sweep, as if it were still pending
+		fakeClock.SetTime(fakeClock.Now().Add(2 * time.Hour))
+		send(room, client, "new")
+
+		assert.Equal(t, []ChatContent{"new"}, contents(room))
+	})
+
+	t.Run("expired messages are unpinned", func(t *testing.T) {
+		room, fakeClock, client := setup(time.Hour, 100)
+		send(room, client, "old")
+		room.mu.Lock()
+		room.pinChat(room.getChatHistory()[0].ChatId)
+		room.mu.Unlock()
+
+		fakeClock.Step(time.Hour + chatExpirySlack)
+
+		assert.Empty(t, room.getRoomState().PinnedChatIds)
+	})
+
+	t.Run("the count limit still applies", func(t *testing.T) {
+		room, _, client := setup(time.Hour, 2)
+
+		send(room, client, "one")
+		send(room, client, "two")
+		send(room, client, "three")
+
+		assert.Equal(t, []ChatContent{"two", "three"}, contents(room))
+	})
+
+	t.Run("zero keeps messages regardless of age", func(t *testing.T) {
+		room, fakeClock, client := setup(0, 100)
+
+		send(room, client, "old")
+		fakeClock.Step(24 * time.Hour)
+		send(room, client, "new")
+
+		assert.Equal(t, []ChatContent{"old", "new"}, contents(room))
+	})
+}
+
+func TestHandleGetChatsByRange(t *testing.T) {
+	setup := func() (*Room, *Client) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClientWithName("participant1", "Test User")
+		room.addParticipant(client)
+		for i, ts := range []Timestamp{100, 200, 300, 400} {
+			room.addChat(AddChatPayload{
+				ClientInfo:  ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+				ChatId:      ChatId(fmt.Sprintf("chat-%d", i)),
+				Timestamp:   ts,
+				ChatContent: "message",
+			})
+		}
+		return room, client
+	}
+	fetch := func(t *testing.T, room *Room, client *Client, from, to Timestamp) []ChatInfo {
+		t.Helper()
+		room.router(context.Background(), client, Message{Event: EventGetChatsByRange, Payload: GetChatsByRangePayload{
+			ClientInfo:    ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+			FromTimestamp: from,
+			ToTimestamp:   to,
+		}})
+		require.Len(t, client.send, 1, "Result should go to the requester")
+		var msg struct {
+			Event   Event      `json:"event"`
+			Payload []ChatInfo `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-client.send, &msg))
+		assert.Equal(t, EventGetChatsByRange, msg.Event)
+		return msg.Payload
+	}
+	timestamps := func(chats []ChatInfo) []Timestamp {
+		out := make([]Timestamp, 0, len(chats))
+		for _, chat := range chats {
+			out = append(out, chat.Timestamp)
+		}
+		return out
+	}
+
+	t.Run("range selects the messages within it inclusively", func(t *testing.T) {
+		room, client := setup()
+		assert.Equal(t, []Timestamp{200, 300}, timestamps(fetch(t, room, client, 200, 300)))
+	})
+
+	t.Run("range with no messages is empty", func(t *testing.T) {
+		room, client := setup()
+		assert.Empty(t, fetch(t, room, client, 500, 900))
+	})
+
+	t.Run("inverted range is empty", func(t *testing.T) {
+		room, client := setup()
+		assert.Empty(t, fetch(t, room, client, 400, 100))
+	})
+
+	t.Run("results are clamped", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		room.maxChatHistoryLength = maxChatRangeResults * 2
+		for i := 0; i < maxChatRangeResults+10; i++ {
+			room.addChat(AddChatPayload{ChatId: ChatId(fmt.Sprintf("chat-%d", i)), Timestamp: Timestamp(i), ChatContent: "message"})
+		}
+
+		chats := room.getChatsByRange(0, Timestamp(maxChatRangeResults+10))
+		require.Len(t, chats, maxChatRangeResults)
+		assert.Equal(t, Timestamp(0), chats[0].Timestamp, "Clamping keeps the start of the range")
+	})
+}
+
 func TestHandleRequestWaiting(t *testing.T) {
 	t.Run("should request waiting successfully", func(t *testing.T) {
 		room := NewTestRoom("test-room", nil)
@@ -289,7 +730,7 @@ func TestHandleRequestWaiting(t *testing.T) {
 		}
 
 		assert.NotPanics(t, func() {
-			room.router(client, Message{Event: EventRequestWaiting, Payload: payload})
+			room.router(context.Background(), client, Message{Event: EventRequestWaiting, Payload: payload})
 		}, "Router should not panic for request waiting")
 
 		// Debug: Check if host is in the room
@@ -308,6 +749,26 @@ func TestHandleRequestWaiting(t *testing.T) {
 		}
 	})
 
+	t.Run("hosts see the waiting client's profile from its token", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClientWithName("waiting1", "Waiting User")
+		client.AvatarURL = "https://example.com/avatar.png"
+		client.Pronouns = "she/her"
+		host := newTestClientWithName("host1", "Host User")
+		room.addHost(host)
+		room.addWaiting(client)
+
+		room.router(context.Background(), client, Message{Event: EventRequestWaiting, Payload: RequestWaitingPayload{ClientId: client.ID}})
+
+		require.Len(t, host.send, 1)
+		var msg struct {
+			Event   Event      `json:"event"`
+			Payload ClientInfo `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-host.send, &msg))
+		assert.Equal(t, client.info(), msg.Payload)
+	})
+
 	t.Run("should handle invalid payload", func(t *testing.T) {
 		room := NewTestRoom("test-room", nil)
 		client := newTestClientWithName("waiting1", "Waiting User")
@@ -315,7 +776,7 @@ func TestHandleRequestWaiting(t *testing.T) {
 		invalidPayload := "invalid payload"
 
 		assert.NotPanics(t, func() {
-			room.router(client, Message{Event: EventRequestWaiting, Payload: invalidPayload})
+			room.router(context.Background(), client, Message{Event: EventRequestWaiting, Payload: invalidPayload})
 		}, "Router should not panic for invalid payload")
 
 		// Should not receive any message due to invalid payload
@@ -535,7 +996,7 @@ func TestHandlerErrorPaths(t *testing.T) {
 
 		// Call handler directly with invalid payload to hit error path
 		assert.NotPanics(t, func() {
-			room.handleAddChat(client, EventAddChat, "invalid payload")
+			room.handleAddChat(context.Background(), client, EventAddChat, "invalid payload")
 		}, "handleAddChat should not panic with invalid payload")
 	})
 
@@ -546,7 +1007,7 @@ func TestHandlerErrorPaths(t *testing.T) {
 		room.addParticipant(client)
 
 		assert.NotPanics(t, func() {
-			room.handleDeleteChat(client, EventDeleteChat, "invalid payload")
+			room.handleDeleteChat(context.Background(), client, EventDeleteChat, "invalid payload")
 		}, "handleDeleteChat should not panic with invalid payload")
 	})
 
@@ -557,7 +1018,7 @@ func TestHandlerErrorPaths(t *testing.T) {
 		room.addParticipant(client)
 
 		assert.NotPanics(t, func() {
-			room.handleRaiseHand(client, EventRaiseHand, "invalid payload")
+			room.handleRaiseHand(context.Background(), client, EventRaiseHand, "invalid payload")
 		}, "handleRaiseHand should not panic with invalid payload")
 	})
 
@@ -568,7 +1029,7 @@ func TestHandlerErrorPaths(t *testing.T) {
 		room.addParticipant(client)
 
 		assert.NotPanics(t, func() {
-			room.handleLowerHand(client, EventLowerHand, "invalid payload")
+			room.handleLowerHand(context.Background(), client, EventLowerHand, "invalid payload")
 		}, "handleLowerHand should not panic with invalid payload")
 	})
 
@@ -579,7 +1040,7 @@ func TestHandlerErrorPaths(t *testing.T) {
 		room.addParticipant(client)
 
 		assert.NotPanics(t, func() {
-			room.handleRequestScreenshare(client, EventRequestScreenshare, "invalid payload")
+			room.handleRequestScreenshare(context.Background(), client, EventRequestScreenshare, "invalid payload")
 		}, "handleRequestScreenshare should not panic with invalid payload")
 	})
 
@@ -590,7 +1051,7 @@ func TestHandlerErrorPaths(t *testing.T) {
 		room.addHost(client)
 
 		assert.NotPanics(t, func() {
-			room.handleAcceptScreenshare(client, EventAcceptScreenshare, "invalid payload")
+			room.handleAcceptScreenshare(context.Background(), client, EventAcceptScreenshare, "invalid payload")
 		}, "handleAcceptScreenshare should not panic with invalid payload")
 	})
 
@@ -601,7 +1062,7 @@ func TestHandlerErrorPaths(t *testing.T) {
 		room.addHost(client)
 
 		assert.NotPanics(t, func() {
-			room.handleDenyScreenshare(client, EventDenyScreenshare, "invalid payload")
+			room.handleDenyScreenshare(context.Background(), client, EventDenyScreenshare, "invalid payload")
 		}, "handleDenyScreenshare should not panic with invalid payload")
 	})
 
@@ -612,7 +1073,7 @@ func TestHandlerErrorPaths(t *testing.T) {
 		room.addHost(client)
 
 		assert.NotPanics(t, func() {
-			room.handleAcceptWaiting(client, EventAcceptWaiting, "invalid payload")
+			room.handleAcceptWaiting(context.Background(), client, EventAcceptWaiting, "invalid payload")
 		}, "handleAcceptWaiting should not panic with invalid payload")
 	})
 
@@ -623,7 +1084,7 @@ func TestHandlerErrorPaths(t *testing.T) {
 		room.addHost(client)
 
 		assert.NotPanics(t, func() {
-			room.handleDenyWaiting(client, EventDenyWaiting, "invalid payload")
+			room.handleDenyWaiting(context.Background(), client, EventDenyWaiting, "invalid payload")
 		}, "handleDenyWaiting should not panic with invalid payload")
 	})
 }
@@ -767,7 +1228,7 @@ func TestBroadcastEdgeCases(t *testing.T) {
 
 		// This should not panic and should handle the marshal error gracefully
 		assert.NotPanics(t, func() {
-			room.broadcast(EventAddChat, unmarshalablePayload, nil)
+			room.broadcast(context.Background(), EventAddChat, unmarshalablePayload, nil)
 		}, "broadcast should handle JSON marshal errors gracefully")
 
 		// Client should not receive any message due to marshal error
@@ -779,6 +1240,34 @@ func TestBroadcastEdgeCases(t *testing.T) {
 		}
 	})
 
+	t.Run("marshal error is logged with the event and payload type", func(t *testing.T) {
+		handler := &capturingHandler{}
+		config := DefaultRoomConfig()
+		config.HandlerLog.Logger = slog.New(handler)
+		room := NewRoomWithConfig("test-room", config, nil)
+		client := newTestClientWithName("test-user", "Test User")
+		room.addParticipant(client)
+
+		err := room.broadcast(context.Background(), EventAddChat, map[string]any{"channel": make(chan int)}, nil)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), string(EventAddChat))
+		assert.Empty(t, client.send)
+
+		require.Len(t, handler.records, 1)
+		record := handler.records[0]
+		assert.Equal(t, slog.LevelError, record.Level)
+		assert.Contains(t, record.Message, "Dropped broadcast")
+		attrs := map[string]string{}
+		record.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value.String()
+			return true
+		})
+		assert.Equal(t, string(EventAddChat), attrs["event"])
+		assert.Equal(t, "map[string]interface {}", attrs["payloadType"])
+		assert.Equal(t, "test-room", attrs["RoomId"])
+	})
+
 	t.Run("broadcast with unknown role type", func(t *testing.T) {
 		room := NewTestRoom("test-room", nil)
 		client := newTestClientWithName("test-user", "Test User")
@@ -792,7 +1281,7 @@ func TestBroadcastEdgeCases(t *testing.T) {
 
 		// This should not panic and should skip the unknown role
 		assert.NotPanics(t, func() {
-			room.broadcast(EventAddChat, validPayload, rolesSet)
+			room.broadcast(context.Background(), EventAddChat, validPayload, rolesSet)
 		}, "broadcast should handle unknown role types gracefully")
 
 		// Client should not receive any message since only unknown role was specified
@@ -818,7 +1307,7 @@ func TestBroadcastEdgeCases(t *testing.T) {
 
 		// This should not panic and should handle the blocked channel
 		assert.NotPanics(t, func() {
-			room.broadcast(EventAddChat, validPayload, nil)
+			room.broadcast(context.Background(), EventAddChat, validPayload, nil)
 		}, "broadcast should handle blocked client channels gracefully")
 	})
 }
@@ -837,7 +1326,7 @@ func TestRoutingEdgeCases(t *testing.T) {
 
 		// Should not panic and should handle gracefully
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should handle unknown event types gracefully")
 	})
 
@@ -857,7 +1346,7 @@ func TestRoutingEdgeCases(t *testing.T) {
 
 		// Should not panic even with unknown role
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should handle unknown roles gracefully")
 	})
 }
@@ -940,7 +1429,7 @@ func TestHandleRequestWaitingDirectCall(t *testing.T) {
 		msg := Message{Event: EventRequestWaiting, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic for request waiting")
 	})
 
@@ -954,7 +1443,7 @@ func TestHandleRequestWaitingDirectCall(t *testing.T) {
 		msg := Message{Event: EventRequestWaiting, Payload: "invalid"}
 
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic with invalid payload")
 	})
 }
@@ -982,7 +1471,7 @@ func TestHandleDenyWaiting(t *testing.T) {
 		msg := Message{Event: EventDenyWaiting, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(host, msg)
+			room.router(context.Background(), host, msg)
 		}, "Router should not panic for deny waiting")
 	})
 
@@ -1007,7 +1496,7 @@ func TestHandleDenyWaiting(t *testing.T) {
 		msg := Message{Event: EventDenyWaiting, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(participant, msg)
+			room.router(context.Background(), participant, msg)
 		}, "Router should not panic even with insufficient permissions")
 	})
 }
@@ -1035,7 +1524,7 @@ func TestHandleDenyScreenshare(t *testing.T) {
 		msg := Message{Event: EventDenyScreenshare, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(host, msg)
+			room.router(context.Background(), host, msg)
 		}, "Router should not panic for deny screenshare")
 	})
 
@@ -1060,7 +1549,7 @@ func TestHandleDenyScreenshare(t *testing.T) {
 		msg := Message{Event: EventDenyScreenshare, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(participant, msg)
+			room.router(context.Background(), participant, msg)
 		}, "Router should not panic even with insufficient permissions")
 	})
 }
@@ -1082,7 +1571,7 @@ func TestHandleRaiseHand(t *testing.T) {
 		msg := Message{Event: EventRaiseHand, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic for raise hand")
 
 		// Verify hand was raised
@@ -1114,152 +1603,612 @@ func TestHandleRaiseHand(t *testing.T) {
 		msg := Message{Event: EventLowerHand, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic for lower hand")
 
 		// Verify hand was lowered
 		_, handRaised := room.raisingHand[client.ID]
 		assert.False(t, handRaised, "Client should not be in raising hand map after lowering")
 	})
-}
 
-// TestHandleWaitingRoomOperations tests waiting room management
-func TestHandleWaitingRoomOperations(t *testing.T) {
-	t.Run("host can accept waiting user", func(t *testing.T) {
+	t.Run("raises beyond the cap are rejected", func(t *testing.T) {
 		room := NewTestRoom("test-room", nil)
+		room.config.MaxRaisedHands = 2
+		clients := make([]*Client, 4)
+		for i := range clients {
+			clients[i] = newTestClient(ClientIdType(fmt.Sprintf("participant%d", i)))
+			room.addParticipant(clients[i])
+		}
 
-		// Create host
-		host := newTestClient("host1")
-		host.Role = RoleTypeHost
-		room.addHost(host)
-
-		// Create waiting user
-		waitingUser := newTestClient("waiting1")
-		waitingUser.Role = RoleTypeWaiting
-		room.addWaiting(waitingUser)
+		for _, c := range clients {
+			room.router(context.Background(), c, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(c.info())})
+			assert.LessOrEqual(t, room.handDrawOrderQueue.Len(), 2, "Queue should never exceed the cap")
+		}
 
-		require.True(t, len(room.waiting) > 0, "Should have waiting user")
+		assert.Len(t, room.raisingHand, 2)
+		assert.Equal(t, []ClientInfo{clients[0].info(), clients[1].info()}, clientInfos(room.raisedHandsInOrder()), "FIFO order is kept")
 
-		// Host accepts waiting user
-		payload := AcceptWaitingPayload{
-			ClientId:    waitingUser.ID,
-			DisplayName: waitingUser.DisplayName,
+		for _, rejected := range clients[2:] {
+			var msg struct {
+				Event   Event        `json:"event"`
+				Payload ErrorPayload `json:"payload"`
+			}
+			var last []byte
+			for len(rejected.send) > 0 {
+				last = <-rejected.send
+			}
+			require.NoError(t, json.Unmarshal(last, &msg))
+			assert.Equal(t, EventError, msg.Event)
+			assert.Equal(t, ErrorCodeHandQueueFull, msg.Payload.Code)
 		}
 
-		msg := Message{Event: EventAcceptWaiting, Payload: payload}
-
-		assert.NotPanics(t, func() {
-			room.router(host, msg)
-		}, "Router should not panic for accept waiting")
+		room.router(context.Background(), clients[0], Message{Event: EventLowerHand, Payload: LowerHandPayload(clients[0].info())})
+		room.router(context.Background(), clients[2], Message{Event: EventRaiseHand, Payload: RaiseHandPayload(clients[2].info())})
+		assert.Contains(t, room.raisingHand, clients[2].ID, "Lowering a hand frees a place")
 	})
 
-	t.Run("participant cannot accept waiting user", func(t *testing.T) {
+	t.Run("raising an already raised hand keeps one queue entry", func(t *testing.T) {
 		room := NewTestRoom("test-room", nil)
+		room.config.MaxRaisedHands = 1
+		client := newTestClient("participant1")
+		room.addParticipant(client)
 
-		// Create participant (not host)
-		participant := newTestClient("participant1")
-		participant.Role = RoleTypeParticipant
-		room.addParticipant(participant)
+		room.router(context.Background(), client, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(client.info())})
+		room.router(context.Background(), client, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(client.info())})
 
-		// Create waiting user
-		waitingUser := newTestClient("waiting1")
-		waitingUser.Role = RoleTypeWaiting
-		room.addWaiting(waitingUser)
+		assert.Equal(t, 1, room.handDrawOrderQueue.Len())
+	})
+}
 
-		payload := AcceptWaitingPayload{
-			ClientId:    waitingUser.ID,
-			DisplayName: waitingUser.DisplayName,
+// TestHandleReaction tests the reaction allowlist
+func TestHandleActiveSpeaker(t *testing.T) {
+	setup := func() (*Room, *testclock.FakeClock, *Client, *Client, *Client) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		room := NewTestRoom("test-room", nil)
+		room.config.Clock = fakeClock
+		room.config.ActiveSpeakerInterval = 250 * time.Millisecond
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		viewer := newTestClientWithName("viewer", "Viewer")
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+		room.addSpectator(viewer)
+		for _, c := range []*Client{alice, bob, viewer} {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+		return room, fakeClock, alice, bob, viewer
+	}
+
+	report := func(room *Room, c *Client, speaking bool) {
+		room.router(context.Background(), c, Message{Event: EventActiveSpeaker, Payload: ActiveSpeakerPayload{Speaking: speaking}})
+	}
+
+	// updates drains a client's active speaker broadcasts
+	updates := func(t *testing.T, c *Client) []ActiveSpeakersPayload {
+		t.Helper()
+		var got []ActiveSpeakersPayload
+		for len(c.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			if msg.Event != EventActiveSpeakers {
+				continue
+			}
+			var p ActiveSpeakersPayload
+			require.NoError(t, json.Unmarshal(msg.Payload, &p))
+			got = append(got, p)
 		}
+		return got
+	}
 
-		msg := Message{Event: EventAcceptWaiting, Payload: payload}
+	t.Run("reports are broadcast to the room", func(t *testing.T) {
+		room, _, alice, _, viewer := setup()
 
-		// This should not panic but should be ignored due to permission check
-		assert.NotPanics(t, func() {
-			room.router(participant, msg)
-		}, "Router should not panic even with insufficient permissions")
+		report(room, alice, true)
 
-		// Waiting user should still be waiting (not moved to participants)
-		_, stillWaiting := room.waiting[waitingUser.ID]
-		assert.True(t, stillWaiting, "User should still be waiting when non-host tries to accept")
+		assert.Equal(t, []ActiveSpeakersPayload{{Speakers: []ClientInfo{alice.info()}, Dominant: alice.ID}}, updates(t, viewer))
 	})
-}
 
-// TestHandleScreenshareOperations tests screen sharing management
-func TestHandleScreenshareOperations(t *testing.T) {
-	t.Run("participant can request screenshare", func(t *testing.T) {
-		room := NewTestRoom("test-room", nil)
+	t.Run("reports that change nothing are ignored", func(t *testing.T) {
+		room, fakeClock, alice, _, viewer := setup()
 
-		participant := newTestClient("participant1")
-		participant.Role = RoleTypeParticipant
-		room.addParticipant(participant)
+		report(room, alice, false)
+		report(room, alice, true)
+		fakeClock.Step(time.Second)
+		report(room, alice, true)
 
-		payload := RequestScreensharePayload{
-			ClientId:    participant.ID,
-			DisplayName: participant.DisplayName,
-		}
+		assert.Len(t, updates(t, viewer), 1)
+	})
 
-		msg := Message{Event: EventRequestScreenshare, Payload: payload}
+	t.Run("changes within the interval are coalesced", func(t *testing.T) {
+		room, fakeClock, alice, bob, viewer := setup()
 
-		assert.NotPanics(t, func() {
-			room.router(participant, msg)
-		}, "Router should not panic for screenshare request")
+		report(room, alice, true)
+		report(room, bob, true)
+		report(room, alice, false)
+		report(room, alice, true)
+		require.Len(t, updates(t, viewer), 1, "Only the first change is broadcast at once")
+
+		fakeClock.Step(250 * time.Millisecond)
+
+		assert.Equal(t, []ActiveSpeakersPayload{{
+			Speakers: []ClientInfo{alice.info(), bob.info()},
+			Dominant: alice.ID,
+		}}, updates(t, viewer), "One broadcast carries the latest state, most recent speaker first")
+		assert.False(t, fakeClock.HasWaiters())
 	})
 
-	t.Run("host can accept screenshare", func(t *testing.T) {
-		room := NewTestRoom("test-room", nil)
+	t.Run("changes after the interval are broadcast at once", func(t *testing.T) {
+		room, fakeClock, alice, _, viewer := setup()
 
-		// Create host
-		host := newTestClient("host1")
-		host.Role = RoleTypeHost
-		room.addHost(host)
+		report(room, alice, true)
+		fakeClock.Step(300 * time.Millisecond)
+		report(room, alice, false)
 
-		// Create participant
-		participant := newTestClient("participant1")
-		participant.Role = RoleTypeParticipant
-		room.addParticipant(participant)
+		assert.Equal(t, []ActiveSpeakersPayload{
+			{Speakers: []ClientInfo{alice.info()}, Dominant: alice.ID},
+			{Speakers: []ClientInfo{}},
+		}, updates(t, viewer))
+	})
 
-		payload := AcceptScreensharePayload{
-			ClientId:    participant.ID,
-			DisplayName: participant.DisplayName,
-		}
+	t.Run("speakers who leave are removed", func(t *testing.T) {
+		room, fakeClock, alice, bob, viewer := setup()
+		report(room, alice, true)
+		report(room, bob, true)
+		fakeClock.Step(time.Second)
+		updates(t, viewer)
 
-		msg := Message{Event: EventAcceptScreenshare, Payload: payload}
+		room.handleClientDisconnect(bob)
 
-		assert.NotPanics(t, func() {
-			room.router(host, msg)
-		}, "Router should not panic for screenshare acceptance")
+		assert.Equal(t, []ActiveSpeakersPayload{{Speakers: []ClientInfo{alice.info()}, Dominant: alice.ID}}, updates(t, viewer))
+	})
+
+	t.Run("spectators cannot report", func(t *testing.T) {
+		room, _, alice, _, viewer := setup()
+
+		report(room, viewer, true)
+
+		assert.Empty(t, updates(t, alice))
 	})
 }
 
-// TestHandlerBroadcasting tests that handlers properly broadcast messages
-func TestHandlerBroadcasting(t *testing.T) {
-	t.Run("chat message is broadcast to all participants", func(t *testing.T) {
-		room := NewTestRoom("test-room", nil)
+// recordingTranscriptStore keeps every caption it is asked to save. If
+// release is set, each save waits for a value from it first.
+type recordingTranscriptStore struct {
+	mu      sync.Mutex
+	saved   []CaptionPayload
+	err     error
+	release chan struct{}
+}
 
-		// Create sender
-		sender := newTestClientWithName("sender", "Sender Name")
-		sender.Role = RoleTypeParticipant
-		room.addParticipant(sender)
+func (s *recordingTranscriptStore) SaveCaption(ctx context.Context, roomId RoomIdType, caption CaptionPayload) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, caption)
+	return s.err
+}
 
-		// Create receiver
-		receiver := newTestClientWithName("receiver", "Receiver Name")
-		receiver.Role = RoleTypeParticipant
-		room.addParticipant(receiver)
+// Saved returns the captions saved so far.
+func (s *recordingTranscriptStore) Saved() []CaptionPayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.saved)
+}
 
-		payload := AddChatPayload{
-			ClientInfo: ClientInfo{
-				ClientId:    sender.ID,
-				DisplayName: sender.DisplayName,
-			},
-			ChatId:      "broadcast-test",
-			Timestamp:   1234567890,
-			ChatContent: "Hello everyone!",
+func TestHandleCaption(t *testing.T) {
+	setup := func() (*Room, *testclock.FakeClock, *Client, *Client) {
+		fakeClock := testclock.NewFakeClock(time.UnixMilli(1_700_000_000_000))
+		room := NewTestRoom("test-room", nil)
+		room.config.Clock = fakeClock
+		alice := newTestClientWithName("alice", "Alice")
+		viewer := newTestClientWithName("viewer", "Viewer")
+		room.addParticipant(alice)
+		room.addSpectator(viewer)
+		for len(viewer.send) > 0 {
+			<-viewer.send
 		}
+		return room, fakeClock, alice, viewer
+	}
+
+	caption := func(room *Room, c *Client, text string) {
+		room.router(context.Background(), c, Message{Event: EventCaption, Payload: CaptionPayload{
+			ClientInfo: ClientInfo{ClientId: "someone-else"},
+			Text:       text,
+			Timestamp:  1,
+		}})
+	}
+
+	t.Run("captions are broadcast and stored with the speaker stamped", func(t *testing.T) {
+		room, _, alice, viewer := setup()
+		store := &recordingTranscriptStore{}
+		room.config.TranscriptStore = store
+
+		caption(room, alice, "hello everyone")
+
+		want := CaptionPayload{ClientInfo: alice.info(), Text: "hello everyone", Timestamp: 1_700_000_000_000}
+		require.Len(t, viewer.send, 1)
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-viewer.send, &msg))
+		assert.Equal(t, EventCaption, msg.Event)
+		var got CaptionPayload
+		require.NoError(t, json.Unmarshal(msg.Payload, &got))
+		assert.Equal(t, want, got, "Spectators receive captions too")
+
+		assert.Eventually(t, func() bool { return slices.Equal([]CaptionPayload{want}, store.Saved()) }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, 1, room.transcript.Len())
+	})
 
-		msg := Message{Event: EventAddChat, Payload: payload}
+	t.Run("captions are relayed when the store fails", func(t *testing.T) {
+		room, _, alice, viewer := setup()
+		room.config.TranscriptStore = &recordingTranscriptStore{err: errors.New("database unavailable")}
+
+		caption(room, alice, "hello")
+
+		assert.Len(t, viewer.send, 1)
+	})
+
+	t.Run("a slow store does not hold up captions", func(t *testing.T) {
+		room, fakeClock, alice, viewer := setup()
+		store := &recordingTranscriptStore{release: make(chan struct{})}
+		room.config.TranscriptStore = store
+
+		caption(room, alice, "one")
+		fakeClock.Step(time.Millisecond)
+		caption(room, alice, "two")
+		assert.Len(t, viewer.send, 2, "Both captions are relayed while the first is still being saved")
+
+		close(store.release)
+		assert.Eventually(t, func() bool { return len(store.Saved()) == 2 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, "one", store.Saved()[0].Text, "Captions are saved in order")
+	})
+
+	t.Run("the transcript is bounded", func(t *testing.T) {
+		room, fakeClock, alice, _ := setup()
+		room.config.MaxTranscript = 2
+
+		for _, text := range []string{"one", "two", "three"} {
+			caption(room, alice, text)
+			fakeClock.Step(time.Millisecond)
+		}
+
+		var texts []string
+		for e := room.transcript.Front(); e != nil; e = e.Next() {
+			texts = append(texts, e.Value.(CaptionPayload).Text)
+		}
+		assert.Equal(t, []string{"two", "three"}, texts)
+	})
+
+	t.Run("empty and oversized captions are dropped", func(t *testing.T) {
+		room, _, alice, viewer := setup()
+
+		caption(room, alice, "   ")
+		caption(room, alice, strings.Repeat("a", 1001))
+
+		assert.Empty(t, viewer.send)
+		assert.Nil(t, room.transcript)
+	})
+
+	t.Run("spectators cannot send captions", func(t *testing.T) {
+		room, _, alice, viewer := setup()
+
+		caption(room, viewer, "hello")
+
+		assert.Empty(t, alice.send)
+		assert.Nil(t, room.transcript)
+	})
+}
+
+func TestHandleReaction(t *testing.T) {
+	// lastMessage drains a client's send channel and decodes the final message
+	lastMessage := func(t *testing.T, c *Client) (Event, json.RawMessage) {
+		t.Helper()
+		var last []byte
+		for len(c.send) > 0 {
+			last = <-c.send
+		}
+		require.NotNil(t, last, "%s received nothing", c.ID)
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(last, &msg))
+		return msg.Event, msg.Payload
+	}
+
+	t.Run("allowed reaction is broadcast with the sender stamped", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+
+		room.router(context.Background(), alice, Message{Event: EventReaction, Payload: ReactionPayload{ClientInfo: ClientInfo{ClientId: "bob"}, Emoji: "👍"}})
+
+		event, raw := lastMessage(t, bob)
+		require.Equal(t, EventReaction, event)
+		var reaction ReactionPayload
+		require.NoError(t, json.Unmarshal(raw, &reaction))
+		assert.Equal(t, ReactionPayload{ClientInfo: alice.info(), Emoji: "👍"}, reaction)
+	})
+
+	t.Run("reaction outside the default set is rejected", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+		for len(bob.send) > 0 {
+			<-bob.send
+		}
+
+		room.router(context.Background(), alice, Message{Event: EventReaction, Payload: ReactionPayload{Emoji: "<script>alert(1)</script>"}})
+
+		event, raw := lastMessage(t, alice)
+		require.Equal(t, EventError, event)
+		var rejection ErrorPayload
+		require.NoError(t, json.Unmarshal(raw, &rejection))
+		assert.Equal(t, ErrorCodeReactionBlocked, rejection.Code)
+		assert.Empty(t, bob.send, "Nothing is broadcast")
+	})
+
+	t.Run("configured allowlist replaces the default", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		room.config.AllowedReactions = set.New("🎉")
+		alice := newTestClientWithName("alice", "Alice")
+		room.addParticipant(alice)
+
+		room.router(context.Background(), alice, Message{Event: EventReaction, Payload: ReactionPayload{Emoji: "🎉"}})
+		event, _ := lastMessage(t, alice)
+		assert.Equal(t, EventReaction, event)
+
+		room.router(context.Background(), alice, Message{Event: EventReaction, Payload: ReactionPayload{Emoji: "👍"}})
+		event, _ = lastMessage(t, alice)
+		assert.Equal(t, EventError, event, "Default reactions are no longer allowed")
+	})
+
+	t.Run("spectators may react", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		viewer := newTestClientWithName("viewer", "Viewer")
+		room.addSpectator(viewer)
+
+		room.router(context.Background(), viewer, Message{Event: EventReaction, Payload: ReactionPayload{Emoji: "👏"}})
+
+		event, _ := lastMessage(t, viewer)
+		assert.Equal(t, EventReaction, event)
+	})
+}
+
+// TestParticipantPolicy tests host-configured participant restrictions
+func TestParticipantPolicy(t *testing.T) {
+	// events drains a client's send channel and returns the events received
+	events := func(c *Client) []Event {
+		var got []Event
+		for len(c.send) > 0 {
+			var msg wireMessage
+			if json.Unmarshal(<-c.send, &msg) == nil {
+				got = append(got, msg.Event)
+			}
+		}
+		return got
+	}
+	setPolicy := func(room *Room, host *Client, policy ParticipantPolicy) {
+		room.router(context.Background(), host, Message{Event: EventSetPolicy, Payload: SetPolicyPayload{ClientInfo: host.info(), ParticipantPolicy: policy}})
+	}
+	requestScreenshare := func(room *Room, c *Client) {
+		room.router(context.Background(), c, Message{Event: EventRequestScreenshare, Payload: RequestScreensharePayload(c.info())})
+	}
+
+	t.Run("toggling the screenshare policy blocks and allows requests", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host", "Host")
+		participant := newTestClientWithName("participant", "Participant")
+		room.addHost(host)
+		room.addParticipant(participant)
+
+		policy := DefaultParticipantPolicy()
+		policy.AllowScreenshare = false
+		setPolicy(room, host, policy)
+		assert.Contains(t, events(participant), EventSetPolicy, "Participants learn the new policy")
+		events(host)
+
+		requestScreenshare(room, participant)
+		assert.Empty(t, events(host), "The request never reaches the host")
+		assert.Equal(t, []Event{EventError}, events(participant))
+
+		setPolicy(room, host, DefaultParticipantPolicy())
+		events(host)
+		requestScreenshare(room, participant)
+		assert.Equal(t, []Event{EventRequestScreenshare}, events(host))
+	})
+
+	t.Run("hosts are not restricted", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host", "Host")
+		room.addHost(host)
+		setPolicy(room, host, ParticipantPolicy{})
+
+		room.router(context.Background(), host, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: host.info(), ChatContent: "still here"}})
+
+		assert.Equal(t, 1, room.chatHistory.Len())
+	})
+
+	t.Run("participants cannot set the policy", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		participant := newTestClientWithName("participant", "Participant")
+		room.addParticipant(participant)
+
+		setPolicy(room, participant, ParticipantPolicy{})
+
+		assert.Equal(t, DefaultParticipantPolicy(), room.policy)
+	})
+
+	t.Run("newly admitted participants inherit the policy", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host", "Host")
+		room.addHost(host)
+		setPolicy(room, host, ParticipantPolicy{AllowScreenshare: true})
+
+		late := newTestClientWithName("late", "Late")
+		room.addWaiting(late)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload(late.info())})
+
+		var changed wireMessage
+		require.NoError(t, json.Unmarshal(<-late.send, &changed))
+		require.Equal(t, EventRoleChanged, changed.Event)
+
+		var snapshot struct {
+			Event   Event                     `json:"event"`
+			Payload MediaStateSnapshotPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-late.send, &snapshot))
+		require.Equal(t, EventMediaStateSnapshot, snapshot.Event)
+		assert.Equal(t, ParticipantPolicy{AllowScreenshare: true}, snapshot.Payload.Policy)
+		events(late)
+
+		room.router(context.Background(), late, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: late.info(), ChatContent: "hi"}})
+		assert.Equal(t, 0, room.chatHistory.Len(), "Chat is disabled for the late joiner too")
+		assert.Equal(t, []Event{EventError}, events(late))
+	})
+}
+
+// TestHandleWaitingRoomOperations tests waiting room management
+func TestHandleWaitingRoomOperations(t *testing.T) {
+	t.Run("host can accept waiting user", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+
+		// Create host
+		host := newTestClient("host1")
+		host.Role = RoleTypeHost
+		room.addHost(host)
+
+		// Create waiting user
+		waitingUser := newTestClient("waiting1")
+		waitingUser.Role = RoleTypeWaiting
+		room.addWaiting(waitingUser)
+
+		require.True(t, len(room.waiting) > 0, "Should have waiting user")
+
+		// Host accepts waiting user
+		payload := AcceptWaitingPayload{
+			ClientId:    waitingUser.ID,
+			DisplayName: waitingUser.DisplayName,
+		}
+
+		msg := Message{Event: EventAcceptWaiting, Payload: payload}
+
+		assert.NotPanics(t, func() {
+			room.router(context.Background(), host, msg)
+		}, "Router should not panic for accept waiting")
+	})
+
+	t.Run("participant cannot accept waiting user", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+
+		// Create participant (not host)
+		participant := newTestClient("participant1")
+		participant.Role = RoleTypeParticipant
+		room.addParticipant(participant)
+
+		// Create waiting user
+		waitingUser := newTestClient("waiting1")
+		waitingUser.Role = RoleTypeWaiting
+		room.addWaiting(waitingUser)
+
+		payload := AcceptWaitingPayload{
+			ClientId:    waitingUser.ID,
+			DisplayName: waitingUser.DisplayName,
+		}
+
+		msg := Message{Event: EventAcceptWaiting, Payload: payload}
+
+		// This should not panic but should be ignored due to permission check
+		assert.NotPanics(t, func() {
+			room.router(context.Background(), participant, msg)
+		}, "Router should not panic even with insufficient permissions")
+
+		// Waiting user should still be waiting (not moved to participants)
+		_, stillWaiting := room.waiting[waitingUser.ID]
+		assert.True(t, stillWaiting, "User should still be waiting when non-host tries to accept")
+	})
+}
+
+// TestHandleScreenshareOperations tests screen sharing management
+func TestHandleScreenshareOperations(t *testing.T) {
+	t.Run("participant can request screenshare", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+
+		participant := newTestClient("participant1")
+		participant.Role = RoleTypeParticipant
+		room.addParticipant(participant)
+
+		payload := RequestScreensharePayload{
+			ClientId:    participant.ID,
+			DisplayName: participant.DisplayName,
+		}
+
+		msg := Message{Event: EventRequestScreenshare, Payload: payload}
+
+		assert.NotPanics(t, func() {
+			room.router(context.Background(), participant, msg)
+		}, "Router should not panic for screenshare request")
+	})
+
+	t.Run("host can accept screenshare", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+
+		// Create host
+		host := newTestClient("host1")
+		host.Role = RoleTypeHost
+		room.addHost(host)
+
+		// Create participant
+		participant := newTestClient("participant1")
+		participant.Role = RoleTypeParticipant
+		room.addParticipant(participant)
+
+		payload := AcceptScreensharePayload{
+			ClientId:    participant.ID,
+			DisplayName: participant.DisplayName,
+		}
+
+		msg := Message{Event: EventAcceptScreenshare, Payload: payload}
+
+		assert.NotPanics(t, func() {
+			room.router(context.Background(), host, msg)
+		}, "Router should not panic for screenshare acceptance")
+	})
+}
+
+// TestHandlerBroadcasting tests that handlers properly broadcast messages
+func TestHandlerBroadcasting(t *testing.T) {
+	t.Run("chat message is broadcast to all participants", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+
+		// Create sender
+		sender := newTestClientWithName("sender", "Sender Name")
+		sender.Role = RoleTypeParticipant
+		room.addParticipant(sender)
+
+		// Create receiver
+		receiver := newTestClientWithName("receiver", "Receiver Name")
+		receiver.Role = RoleTypeParticipant
+		room.addParticipant(receiver)
+
+		payload := AddChatPayload{
+			ClientInfo: ClientInfo{
+				ClientId:    sender.ID,
+				DisplayName: sender.DisplayName,
+			},
+			ChatId:      "broadcast-test",
+			Timestamp:   1234567890,
+			ChatContent: "Hello everyone!",
+		}
+
+		msg := Message{Event: EventAddChat, Payload: payload}
 
 		// Send the chat message
-		room.router(sender, msg)
+		room.router(context.Background(), sender, msg)
 
 		// Check if receiver got the broadcast
 		select {
@@ -1329,18 +2278,96 @@ func TestLogHelper(t *testing.T) {
 	t.Run("logHelper with successful operation", func(t *testing.T) {
 		// This will log an info message, just test it doesn't panic
 		assert.NotPanics(t, func() {
-			logHelper(true, "test-client", "TestMethod", "test-room")
+			NewTestRoom("test-room", nil).logHelper(true, "test-client", "TestMethod")
 		}, "logHelper should not panic with successful operation")
 	})
 
 	t.Run("logHelper with failed operation", func(t *testing.T) {
 		// This will log an error message, just test it doesn't panic
 		assert.NotPanics(t, func() {
-			logHelper(false, "test-client", "TestMethod", "test-room")
+			NewTestRoom("test-room", nil).logHelper(false, "test-client", "TestMethod")
 		}, "logHelper should not panic with failed operation")
 	})
 }
 
+// capturingHandler is a slog.Handler that records every log record it receives.
+type capturingHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *capturingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *capturingHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *capturingHandler) WithGroup(string) slog.Handler            { return h }
+func (h *capturingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+// levels returns the level of every captured record.
+func (h *capturingHandler) levels() []slog.Level {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	levels := make([]slog.Level, len(h.records))
+	for i, r := range h.records {
+		levels[i] = r.Level
+	}
+	return levels
+}
+
+// TestHandlerLogSampling tests that successful handler logs honor the room's HandlerLogConfig
+func TestHandlerLogSampling(t *testing.T) {
+	newLoggedRoom := func(cfg HandlerLogConfig) (*Room, *Client) {
+		config := DefaultRoomConfig()
+		config.HandlerLog = cfg
+		room := NewRoomWithConfig("test-room", config, nil)
+		client := newTestClientWithName("participant1", "John Doe")
+		room.addParticipant(client)
+		return room, client
+	}
+
+	chatMsg := func(client *Client, i int) Message {
+		return Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+			ChatId:      ChatId(fmt.Sprintf("chat-%d", i)),
+			ChatContent: "hello",
+		}}
+	}
+
+	t.Run("only one in N successful calls is logged", func(t *testing.T) {
+		handler := &capturingHandler{}
+		room, client := newLoggedRoom(HandlerLogConfig{Logger: slog.New(handler), SuccessSampleRate: 10})
+
+		for i := 0; i < 100; i++ {
+			room.router(context.Background(), client, chatMsg(client, i))
+		}
+
+		assert.Len(t, handler.levels(), 10, "Exactly one in ten successful calls should be logged")
+	})
+
+	t.Run("success level is configurable", func(t *testing.T) {
+		handler := &capturingHandler{}
+		room, client := newLoggedRoom(HandlerLogConfig{Logger: slog.New(handler), SuccessLevel: slog.LevelDebug})
+
+		room.router(context.Background(), client, chatMsg(client, 0))
+
+		assert.Equal(t, []slog.Level{slog.LevelDebug}, handler.levels())
+	})
+
+	t.Run("failures are never sampled", func(t *testing.T) {
+		handler := &capturingHandler{}
+		room, client := newLoggedRoom(HandlerLogConfig{Logger: slog.New(handler), SuccessSampleRate: 1000})
+
+		for i := 0; i < 3; i++ {
+			room.router(context.Background(), client, Message{Event: EventAddChat, Payload: "not a chat payload"})
+		}
+
+		assert.Equal(t, []slog.Level{slog.LevelError, slog.LevelError, slog.LevelError}, handler.levels())
+	})
+}
+
 // TestGetRecentChats tests the room method directly
 func TestGetRecentChats(t *testing.T) {
 	t.Run("should return recent chats", func(t *testing.T) {
@@ -1658,7 +2685,7 @@ func TestHandleRequestWaitingEdgeCases(t *testing.T) {
 
 		// This should broadcast to hosts without error
 		assert.NotPanics(t, func() {
-			room.handleRequestWaiting(waitingClient, EventRequestWaiting, payload)
+			room.handleRequestWaiting(context.Background(), waitingClient, EventRequestWaiting, payload)
 		}, "handleRequestWaiting should broadcast without panic")
 	})
 
@@ -1668,7 +2695,7 @@ func TestHandleRequestWaitingEdgeCases(t *testing.T) {
 
 		// Test with wrong payload type
 		assert.NotPanics(t, func() {
-			room.handleRequestWaiting(waitingClient, EventRequestWaiting, "invalid_payload")
+			room.handleRequestWaiting(context.Background(), waitingClient, EventRequestWaiting, "invalid_payload")
 		}, "handleRequestWaiting should handle invalid payload gracefully")
 	})
 
@@ -1678,7 +2705,7 @@ func TestHandleRequestWaitingEdgeCases(t *testing.T) {
 
 		// Test with nil payload
 		assert.NotPanics(t, func() {
-			room.handleRequestWaiting(waitingClient, EventRequestWaiting, nil)
+			room.handleRequestWaiting(context.Background(), waitingClient, EventRequestWaiting, nil)
 		}, "handleRequestWaiting should handle nil payload gracefully")
 	})
 }
@@ -1812,7 +2839,7 @@ func TestHandleGetRecentChatsAdditionalEdgeCases(t *testing.T) {
 
 		// This should handle any marshal issues gracefully
 		assert.NotPanics(t, func() {
-			room.handleGetRecentChats(client, EventGetRecentChats, getPayload)
+			room.handleGetRecentChats(context.Background(), client, EventGetRecentChats, getPayload)
 		}, "handleGetRecentChats should handle large content gracefully")
 	})
 }
@@ -1831,7 +2858,7 @@ func TestRouterAdditionalEdgeCases(t *testing.T) {
 
 		// Should handle gracefully without panic
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should handle nonexistent events gracefully")
 	})
 }
@@ -1851,7 +2878,7 @@ func TestHandleAcceptScreenshareEdgeCases(t *testing.T) {
 
 		// Should handle non-existent client gracefully
 		assert.NotPanics(t, func() {
-			room.handleAcceptScreenshare(host, EventAcceptScreenshare, payload)
+			room.handleAcceptScreenshare(context.Background(), host, EventAcceptScreenshare, payload)
 		}, "handleAcceptScreenshare should handle non-existent client gracefully")
 	})
 
@@ -1872,7 +2899,7 @@ func TestHandleAcceptScreenshareEdgeCases(t *testing.T) {
 
 		// Should find the participant and add them to screenshare
 		assert.NotPanics(t, func() {
-			room.handleAcceptScreenshare(host, EventAcceptScreenshare, payload)
+			room.handleAcceptScreenshare(context.Background(), host, EventAcceptScreenshare, payload)
 		}, "handleAcceptScreenshare should handle existing client")
 
 		// Verify client was added to screenshare
@@ -1896,7 +2923,7 @@ func TestHandleDenyScreenshareEdgeCases(t *testing.T) {
 
 		// Should handle non-existent client gracefully
 		assert.NotPanics(t, func() {
-			room.handleDenyScreenshare(host, EventDenyScreenshare, payload)
+			room.handleDenyScreenshare(context.Background(), host, EventDenyScreenshare, payload)
 		}, "handleDenyScreenshare should handle non-existent client gracefully")
 	})
 
@@ -1917,7 +2944,7 @@ func TestHandleDenyScreenshareEdgeCases(t *testing.T) {
 
 		// Should find the participant and send them a denial message
 		assert.NotPanics(t, func() {
-			room.handleDenyScreenshare(host, EventDenyScreenshare, payload)
+			room.handleDenyScreenshare(context.Background(), host, EventDenyScreenshare, payload)
 		}, "handleDenyScreenshare should handle existing client")
 
 		// Verify participant received the denial message
@@ -2102,7 +3129,7 @@ func TestHandleGetRecentChatsAdditionalCoverage(t *testing.T) {
 
 		// This should successfully marshal and send
 		assert.NotPanics(t, func() {
-			room.handleGetRecentChats(client, EventGetRecentChats, getPayload)
+			room.handleGetRecentChats(context.Background(), client, EventGetRecentChats, getPayload)
 		}, "handleGetRecentChats should handle normal case without panic")
 
 		// Should have sent a message
@@ -2132,7 +3159,7 @@ func TestHandleGetRecentChatsAdditionalCoverage(t *testing.T) {
 
 		// This should handle the full channel gracefully (default case in select)
 		assert.NotPanics(t, func() {
-			room.handleGetRecentChats(client, EventGetRecentChats, getPayload)
+			room.handleGetRecentChats(context.Background(), client, EventGetRecentChats, getPayload)
 		}, "handleGetRecentChats should handle full channel gracefully")
 	})
 }
@@ -2157,7 +3184,7 @@ func TestRouterPermissionEdgeCases(t *testing.T) {
 		initialChatCount := room.chatHistory.Len()
 
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should handle unauthorized actions gracefully")
 
 		// Chat should not be added
@@ -2183,7 +3210,7 @@ func TestRouterPermissionEdgeCases(t *testing.T) {
 		initialWaitingCount := len(room.waiting)
 
 		assert.NotPanics(t, func() {
-			room.router(participant, msg)
+			room.router(context.Background(), participant, msg)
 		}, "Router should handle unauthorized host actions gracefully")
 
 		// Waiting user should still be waiting
@@ -2201,7 +3228,1663 @@ func TestRouterPermissionEdgeCases(t *testing.T) {
 		invalidData := "not-a-message"
 
 		assert.NotPanics(t, func() {
-			room.router(client, invalidData)
+			room.router(context.Background(), client, invalidData)
 		}, "Router should handle invalid message type gracefully")
 	})
 }
+
+// TestHandleValidate tests the dry-run validation mode
+func TestHandleValidate(t *testing.T) {
+	// readValidationResult reads the next message from the client and decodes it as a validation result
+	readValidationResult := func(t *testing.T, client *Client) ValidationResultPayload {
+		select {
+		case msgBytes := <-client.send:
+			var msg struct {
+				Event   Event                   `json:"event"`
+				Payload ValidationResultPayload `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(msgBytes, &msg))
+			require.Equal(t, EventValidationResult, msg.Event)
+			return msg.Payload
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("Client did not receive validation result")
+		}
+		return ValidationResultPayload{}
+	}
+
+	t.Run("malformed chat payload returns errors without touching chat history", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClientWithName("participant1", "John Doe")
+		room.addParticipant(client)
+		other := newTestClientWithName("participant2", "Jane Doe")
+		room.addParticipant(other)
+
+		inner := Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo: ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+			ChatId:     "chat-1",
+			// Empty content is invalid
+		}}
+		room.router(context.Background(), client, Message{Event: EventValidate, Payload: inner})
+
+		result := readValidationResult(t, client)
+		assert.False(t, result.Ok)
+		assert.Equal(t, EventAddChat, result.Event)
+		require.Len(t, result.Errors, 1)
+		assert.Contains(t, result.Errors[0], "chat content cannot be empty")
+
+		assert.Equal(t, 0, room.chatHistory.Len(), "Validation must not add to chat history")
+		assert.Len(t, other.send, 0, "Validation must not broadcast to other clients")
+	})
+
+	t.Run("valid chat payload is reported ok without being added", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClientWithName("participant1", "John Doe")
+		room.addParticipant(client)
+
+		inner := Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+			ChatId:      "chat-1",
+			ChatContent: "Hello world!",
+		}}
+		room.router(context.Background(), client, Message{Event: EventValidate, Payload: inner})
+
+		result := readValidationResult(t, client)
+		assert.True(t, result.Ok)
+		assert.Empty(t, result.Errors)
+		assert.Equal(t, 0, room.chatHistory.Len(), "Validation must not add to chat history")
+	})
+
+	t.Run("permission failures are reported", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		participant := newTestClientWithName("participant1", "John Doe")
+		room.addParticipant(participant)
+		waitingUser := newTestClientWithName("waiting1", "Waiting User")
+		room.addWaiting(waitingUser)
+
+		inner := Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: waitingUser.ID}}
+		room.router(context.Background(), participant, Message{Event: EventValidate, Payload: inner})
+
+		result := readValidationResult(t, participant)
+		assert.False(t, result.Ok)
+		require.Len(t, result.Errors, 1)
+		assert.Contains(t, result.Errors[0], "not permitted")
+		assert.Contains(t, room.waiting, waitingUser.ID, "Validation must not admit the waiting user")
+	})
+
+	t.Run("unknown and nested events are rejected", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClientWithName("participant1", "John Doe")
+		room.addParticipant(client)
+
+		room.router(context.Background(), client, Message{Event: EventValidate, Payload: Message{Event: "not_an_event"}})
+		result := readValidationResult(t, client)
+		assert.False(t, result.Ok)
+		assert.Contains(t, result.Errors[0], "unknown event")
+
+		room.router(context.Background(), client, Message{Event: EventValidate, Payload: Message{Event: EventValidate}})
+		result = readValidationResult(t, client)
+		assert.False(t, result.Ok)
+		assert.Contains(t, result.Errors[0], "cannot be nested")
+	})
+
+	t.Run("waiting client can validate", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		waitingUser := newTestClientWithName("waiting1", "Waiting User")
+		room.addWaiting(waitingUser)
+
+		inner := Message{Event: EventRequestWaiting, Payload: RequestWaitingPayload{ClientId: waitingUser.ID}}
+		room.router(context.Background(), waitingUser, Message{Event: EventValidate, Payload: inner})
+
+		result := readValidationResult(t, waitingUser)
+		assert.True(t, result.Ok)
+	})
+
+	t.Run("messages the router would refuse are reported", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			configure func(*Room)
+			want      string
+		}{
+			{"outside the endpoint's scope", func(r *Room) { r.config.AllowedEvents = set.New(EventValidate, EventRaiseHand) }, "not available on this endpoint"},
+			{"a disabled feature", func(r *Room) { r.features.ChatEnabled = false }, "disabled feature"},
+			{"forbidden by the participant policy", func(r *Room) { r.policy.AllowChat = false }, "participant policy forbids"},
+			{"a payload over its size limit", func(r *Room) { r.maxPayloadSizes = map[Event]int{EventAddChat: 16} }, "the limit is 16"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				room := NewTestRoom("test-room", nil)
+				tt.configure(room)
+				client := newTestClientWithName("participant1", "John Doe")
+				room.addParticipant(client)
+
+				inner := Message{Event: EventAddChat, Payload: AddChatPayload{
+					ClientInfo:  ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+					ChatId:      "chat-1",
+					ChatContent: "Hello world!",
+				}}
+				room.router(context.Background(), client, Message{Event: EventValidate, Payload: inner})
+
+				result := readValidationResult(t, client)
+				assert.False(t, result.Ok)
+				require.Len(t, result.Errors, 1)
+				assert.Contains(t, result.Errors[0], tt.want)
+				assert.Empty(t, client.send, "Validation reports the rejection without sending an error")
+				assert.Equal(t, 0, room.chatHistory.Len())
+			})
+		}
+	})
+}
+
+// TestRoomLimits tests enforcement of the capacity, chat rate and idle limits in RoomConfig
+func TestRoomLimits(t *testing.T) {
+	newLimitedRoom := func(configure func(*RoomConfig)) (*Room, *testclock.FakeClock) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultRoomConfig()
+		config.Clock = fakeClock
+		configure(&config)
+		return NewRoomWithConfig("test-room", config, nil), fakeClock
+	}
+
+	// readError decodes the next message to a client as an EventError.
+	readError := func(t *testing.T, client *Client) ErrorPayload {
+		t.Helper()
+		require.NotEmpty(t, client.send)
+		var msg struct {
+			Event   Event        `json:"event"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-client.send, &msg))
+		require.Equal(t, EventError, msg.Event)
+		return msg.Payload
+	}
+
+	t.Run("host cannot admit waiting users into a full room", func(t *testing.T) {
+		room, _ := newLimitedRoom(func(c *RoomConfig) { c.MaxParticipants = 2 })
+		host := newTestClientWithName("host1", "Host")
+		room.handleClientConnect(host)
+		first := newTestClientWithName("waiting1", "First")
+		second := newTestClientWithName("waiting2", "Second")
+		room.handleClientConnect(first)
+		room.handleClientConnect(second)
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: first.ID}})
+		for len(host.send) > 0 {
+			<-host.send
+		}
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: second.ID}})
+
+		assert.Contains(t, room.participants, first.ID)
+		assert.Contains(t, room.waiting, second.ID, "Second user should remain waiting")
+		assert.Equal(t, ErrorCodeRoomFull, readError(t, host).Code)
+	})
+
+	t.Run("chat messages over the rate limit are rejected until the window passes", func(t *testing.T) {
+		room, fakeClock := newLimitedRoom(func(c *RoomConfig) { c.ChatRateLimit = 2 })
+		client := newTestClientWithName("participant1", "John Doe")
+		room.addParticipant(client)
+
+		chat := func(i int) {
+			room.router(context.Background(), client, Message{Event: EventAddChat, Payload: AddChatPayload{
+				ClientInfo:  ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+				ChatId:      ChatId(fmt.Sprintf("chat-%d", i)),
+				ChatContent: "hello",
+			}})
+		}
+
+		chat(1)
+		chat(2)
+		for len(client.send) > 0 {
+			<-client.send
+		}
+		chat(3)
+		assert.Equal(t, ErrorCodeRateLimited, readError(t, client).Code)
+		assert.Equal(t, 2, room.chatHistory.Len())
+
+		fakeClock.Step(time.Minute)
+		chat(4)
+		assert.Equal(t, 3, room.chatHistory.Len())
+	})
+
+	t.Run("idle clients are disconnected and activity resets the timeout", func(t *testing.T) {
+		room, fakeClock := newLimitedRoom(func(c *RoomConfig) { c.IdleTimeout = time.Minute })
+		client := newTestClientWithName("host1", "Host")
+		conn := newMockConn()
+		client.conn = conn
+		room.handleClientConnect(client)
+
+		fakeClock.Step(45 * time.Second)
+		room.router(context.Background(), client, Message{Event: EventRaiseHand, Payload: RaiseHandPayload{ClientId: client.ID, DisplayName: client.DisplayName}})
+		fakeClock.Step(45 * time.Second)
+		assert.Empty(t, conn.CloseCalled, "Activity should reset the idle timeout")
+
+		fakeClock.Step(15 * time.Second)
+		assert.Len(t, conn.CloseCalled, 1, "Idle connection should be closed")
+	})
+}
+
+// TestMultipleHostsAdmission tests waiting room handling when a room has several hosts
+func TestMultipleHostsAdmission(t *testing.T) {
+	// newTwoHostRoom creates a room with two hosts and one waiting client.
+	newTwoHostRoom := func(approvals int) (*Room, *Client, *Client, *Client) {
+		config := DefaultRoomConfig()
+		config.WaitingApprovals = approvals
+		room := NewRoomWithConfig("test-room", config, nil)
+		host1 := newTestClientWithName("host1", "Host One")
+		host2 := newTestClientWithName("host2", "Host Two")
+		waiting := newTestClientWithName("waiting1", "Waiting User")
+		room.addHost(host1)
+		room.addHost(host2)
+		room.addWaiting(waiting)
+		return room, host1, host2, waiting
+	}
+
+	drain := func(clients ...*Client) {
+		for _, c := range clients {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+	}
+
+	accept := func(waiting *Client) Message {
+		return Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: waiting.ID, DisplayName: waiting.DisplayName}}
+	}
+
+	t.Run("every host receives waiting requests", func(t *testing.T) {
+		room, host1, host2, waiting := newTwoHostRoom(0)
+
+		room.router(context.Background(), waiting, Message{Event: EventRequestWaiting, Payload: RequestWaitingPayload{ClientId: waiting.ID, DisplayName: waiting.DisplayName}})
+
+		assert.Len(t, host1.send, 1)
+		assert.Len(t, host2.send, 1)
+	})
+
+	t.Run("second accept of an already admitted user is a no-op", func(t *testing.T) {
+		room, host1, host2, waiting := newTwoHostRoom(0)
+
+		room.router(context.Background(), host1, accept(waiting))
+		require.Contains(t, room.participants, waiting.ID)
+		drain(host1, host2, waiting)
+
+		room.router(context.Background(), host2, accept(waiting))
+
+		assert.Contains(t, room.participants, waiting.ID)
+		assert.Len(t, room.participants, 1)
+		assert.Equal(t, 1, room.clientDrawOrderQueue.Len()-len(room.hosts), "Client should not be added to the draw order twice")
+		assert.Empty(t, host1.send, "No second acceptance should be broadcast")
+		assert.Empty(t, host2.send)
+		assert.Empty(t, waiting.send)
+	})
+
+	t.Run("configured approvals require distinct hosts", func(t *testing.T) {
+		room, host1, host2, waiting := newTwoHostRoom(2)
+
+		room.router(context.Background(), host1, accept(waiting))
+		room.router(context.Background(), host1, accept(waiting))
+		assert.Contains(t, room.waiting, waiting.ID, "One host's repeated approval should not be enough")
+
+		room.router(context.Background(), host2, accept(waiting))
+		assert.Contains(t, room.participants, waiting.ID)
+		assert.NotContains(t, room.waiting, waiting.ID)
+	})
+
+	t.Run("required approvals are capped at the number of hosts", func(t *testing.T) {
+		room, host1, host2, waiting := newTwoHostRoom(3)
+		room.deleteHost(host2)
+
+		room.router(context.Background(), host1, accept(waiting))
+
+		assert.Contains(t, room.participants, waiting.ID)
+	})
+}
+
+// TestWaitingRequestDeduplication tests that repeated waiting requests do not flood hosts
+func TestWaitingRequestDeduplication(t *testing.T) {
+	newDedupRoom := func() (*Room, *testclock.FakeClock, *Client, *Client) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultRoomConfig()
+		config.Clock = fakeClock
+		config.WaitingRequestInterval = 10 * time.Second
+		room := NewRoomWithConfig("test-room", config, nil)
+		host := newTestClientWithName("host1", "Host")
+		waiting := newTestClientWithName("waiting1", "Waiting User")
+		room.addHost(host)
+		room.addWaiting(waiting)
+		return room, fakeClock, host, waiting
+	}
+
+	request := func(c *Client) Message {
+		return Message{Event: EventRequestWaiting, Payload: RequestWaitingPayload{ClientId: c.ID, DisplayName: c.DisplayName}}
+	}
+
+	t.Run("rapid repeated requests produce one host notification", func(t *testing.T) {
+		room, _, host, waiting := newDedupRoom()
+
+		for i := 0; i < 5; i++ {
+			room.router(context.Background(), waiting, request(waiting))
+		}
+
+		assert.Len(t, host.send, 1)
+	})
+
+	t.Run("request is refreshed after the interval", func(t *testing.T) {
+		room, fakeClock, host, waiting := newDedupRoom()
+
+		room.router(context.Background(), waiting, request(waiting))
+		fakeClock.Step(10 * time.Second)
+		room.router(context.Background(), waiting, request(waiting))
+
+		assert.Len(t, host.send, 2)
+	})
+
+	t.Run("requests from different clients are not deduplicated together", func(t *testing.T) {
+		room, _, host, waiting := newDedupRoom()
+		other := newTestClientWithName("waiting2", "Other User")
+		room.addWaiting(other)
+
+		room.router(context.Background(), waiting, request(waiting))
+		room.router(context.Background(), other, request(other))
+
+		assert.Len(t, host.send, 2)
+	})
+}
+
+func TestWebinarSpeakers(t *testing.T) {
+	// newWebinar creates a webinar room with a host and a spectator admitted
+	// from the waiting room.
+	newWebinar := func() (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		room.config.WebinarMode = true
+		host := newTestClientWithName("host", "Host")
+		spectator := newTestClientWithName("spectator", "Spectator")
+		room.handleClientConnect(host)
+		room.handleClientConnect(spectator)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: spectator.ID}})
+		for _, c := range []*Client{host, spectator} {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+		return room, host, spectator
+	}
+
+	received := func(t *testing.T, c *Client) []Event {
+		var events []Event
+		for len(c.send) > 0 {
+			var msg Message
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			events = append(events, msg.Event)
+		}
+		return events
+	}
+
+	routeOf := func(room *Room, client *Client, msg Message) routeResult {
+		result, _ := room.route(context.Background(), client, msg)
+		return result
+	}
+
+	t.Run("admitted clients become spectators", func(t *testing.T) {
+		room, _, spectator := newWebinar()
+		assert.Equal(t, RoleTypeSpectator, spectator.Role)
+		assert.Contains(t, room.spectators, spectator.ID)
+		assert.Empty(t, room.participants)
+		assert.Equal(t, []ClientInfo{spectator.info()}, room.getRoomState().Spectators)
+	})
+
+	t.Run("spectators cannot chat or request screenshare", func(t *testing.T) {
+		room, _, spectator := newWebinar()
+
+		assert.Equal(t, routePermissionDenied, routeOf(room, spectator, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  spectator.info(),
+			ChatId:      "chat",
+			ChatContent: "hello",
+		}}))
+		assert.Equal(t, routePermissionDenied, routeOf(room, spectator, Message{Event: EventRequestScreenshare, Payload: RequestScreensharePayload(spectator.info())}))
+		assert.Equal(t, 0, room.chatHistory.Len())
+	})
+
+	t.Run("request, grant and revoke", func(t *testing.T) {
+		room, host, spectator := newWebinar()
+
+		room.router(context.Background(), spectator, Message{Event: EventRequestSpeak, Payload: RequestSpeakPayload{ClientId: "someone-else"}})
+		require.Len(t, host.send, 1)
+		var request struct {
+			Event   Event      `json:"event"`
+			Payload ClientInfo `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-host.send, &request))
+		assert.Equal(t, EventRequestSpeak, request.Event)
+		assert.Equal(t, spectator.info(), request.Payload, "Requests are stamped with the sender's identity")
+
+		room.router(context.Background(), host, Message{Event: EventGrantSpeak, Payload: GrantSpeakPayload{ClientId: spectator.ID}})
+		assert.Equal(t, RoleTypeParticipant, spectator.Role)
+		assert.Contains(t, room.participants, spectator.ID)
+		assert.Empty(t, room.spectators)
+		assert.Equal(t, []Event{EventGrantSpeak}, received(t, host))
+		assert.Equal(t, []Event{EventRoleChanged, EventGrantSpeak}, received(t, spectator))
+
+		assert.Equal(t, routeHandled, routeOf(room, spectator, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  spectator.info(),
+			ChatId:      "chat",
+			ChatContent: "hello",
+		}}), "Speakers may chat")
+		received(t, host)
+		received(t, spectator)
+
+		room.router(context.Background(), host, Message{Event: EventRevokeSpeak, Payload: RevokeSpeakPayload{ClientId: spectator.ID}})
+		assert.Equal(t, RoleTypeSpectator, spectator.Role)
+		assert.Contains(t, room.spectators, spectator.ID)
+		assert.Empty(t, room.participants)
+		assert.Equal(t, []Event{EventRoleChanged, EventRevokeSpeak}, received(t, spectator))
+	})
+
+	t.Run("revoking a screensharer stops the share", func(t *testing.T) {
+		room, host, spectator := newWebinar()
+		room.router(context.Background(), host, Message{Event: EventGrantSpeak, Payload: GrantSpeakPayload{ClientId: spectator.ID}})
+		require.NoError(t, room.transitionRole(spectator, RoleTypeParticipant, RoleTypeScreenshare))
+
+		room.router(context.Background(), host, Message{Event: EventRevokeSpeak, Payload: RevokeSpeakPayload{ClientId: spectator.ID}})
+		assert.Equal(t, RoleTypeSpectator, spectator.Role)
+		assert.Empty(t, room.sharingScreen)
+	})
+
+	t.Run("revoking a speaker lowers their raised hand", func(t *testing.T) {
+		room, host, spectator := newWebinar()
+		room.router(context.Background(), host, Message{Event: EventGrantSpeak, Payload: GrantSpeakPayload{ClientId: spectator.ID}})
+		room.router(context.Background(), spectator, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(spectator.info())})
+		require.Contains(t, room.raisingHand, spectator.ID)
+		received(t, host)
+
+		room.router(context.Background(), host, Message{Event: EventRevokeSpeak, Payload: RevokeSpeakPayload{ClientId: spectator.ID}})
+
+		assert.Equal(t, RoleTypeSpectator, spectator.Role)
+		assert.Empty(t, room.raisingHand)
+		assert.Zero(t, room.handDrawOrderQueue.Len())
+		assert.Empty(t, room.getRoomState().HandsRaised)
+		assert.Equal(t, []Event{EventRevokeSpeak, EventLowerHand}, received(t, host))
+	})
+
+	t.Run("spectators cannot grant themselves speaking", func(t *testing.T) {
+		room, _, spectator := newWebinar()
+		assert.Equal(t, routePermissionDenied, routeOf(room, spectator, Message{Event: EventGrantSpeak, Payload: GrantSpeakPayload{ClientId: spectator.ID}}))
+		assert.Equal(t, RoleTypeSpectator, spectator.Role)
+	})
+
+	t.Run("spectators do not count towards capacity", func(t *testing.T) {
+		room, host, spectator := newWebinar()
+		room.config.MaxParticipants = 1
+
+		late := newTestClient("late")
+		room.handleClientConnect(late)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: late.ID}})
+		assert.Equal(t, RoleTypeSpectator, late.Role)
+
+		received(t, host)
+		room.router(context.Background(), host, Message{Event: EventGrantSpeak, Payload: GrantSpeakPayload{ClientId: spectator.ID}})
+		assert.Equal(t, RoleTypeSpectator, spectator.Role, "A full room cannot take another speaker")
+		assert.Equal(t, []Event{EventError}, received(t, host))
+	})
+}
+
+func TestAssertPayloadWireTypes(t *testing.T) {
+	t.Run("decoded JSON objects convert to the handler's type", func(t *testing.T) {
+		var wire Message
+		require.NoError(t, json.Unmarshal([]byte(`{"event":"add_chat","payload":{"clientId":"c1","displayName":"Alice","chatId":"x","chatContent":"hi"}}`), &wire))
+
+		p, ok := assertPayload[AddChatPayload](wire.Payload)
+		require.True(t, ok)
+		assert.Equal(t, ClientIdType("c1"), p.ClientId)
+		assert.Equal(t, ChatContent("hi"), p.ChatContent)
+	})
+
+	t.Run("fields of the wrong type are rejected", func(t *testing.T) {
+		_, ok := assertPayload[AddChatPayload](map[string]any{"chatContent": 42})
+		assert.False(t, ok)
+	})
+
+	t.Run("non-object payloads are rejected", func(t *testing.T) {
+		_, ok := assertPayload[AddChatPayload]("hello")
+		assert.False(t, ok)
+	})
+}
+
+func TestHandleKick(t *testing.T) {
+	newMeeting := func() (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host", "Host")
+		participant := newTestClientWithName("participant", "Participant")
+		room.addHost(host)
+		room.addParticipant(participant)
+		return room, host, participant
+	}
+
+	t.Run("kicked client is removed and notified", func(t *testing.T) {
+		room, host, participant := newMeeting()
+
+		room.router(context.Background(), host, Message{Event: EventKick, Payload: KickPayload{ClientInfo: ClientInfo{ClientId: participant.ID}, Reason: "spam"}})
+
+		assert.NotContains(t, room.participants, participant.ID)
+		require.Len(t, participant.send, 1)
+		var notice struct {
+			Event   Event         `json:"event"`
+			Payload KickedPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-participant.send, &notice))
+		assert.Equal(t, EventKicked, notice.Event)
+		assert.Equal(t, "spam", notice.Payload.Reason)
+		assert.Equal(t, participant.ID, notice.Payload.ClientId)
+
+		require.Len(t, host.send, 1)
+		var announced Message
+		require.NoError(t, json.Unmarshal(<-host.send, &announced))
+		assert.Equal(t, EventKick, announced.Event)
+	})
+
+	t.Run("kicked client can send nothing further", func(t *testing.T) {
+		room, host, participant := newMeeting()
+		room.router(context.Background(), host, Message{Event: EventKick, Payload: KickPayload{ClientInfo: ClientInfo{ClientId: participant.ID}}})
+
+		result, _ := room.route(context.Background(), participant, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(participant.info())})
+		assert.Equal(t, routePermissionDenied, result)
+		assert.Empty(t, room.raisingHand)
+	})
+
+	t.Run("the later disconnect announces nothing further", func(t *testing.T) {
+		room, host, participant := newMeeting()
+		room.router(context.Background(), host, Message{Event: EventKick, Payload: KickPayload{ClientInfo: ClientInfo{ClientId: participant.ID}}})
+		<-host.send
+
+		room.handleClientDisconnect(participant)
+		assert.Empty(t, host.send)
+	})
+
+	t.Run("hosts cannot be kicked", func(t *testing.T) {
+		room, host, _ := newMeeting()
+		other := newTestClient("host-2")
+		room.addHost(other)
+
+		room.router(context.Background(), host, Message{Event: EventKick, Payload: KickPayload{ClientInfo: ClientInfo{ClientId: other.ID}}})
+		assert.Contains(t, room.hosts, other.ID)
+		assert.False(t, other.kicked)
+	})
+
+	t.Run("participants cannot kick", func(t *testing.T) {
+		room, host, participant := newMeeting()
+		result, _ := room.route(context.Background(), participant, Message{Event: EventKick, Payload: KickPayload{ClientInfo: ClientInfo{ClientId: host.ID}}})
+		assert.Equal(t, routePermissionDenied, result)
+	})
+}
+
+func TestSelfTargetedRoleChanges(t *testing.T) {
+	lastError := func(t *testing.T, c *Client) ErrorPayload {
+		t.Helper()
+		require.NotEmpty(t, c.send)
+		var last []byte
+		for len(c.send) > 0 {
+			last = <-c.send
+		}
+		var msg struct {
+			Event   Event        `json:"event"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(last, &msg))
+		require.Equal(t, EventError, msg.Event)
+		return msg.Payload
+	}
+
+	t.Run("host accepting itself from waiting is rejected", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+		room.addHost(host)
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: host.ID}})
+
+		assert.Equal(t, RoleTypeHost, host.Role)
+		assert.Contains(t, room.hosts, host.ID)
+		assert.Empty(t, room.participants)
+		assert.Equal(t, ErrorCodeSelfTarget, lastError(t, host).Code)
+	})
+
+	t.Run("participant cannot grant itself screenshare even past the router", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		participant := newTestClient("participant")
+		room.addParticipant(participant)
+
+		room.handleAcceptScreenshare(context.Background(), participant, EventAcceptScreenshare, AcceptScreensharePayload{ClientId: participant.ID})
+
+		assert.Equal(t, RoleTypeParticipant, participant.Role)
+		assert.Empty(t, room.sharingScreen)
+		assert.Equal(t, ErrorCodeSelfTarget, lastError(t, participant).Code)
+	})
+
+	t.Run("spectator cannot grant itself speaking even past the router", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		spectator := newTestClient("spectator")
+		room.addSpectator(spectator)
+
+		room.handleGrantSpeak(context.Background(), spectator, EventGrantSpeak, GrantSpeakPayload{ClientId: spectator.ID})
+
+		assert.Equal(t, RoleTypeSpectator, spectator.Role)
+		assert.Equal(t, ErrorCodeSelfTarget, lastError(t, spectator).Code)
+	})
+
+	t.Run("waiting client cannot admit itself even past the router", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+		room.addHost(host)
+		waiting := newTestClient("waiting")
+		room.addWaiting(waiting)
+
+		room.handleAcceptWaiting(context.Background(), waiting, EventAcceptWaiting, AcceptWaitingPayload{ClientId: waiting.ID})
+
+		assert.Equal(t, RoleTypeWaiting, waiting.Role)
+		assert.Contains(t, room.waiting, waiting.ID)
+	})
+}
+
+func TestConnectionReport(t *testing.T) {
+	setup := func() (*Room, *Client, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		room.config.Clock = testclock.NewFakeClock(time.UnixMilli(1_700_000_000_000))
+		host := newTestClientWithName("host", "Host")
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addHost(host)
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+		for _, c := range []*Client{host, alice, bob} {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+		return room, host, alice, bob
+	}
+
+	report := func(t *testing.T, room *Room, c *Client) []ConnectionStatsPayload {
+		t.Helper()
+		room.router(context.Background(), c, Message{Event: EventConnectionReport})
+		require.Len(t, c.send, 1)
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-c.send, &msg))
+		require.Equal(t, EventConnectionReport, msg.Event)
+		var got ConnectionReportPayload
+		require.NoError(t, json.Unmarshal(msg.Payload, &got))
+		return got.Stats
+	}
+
+	t.Run("hosts read the latest stats of each client", func(t *testing.T) {
+		room, host, alice, bob := setup()
+
+		send := func(c *Client, loss float64) {
+			room.router(context.Background(), c, Message{Event: EventConnectionStats, Payload: ConnectionStatsPayload{
+				ClientInfo:  ClientInfo{ClientId: "someone-else"},
+				PacketLoss:  loss,
+				RoundTripMs: 80,
+				JitterMs:    12,
+			}})
+		}
+		send(bob, 0.2)
+		send(alice, 0.5)
+		send(alice, 0.01)
+
+		assert.Zero(t, len(alice.send)+len(bob.send), "Stats are not broadcast")
+		assert.Equal(t, []ConnectionStatsPayload{
+			{ClientInfo: alice.info(), PacketLoss: 0.01, RoundTripMs: 80, JitterMs: 12, Timestamp: 1_700_000_000_000},
+			{ClientInfo: bob.info(), PacketLoss: 0.2, RoundTripMs: 80, JitterMs: 12, Timestamp: 1_700_000_000_000},
+		}, report(t, room, host))
+	})
+
+	t.Run("participants cannot read the report", func(t *testing.T) {
+		room, _, alice, _ := setup()
+		room.setConnectionStats(ConnectionStatsPayload{ClientInfo: alice.info(), PacketLoss: 0.1})
+
+		room.router(context.Background(), alice, Message{Event: EventConnectionReport})
+
+		for len(alice.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-alice.send, &msg))
+			assert.NotEqual(t, EventConnectionReport, msg.Event)
+		}
+	})
+
+	t.Run("out of range stats are dropped", func(t *testing.T) {
+		room, host, alice, _ := setup()
+
+		room.router(context.Background(), alice, Message{Event: EventConnectionStats, Payload: ConnectionStatsPayload{PacketLoss: 1.5}})
+		room.router(context.Background(), alice, Message{Event: EventConnectionStats, Payload: ConnectionStatsPayload{RoundTripMs: -1}})
+
+		assert.Empty(t, report(t, room, host))
+	})
+
+	t.Run("stats are forgotten when the client leaves", func(t *testing.T) {
+		room, host, alice, _ := setup()
+		room.setConnectionStats(ConnectionStatsPayload{ClientInfo: alice.info(), PacketLoss: 0.1})
+
+		room.disconnectClient(alice)
+
+		assert.Empty(t, report(t, room, host))
+	})
+}
+
+func TestSpotlight(t *testing.T) {
+	setup := func() (*Room, *Client, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host", "Host")
+		alice := newTestClientWithName("alice", "Alice")
+		viewer := newTestClientWithName("viewer", "Viewer")
+		room.addHost(host)
+		room.addParticipant(alice)
+		room.addSpectator(viewer)
+		for _, c := range []*Client{host, alice, viewer} {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+		return room, host, alice, viewer
+	}
+
+	nextEvent := func(t *testing.T, c *Client) (Event, ClientInfo) {
+		t.Helper()
+		require.NotZero(t, len(c.send))
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-c.send, &msg))
+		var info ClientInfo
+		require.NoError(t, json.Unmarshal(msg.Payload, &info))
+		return msg.Event, info
+	}
+
+	t.Run("hosts spotlight a participant for everyone", func(t *testing.T) {
+		room, host, alice, viewer := setup()
+
+		room.router(context.Background(), host, Message{Event: EventSpotlight, Payload: SpotlightPayload{ClientId: alice.ID}})
+
+		assert.Equal(t, alice.ID, room.getRoomState().Spotlight)
+		event, info := nextEvent(t, viewer)
+		assert.Equal(t, EventSpotlight, event)
+		assert.Equal(t, alice.info(), info)
+
+		room.router(context.Background(), host, Message{Event: EventSpotlight, Payload: SpotlightPayload{ClientId: alice.ID}})
+		assert.Zero(t, len(viewer.send), "Spotlighting the same participant again is a no-op")
+	})
+
+	t.Run("only current participants can be spotlighted", func(t *testing.T) {
+		room, host, _, viewer := setup()
+
+		for _, id := range []ClientIdType{viewer.ID, "nobody"} {
+			room.router(context.Background(), host, Message{Event: EventSpotlight, Payload: SpotlightPayload{ClientId: id}})
+
+			assert.Empty(t, room.getRoomState().Spotlight)
+			var msg struct {
+				Event   Event        `json:"event"`
+				Payload ErrorPayload `json:"payload"`
+			}
+			require.Len(t, host.send, 1)
+			require.NoError(t, json.Unmarshal(<-host.send, &msg))
+			assert.Equal(t, ErrorCodeClientNotFound, msg.Payload.Code)
+		}
+	})
+
+	t.Run("participants cannot spotlight", func(t *testing.T) {
+		room, _, alice, _ := setup()
+
+		room.router(context.Background(), alice, Message{Event: EventSpotlight, Payload: SpotlightPayload{ClientId: alice.ID}})
+
+		assert.Empty(t, room.getRoomState().Spotlight)
+	})
+
+	t.Run("hosts clear the spotlight", func(t *testing.T) {
+		room, host, alice, viewer := setup()
+		room.router(context.Background(), host, Message{Event: EventSpotlight, Payload: SpotlightPayload{ClientId: alice.ID}})
+		<-viewer.send
+
+		room.router(context.Background(), host, Message{Event: EventClearSpotlight})
+
+		assert.Empty(t, room.getRoomState().Spotlight)
+		event, info := nextEvent(t, viewer)
+		assert.Equal(t, EventClearSpotlight, event)
+		assert.Equal(t, alice.ID, info.ClientId)
+
+		room.router(context.Background(), host, Message{Event: EventClearSpotlight})
+		assert.Zero(t, len(viewer.send), "Clearing an empty spotlight is a no-op")
+	})
+
+	t.Run("the spotlight clears when the participant disconnects", func(t *testing.T) {
+		room, host, alice, viewer := setup()
+		room.router(context.Background(), host, Message{Event: EventSpotlight, Payload: SpotlightPayload{ClientId: alice.ID}})
+		<-viewer.send
+
+		room.handleClientDisconnect(alice)
+
+		assert.Empty(t, room.getRoomState().Spotlight)
+		var cleared bool
+		for len(viewer.send) > 0 {
+			if event, info := nextEvent(t, viewer); event == EventClearSpotlight {
+				cleared = info.ClientId == alice.ID
+			}
+		}
+		assert.True(t, cleared, "The room should be told the spotlight was cleared")
+	})
+
+	t.Run("the spotlight clears when the participant returns to the audience", func(t *testing.T) {
+		room, host, alice, _ := setup()
+		room.router(context.Background(), host, Message{Event: EventSpotlight, Payload: SpotlightPayload{ClientId: alice.ID}})
+
+		room.router(context.Background(), host, Message{Event: EventRevokeSpeak, Payload: RevokeSpeakPayload{ClientId: alice.ID}})
+
+		assert.Empty(t, room.getRoomState().Spotlight)
+	})
+}
+
+func TestJoinLeaveNotify(t *testing.T) {
+	setup := func(config func(*RoomConfig)) (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		config(&room.config)
+		host := newTestClientWithName("host", "Host")
+		alice := newTestClientWithName("alice", "Alice")
+		room.addHost(host)
+		room.addParticipant(alice)
+		return room, host, alice
+	}
+
+	// admit accepts a new waiting client and returns the join broadcast
+	// the host received.
+	admit := func(t *testing.T, room *Room, host *Client, id ClientIdType) ParticipantJoinedPayload {
+		t.Helper()
+		guest := newTestClient(id)
+		room.addWaiting(guest)
+		for len(host.send) > 0 {
+			<-host.send
+		}
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: id}})
+		require.Len(t, host.send, 1)
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-host.send, &msg))
+		require.Equal(t, EventAcceptWaiting, msg.Event)
+		var joined ParticipantJoinedPayload
+		require.NoError(t, json.Unmarshal(msg.Payload, &joined))
+		assert.Equal(t, id, joined.ClientId)
+		return joined
+	}
+
+	// leave drops a client's connection and returns the notice the host received.
+	leave := func(t *testing.T, room *Room, host, c *Client) ClientDisconnectPayload {
+		t.Helper()
+		for len(host.send) > 0 {
+			<-host.send
+		}
+		room.handleClientDisconnect(c)
+		for len(host.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-host.send, &msg))
+			if msg.Event == EventDisconnect {
+				var gone ClientDisconnectPayload
+				require.NoError(t, json.Unmarshal(msg.Payload, &gone))
+				return gone
+			}
+		}
+		t.Fatal("no disconnect notice was broadcast")
+		return ClientDisconnectPayload{}
+	}
+
+	t.Run("joins and leaves notify by default", func(t *testing.T) {
+		room, host, alice := setup(func(*RoomConfig) {})
+
+		assert.True(t, admit(t, room, host, "guest").Notify)
+		assert.True(t, leave(t, room, host, alice).Notify)
+	})
+
+	t.Run("joins and leaves are silent above the threshold", func(t *testing.T) {
+		room, host, alice := setup(func(c *RoomConfig) { c.JoinLeaveNotifyLimit = 3 })
+
+		assert.True(t, admit(t, room, host, "guest-1").Notify, "Three admitted clients is within the limit")
+		assert.False(t, admit(t, room, host, "guest-2").Notify, "Four admitted clients is above the limit")
+		assert.False(t, leave(t, room, host, alice).Notify, "Leaving a room of four is silent too")
+		assert.True(t, leave(t, room, host, room.participants["guest-1"]).Notify, "Leaving a room of three notifies")
+	})
+
+	t.Run("silent rooms never notify", func(t *testing.T) {
+		room, host, alice := setup(func(c *RoomConfig) { c.SilentJoinLeave = true })
+
+		assert.False(t, admit(t, room, host, "guest").Notify)
+		assert.False(t, leave(t, room, host, alice).Notify)
+	})
+}
+
+func TestWelcomeMessage(t *testing.T) {
+	// welcomes returns the welcome messages among everything c was sent.
+	welcomes := func(t *testing.T, c *Client) []string {
+		t.Helper()
+		var got []string
+		for len(c.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			if msg.Event == EventWelcome {
+				var welcome WelcomePayload
+				require.NoError(t, json.Unmarshal(msg.Payload, &welcome))
+				got = append(got, welcome.Message)
+			}
+		}
+		return got
+	}
+
+	t.Run("the creating host and admitted participants are welcomed", func(t *testing.T) {
+		config := DefaultRoomConfig()
+		config.WelcomeMessage = "  Welcome!\nPlease stay muted.  "
+		room := NewRoomWithConfig("test-room", config, nil)
+		defer room.close()
+		host := newTestClient("host")
+		guest := newTestClient("guest")
+
+		room.handleClientConnect(host)
+		assert.Equal(t, []string{"Welcome!\nPlease stay muted."}, welcomes(t, host))
+
+		room.handleClientConnect(guest)
+		assert.Empty(t, welcomes(t, guest), "Waiting clients are not welcomed until admitted")
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: guest.ID}})
+		assert.Equal(t, []string{"Welcome!\nPlease stay muted."}, welcomes(t, guest))
+		assert.Zero(t, room.chatHistory.Len(), "The welcome is not chat")
+	})
+
+	t.Run("hosts change the message for later joiners", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+		alice := newTestClient("alice")
+		room.addHost(host)
+		room.addParticipant(alice)
+		for _, c := range []*Client{host, alice} {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+
+		room.router(context.Background(), host, Message{Event: EventSetWelcome, Payload: SetWelcomePayload{Message: "Hi \u202eeveryone\x07"}})
+
+		assert.Equal(t, "Hi everyone", room.getRoomState().Welcome)
+		require.Len(t, host.send, 1)
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-host.send, &msg))
+		assert.Equal(t, EventSetWelcome, msg.Event)
+		assert.Zero(t, len(alice.send), "Only hosts are told about the change")
+
+		guest := newTestClient("guest")
+		room.addWaiting(guest)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: guest.ID}})
+		assert.Equal(t, []string{"Hi everyone"}, welcomes(t, guest))
+
+		room.router(context.Background(), host, Message{Event: EventSetWelcome, Payload: SetWelcomePayload{}})
+		late := newTestClient("late")
+		room.addWaiting(late)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: late.ID}})
+		assert.Empty(t, welcomes(t, late), "An empty message turns the welcome off")
+	})
+
+	t.Run("participants cannot change the message and long messages are rejected", func(t *testing.T) {
+		allowed, known := HasEventPermission(RoleTypeParticipant, EventSetWelcome)
+		assert.True(t, known)
+		assert.False(t, allowed)
+
+		assert.NoError(t, SetWelcomePayload{Message: strings.Repeat("é", maxWelcomeMessageLength)}.Validate())
+		assert.Error(t, SetWelcomePayload{Message: strings.Repeat("é", maxWelcomeMessageLength+1)}.Validate())
+	})
+}
+
+func TestMuteOnJoin(t *testing.T) {
+	// forceMutes returns the mute instructions among everything c was sent.
+	forceMutes := func(t *testing.T, c *Client) []ForceMutePayload {
+		t.Helper()
+		var got []ForceMutePayload
+		for len(c.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			if msg.Event == EventForceMute {
+				var mute ForceMutePayload
+				require.NoError(t, json.Unmarshal(msg.Payload, &mute))
+				got = append(got, mute)
+			}
+		}
+		return got
+	}
+
+	setup := func(muteOnJoin bool) (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		room.config.MuteOnJoin = muteOnJoin
+		host := newTestClient("host")
+		guest := newTestClient("guest")
+		room.addHost(host)
+		room.addWaiting(guest)
+		room.unmuted[guest.ID] = guest
+		return room, host, guest
+	}
+
+	t.Run("admitted participants start muted", func(t *testing.T) {
+		room, host, guest := setup(true)
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: guest.ID}})
+
+		assert.NotContains(t, room.unmuted, guest.ID)
+		assert.Equal(t, []ForceMutePayload{{Reason: ForceMuteReasonJoin}}, forceMutes(t, guest))
+		assert.Empty(t, forceMutes(t, host), "Hosts are not muted")
+	})
+
+	t.Run("participants are not muted by default", func(t *testing.T) {
+		room, host, guest := setup(false)
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: guest.ID}})
+
+		assert.Contains(t, room.unmuted, guest.ID)
+		assert.Empty(t, forceMutes(t, guest))
+	})
+
+	t.Run("a participant muted on join is unmuted once it says so", func(t *testing.T) {
+		room, host, guest := setup(true)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: guest.ID}})
+		require.NotContains(t, room.unmuted, guest.ID)
+
+		room.router(context.Background(), guest, Message{Event: EventMediaState, Payload: MediaStatePayload{Unmuted: true}})
+
+		assert.Contains(t, room.unmuted, guest.ID)
+	})
+}
+
+func TestHostTransfer(t *testing.T) {
+	setup := func() (*Room, *testclock.FakeClock, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		fakeClock := testclock.NewFakeClock(time.Now())
+		room.config.Clock = fakeClock
+		room.config.HostReclaimWindow = 30 * time.Second
+		host := newTestClientWithName("host", "Host")
+		alice := newTestClientWithName("alice", "Alice")
+		room.addHost(host)
+		room.addParticipant(alice)
+		room.router(context.Background(), host, Message{Event: EventTransferHost, Payload: TransferHostPayload{ClientInfo: ClientInfo{ClientId: alice.ID}}})
+		return room, fakeClock, host, alice
+	}
+
+	// lastError returns the error code of the last error c was sent, if any.
+	lastError := func(t *testing.T, c *Client) ErrorCode {
+		t.Helper()
+		var code ErrorCode
+		for len(c.send) > 0 {
+			var msg struct {
+				Event   Event        `json:"event"`
+				Payload ErrorPayload `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			if msg.Event == EventError {
+				code = msg.Payload.Code
+			}
+		}
+		return code
+	}
+
+	t.Run("a transfer swaps the host and announces the reclaim deadline", func(t *testing.T) {
+		_, fakeClock, host, alice := setup()
+
+		assert.Equal(t, RoleTypeHost, alice.Role)
+		assert.Equal(t, RoleTypeParticipant, host.Role)
+		var transfer struct {
+			Event   Event               `json:"event"`
+			Payload TransferHostPayload `json:"payload"`
+		}
+		for len(alice.send) > 0 {
+			require.NoError(t, json.Unmarshal(<-alice.send, &transfer))
+		}
+		assert.Equal(t, EventTransferHost, transfer.Event)
+		assert.Equal(t, alice.info(), transfer.Payload.ClientInfo)
+		assert.Equal(t, host.ID, transfer.Payload.PreviousHostId)
+		assert.Equal(t, Timestamp(fakeClock.Now().Add(30*time.Second).UnixMilli()), transfer.Payload.ReclaimUntil)
+	})
+
+	t.Run("the previous host reclaims within the window", func(t *testing.T) {
+		room, fakeClock, host, alice := setup()
+		fakeClock.Step(20 * time.Second)
+
+		room.router(context.Background(), host, Message{Event: EventReclaimHost})
+
+		assert.Equal(t, RoleTypeHost, host.Role)
+		assert.Equal(t, RoleTypeParticipant, alice.Role)
+		assert.Contains(t, room.hosts, host.ID)
+		assert.Contains(t, room.participants, alice.ID)
+		assert.Empty(t, lastError(t, host))
+
+		room.router(context.Background(), host, Message{Event: EventTransferHost, Payload: TransferHostPayload{ClientInfo: ClientInfo{ClientId: alice.ID}}})
+		room.router(context.Background(), alice, Message{Event: EventReclaimHost})
+		assert.Equal(t, ErrorCodeReclaimExpired, lastError(t, alice), "Only the host who transferred may reclaim")
+		assert.Equal(t, RoleTypeHost, alice.Role)
+	})
+
+	t.Run("after the window a host must hand the role back", func(t *testing.T) {
+		room, fakeClock, host, alice := setup()
+		fakeClock.Step(31 * time.Second)
+
+		room.router(context.Background(), host, Message{Event: EventReclaimHost})
+
+		assert.Equal(t, ErrorCodeReclaimExpired, lastError(t, host))
+		assert.Equal(t, RoleTypeParticipant, host.Role)
+		assert.Equal(t, RoleTypeHost, alice.Role)
+
+		room.router(context.Background(), alice, Message{Event: EventTransferHost, Payload: TransferHostPayload{ClientInfo: ClientInfo{ClientId: host.ID}}})
+		assert.Equal(t, RoleTypeHost, host.Role)
+		assert.Equal(t, RoleTypeParticipant, alice.Role)
+	})
+
+	t.Run("only participants can receive the host role", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+		waiting := newTestClient("waiting")
+		room.addHost(host)
+		room.addWaiting(waiting)
+
+		room.router(context.Background(), host, Message{Event: EventTransferHost, Payload: TransferHostPayload{ClientInfo: ClientInfo{ClientId: waiting.ID}}})
+
+		assert.Equal(t, ErrorCodeClientNotFound, lastError(t, host))
+		assert.Equal(t, RoleTypeHost, host.Role)
+		assert.Equal(t, RoleTypeWaiting, waiting.Role)
+	})
+}
+
+func TestDeleteChatAuthorship(t *testing.T) {
+	setup := func() (*Room, *Client, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host", "Host")
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addHost(host)
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+		room.addChat(AddChatPayload{ClientInfo: alice.info(), ChatId: "alice-chat", ChatContent: "hi"})
+		room.addChat(AddChatPayload{ClientInfo: bob.info(), ChatId: "bob-chat", ChatContent: "hello"})
+		return room, host, alice, bob
+	}
+
+	// errorCode returns the code of the last error c was sent, if any.
+	errorCode := func(t *testing.T, c *Client) ErrorCode {
+		t.Helper()
+		var code ErrorCode
+		for len(c.send) > 0 {
+			var msg struct {
+				Event   Event        `json:"event"`
+				Payload ErrorPayload `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			if msg.Event == EventError {
+				code = msg.Payload.Code
+			}
+		}
+		return code
+	}
+
+	deleteChat := func(room *Room, c *Client, chatId ChatId) {
+		room.router(context.Background(), c, Message{Event: EventDeleteChat, Payload: DeleteChatPayload{ClientInfo: c.info(), ChatId: chatId}})
+	}
+
+	t.Run("a participant deletes their own message", func(t *testing.T) {
+		room, _, alice, _ := setup()
+
+		deleteChat(room, alice, "alice-chat")
+
+		assert.False(t, room.hasChat("alice-chat"))
+		assert.Empty(t, errorCode(t, alice))
+	})
+
+	t.Run("a participant cannot delete another's message", func(t *testing.T) {
+		room, _, alice, bob := setup()
+
+		deleteChat(room, alice, "bob-chat")
+
+		assert.True(t, room.hasChat("bob-chat"))
+		assert.Equal(t, ErrorCodeNotAuthor, errorCode(t, alice))
+		assert.Empty(t, errorCode(t, bob), "Nothing is broadcast for a refused delete")
+	})
+
+	t.Run("a host deletes any message", func(t *testing.T) {
+		room, host, _, _ := setup()
+
+		deleteChat(room, host, "alice-chat")
+		deleteChat(room, host, "bob-chat")
+
+		assert.False(t, room.hasChat("alice-chat"))
+		assert.False(t, room.hasChat("bob-chat"))
+		assert.Empty(t, errorCode(t, host))
+	})
+
+	t.Run("deleting a missing message reports it", func(t *testing.T) {
+		room, _, alice, _ := setup()
+
+		deleteChat(room, alice, "gone")
+
+		assert.Equal(t, ErrorCodeChatNotFound, errorCode(t, alice))
+	})
+}
+
+func TestDuplicateDecisions(t *testing.T) {
+	// count returns how many of c's queued messages carry event, draining them.
+	count := func(t *testing.T, c *Client, event Event) int {
+		t.Helper()
+		n := 0
+		for len(c.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			if msg.Event == event {
+				n++
+			}
+		}
+		return n
+	}
+	twice := func(room *Room, from *Client, msg Message) {
+		room.router(context.Background(), from, msg)
+		room.router(context.Background(), from, msg)
+	}
+
+	// The router's duplicate window would drop the second copy before the
+	// handler saw it; turn it off so the handlers' own guards are tested.
+	setup := func() (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		room.config.DuplicateEventWindow = 0
+		host := newTestClientWithName("host", "Host")
+		observer := newTestClientWithName("observer", "Observer")
+		room.addHost(host)
+		room.addHost(observer)
+		return room, host, observer
+	}
+
+	t.Run("accept_waiting admits once", func(t *testing.T) {
+		room, host, observer := setup()
+		waiting := newTestClientWithName("waiting", "Waiting")
+		room.addWaiting(waiting)
+
+		twice(room, host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: waiting.ID}})
+
+		assert.Equal(t, RoleTypeParticipant, waiting.Role)
+		assert.Equal(t, 1, count(t, observer, EventAcceptWaiting))
+		assert.Equal(t, 1, count(t, waiting, EventRoleChanged))
+	})
+
+	t.Run("deny_waiting removes and announces once", func(t *testing.T) {
+		room, host, _ := setup()
+		waiting := newTestClientWithName("waiting", "Waiting")
+		stillWaiting := newTestClientWithName("still-waiting", "Still Waiting")
+		room.addWaiting(waiting)
+		room.addWaiting(stillWaiting)
+
+		twice(room, host, Message{Event: EventDenyWaiting, Payload: DenyWaitingPayload{ClientId: waiting.ID}})
+
+		assert.NotContains(t, room.waiting, waiting.ID)
+		assert.Equal(t, 1, count(t, stillWaiting, EventDenyWaiting))
+	})
+
+	t.Run("accept_screenshare grants once", func(t *testing.T) {
+		room, host, _ := setup()
+		participant := newTestClientWithName("participant", "Participant")
+		room.addParticipant(participant)
+
+		twice(room, host, Message{Event: EventAcceptScreenshare, Payload: AcceptScreensharePayload{ClientId: participant.ID}})
+
+		assert.Equal(t, RoleTypeScreenshare, participant.Role)
+		assert.Contains(t, room.sharingScreen, participant.ID)
+		assert.Equal(t, 1, count(t, participant, EventAcceptScreenshare))
+	})
+
+	t.Run("deny_screenshare notifies once per request", func(t *testing.T) {
+		room, host, observer := setup()
+		participant := newTestClientWithName("participant", "Participant")
+		room.addParticipant(participant)
+		deny := Message{Event: EventDenyScreenshare, Payload: DenyScreensharePayload{ClientId: participant.ID}}
+
+		twice(room, host, deny)
+
+		assert.Equal(t, RoleTypeParticipant, participant.Role)
+		assert.Equal(t, 1, count(t, participant, EventDenyScreenshare))
+		assert.Equal(t, 1, count(t, observer, EventDenyScreenshare))
+
+		room.router(context.Background(), participant, Message{Event: EventRequestScreenshare, Payload: RequestScreensharePayload(participant.info())})
+		room.router(context.Background(), host, deny)
+		assert.Equal(t, 1, count(t, participant, EventDenyScreenshare), "A new request can be denied again")
+	})
+
+	t.Run("deny_screenshare after acceptance changes nothing", func(t *testing.T) {
+		room, host, observer := setup()
+		participant := newTestClientWithName("participant", "Participant")
+		room.addParticipant(participant)
+
+		room.router(context.Background(), host, Message{Event: EventAcceptScreenshare, Payload: AcceptScreensharePayload{ClientId: participant.ID}})
+		room.router(context.Background(), observer, Message{Event: EventDenyScreenshare, Payload: DenyScreensharePayload{ClientId: participant.ID}})
+
+		assert.Equal(t, RoleTypeScreenshare, participant.Role)
+		assert.Zero(t, count(t, participant, EventDenyScreenshare))
+	})
+
+	t.Run("grant_speak and revoke_speak change the role once", func(t *testing.T) {
+		room, host, observer := setup()
+		spectator := newTestClientWithName("spectator", "Spectator")
+		room.addSpectator(spectator)
+
+		twice(room, host, Message{Event: EventGrantSpeak, Payload: GrantSpeakPayload{ClientId: spectator.ID}})
+		assert.Equal(t, RoleTypeParticipant, spectator.Role)
+		assert.Equal(t, 1, count(t, observer, EventGrantSpeak))
+
+		twice(room, host, Message{Event: EventRevokeSpeak, Payload: RevokeSpeakPayload{ClientId: spectator.ID}})
+		assert.Equal(t, RoleTypeSpectator, spectator.Role)
+		assert.Equal(t, 1, count(t, observer, EventRevokeSpeak))
+	})
+}
+
+func TestCodecPolicy(t *testing.T) {
+	policy := CodecPolicy{Audio: []string{"opus"}, Video: []string{"VP9", "VP8"}}
+
+	// codecPolicies returns the codec policies among everything c was sent.
+	codecPolicies := func(t *testing.T, c *Client) []CodecPolicyPayload {
+		t.Helper()
+		var got []CodecPolicyPayload
+		for len(c.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			if msg.Event == EventCodecPolicy {
+				var payload CodecPolicyPayload
+				require.NoError(t, json.Unmarshal(msg.Payload, &payload))
+				got = append(got, payload)
+			}
+		}
+		return got
+	}
+
+	t.Run("the first host receives the policy", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		room.config.CodecPolicy = policy
+		host := newTestClient("host")
+
+		room.handleClientConnect(host)
+
+		assert.Equal(t, []CodecPolicyPayload{policy}, codecPolicies(t, host))
+	})
+
+	t.Run("an admitted participant receives the policy", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		room.config.CodecPolicy = policy
+		host := newTestClient("host")
+		guest := newTestClient("guest")
+		room.addHost(host)
+		room.addWaiting(guest)
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: guest.ID}})
+
+		assert.Equal(t, []CodecPolicyPayload{policy}, codecPolicies(t, guest))
+		assert.Empty(t, codecPolicies(t, host), "Clients already in the room are not sent it again")
+	})
+
+	t.Run("no policy is sent when none is configured", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+
+		room.handleClientConnect(host)
+
+		assert.Empty(t, codecPolicies(t, host))
+	})
+}
+
+func TestReplyToFullChannel(t *testing.T) {
+	// fill queues messages until c's send channel has no room left.
+	fill := func(c *Client) {
+		for len(c.send) < cap(c.send) {
+			c.send <- []byte("{}")
+		}
+	}
+
+	t.Run("accepting a screenshare for a client with a full channel does not block", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+		sharer := newTestClient("sharer")
+		room.addHost(host)
+		room.addParticipant(sharer)
+		fill(sharer)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			room.router(context.Background(), host, Message{Event: EventAcceptScreenshare, Payload: AcceptScreensharePayload{ClientId: sharer.ID}})
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("handleAcceptScreenshare blocked on a full send channel")
+		}
+		assert.Contains(t, room.sharingScreen, sharer.ID, "The grant still takes effect")
+	})
+
+	t.Run("denying a screenshare for a client with a full channel does not block", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+		otherHost := newTestClient("other-host")
+		requester := newTestClient("requester")
+		room.addHost(host)
+		room.addHost(otherHost)
+		room.addParticipant(requester)
+		fill(requester)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			room.router(context.Background(), host, Message{Event: EventDenyScreenshare, Payload: DenyScreensharePayload{ClientId: requester.ID}})
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("handleDenyScreenshare blocked on a full send channel")
+		}
+		assert.True(t, room.deniedScreenshare.Has(requester.ID))
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-otherHost.send, &msg))
+		assert.Equal(t, EventDenyScreenshare, msg.Event, "Hosts still hear of the denial")
+	})
+
+	t.Run("replies report whether they were queued", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClient("client")
+
+		assert.True(t, room.replyTo(client, EventWelcome, WelcomePayload{Message: "hi"}))
+		fill(client)
+		assert.False(t, room.replyTo(client, EventWelcome, WelcomePayload{Message: "hi"}))
+	})
+}
+
+func TestLowerAllHands(t *testing.T) {
+	setup := func() (*Room, *Client, []*Client) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+		room.addHost(host)
+		var participants []*Client
+		for _, id := range []string{"alice", "bob", "carol"} {
+			p := newTestClient(ClientIdType(id))
+			room.addParticipant(p)
+			participants = append(participants, p)
+		}
+		return room, host, participants
+	}
+
+	t.Run("every raised hand is lowered and the queue emptied", func(t *testing.T) {
+		room, host, participants := setup()
+		alice, bob, carol := participants[0], participants[1], participants[2]
+		for _, p := range []*Client{bob, alice, carol} {
+			room.raiseHand(RaiseHandPayload(p.info()))
+		}
+		for len(alice.send) > 0 {
+			<-alice.send
+		}
+
+		room.router(context.Background(), host, Message{Event: EventLowerAllHands})
+
+		assert.Empty(t, room.raisingHand)
+		assert.Zero(t, room.handDrawOrderQueue.Len())
+		for _, p := range participants {
+			assert.Nil(t, p.drawOrderElement, "Client %s keeps no queue element", p.ID)
+		}
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-alice.send, &msg))
+		require.Equal(t, EventLowerAllHands, msg.Event)
+		var payload LowerAllHandsPayload
+		require.NoError(t, json.Unmarshal(msg.Payload, &payload))
+		assert.Equal(t, host.ID, payload.ClientId)
+		assert.Equal(t, []ClientInfo{bob.info(), alice.info(), carol.info()}, payload.Lowered, "Hands are listed in the order raised")
+	})
+
+	t.Run("the queue works normally afterwards", func(t *testing.T) {
+		room, host, participants := setup()
+		alice, bob := participants[0], participants[1]
+		room.raiseHand(RaiseHandPayload(alice.info()))
+		room.raiseHand(RaiseHandPayload(bob.info()))
+
+		room.router(context.Background(), host, Message{Event: EventLowerAllHands})
+		room.raiseHand(RaiseHandPayload(bob.info()))
+		room.lowerHand(LowerHandPayload(alice.info()))
+		room.disconnectClient(alice)
+
+		assert.Equal(t, []*Client{bob}, room.raisedHandsInOrder())
+		assert.Equal(t, 1, room.handDrawOrderQueue.Len())
+	})
+
+	t.Run("clearing an empty queue sends nothing", func(t *testing.T) {
+		room, host, participants := setup()
+		for len(participants[0].send) > 0 {
+			<-participants[0].send
+		}
+
+		room.router(context.Background(), host, Message{Event: EventLowerAllHands})
+
+		assert.Empty(t, participants[0].send)
+	})
+
+	t.Run("participants cannot lower everyone's hands", func(t *testing.T) {
+		room, _, participants := setup()
+		alice, bob := participants[0], participants[1]
+		room.raiseHand(RaiseHandPayload(bob.info()))
+
+		room.router(context.Background(), alice, Message{Event: EventLowerAllHands})
+
+		assert.Contains(t, room.raisingHand, bob.ID)
+	})
+}
+
+func TestStopScreenshare(t *testing.T) {
+	// setup returns a room with a host and a participant who is sharing.
+	setup := func() (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		room.config.DuplicateEventWindow = 0
+		host := newTestClient("host")
+		sharer := newTestClient("sharer")
+		room.addHost(host)
+		room.addParticipant(sharer)
+		room.router(context.Background(), host, Message{Event: EventAcceptScreenshare, Payload: AcceptScreensharePayload{ClientId: sharer.ID}})
+		require.Contains(t, room.sharingScreen, sharer.ID)
+		for _, c := range []*Client{host, sharer} {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+		return room, host, sharer
+	}
+
+	// events returns the events among everything c was sent.
+	events := func(c *Client) []Event {
+		var got []Event
+		for len(c.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			got = append(got, msg.Event)
+		}
+		return got
+	}
+
+	// assertParticipant checks that c is a participant in every structure.
+	assertParticipant := func(t *testing.T, room *Room, c *Client) {
+		t.Helper()
+		assert.Equal(t, RoleTypeParticipant, c.Role)
+		assert.Contains(t, room.participants, c.ID)
+		assert.NotContains(t, room.sharingScreen, c.ID)
+		require.NotNil(t, c.drawOrderElement, "A fresh draw order element is assigned")
+		var queued bool
+		for e := room.clientDrawOrderQueue.Front(); e != nil; e = e.Next() {
+			if e == c.drawOrderElement {
+				queued = true
+			}
+		}
+		assert.True(t, queued, "The draw order element is in the queue")
+	}
+
+	t.Run("a sharer who stops is a participant again", func(t *testing.T) {
+		room, host, sharer := setup()
+
+		room.router(context.Background(), sharer, Message{Event: EventStopScreenshare, Payload: StopScreensharePayload(sharer.info())})
+
+		assertParticipant(t, room, sharer)
+		assert.Equal(t, []Event{EventRoleChanged, EventStopScreenshare}, events(sharer))
+		assert.Equal(t, []Event{EventStopScreenshare}, events(host))
+
+		room.broadcast(context.Background(), EventRaiseHand, RaiseHandPayload(host.info()), HasParticipantPermission())
+		assert.Equal(t, []Event{EventRaiseHand}, events(sharer), "Participant broadcasts reach the former sharer")
+	})
+
+	t.Run("a host can stop someone else's share", func(t *testing.T) {
+		room, host, sharer := setup()
+
+		room.router(context.Background(), host, Message{Event: EventStopScreenshare, Payload: StopScreensharePayload{ClientId: sharer.ID}})
+
+		assertParticipant(t, room, sharer)
+	})
+
+	t.Run("a sharer can only stop its own share", func(t *testing.T) {
+		room, host, sharer := setup()
+		other := newTestClient("other")
+		room.addParticipant(other)
+		room.router(context.Background(), host, Message{Event: EventAcceptScreenshare, Payload: AcceptScreensharePayload{ClientId: other.ID}})
+
+		room.router(context.Background(), sharer, Message{Event: EventStopScreenshare, Payload: StopScreensharePayload{ClientId: other.ID}})
+
+		assertParticipant(t, room, sharer)
+		assert.Contains(t, room.sharingScreen, other.ID)
+	})
+
+	t.Run("stopping a share that is not running is rejected", func(t *testing.T) {
+		room, host, sharer := setup()
+		room.router(context.Background(), sharer, Message{Event: EventStopScreenshare, Payload: StopScreensharePayload(sharer.info())})
+		events(host)
+
+		room.router(context.Background(), host, Message{Event: EventStopScreenshare, Payload: StopScreensharePayload{ClientId: sharer.ID}})
+
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-host.send, &msg))
+		var errPayload ErrorPayload
+		require.NoError(t, json.Unmarshal(msg.Payload, &errPayload))
+		assert.Equal(t, ErrorCodeNotSharing, errPayload.Code)
+		assertParticipant(t, room, sharer)
+	})
+
+	t.Run("a host shares without approval and stops as a host", func(t *testing.T) {
+		room, host, sharer := setup()
+
+		room.router(context.Background(), host, Message{Event: EventRequestScreenshare, Payload: RequestScreensharePayload(host.info())})
+
+		assert.Equal(t, []Event{EventAcceptScreenshare}, events(host), "The host's own request is accepted at once")
+		assert.Equal(t, RoleTypeHost, host.Role)
+		assert.Equal(t, []ClientInfo{host.info(), sharer.info()}, room.roomState().SharingScreen)
+
+		room.router(context.Background(), host, Message{Event: EventStopScreenshare, Payload: StopScreensharePayload(host.info())})
+
+		assert.Equal(t, []Event{EventStopScreenshare}, events(sharer))
+		assert.Equal(t, RoleTypeHost, host.Role)
+		assert.Contains(t, room.hosts, host.ID, "Stopping leaves the host in place")
+		assert.Equal(t, []ClientInfo{sharer.info()}, room.roomState().SharingScreen)
+	})
+
+	t.Run("a host can stop another host's share", func(t *testing.T) {
+		room, host, _ := setup()
+		cohost := newTestClient("cohost")
+		room.addHost(cohost)
+		room.router(context.Background(), cohost, Message{Event: EventRequestScreenshare, Payload: RequestScreensharePayload(cohost.info())})
+
+		room.router(context.Background(), host, Message{Event: EventStopScreenshare, Payload: StopScreensharePayload{ClientId: cohost.ID}})
+
+		assert.False(t, room.isSharing(cohost.ID))
+		assert.Equal(t, RoleTypeHost, cohost.Role)
+	})
+}
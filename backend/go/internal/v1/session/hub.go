@@ -22,11 +22,14 @@
 package session
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"Social-Media/backend/go/internal/v1/auth"
 
@@ -79,8 +82,32 @@ type Hub struct {
 	rooms     map[RoomIdType]*Room // Registry of active rooms by room ID
 	mu        sync.Mutex           // Protects concurrent access to rooms map
 	validator TokenValidator       // JWT authentication service
+	config    HubConfig            // Hub settings and the configuration applied to every room it creates
+	draining  atomic.Bool          // Set by Drain; new connections are refused while set
+
+	// Open connections per subject per room, for MaxRoomsPerUser. Protected by mu.
+	userRooms map[ClientIdType]map[RoomIdType]int
+
+	// Per-IP connection throttle, nil when ConnectRatePerIP is zero
+	connectLimiter *ipRateLimiter
+
+	// Failed-attempt backoff per subject and, more leniently, per IP; nil
+	// when ReconnectBackoff is zero
+	reconnects   *reconnectBackoff
+	ipReconnects *reconnectBackoff
 }
 
+// Compile-time checks that room identifiers share a single type across the hub
+// and its rooms, and that Room satisfies the interface clients depend on.
+var (
+	_ func(*Hub, RoomIdType) (*Room, error) = (*Hub).getOrCreateRoom
+	_ func(*Hub, RoomIdType)                = (*Hub).removeRoom
+	_ Roomer                                = (*Room)(nil)
+)
+
+// errTooManyRooms is returned when creating a room would exceed HubConfig.MaxRooms.
+var errTooManyRooms = errors.New("hub is at its room limit")
+
 // ServeWs authenticates the user and hands them off to the room.
 // ServeWs upgrades an HTTP request to a WebSocket connection for real-time communication.
 // It authenticates the user using a JWT token provided as a query parameter, validates the token,
@@ -94,23 +121,69 @@ type Hub struct {
 //
 // Responses:
 //   - 401 Unauthorized if the token is missing or invalid.
+//   - 503 Service Unavailable if the room does not exist and the hub is
+//     already hosting HubConfig.MaxRooms rooms.
+//   - 429 Too Many Requests, with Retry-After, while the IP or subject is
+//     backing off after failed attempts; see reconnectBackoff.
 //   - Upgrades to WebSocket on success.
 func (h *Hub) ServeWs(c *gin.Context) {
+	// Refuse new connections while draining so load balancers route elsewhere
+	if h.IsDraining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is draining"})
+		return
+	}
+
+	// Throttle connection storms before doing any authentication work
+	if ip := c.ClientIP(); !h.connectLimiter.allow(ip) {
+		slog.Warn("Refused connection over the per-IP rate limit", "ip", ip)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many connection attempts"})
+		return
+	}
+
+	// Make clients that keep failing wait before trying again
+	ipKey := ipBackoffKey(c.ClientIP())
+	if h.refuseBackingOff(c, h.ipReconnects, ipKey) {
+		return
+	}
+
 	// --- AUTHENTICATION ---
 	tokenString := c.Query("token") // from Auth0
 	if tokenString == "" {
+		h.ipReconnects.fail(ipKey)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "token not provided"})
 		return
 	}
 
 	claims, err := h.validator.ValidateToken(tokenString)
 	if err != nil {
+		h.ipReconnects.fail(ipKey)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 		return
 	}
 
+	subject := ClientIdType(claims.Subject)
+	subjectKey := subjectBackoffKey(subject)
+	if h.refuseBackingOff(c, h.reconnects, subjectKey) {
+		return
+	}
+	session := c.Query("session")
+	if len(session) > maxSessionIdLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "session id too long"})
+		return
+	}
+	roomId := RoomIdType(c.Param("roomId"))
+	if !h.roomAvailable(roomId) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "too many rooms"})
+		return
+	}
+	if !h.acquireMembership(subject, roomId) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many rooms joined"})
+		return
+	}
+
 	allowedOrigins := GetAllowedOriginsFromEnv("ALLOWED_ORIGINS", []string{"http://localhost:3000"})
 	upgrader := websocket.Upgrader{
+		EnableCompression: h.config.EnableCompression,
 		// This is the secure way to check the origin.
 		CheckOrigin: func(r *http.Request) bool {
 			origin := r.Header.Get("Origin")
@@ -139,72 +212,389 @@ func (h *Hub) ServeWs(c *gin.Context) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		slog.Error("Failed to upgrade connection", "error", err)
+		h.ipReconnects.fail(ipKey)
+		h.reconnects.fail(subjectKey)
+		h.releaseMembership(subject, roomId)
 		return
 	}
+	// A no-op unless the client negotiated per-message deflate.
+	conn.EnableWriteCompression(h.config.EnableCompression)
 
 	// --- CLIENT & ROOM SETUP ---
-	roomId := c.Param("roomId")
-	room := h.getOrCreateRoom(RoomIdType(roomId))
-
-	displayName := claims.Subject // Fallback to subject if name is not in token
-	if claims.Name != "" {
-		displayName = claims.Name
-	} else if claims.Email != "" {
-		// Use email prefix as display name
-		if parts := strings.Split(claims.Email, "@"); len(parts) > 0 {
-			displayName = parts[0]
-		}
-	}
-
+	// The connection outlives the HTTP request, so keep the request's values,
+	// such as trace ids, but not its cancellation.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(c.Request.Context()))
 	client := &Client{
+		ctx:         ctx,
+		cancel:      cancel,
 		conn:        conn,
 		send:        make(chan []byte, 256),
-		room:        room,
-		ID:          ClientIdType(claims.Subject),
-		DisplayName: DisplayNameType(displayName),
+		ID:          subject,
+		DisplayName: h.displayNameFor(claims),
+		AvatarURL:   claims.Picture,
+		Pronouns:    claims.Pronouns,
 		Role:        RoleTypeHost, // Default role, should be derived from token scopes
+		closeNotice: make(chan closeNotice, 1),
+		done:        make(chan struct{}),
 	}
 
-	room.handleClientConnect(client)
+	_, err = h.joinRoom(roomId, client)
+	if err != nil {
+		// Rooms filled up between the check above and the upgrade
+		slog.Warn("Closing connection without a room", "ClientId", subject, "RoomId", roomId, "error", err)
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many rooms"))
+		conn.Close()
+		cancel()
+		h.releaseMembership(subject, roomId)
+		return
+	}
+	if client.refused {
+		h.ipReconnects.fail(ipKey)
+		h.reconnects.fail(subjectKey)
+	} else {
+		h.resetBackoffWhenStable(client, ipKey, subjectKey)
+		h.config.Room.ConnectionObserver.OnConnect(roomId, client.ID, claims.Subject)
+	}
+	linked := h.config.Sessions != nil && session != "" && !client.refused
+	sessionKey := sessionKey{subject: subject, session: session}
+	if linked {
+		h.config.Sessions.link(sessionKey, client)
+	}
 
 	// Start the client's goroutines.
 	go client.writePump()
-	go client.readPump()
+	go func() {
+		client.readPump()
+		// The client may have been moved to another room since it joined
+		if current, ok := client.currentRoom().(*Room); ok {
+			roomId = current.ID
+		}
+		h.releaseMembership(subject, roomId)
+		if linked {
+			h.endLinkedSessions(sessionKey, client)
+		}
+	}()
+}
+
+// endLinkedSessions unlinks a client whose connection has closed and, if
+// EndLinkedSessions is set and the client left intentionally or was kicked,
+// closes the connections linked to it on other hubs.
+//
+// Thread Safety: Must be called without hub or room locks held, after the
+// client's room has handled its disconnect.
+func (h *Hub) endLinkedSessions(key sessionKey, client *Client) {
+	var reason DisconnectReason
+	switch {
+	case client.kicked:
+		reason = DisconnectReasonKicked
+	case client.leaving:
+		reason = DisconnectReasonLeft
+	default:
+		reason = DisconnectReasonDropped
+	}
+	endSession := h.config.EndLinkedSessions && reason != DisconnectReasonDropped
+	for _, other := range h.config.Sessions.unlink(key, client, endSession) {
+		// Resolved now rather than when linked, since a client may have been moved
+		if room, ok := other.currentRoom().(*Room); ok {
+			room.endLinkedSession(other, reason)
+		}
+	}
+}
+
+// refuseBackingOff answers the request with 429 Too Many Requests if key is
+// still backing off in backoff after failed connection attempts.
+//
+// Returns:
+//   - true if the request was refused and the caller must stop handling it
+func (h *Hub) refuseBackingOff(c *gin.Context, backoff *reconnectBackoff, key string) bool {
+	wait := backoff.retryAfter(key)
+	if wait <= 0 {
+		return false
+	}
+	slog.Warn("Refused connection during reconnect backoff", "key", key, "retryAfter", wait)
+	c.Header("Retry-After", retryAfterSeconds(wait))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed connection attempts"})
+	return true
+}
+
+// acquireMembership records a new connection by subject to a room, refusing it
+// if it would put the subject in more than MaxRoomsPerUser rooms. Further
+// connections to a room the subject is already in are always allowed.
+//
+// Thread Safety: Acquires the hub lock.
+//
+// Returns:
+//   - true if the connection may proceed; it must later be released with releaseMembership
+func (h *Hub) acquireMembership(subject ClientIdType, roomId RoomIdType) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rooms := h.userRooms[subject]
+	if limit := h.config.MaxRoomsPerUser; limit > 0 && rooms[roomId] == 0 && len(rooms) >= limit {
+		slog.Warn("Refused connection over the per-user room limit", "ClientId", subject, "RoomId", roomId, "limit", limit)
+		return false
+	}
+	if rooms == nil {
+		rooms = make(map[RoomIdType]int)
+		h.userRooms[subject] = rooms
+	}
+	rooms[roomId]++
+	return true
+}
+
+// releaseMembership forgets a connection recorded by acquireMembership.
+//
+// Thread Safety: Acquires the hub lock.
+func (h *Hub) releaseMembership(subject ClientIdType, roomId RoomIdType) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rooms := h.userRooms[subject]
+	if rooms[roomId] <= 1 {
+		delete(rooms, roomId)
+	} else {
+		rooms[roomId]--
+	}
+	if len(rooms) == 0 {
+		delete(h.userRooms, subject)
+	}
 }
 
 // NewHub creates a new Hub and configures it with its dependencies.
-func NewHub(validator TokenValidator) *Hub {
+// The hub uses DefaultHubConfig, adjusted by opts in order; see options.go.
+//
+// Parameters:
+//   - validator: Validates the token each connection presents
+//   - opts: Settings that differ from DefaultHubConfig
+//
+// Returns:
+//   - *Hub: The configured hub, holding no rooms
+func NewHub(validator TokenValidator, opts ...HubOption) *Hub {
+	config := DefaultHubConfig()
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return NewHubWithConfig(validator, config)
+}
+
+// NewHubWithConfig creates a new Hub with the given settings.
+// Rooms created by the hub use config.Room.
+func NewHubWithConfig(validator TokenValidator, config HubConfig) *Hub {
+	if config.Room.ConnectionObserver == nil {
+		config.Room.ConnectionObserver = NoopConnectionObserver{}
+	}
+	if config.FallbackDisplayName == nil {
+		config.FallbackDisplayName = GuestDisplayName
+	}
+	if config.MaxDisplayNameLength < 1 {
+		config.MaxDisplayNameLength = DefaultMaxDisplayNameLength
+	}
 	return &Hub{
 		rooms:     make(map[RoomIdType]*Room),
 		validator: validator,
+		config:    config,
+		userRooms: make(map[ClientIdType]map[RoomIdType]int),
+
+		connectLimiter: newIPRateLimiter(config.ConnectRatePerIP, config.ConnectBurstPerIP, config.Room.Clock),
+		reconnects:     newReconnectBackoff(config.ReconnectBackoff, config.ReconnectBackoffMax, 1, config.Room.Clock),
+		ipReconnects:   newReconnectBackoff(config.ReconnectBackoff, config.ReconnectBackoffMax, config.ReconnectBackoffIPFailures, config.Room.Clock),
+	}
+}
+
+// displayNameFor picks the name shown for a client in rosters and chat: the
+// token's name, then the part of its email before the @, and otherwise the
+// hub's FallbackDisplayName, so opaque subjects such as "auth0|abc123" are
+// never shown to other users. Each candidate is passed through
+// sanitizeDisplayName and capped at MaxDisplayNameLength, so a name that is
+// blank once cleaned falls through to the next.
+func (h *Hub) displayNameFor(claims *auth.CustomClaims) DisplayNameType {
+	limit := h.config.MaxDisplayNameLength
+	if name := sanitizeDisplayName(claims.Name, limit); name != "" {
+		return name
+	}
+	local, _, _ := strings.Cut(claims.Email, "@")
+	if name := sanitizeDisplayName(local, limit); name != "" {
+		return name
+	}
+	return sanitizeDisplayName(string(h.config.FallbackDisplayName(ClientIdType(claims.Subject))), limit)
+}
+
+// BroadcastToAll sends an event to every client in every room on the hub,
+// including waiting clients. It is intended for operator announcements.
+//
+// Thread Safety:
+// The room registry is snapshotted under the hub lock, which is released before
+// any room lock is taken, so broadcasting never holds the hub lock while rooms
+// are busy and cannot deadlock against room removal. Rooms removed after the
+// snapshot are already empty, so broadcasting to them is harmless.
+//
+// Parameters:
+//   - event: The event to send
+//   - payload: The event payload
+func (h *Hub) BroadcastToAll(event Event, payload any) {
+	h.mu.Lock()
+	rooms := make([]*Room, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	h.mu.Unlock()
+
+	for _, room := range rooms {
+		room.mu.Lock()
+		room.broadcast(room.ctx, event, payload, nil)
+		room.mu.Unlock()
 	}
+	slog.Info("Broadcast event to all rooms", "event", event, "rooms", len(rooms))
+}
+
+// Drain stops the hub from accepting new WebSocket connections, for rolling
+// deploys. ServeWs responds 503 Service Unavailable from then on, while clients
+// that are already connected keep their meetings until they leave. Draining
+// cannot be undone; the process is expected to exit once its rooms empty.
+//
+// Thread Safety: Safe to call concurrently and more than once.
+func (h *Hub) Drain() {
+	if h.draining.CompareAndSwap(false, true) {
+		h.mu.Lock()
+		rooms := len(h.rooms)
+		h.mu.Unlock()
+		slog.Info("Hub draining, refusing new connections", "activeRooms", rooms)
+	}
+}
+
+// IsDraining reports whether Drain has been called.
+func (h *Hub) IsDraining() bool {
+	return h.draining.Load()
 }
 
 // removeRoom is a private method for the Hub to clean up empty rooms.
 func (h *Hub) removeRoom(roomId RoomIdType) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
+	room, ok := h.rooms[roomId]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+
+	// Check the room is still empty before deleting. Lock order is always
+	// hub then room, so taking the room lock here cannot deadlock. A client
+	// that joined after the room emptied, even one only waiting, keeps it.
+	// Marking the room closed under the same lock as the check means a join
+	// racing with removal either lands first and keeps the room, or sees it
+	// closed and retries, finding it gone from the hub.
+	room.mu.Lock()
+	empty := room.isRoomEmpty() && len(room.waiting) == 0
+	var stats RoomStats
+	if empty {
+		room.closed = true
+		stats = room.stats()
+	}
+	room.mu.Unlock()
 
-	// Check if the room still exists and is empty before deleting.
-	if room, ok := h.rooms[roomId]; ok && len(room.participants) == 0 {
+	if empty {
 		delete(h.rooms, roomId)
-		slog.Info("Removed empty room from hub", "roomId", roomId)
+	}
+	h.mu.Unlock()
+	if !empty {
+		return
+	}
+
+	room.close()
+	logRoomLifecycle(h.config.Room.HandlerLog.logger(), stats)
+	// Final stats are handed over only once removal is certain, without the
+	// hub lock, so a client rejoining in the meantime cannot cause them to be
+	// emitted twice.
+	if room.config.OnClose != nil {
+		room.config.OnClose(stats)
+	}
+}
+
+// logRoomLifecycle writes one structured summary of a removed room, giving
+// operators per-meeting telemetry without a metrics pipeline.
+//
+// Parameters:
+//   - logger: Receives the summary at Info level
+//   - stats: The room's statistics, taken as it was removed
+func logRoomLifecycle(logger *slog.Logger, stats RoomStats) {
+	logger.Info("Removed empty room from hub",
+		"roomId", stats.RoomID,
+		"createdAt", stats.CreatedAt,
+		"duration", stats.Duration,
+		"peakParticipants", stats.PeakParticipants,
+		"totalJoins", stats.TotalJoins,
+		"totalMessages", stats.TotalChats)
+}
+
+// joinRoom connects a client to the hub's room for roomId, creating the room
+// if it does not exist.
+//
+// Removal Race:
+// The last client leaving a room schedules its removal, which runs later
+// without the room lock. A client joining in between is placed in the room,
+// which removeRoom then keeps. A client that finds the room already closed by
+// removeRoom is turned away and looks it up again; by then the room is gone
+// from the hub, so a fresh one is created.
+//
+// Parameters:
+//   - roomId: The room to join
+//   - client: The joining client; its room is set to the room it joined
+//
+// Returns:
+//   - *Room: The room the client joined
+//   - error: errTooManyRooms if the room had to be created and the hub is full
+func (h *Hub) joinRoom(roomId RoomIdType, client *Client) (*Room, error) {
+	for {
+		room, err := h.getOrCreateRoom(roomId)
+		if err != nil {
+			return nil, err
+		}
+		client.setRoom(room)
+		if room.handleClientConnect(client) {
+			return room, nil
+		}
 	}
 }
 
 // getOrCreateRoom retrieves the Room associated with the given RoomId from the Hub.
 // If the Room does not exist, it creates a new Room, stores it in the Hub, and returns it.
+// Creation is refused with errTooManyRooms when the hub already holds
+// HubConfig.MaxRooms rooms; existing rooms are always returned.
 // This method is safe for concurrent use.
-func (h *Hub) getOrCreateRoom(roomId RoomIdType) *Room {
+func (h *Hub) getOrCreateRoom(roomId RoomIdType) (*Room, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	return h.getOrCreateRoomLocked(roomId)
+}
 
+// getOrCreateRoomLocked is getOrCreateRoom for callers already holding h.mu.
+func (h *Hub) getOrCreateRoomLocked(roomId RoomIdType) (*Room, error) {
 	if room, ok := h.rooms[roomId]; ok {
-		return room
+		return room, nil
+	}
+	if h.atRoomLimit() {
+		slog.Warn("Refused to create a room over the hub's room limit", "RoomId", roomId, "limit", h.config.MaxRooms)
+		return nil, errTooManyRooms
 	}
 
-	slog.Info("Creating new session room", "roomroomId", roomId)
-	room := NewRoom(roomId, h.removeRoom)
+	slog.Info("Creating new session room", "RoomId", roomId)
+	room := NewRoomWithConfig(roomId, h.config.Room, h.removeRoom)
 	h.rooms[roomId] = room
-	return room
+	return room, nil
+}
+
+// roomAvailable reports whether a client could join roomId now: the room
+// exists, or the hub has room to create it.
+//
+// Thread Safety: Acquires the hub lock.
+func (h *Hub) roomAvailable(roomId RoomIdType) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, exists := h.rooms[roomId]
+	return exists || !h.atRoomLimit()
+}
+
+// atRoomLimit reports whether the hub holds HubConfig.MaxRooms rooms. The
+// caller must hold h.mu.
+func (h *Hub) atRoomLimit() bool {
+	return h.config.MaxRooms > 0 && len(h.rooms) >= h.config.MaxRooms
 }
@@ -1,14 +1,24 @@
 package session
 
 import (
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"Social-Media/backend/go/internal/v1/auth"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	testclock "k8s.io/utils/clock/testing"
 )
 
 // MockValidator is a mock implementation of the TokenValidator interface for testing.
@@ -23,12 +33,12 @@ func (m *MockValidator) ValidateToken(tokenString string) (*auth.CustomClaims, e
 }
 
 // NewTestHub creates a new Hub with a mock validator for testing purposes.
-func NewTestHub(mockValidator TokenValidator) *Hub {
+func NewTestHub(mockValidator TokenValidator, opts ...HubOption) *Hub {
 	if mockValidator == nil {
 		// Provide a default mock if none is given
 		mockValidator = &MockValidator{}
 	}
-	return NewHub(mockValidator)
+	return NewHub(mockValidator, opts...)
 }
 
 func TestGetOrCreateRoom(t *testing.T) {
@@ -36,7 +46,7 @@ func TestGetOrCreateRoom(t *testing.T) {
 	var roomId RoomIdType = "test-room-1"
 
 	// First call should create the room
-	room1 := hub.getOrCreateRoom(roomId)
+	room1, _ := hub.getOrCreateRoom(roomId)
 	require.NotNil(t, room1, "getOrCreateRoom should not return nil")
 	assert.Equal(t, roomId, room1.ID, "Room ID should match the one provided")
 
@@ -47,7 +57,7 @@ func TestGetOrCreateRoom(t *testing.T) {
 	assert.True(t, exists, "Room should exist in the hub's map after creation")
 
 	// Second call should return the exact same room instance
-	room2 := hub.getOrCreateRoom(roomId)
+	room2, _ := hub.getOrCreateRoom(roomId)
 	assert.Same(t, room1, room2, "Subsequent calls with the same ID should return the same room instance")
 }
 
@@ -91,3 +101,509 @@ func TestRemoveHub(t *testing.T) {
 	assert.Empty(t, hub.rooms)
 	assert.Empty(t, hub.rooms[testID])
 }
+
+func TestRemoveRoomOnlyRemovesEmptyRooms(t *testing.T) {
+	t.Run("room with only a host is kept", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		room, _ := hub.getOrCreateRoom("host-only")
+		room.addHost(newTestClient("host-1"))
+
+		hub.removeRoom("host-only")
+
+		assert.Contains(t, hub.rooms, RoomIdType("host-only"), "A room with a host should not be removed")
+	})
+
+	t.Run("room emptied through disconnects is removed once", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		room, _ := hub.getOrCreateRoom("emptied")
+		host := newTestClient("host-1")
+		waiting := newTestClient("waiting-1")
+		room.handleClientConnect(host)
+		room.handleClientConnect(waiting)
+
+		room.handleClientDisconnect(host)
+		room.handleClientDisconnect(waiting)
+
+		assert.Eventually(t, func() bool {
+			hub.mu.Lock()
+			defer hub.mu.Unlock()
+			return len(hub.rooms) == 0
+		}, time.Second, 5*time.Millisecond, "The emptied room should be removed from the hub")
+	})
+}
+
+func TestRoomLifecycleLog(t *testing.T) {
+	handler := &capturingHandler{}
+	fakeClock := testclock.NewFakeClock(time.Now())
+	config := DefaultHubConfig()
+	config.Room.Clock = fakeClock
+	config.Room.HandlerLog.Logger = slog.New(handler)
+	hub := NewHubWithConfig(&MockValidator{}, config)
+	room, err := hub.getOrCreateRoom("meeting")
+	require.NoError(t, err)
+
+	host, guest := newTestClient("host"), newTestClient("guest")
+	room.mu.Lock()
+	room.addHost(host)
+	room.addParticipant(guest)
+	room.totalChats = 3
+	room.deleteParticipant(guest)
+	room.deleteHost(host)
+	room.mu.Unlock()
+	fakeClock.Step(90 * time.Minute)
+
+	hub.removeRoom("meeting")
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	var summary map[string]slog.Value
+	for _, record := range handler.records {
+		if record.Message == "Removed empty room from hub" {
+			summary = make(map[string]slog.Value)
+			record.Attrs(func(a slog.Attr) bool {
+				summary[a.Key] = a.Value
+				return true
+			})
+		}
+	}
+	require.NotNil(t, summary, "Removing the room should log its summary")
+	assert.Equal(t, "meeting", summary["roomId"].String())
+	assert.Equal(t, 90*time.Minute, summary["duration"].Duration())
+	assert.Equal(t, int64(2), summary["peakParticipants"].Int64())
+	assert.Equal(t, uint64(3), summary["totalMessages"].Uint64())
+}
+
+func TestRoomRemovalRace(t *testing.T) {
+	// roomCount reads the hub's room registry under its lock.
+	roomCount := func(hub *Hub) int {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		return len(hub.rooms)
+	}
+
+	t.Run("a client joining before removal keeps the room", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		first := newTestClient("first")
+		room, _ := hub.joinRoom("room", first)
+		room.handleClientDisconnect(first) // schedules removal
+
+		again := newTestClient("again")
+		joined, _ := hub.joinRoom("room", again)
+		assert.Same(t, room, joined)
+
+		// Whether removal ran before or after the join, the room is in use
+		hub.removeRoom("room")
+		assert.Equal(t, 1, roomCount(hub))
+
+		room.handleClientDisconnect(again)
+		assert.Eventually(t, func() bool { return roomCount(hub) == 0 }, time.Second, 5*time.Millisecond,
+			"The room should be removed when it empties again")
+	})
+
+	t.Run("final stats are handed over once the room is really removed", func(t *testing.T) {
+		var closes atomic.Int32
+		config := DefaultRoomConfig()
+		config.OnClose = func(RoomStats) { closes.Add(1) }
+		hub := NewTestHub(nil, WithRoomConfig(config))
+		first := newTestClient("first")
+		room, _ := hub.joinRoom("room", first)
+		// Hold back the scheduled removal so the rejoin always wins the race
+		room.mu.Lock()
+		room.onEmpty = func(RoomIdType) {}
+		room.mu.Unlock()
+
+		room.handleClientDisconnect(first)
+		again := newTestClient("again")
+		hub.joinRoom("room", again)
+		hub.removeRoom("room")
+		assert.Zero(t, closes.Load(), "A rejoined room has not closed")
+
+		room.handleClientDisconnect(again)
+		hub.removeRoom("room")
+		assert.Equal(t, int32(1), closes.Load())
+		assert.Zero(t, roomCount(hub))
+	})
+
+	t.Run("a waiting joiner keeps the room", func(t *testing.T) {
+		config := DefaultHubConfig()
+		config.Room.HostClientIds = map[RoomIdType][]ClientIdType{"room": {"organizer"}}
+		hub := NewHubWithConfig(&MockValidator{}, config)
+		room, _ := hub.getOrCreateRoom("room")
+		room.handleClientConnect(newTestClient("early-bird"))
+
+		hub.removeRoom("room")
+
+		assert.Equal(t, 1, roomCount(hub), "Removing the room would strand the waiting client")
+	})
+
+	t.Run("a client arriving after removal gets a fresh room", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		stale, _ := hub.getOrCreateRoom("room")
+		hub.removeRoom("room")
+
+		late := newTestClient("late")
+		assert.False(t, stale.handleClientConnect(late), "A closed room should turn joiners away")
+
+		fresh, _ := hub.joinRoom("room", late)
+		assert.NotSame(t, stale, fresh)
+		assert.Same(t, fresh, late.room)
+		assert.Contains(t, fresh.hosts, late.ID)
+	})
+
+	t.Run("rapid joins and leaves never attach to a removed room", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		const workers, rounds = 4, 200
+
+		var wg sync.WaitGroup
+		var stranded atomic.Int32
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < rounds; i++ {
+					client := newTestClient(ClientIdType(fmt.Sprintf("client-%d-%d", w, i)))
+					room, _ := hub.joinRoom("room", client)
+
+					// While the client is in the room, the hub must still hold it
+					hub.mu.Lock()
+					if hub.rooms["room"] != room {
+						stranded.Add(1)
+					}
+					hub.mu.Unlock()
+
+					runtime.Gosched()
+					room.handleClientDisconnect(client)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Zero(t, stranded.Load(), "Clients were placed in rooms the hub had removed")
+		assert.Eventually(t, func() bool { return roomCount(hub) == 0 }, time.Second, 5*time.Millisecond,
+			"Every room should be removed once everyone has left")
+	})
+}
+
+func TestServeWsCompression(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// dial connects a compression-capable client to a hub served over a real socket.
+	dial := func(t *testing.T, enabled bool) (*websocket.Conn, *http.Response) {
+		t.Helper()
+		config := DefaultHubConfig()
+		config.EnableCompression = enabled
+		hub := NewHubWithConfig(&MockValidator{ClaimsToReturn: &auth.CustomClaims{
+			Name:             "Host User",
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "host-1"},
+		}}, config)
+		router := gin.New()
+		router.GET("/ws/:roomId", hub.ServeWs)
+		server := httptest.NewServer(router)
+		t.Cleanup(server.Close)
+
+		dialer := websocket.Dialer{EnableCompression: true}
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/room-1?token=valid"
+		conn, resp, err := dialer.Dial(url, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return conn, resp
+	}
+
+	t.Run("compression is negotiated when enabled and messages round-trip", func(t *testing.T) {
+		conn, resp := dial(t, true)
+		assert.Contains(t, resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+		// A large malformed message is decompressed by the server, which replies
+		// with a (compressed) parse error.
+		garbage := strings.Repeat("not json ", 100)
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(garbage)))
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		var msg struct {
+			Event   Event        `json:"event"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, EventError, msg.Event)
+		assert.Equal(t, ErrorCodeParse, msg.Payload.Code)
+	})
+
+	t.Run("compression is not negotiated when disabled", func(t *testing.T) {
+		_, resp := dial(t, false)
+		assert.NotContains(t, resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+	})
+}
+
+func TestServeWsProfileClaims(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// connect joins room-1 over a real socket with the given claims and returns
+	// the room state once the client has been placed.
+	connect := func(t *testing.T, claims *auth.CustomClaims) RoomStatePayload {
+		t.Helper()
+		hub := NewTestHub(&MockValidator{ClaimsToReturn: claims})
+		router := gin.New()
+		router.GET("/ws/:roomId", hub.ServeWs)
+		server := httptest.NewServer(router)
+		t.Cleanup(server.Close)
+
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/room-1?token=valid"
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		var state RoomStatePayload
+		require.Eventually(t, func() bool {
+			hub.mu.Lock()
+			room := hub.rooms["room-1"]
+			hub.mu.Unlock()
+			if room == nil {
+				return false
+			}
+			state = room.getRoomState()
+			return len(state.Hosts) == 1
+		}, time.Second, 10*time.Millisecond)
+		return state
+	}
+
+	t.Run("avatar and pronouns flow into the room state", func(t *testing.T) {
+		state := connect(t, &auth.CustomClaims{
+			Name:             "Host User",
+			Picture:          "https://example.com/avatar.png",
+			Pronouns:         "they/them",
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "host-1"},
+		})
+
+		assert.Equal(t, ClientInfo{
+			ClientId:    "host-1",
+			DisplayName: "Host User",
+			AvatarURL:   "https://example.com/avatar.png",
+			Pronouns:    "they/them",
+		}, state.Hosts[0])
+	})
+
+	t.Run("tokens without profile claims default to empty", func(t *testing.T) {
+		state := connect(t, &auth.CustomClaims{
+			Name:             "Host User",
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "host-1"},
+		})
+
+		assert.Empty(t, state.Hosts[0].AvatarURL)
+		assert.Empty(t, state.Hosts[0].Pronouns)
+	})
+
+	t.Run("tokens without a name or email get a friendly name", func(t *testing.T) {
+		state := connect(t, &auth.CustomClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "auth0|abc123"},
+		})
+
+		assert.Equal(t, GuestDisplayName("auth0|abc123"), state.Hosts[0].DisplayName)
+	})
+}
+
+func TestDisplayNameFor(t *testing.T) {
+	hub := NewTestHub(nil)
+
+	tests := []struct {
+		name   string
+		claims auth.CustomClaims
+		want   DisplayNameType
+	}{
+		{
+			name:   "name is preferred",
+			claims: auth.CustomClaims{Name: "Ada Lovelace", Email: "ada@example.com"},
+			want:   "Ada Lovelace",
+		},
+		{
+			name:   "email prefix when there is no name",
+			claims: auth.CustomClaims{Email: "ada@example.com"},
+			want:   "ada",
+		},
+		{
+			name:   "email without a local part falls back",
+			claims: auth.CustomClaims{Email: "@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "auth0|abc123"}},
+			want:   GuestDisplayName("auth0|abc123"),
+		},
+		{
+			name:   "oversized name is capped",
+			claims: auth.CustomClaims{Name: strings.Repeat("A", 10*1024)},
+			want:   DisplayNameType(strings.Repeat("A", DefaultMaxDisplayNameLength)),
+		},
+		{
+			name:   "control characters are stripped",
+			claims: auth.CustomClaims{Name: "Ada\n[host] Mallory\x1b[0m"},
+			want:   "Ada [host] Mallory[0m",
+		},
+		{
+			name:   "all-whitespace name falls back to the email",
+			claims: auth.CustomClaims{Name: " \t\n ", Email: "ada@example.com"},
+			want:   "ada",
+		},
+		{
+			name:   "all-whitespace name and email fall back to a generated name",
+			claims: auth.CustomClaims{Name: "   ", Email: "\n@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "auth0|abc123"}},
+			want:   GuestDisplayName("auth0|abc123"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, hub.displayNameFor(&tt.claims))
+		})
+	}
+
+	t.Run("fallback never shows the raw subject", func(t *testing.T) {
+		claims := &auth.CustomClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "auth0|abc123"}}
+
+		name := hub.displayNameFor(claims)
+		assert.Regexp(t, `^Guest \d{4}$`, name)
+		assert.NotContains(t, name, "abc123")
+		assert.Equal(t, name, hub.displayNameFor(claims), "The same subject should keep the same name")
+	})
+
+	t.Run("the generator is configurable", func(t *testing.T) {
+		config := DefaultHubConfig()
+		config.FallbackDisplayName = func(subject ClientIdType) DisplayNameType { return "Curious Otter" }
+		hub := NewHubWithConfig(&MockValidator{}, config)
+
+		claims := &auth.CustomClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "auth0|abc123"}}
+		assert.Equal(t, DisplayNameType("Curious Otter"), hub.displayNameFor(claims))
+	})
+
+	t.Run("the length cap is configurable", func(t *testing.T) {
+		config := DefaultHubConfig()
+		config.MaxDisplayNameLength = 3
+		hub := NewHubWithConfig(&MockValidator{}, config)
+
+		assert.Equal(t, DisplayNameType("Ada"), hub.displayNameFor(&auth.CustomClaims{Name: "Ada Lovelace"}))
+	})
+}
+
+func TestMaxRoomsPerUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := DefaultHubConfig()
+	config.MaxRoomsPerUser = 2
+	hub := NewHubWithConfig(&MockValidator{ClaimsToReturn: &auth.CustomClaims{
+		Name:             "Busy User",
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"},
+	}}, config)
+	router := gin.New()
+	router.GET("/ws/:roomId", hub.ServeWs)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	join := func(roomId string) (*websocket.Conn, int) {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/" + roomId + "?token=valid"
+		conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			require.NotNil(t, resp, "dial failed without a response: %v", err)
+			return nil, resp.StatusCode
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn, resp.StatusCode
+	}
+
+	first, status := join("room-1")
+	require.Equal(t, http.StatusSwitchingProtocols, status)
+	_, status = join("room-2")
+	require.Equal(t, http.StatusSwitchingProtocols, status)
+
+	_, status = join("room-3")
+	assert.Equal(t, http.StatusTooManyRequests, status, "A third room should be refused")
+
+	_, status = join("room-2")
+	assert.Equal(t, http.StatusSwitchingProtocols, status, "Another connection to a joined room does not count")
+
+	// Leaving a room frees a slot once the server has processed the disconnect
+	require.NoError(t, first.Close())
+	require.Eventually(t, func() bool {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		return len(hub.userRooms["user-1"]) < 2
+	}, time.Second, 10*time.Millisecond)
+
+	_, status = join("room-3")
+	assert.Equal(t, http.StatusSwitchingProtocols, status)
+}
+
+func TestMaxRooms(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultHubConfig()
+	config.MaxRooms = 2
+	hub := NewHubWithConfig(&MockValidator{ClaimsToReturn: &auth.CustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"},
+	}}, config)
+
+	first, err := hub.getOrCreateRoom("room-1")
+	require.NoError(t, err)
+	_, err = hub.getOrCreateRoom("room-2")
+	require.NoError(t, err)
+
+	t.Run("a new room beyond the cap is refused", func(t *testing.T) {
+		room, err := hub.getOrCreateRoom("room-3")
+		assert.ErrorIs(t, err, errTooManyRooms)
+		assert.Nil(t, room)
+
+		_, err = hub.joinRoom("room-3", newTestClient("late"))
+		assert.ErrorIs(t, err, errTooManyRooms)
+		hub.mu.Lock()
+		assert.Len(t, hub.rooms, 2)
+		hub.mu.Unlock()
+	})
+
+	t.Run("existing rooms can still be joined", func(t *testing.T) {
+		room, err := hub.getOrCreateRoom("room-1")
+		require.NoError(t, err)
+		assert.Same(t, first, room)
+
+		joined, err := hub.joinRoom("room-1", newTestClient("guest"))
+		require.NoError(t, err)
+		assert.Same(t, first, joined)
+	})
+
+	t.Run("ServeWs answers 503 for a room it cannot create", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/ws/:roomId", hub.ServeWs)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws/room-3?token=valid", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		hub.mu.Lock()
+		assert.Empty(t, hub.userRooms, "A refused connection holds no membership")
+		hub.mu.Unlock()
+	})
+}
+
+// clientPumps counts the goroutines running a client's read or write pump.
+func clientPumps() int {
+	buf := make([]byte, 1<<20)
+	stacks := string(buf[:runtime.Stack(buf, true)])
+	return strings.Count(stacks, "(*Client).readPump") + strings.Count(stacks, "(*Client).writePump")
+}
+
+func TestRemoveRoomStopsBackgroundWork(t *testing.T) {
+	config := DefaultHubConfig()
+	config.Room.IdleTimeout = time.Hour
+	server := newTestServer(t, config, "host", "guest")
+	before := clientPumps()
+
+	host := server.dial(t, "room-1", "host")
+	guest := server.dial(t, "room-1", "guest")
+	server.hub.mu.Lock()
+	room := server.hub.rooms["room-1"]
+	server.hub.mu.Unlock()
+	require.NotNil(t, room)
+	assert.GreaterOrEqual(t, clientPumps(), 4, "Each connection runs read and write pumps")
+
+	require.NoError(t, guest.conn.Close())
+	require.NoError(t, host.conn.Close())
+
+	require.Eventually(t, func() bool { return room.ctx.Err() != nil }, time.Second, 5*time.Millisecond,
+		"Removing the room closes it")
+	server.hub.mu.Lock()
+	assert.NotContains(t, server.hub.rooms, RoomIdType("room-1"))
+	server.hub.mu.Unlock()
+
+	assert.Eventually(t, func() bool { return clientPumps() <= before }, time.Second, 5*time.Millisecond,
+		"Both pumps of every connection exit once the room is removed")
+}
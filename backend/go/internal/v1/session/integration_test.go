@@ -0,0 +1,240 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Social-Media/backend/go/internal/v1/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenTable is a TokenValidator that accepts a fixed set of tokens, so each
+// test client can connect as a different user.
+type tokenTable map[string]*auth.CustomClaims
+
+func (tt tokenTable) ValidateToken(token string) (*auth.CustomClaims, error) {
+	if claims, ok := tt[token]; ok {
+		return claims, nil
+	}
+	return nil, errors.New("unknown token")
+}
+
+// testServer is a Hub served over HTTP by a real httptest.Server.
+type testServer struct {
+	hub *Hub
+	url string
+}
+
+// newTestServer starts a hub on /ws/:roomId that accepts one token per user,
+// named after the user's client id.
+func newTestServer(t *testing.T, config HubConfig, users ...string) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	tokens := make(tokenTable, len(users))
+	for _, user := range users {
+		tokens[user] = &auth.CustomClaims{
+			Name:             strings.ToUpper(user[:1]) + user[1:],
+			RegisteredClaims: jwt.RegisteredClaims{Subject: user},
+		}
+	}
+
+	hub := NewHubWithConfig(tokens, config)
+	router := gin.New()
+	router.GET("/ws/:roomId", hub.ServeWs)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &testServer{hub: hub, url: "ws" + strings.TrimPrefix(server.URL, "http")}
+}
+
+// wsClient is a real WebSocket connection to a testServer.
+type wsClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+// wireMessage is a message as read off the wire, with its payload left encoded.
+type wireMessage struct {
+	Event   Event           `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// dial connects user to room and waits until the room has placed them, so
+// that tests observe a settled roster.
+func (s *testServer) dial(t *testing.T, room RoomIdType, user string) *wsClient {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(s.url+"/ws/"+string(room)+"?token="+user, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	require.Eventually(t, func() bool {
+		s.hub.mu.Lock()
+		r := s.hub.rooms[room]
+		s.hub.mu.Unlock()
+		if r == nil {
+			return false
+		}
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		_, admitted := r.findPeer(ClientIdType(user))
+		_, waiting := r.waiting[ClientIdType(user)]
+		return admitted || waiting
+	}, time.Second, 5*time.Millisecond, "%s was never placed in %s", user, room)
+
+	return &wsClient{t: t, conn: conn}
+}
+
+// send writes a message over the socket.
+func (c *wsClient) send(event Event, payload any) {
+	c.t.Helper()
+	require.NoError(c.t, c.conn.WriteJSON(Message{Event: event, Payload: payload}))
+}
+
+// expect reads messages until one with the given event arrives, decoding its
+// payload into out if out is not nil. Other messages are skipped.
+func (c *wsClient) expect(event Event, out any) {
+	c.t.Helper()
+	require.NoError(c.t, c.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for {
+		var msg wireMessage
+		require.NoError(c.t, c.conn.ReadJSON(&msg), "waiting for %q", event)
+		if msg.Event != event {
+			continue
+		}
+		if out != nil {
+			require.NoError(c.t, json.Unmarshal(msg.Payload, out))
+		}
+		return
+	}
+}
+
+// admit runs the waiting-room flow over the wire: guest asks to join, the
+// host sees the request and accepts it, and guest is told it was admitted.
+func admit(t *testing.T, host, guest *wsClient, guestId ClientIdType) {
+	t.Helper()
+	guest.send(EventRequestWaiting, RequestWaitingPayload{ClientId: guestId})
+
+	var request ClientInfo
+	host.expect(EventRequestWaiting, &request)
+	require.Equal(t, guestId, request.ClientId)
+
+	host.send(EventAcceptWaiting, AcceptWaitingPayload{ClientId: guestId})
+	var accepted ClientInfo
+	guest.expect(EventAcceptWaiting, &accepted)
+	require.Equal(t, guestId, accepted.ClientId)
+}
+
+func TestIntegration(t *testing.T) {
+	t.Run("waiting room admit flow", func(t *testing.T) {
+		server := newTestServer(t, DefaultHubConfig(), "host", "guest")
+		host := server.dial(t, "room-1", "host")
+		guest := server.dial(t, "room-1", "guest")
+
+		guest.send(EventRequestWaiting, RequestWaitingPayload{ClientId: "guest"})
+		var request ClientInfo
+		host.expect(EventRequestWaiting, &request)
+		assert.Equal(t, ClientInfo{ClientId: "guest", DisplayName: "Guest"}, request)
+
+		host.send(EventAcceptWaiting, AcceptWaitingPayload{ClientId: "guest"})
+
+		// The admitted client learns its peers' media state before the
+		// admission is announced to the room.
+		var snapshot MediaStateSnapshotPayload
+		guest.expect(EventMediaStateSnapshot, &snapshot)
+		require.Len(t, snapshot.Peers, 1)
+		assert.Equal(t, ClientIdType("host"), snapshot.Peers[0].ClientId)
+
+		for _, c := range []*wsClient{host, guest} {
+			var accepted ClientInfo
+			c.expect(EventAcceptWaiting, &accepted)
+			assert.Equal(t, ClientIdType("guest"), accepted.ClientId)
+		}
+	})
+
+	t.Run("chat round trip", func(t *testing.T) {
+		server := newTestServer(t, DefaultHubConfig(), "host", "guest")
+		host := server.dial(t, "room-1", "host")
+		guest := server.dial(t, "room-1", "guest")
+		admit(t, host, guest, "guest")
+
+		guest.send(EventAddChat, AddChatPayload{
+			ClientInfo:  ClientInfo{ClientId: "guest", DisplayName: "Guest"},
+			ChatId:      "chat-1",
+			ChatContent: "hello over the wire",
+		})
+
+		var chatId ChatId
+		for _, c := range []*wsClient{host, guest} {
+			var chat ChatInfo
+			c.expect(EventAddChat, &chat)
+			assert.Equal(t, ChatContent("hello over the wire"), chat.ChatContent)
+			assert.Equal(t, ClientIdType("guest"), chat.ClientId)
+			assert.NotZero(t, chat.Timestamp, "The server stamps the time")
+			assert.NotEqual(t, ChatId("chat-1"), chat.ChatId, "The server assigns the id")
+			chatId = chat.ChatId
+		}
+
+		host.send(EventGetRecentChats, GetRecentChatsPayload{ClientInfo: ClientInfo{ClientId: "host", DisplayName: "Host"}})
+		var history []ChatInfo
+		host.expect(EventGetRecentChats, &history)
+		require.Len(t, history, 1)
+		assert.Equal(t, chatId, history[0].ChatId)
+	})
+
+	t.Run("dropped connection is broadcast", func(t *testing.T) {
+		server := newTestServer(t, DefaultHubConfig(), "host", "guest")
+		host := server.dial(t, "room-1", "host")
+		guest := server.dial(t, "room-1", "guest")
+		admit(t, host, guest, "guest")
+
+		require.NoError(t, guest.conn.Close())
+
+		var gone ClientDisconnectPayload
+		host.expect(EventDisconnect, &gone)
+		assert.Equal(t, ClientIdType("guest"), gone.ClientId)
+	})
+
+	t.Run("graceful leave is broadcast", func(t *testing.T) {
+		server := newTestServer(t, DefaultHubConfig(), "host", "guest")
+		host := server.dial(t, "room-1", "host")
+		guest := server.dial(t, "room-1", "guest")
+		admit(t, host, guest, "guest")
+
+		guest.send(EventLeave, LeavePayload{ClientId: "guest"})
+
+		var left ParticipantLeftPayload
+		host.expect(EventParticipantLeft, &left)
+		assert.Equal(t, ClientIdType("guest"), left.ClientId)
+	})
+
+	t.Run("kicked client receives the notice before the connection closes", func(t *testing.T) {
+		server := newTestServer(t, DefaultHubConfig(), "host", "guest")
+		host := server.dial(t, "room-1", "host")
+		guest := server.dial(t, "room-1", "guest")
+		admit(t, host, guest, "guest")
+
+		host.send(EventKick, KickPayload{ClientInfo: ClientInfo{ClientId: "guest"}, Reason: "off topic"})
+
+		var notice KickedPayload
+		guest.expect(EventKicked, &notice)
+		assert.Equal(t, "off topic", notice.Reason)
+
+		var msg wireMessage
+		err := guest.conn.ReadJSON(&msg)
+		assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "got %v", err)
+
+		var kicked KickPayload
+		host.expect(EventKick, &kicked)
+		assert.Equal(t, ClientIdType("guest"), kicked.ClientId)
+	})
+}
@@ -0,0 +1,128 @@
+// Package session - linked.go
+//
+// This file correlates the connections one user opens to several hubs for the
+// same meeting. A client in a call typically connects to the zoom, chat and
+// screenshare endpoints at once, which the server otherwise sees as three
+// unrelated clients that happen to share an ID.
+//
+// Linking:
+// Clients pass the same opaque session id, such as one generated per browser
+// tab, as the "session" query parameter on every connection. Hubs sharing a
+// SessionLinker record admitted connections under the token subject and that
+// id, so one user cannot link to, or end, another user's connections, and two
+// tabs of the same user stay independent. Connections without a session id
+// are never linked.
+//
+// Ending Together:
+// With HubConfig.EndLinkedSessions set, a client that leaves intentionally or
+// is kicked has its linked connections on the other hubs closed as well. They
+// are sent EventSessionEnded and leave their rooms as if they had sent
+// EventLeave, so every roster shows the user leaving rather than dropping. A
+// dropped connection ends nothing, since the client may be about to
+// reconnect.
+package session
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxSessionIdLength bounds the session query parameter.
+const maxSessionIdLength = 128
+
+// sessionKey identifies one user's linked connections.
+type sessionKey struct {
+	subject ClientIdType
+	session string
+}
+
+// SessionLinker records which connections on a set of hubs belong to the same
+// user session. Share one linker between the hubs serving a meeting's
+// endpoints through HubConfig.Sessions.
+//
+// Thread Safety: All methods are safe for concurrent use. The linker's lock
+// is never held while a hub or room lock is taken.
+type SessionLinker struct {
+	mu       sync.Mutex
+	sessions map[sessionKey]map[*Client]struct{}
+}
+
+// NewSessionLinker creates a linker with no sessions.
+func NewSessionLinker() *SessionLinker {
+	return &SessionLinker{sessions: make(map[sessionKey]map[*Client]struct{})}
+}
+
+// link records an admitted client as one of key's connections.
+func (l *SessionLinker) link(key sessionKey, client *Client) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	clients := l.sessions[key]
+	if clients == nil {
+		clients = make(map[*Client]struct{})
+		l.sessions[key] = clients
+	}
+	clients[client] = struct{}{}
+}
+
+// unlink forgets client. If endSession is set, the rest of the session is
+// forgotten too and returned for the caller to close, so connections closed
+// as a result do not end the session a second time.
+//
+// Returns:
+//   - []*Client: The other connections to close; nil unless endSession is set
+func (l *SessionLinker) unlink(key sessionKey, client *Client, endSession bool) []*Client {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	clients := l.sessions[key]
+	if _, ok := clients[client]; !ok {
+		return nil
+	}
+	delete(clients, client)
+	if !endSession {
+		if len(clients) == 0 {
+			delete(l.sessions, key)
+		}
+		return nil
+	}
+
+	delete(l.sessions, key)
+	linked := make([]*Client, 0, len(clients))
+	for c := range clients {
+		linked = append(linked, c)
+	}
+	return linked
+}
+
+// Linked reports how many connections are linked under subject and session.
+func (l *SessionLinker) Linked(subject ClientIdType, session string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.sessions[sessionKey{subject: subject, session: session}])
+}
+
+// endLinkedSession closes client because a connection linked to it ended its
+// session. The client is marked as leaving, so its room announces
+// EventParticipantLeft when the connection closes.
+//
+// Thread Safety: Acquires the room's lock.
+//
+// Parameters:
+//   - client: The linked connection to close
+//   - reason: Why the connection that ended the session left
+func (r *Room) endLinkedSession(client *Client, reason DisconnectReason) {
+	r.mu.Lock()
+	client.leaving = true
+	r.mu.Unlock()
+
+	msg, err := marshalMessage(EventSessionEnded, SessionEndedPayload{Reason: reason})
+	if err != nil {
+		slog.Error("Failed to marshal session end notice", "error", err, "ClientId", client.ID, "RoomId", r.ID)
+		return
+	}
+	slog.Info("Closing linked connection", "ClientId", client.ID, "RoomId", r.ID, "reason", reason)
+	client.closeWithNotice(msg, websocket.CloseNormalClosure, "session ended")
+}
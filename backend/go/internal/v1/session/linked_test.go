@@ -0,0 +1,145 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkedSessions(t *testing.T) {
+	endpoints := []string{"zoom", "chat", "screenshare"}
+
+	// newServer serves a hub per endpoint, all sharing one SessionLinker, and
+	// accepts the token "alice".
+	newServer := func(t *testing.T, endLinked bool) (string, map[string]*Hub, *SessionLinker) {
+		gin.SetMode(gin.TestMode)
+		linker := NewSessionLinker()
+		tokens := tokenTable{"alice": {Name: "Alice", RegisteredClaims: jwt.RegisteredClaims{Subject: "alice"}}}
+		hubs := make(map[string]*Hub, len(endpoints))
+		router := gin.New()
+		for _, endpoint := range endpoints {
+			hub := NewHub(tokens, WithSessionLinker(linker), func(c *HubConfig) { c.EndLinkedSessions = endLinked })
+			hubs[endpoint] = hub
+			router.GET("/ws/"+endpoint+"/:roomId", hub.ServeWs)
+		}
+		server := httptest.NewServer(router)
+		t.Cleanup(server.Close)
+		return "ws" + strings.TrimPrefix(server.URL, "http"), hubs, linker
+	}
+
+	// connect dials endpoint as alice with the given session id and waits
+	// until she has been placed in the meeting.
+	connect := func(t *testing.T, url string, hub *Hub, endpoint, session string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(url+"/ws/"+endpoint+"/meeting?token=alice&session="+session, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		require.Eventually(t, func() bool { return inMeeting(hub) }, time.Second, 5*time.Millisecond)
+		return conn
+	}
+
+	// expectSessionEnded reads from conn until the session end notice and the
+	// close that follows it.
+	expectSessionEnded := func(t *testing.T, conn *websocket.Conn) SessionEndedPayload {
+		t.Helper()
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		var ended *SessionEndedPayload
+		for {
+			var msg wireMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				require.NotNil(t, ended, "connection closed without a session end notice: %v", err)
+				assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "unexpected close: %v", err)
+				return *ended
+			}
+			if msg.Event == EventSessionEnded {
+				ended = &SessionEndedPayload{}
+				require.NoError(t, json.Unmarshal(msg.Payload, ended))
+			}
+		}
+	}
+
+	t.Run("leaving one hub ends the linked connections on the others", func(t *testing.T) {
+		url, hubs, linker := newServer(t, true)
+		conns := map[string]*websocket.Conn{}
+		for _, endpoint := range endpoints {
+			conns[endpoint] = connect(t, url, hubs[endpoint], endpoint, "tab-1")
+		}
+		require.Equal(t, 3, linker.Linked("alice", "tab-1"))
+
+		require.NoError(t, conns["zoom"].WriteJSON(Message{Event: EventLeave}))
+
+		for _, endpoint := range []string{"chat", "screenshare"} {
+			assert.Equal(t, DisconnectReasonLeft, expectSessionEnded(t, conns[endpoint]).Reason, endpoint)
+			assert.Eventually(t, func() bool { return !inMeeting(hubs[endpoint]) }, time.Second, 5*time.Millisecond,
+				"alice is removed from the %s room", endpoint)
+		}
+		assert.Eventually(t, func() bool { return linker.Linked("alice", "tab-1") == 0 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("a dropped connection ends nothing", func(t *testing.T) {
+		url, hubs, linker := newServer(t, true)
+		conns := map[string]*websocket.Conn{}
+		for _, endpoint := range endpoints {
+			conns[endpoint] = connect(t, url, hubs[endpoint], endpoint, "tab-1")
+		}
+
+		conns["zoom"].Close()
+
+		require.Eventually(t, func() bool { return linker.Linked("alice", "tab-1") == 2 }, time.Second, 5*time.Millisecond)
+		assert.True(t, inMeeting(hubs["chat"]))
+		assert.True(t, inMeeting(hubs["screenshare"]))
+	})
+
+	t.Run("other sessions of the same user are left alone", func(t *testing.T) {
+		url, hubs, linker := newServer(t, true)
+		zoom := connect(t, url, hubs["zoom"], "zoom", "tab-1")
+		connect(t, url, hubs["chat"], "chat", "tab-2")
+
+		require.NoError(t, zoom.WriteJSON(Message{Event: EventLeave}))
+
+		require.Eventually(t, func() bool { return linker.Linked("alice", "tab-1") == 0 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, 1, linker.Linked("alice", "tab-2"))
+		assert.True(t, inMeeting(hubs["chat"]))
+	})
+
+	t.Run("linked connections stay open unless EndLinkedSessions is set", func(t *testing.T) {
+		url, hubs, linker := newServer(t, false)
+		zoom := connect(t, url, hubs["zoom"], "zoom", "tab-1")
+		connect(t, url, hubs["chat"], "chat", "tab-1")
+
+		require.NoError(t, zoom.WriteJSON(Message{Event: EventLeave}))
+
+		require.Eventually(t, func() bool { return linker.Linked("alice", "tab-1") == 1 }, time.Second, 5*time.Millisecond)
+		assert.True(t, inMeeting(hubs["chat"]))
+	})
+
+	t.Run("an over-long session id is refused", func(t *testing.T) {
+		url, _, _ := newServer(t, true)
+		_, resp, err := websocket.DefaultDialer.Dial(url+"/ws/zoom/meeting?token=alice&session="+strings.Repeat("x", maxSessionIdLength+1), nil)
+		require.Error(t, err)
+		require.NotNil(t, resp)
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+}
+
+// inMeeting reports whether alice is in hub's "meeting" room in any role.
+func inMeeting(hub *Hub) bool {
+	hub.mu.Lock()
+	room := hub.rooms["meeting"]
+	hub.mu.Unlock()
+	if room == nil {
+		return false
+	}
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	_, admitted := room.findPeer("alice")
+	_, waiting := room.waiting["alice"]
+	return admitted || waiting
+}
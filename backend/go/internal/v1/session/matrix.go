@@ -0,0 +1,110 @@
+// Package session - matrix.go
+//
+// This file loads a deployment's permission matrix, which replaces rows of
+// the built-in permission table without recompiling, such as to forbid
+// participants from deleting chat.
+//
+// File Format:
+// The file is a JSON object mapping a role to every event that role may
+// send:
+//
+//	{
+//	  "participant": ["add_chat", "get_recent_chats", "raise_hand", "lower_hand", "leave"]
+//	}
+//
+// Each listed role's permissions are replaced by its list; roles the file does
+// not mention keep their built-in permissions. The waiting room guard still
+// applies, so granting waiting clients more than the waiting room events has
+// no effect unless RoomConfig.DisableWaitingGuard is set.
+//
+// Validation:
+// Every role and event must be known to the server. A file that names an
+// unknown one is rejected as a whole, so a typo cannot silently remove a
+// permission; the server refuses to start rather than run with a partial
+// matrix.
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"k8s.io/utils/set"
+)
+
+// knownRoles are the roles a permission matrix may name.
+var knownRoles = set.New(RoleTypeWaiting, RoleTypeSpectator, RoleTypeParticipant, RoleTypeScreenshare, RoleTypeHost)
+
+// PermissionMatrix maps a role to every event it may send.
+type PermissionMatrix map[RoleType][]Event
+
+// LoadPermissionMatrix reads a permission matrix file and applies it to the
+// built-in permission table. See ParsePermissionMatrix.
+//
+// Parameters:
+//   - path: The JSON file to read
+//
+// Returns:
+//   - map: The resulting table, for RoomConfig.EventPermissions
+//   - error: If the file cannot be read or is invalid
+func LoadPermissionMatrix(path string) (map[Event]set.Set[RoleType], error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading permission matrix: %w", err)
+	}
+	permissions, err := ParsePermissionMatrix(data)
+	if err != nil {
+		return nil, fmt.Errorf("permission matrix %s: %w", path, err)
+	}
+	return permissions, nil
+}
+
+// ParsePermissionMatrix decodes a JSON permission matrix and applies it to the
+// built-in permission table: each listed role may send exactly the events
+// listed for it, and other roles are unchanged.
+//
+// Parameters:
+//   - data: The JSON matrix
+//
+// Returns:
+//   - map: The resulting table, for RoomConfig.EventPermissions
+//   - error: If the JSON is malformed or names unknown roles or events; every
+//     unknown name is reported
+func ParsePermissionMatrix(data []byte) (map[Event]set.Set[RoleType], error) {
+	var matrix PermissionMatrix
+	if err := json.Unmarshal(data, &matrix); err != nil {
+		return nil, fmt.Errorf("decoding permission matrix: %w", err)
+	}
+	if err := matrix.validate(); err != nil {
+		return nil, err
+	}
+
+	permissions := DefaultEventPermissions()
+	for role, events := range matrix {
+		for _, roles := range permissions {
+			roles.Delete(role)
+		}
+		for _, event := range events {
+			permissions[event].Insert(role)
+		}
+	}
+	return permissions, nil
+}
+
+// validate reports every role and event in the matrix the server does not
+// know.
+func (m PermissionMatrix) validate() error {
+	var errs []error
+	for role, events := range m {
+		if !knownRoles.Has(role) {
+			errs = append(errs, fmt.Errorf("unknown role %q", role))
+		}
+		for _, event := range events {
+			if _, ok := eventPermissions[event]; !ok {
+				errs = append(errs, fmt.Errorf("unknown event %q for role %q", event, role))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
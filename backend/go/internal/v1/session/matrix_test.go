@@ -0,0 +1,115 @@
+package session
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/set"
+)
+
+func TestPermissionMatrix(t *testing.T) {
+	// noParticipantDeletes lets participants do everything they normally may,
+	// except delete chat.
+	noParticipantDeletes := `{"participant": ["add_chat", "recents_chat", "range_chat", "raise_hand", "lower_hand", "leave"]}`
+
+	// writeMatrix writes a matrix file and returns its path.
+	writeMatrix := func(t *testing.T, contents string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "permissions.json")
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+		return path
+	}
+
+	// newMeeting creates a room with the given permissions, a host and an
+	// admitted participant who has sent one chat message.
+	newMeeting := func(t *testing.T, permissions map[Event]set.Set[RoleType]) (*Room, *Client, *Client) {
+		t.Helper()
+		config := DefaultRoomConfig()
+		config.EventPermissions = permissions
+		config.DuplicateEventWindow = 0
+		room := NewRoomWithConfig("test-room", config, nil)
+		host := newTestClientWithName("host", "Host")
+		participant := newTestClientWithName("participant", "Participant")
+		room.handleClientConnect(host)
+		room.handleClientConnect(participant)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: participant.ID}})
+		room.router(context.Background(), participant, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo: participant.info(), ChatId: "chat-1", ChatContent: "hello",
+		}})
+		require.Equal(t, 1, room.chatHistory.Len())
+		return room, host, participant
+	}
+
+	// route routes msg from client and returns the outcome.
+	route := func(room *Room, client *Client, msg Message) routeResult {
+		room.mu.Lock()
+		defer room.mu.Unlock()
+		result, _ := room.route(context.Background(), client, msg)
+		return result
+	}
+
+	// deleteChat asks to delete the room's first chat message.
+	deleteChat := func(room *Room, author *Client) Message {
+		stored := room.chatHistory.Front().Value.(AddChatPayload)
+		return Message{Event: EventDeleteChat, Payload: DeleteChatPayload{ClientInfo: author.info(), ChatId: stored.ChatId}}
+	}
+
+	t.Run("the router enforces a loaded matrix", func(t *testing.T) {
+		permissions, err := LoadPermissionMatrix(writeMatrix(t, noParticipantDeletes))
+		require.NoError(t, err)
+		room, host, participant := newMeeting(t, permissions)
+
+		assert.Equal(t, routePermissionDenied, route(room, participant, deleteChat(room, participant)))
+		assert.Equal(t, 1, room.chatHistory.Len())
+		assert.NotContains(t, room.capabilities(RoleTypeParticipant), EventDeleteChat)
+		assert.Contains(t, room.capabilities(RoleTypeParticipant), EventAddChat)
+
+		assert.Equal(t, routeHandled, route(room, host, deleteChat(room, participant)), "Roles the matrix omits keep their permissions")
+		assert.Zero(t, room.chatHistory.Len())
+	})
+
+	t.Run("rooms without a matrix use the built-in table", func(t *testing.T) {
+		room, _, participant := newMeeting(t, nil)
+
+		assert.Equal(t, routeHandled, route(room, participant, deleteChat(room, participant)))
+		assert.Zero(t, room.chatHistory.Len())
+	})
+
+	t.Run("listed roles lose events they are not given", func(t *testing.T) {
+		permissions, err := ParsePermissionMatrix([]byte(noParticipantDeletes))
+		require.NoError(t, err)
+
+		assert.False(t, permissions[EventDeleteChat].Has(RoleTypeParticipant))
+		assert.False(t, permissions[EventReaction].Has(RoleTypeParticipant))
+		assert.True(t, permissions[EventRaiseHand].Has(RoleTypeParticipant))
+		assert.True(t, permissions[EventReaction].Has(RoleTypeSpectator))
+		assert.True(t, DefaultEventPermissions()[EventDeleteChat].Has(RoleTypeParticipant), "The built-in table is not modified")
+	})
+
+	t.Run("an empty matrix keeps every default", func(t *testing.T) {
+		permissions, err := ParsePermissionMatrix([]byte(`{}`))
+		require.NoError(t, err)
+
+		assert.Equal(t, DefaultEventPermissions(), permissions)
+	})
+
+	t.Run("unknown roles and events are all reported", func(t *testing.T) {
+		_, err := ParsePermissionMatrix([]byte(`{"moderator": ["kick"], "participant": ["add_chat", "delete_everything"]}`))
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, `unknown role "moderator"`)
+		assert.ErrorContains(t, err, `unknown event "delete_everything" for role "participant"`)
+	})
+
+	t.Run("malformed and missing files fail", func(t *testing.T) {
+		_, err := LoadPermissionMatrix(writeMatrix(t, `{"participant": "add_chat"}`))
+		assert.ErrorContains(t, err, "decoding permission matrix")
+
+		_, err = LoadPermissionMatrix(filepath.Join(t.TempDir(), "missing.json"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
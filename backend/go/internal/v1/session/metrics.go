@@ -0,0 +1,195 @@
+// Package session - metrics.go
+//
+// This file defines the HandlerMetrics extension point, which records how
+// long each event handler runs. Handlers run while the room lock is held, so
+// a slow handler delays every other message in the room; per-event latency
+// shows which event types hold the lock longest.
+//
+// Measurement:
+// The router times only the handler itself, from dispatch to return, after
+// the message has passed its permission and payload checks. Waiting for the
+// room lock is not included; SpanRoute covers that. Messages that are
+// rejected before dispatch are not recorded.
+//
+// Provided Implementations:
+//   - NoopHandlerMetrics: Records nothing (the default)
+//   - HandlerLatencyHistogram: Keeps a histogram per event in memory and
+//     serves it in the Prometheus text exposition format
+package session
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HandlerMetrics records handler latency. Implementations must be safe for
+// concurrent use and must not block, since they are called while the room
+// lock is held.
+type HandlerMetrics interface {
+	// ObserveHandler records that the handler for event ran for d.
+	ObserveHandler(event Event, d time.Duration)
+}
+
+// NoopHandlerMetrics records nothing. It is the default for rooms.
+type NoopHandlerMetrics struct{}
+
+// ObserveHandler does nothing.
+func (NoopHandlerMetrics) ObserveHandler(event Event, d time.Duration) {}
+
+// handlerLatencyMetric is the name under which HandlerLatencyHistogram is
+// exposed to Prometheus.
+const handlerLatencyMetric = "session_handler_duration_seconds"
+
+// DefaultHandlerLatencyBuckets are the histogram upper bounds used when none
+// are given. Most handlers finish in microseconds; the upper buckets catch
+// the ones that marshal large payloads or wait on storage.
+var DefaultHandlerLatencyBuckets = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	time.Second,
+}
+
+// HandlerLatency is one event's recorded latency.
+type HandlerLatency struct {
+	Count   uint64          // Handler runs recorded
+	Sum     time.Duration   // Total time across those runs
+	Buckets []time.Duration // Upper bounds, ascending
+	Counts  []uint64        // Runs at or below each bound, cumulative like Prometheus buckets
+}
+
+// handlerHistogram holds one event's observations. Counts are per bucket,
+// not cumulative; the final slot counts runs above the last bound.
+type handlerHistogram struct {
+	count  atomic.Uint64
+	sum    atomic.Int64 // Nanoseconds
+	counts []atomic.Uint64
+}
+
+// HandlerLatencyHistogram keeps a latency histogram per event type. Observing
+// is lock-free once an event has been seen; the set of events is bounded by
+// the events rooms know how to handle.
+//
+// Thread Safety: All methods are safe for concurrent use.
+type HandlerLatencyHistogram struct {
+	buckets []time.Duration
+
+	mu     sync.RWMutex
+	events map[Event]*handlerHistogram
+}
+
+// NewHandlerLatencyHistogram creates an empty histogram with the given
+// bucket upper bounds, or DefaultHandlerLatencyBuckets if none are given.
+func NewHandlerLatencyHistogram(buckets ...time.Duration) *HandlerLatencyHistogram {
+	if len(buckets) == 0 {
+		buckets = DefaultHandlerLatencyBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &HandlerLatencyHistogram{
+		buckets: slices.Compact(buckets),
+		events:  make(map[Event]*handlerHistogram),
+	}
+}
+
+// ObserveHandler records one run of the handler for event.
+func (h *HandlerLatencyHistogram) ObserveHandler(event Event, d time.Duration) {
+	hist := h.histogram(event)
+	i, _ := slices.BinarySearch(h.buckets, d)
+	hist.counts[i].Add(1)
+	hist.sum.Add(int64(d))
+	hist.count.Add(1)
+}
+
+// histogram returns the histogram for event, creating it on first use.
+func (h *HandlerLatencyHistogram) histogram(event Event) *handlerHistogram {
+	h.mu.RLock()
+	hist, ok := h.events[event]
+	h.mu.RUnlock()
+	if ok {
+		return hist
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hist, ok = h.events[event]; !ok {
+		hist = &handlerHistogram{counts: make([]atomic.Uint64, len(h.buckets)+1)}
+		h.events[event] = hist
+	}
+	return hist
+}
+
+// Latency returns what has been recorded for event so far.
+//
+// Returns:
+//   - HandlerLatency: The event's latency
+//   - bool: false if no run of the event's handler has been recorded
+func (h *HandlerLatencyHistogram) Latency(event Event) (HandlerLatency, bool) {
+	h.mu.RLock()
+	hist, ok := h.events[event]
+	h.mu.RUnlock()
+	if !ok {
+		return HandlerLatency{}, false
+	}
+
+	latency := HandlerLatency{
+		Count:   hist.count.Load(),
+		Sum:     time.Duration(hist.sum.Load()),
+		Buckets: slices.Clone(h.buckets),
+		Counts:  make([]uint64, len(h.buckets)),
+	}
+	var cumulative uint64
+	for i := range h.buckets {
+		cumulative += hist.counts[i].Load()
+		latency.Counts[i] = cumulative
+	}
+	return latency, true
+}
+
+// ServeHTTP writes every event's histogram in the Prometheus text exposition
+// format, so the histogram can be mounted as a scrape target.
+//
+// A scrape may land between the updates of a single observation, so an
+// event's count can briefly differ from its +Inf bucket by the handlers
+// running at that moment.
+func (h *HandlerLatencyHistogram) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mu.RLock()
+	events := make([]Event, 0, len(h.events))
+	for event := range h.events {
+		events = append(events, event)
+	}
+	h.mu.RUnlock()
+	slices.Sort(events)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintf(w, "# HELP %s Time spent in each event handler while holding the room lock.\n", handlerLatencyMetric)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", handlerLatencyMetric)
+	for _, event := range events {
+		latency, _ := h.Latency(event)
+		for i, bound := range latency.Buckets {
+			fmt.Fprintf(w, "%s_bucket{event=%q,le=%q} %d\n", handlerLatencyMetric, event, formatSeconds(bound), latency.Counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{event=%q,le=\"+Inf\"} %d\n", handlerLatencyMetric, event, latency.Count)
+		fmt.Fprintf(w, "%s_sum{event=%q} %s\n", handlerLatencyMetric, event, formatSeconds(latency.Sum))
+		fmt.Fprintf(w, "%s_count{event=%q} %d\n", handlerLatencyMetric, event, latency.Count)
+	}
+}
+
+// formatSeconds formats d as seconds, the unit Prometheus expects.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
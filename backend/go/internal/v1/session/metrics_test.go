@@ -0,0 +1,74 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerLatencyHistogram(t *testing.T) {
+	t.Run("records latency for a routed event", func(t *testing.T) {
+		metrics := NewHandlerLatencyHistogram()
+		room := NewTestRoom("test-room", nil)
+		room.config.HandlerMetrics = metrics
+		host := newTestClientWithName("host-1", "Host")
+		room.handleClientConnect(host)
+
+		room.router(context.Background(), host, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(host.info())})
+
+		latency, ok := metrics.Latency(EventRaiseHand)
+		require.True(t, ok, "The handled event is recorded")
+		assert.Equal(t, uint64(1), latency.Count)
+		_, ok = metrics.Latency(EventLowerHand)
+		assert.False(t, ok, "Events that were not handled are not recorded")
+	})
+
+	t.Run("rejected messages are not recorded", func(t *testing.T) {
+		metrics := NewHandlerLatencyHistogram()
+		room := NewTestRoom("test-room", nil)
+		room.config.HandlerMetrics = metrics
+		waiting := newTestClient("waiting")
+		room.addWaiting(waiting)
+
+		room.router(context.Background(), waiting, Message{Event: EventAddChat, Payload: AddChatPayload{}})
+
+		_, ok := metrics.Latency(EventAddChat)
+		assert.False(t, ok)
+	})
+
+	t.Run("observations fall into cumulative buckets", func(t *testing.T) {
+		metrics := NewHandlerLatencyHistogram(time.Millisecond, 10*time.Millisecond)
+
+		metrics.ObserveHandler(EventAddChat, 500*time.Microsecond)
+		metrics.ObserveHandler(EventAddChat, time.Millisecond)
+		metrics.ObserveHandler(EventAddChat, 5*time.Millisecond)
+		metrics.ObserveHandler(EventAddChat, time.Second)
+
+		latency, ok := metrics.Latency(EventAddChat)
+		require.True(t, ok)
+		assert.Equal(t, uint64(4), latency.Count)
+		assert.Equal(t, 1006500*time.Microsecond, latency.Sum)
+		assert.Equal(t, []uint64{2, 3}, latency.Counts, "A bound includes observations equal to it")
+	})
+
+	t.Run("serves the Prometheus text format", func(t *testing.T) {
+		metrics := NewHandlerLatencyHistogram(time.Millisecond)
+		metrics.ObserveHandler(EventAddChat, 2*time.Millisecond)
+
+		w := httptest.NewRecorder()
+		metrics.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		assert.Equal(t, `# HELP session_handler_duration_seconds Time spent in each event handler while holding the room lock.
+# TYPE session_handler_duration_seconds histogram
+session_handler_duration_seconds_bucket{event="add_chat",le="0.001"} 0
+session_handler_duration_seconds_bucket{event="add_chat",le="+Inf"} 1
+session_handler_duration_seconds_sum{event="add_chat"} 0.002
+session_handler_duration_seconds_count{event="add_chat"} 1
+`, w.Body.String())
+	})
+}
@@ -0,0 +1,60 @@
+// Package session - moderation.go
+//
+// This file defines the ModerationClient extension point, which lets
+// deployments route chat through an external moderation service, such as a
+// profanity or abuse detection webhook, before it reaches the room.
+//
+// Asynchronous Checks:
+// A webhook can take hundreds of milliseconds, far too long to hold the room
+// lock. When a room has a ModerationClient, handleAddChat validates and rate
+// limits the message under the lock and then checks it on its own goroutine.
+// Approved messages are saved and published as described in store.go, so a
+// sender's messages can be delivered out of order if the service answers them
+// out of order.
+//
+// Failure Policy:
+// If the service returns an error, RoomConfig.ModerationFailOpen decides
+// whether the message is delivered unchecked or rejected.
+package session
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ModerationClient decides whether chat content may be shown to the room.
+type ModerationClient interface {
+	// Check reports whether the content is allowed and, if not, a reason that
+	// is shown to the sender. A non-nil error means the content could not be
+	// checked. ctx is cancelled if the sender disconnects.
+	Check(ctx context.Context, content ChatContent) (allowed bool, reason string, err error)
+}
+
+// moderateChat checks a chat message with the room's ModerationClient.
+// Rejected messages are dropped and the sender is told why.
+//
+// Thread Safety: Must be called WITHOUT the room lock; the check may take a
+// long time.
+//
+// Parameters:
+//   - ctx: Cancelled when the sender disconnects
+//   - client: The sender
+//   - event: The event type (should be EventAddChat)
+//   - p: The validated message, not yet assigned an id
+//
+// Returns:
+//   - bool: Whether the message may be saved and delivered
+func (r *Room) moderateChat(ctx context.Context, client *Client, event Event, p AddChatPayload) bool {
+	allowed, reason, err := r.config.Moderation.Check(ctx, p.ChatContent)
+	if err != nil {
+		allowed = r.config.ModerationFailOpen
+		reason = "message could not be checked"
+		slog.Warn("Chat moderation failed", "ClientId", client.ID, "RoomId", r.ID, "failOpen", allowed, "error", err)
+	}
+	if !allowed {
+		slog.Info("Chat message rejected by moderation", "ClientId", client.ID, "RoomId", r.ID, "reason", reason)
+		client.sendError(ErrorCodeChatRejected, reason, event)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// moderationFunc adapts a function to the ModerationClient interface.
+type moderationFunc func(ctx context.Context, content ChatContent) (bool, string, error)
+
+func (f moderationFunc) Check(ctx context.Context, content ChatContent) (bool, string, error) {
+	return f(ctx, content)
+}
+
+func TestChatModeration(t *testing.T) {
+	setup := func(moderation ModerationClient, failOpen bool) (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		room.config.Moderation = moderation
+		room.config.ModerationFailOpen = failOpen
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+		return room, alice, bob
+	}
+
+	send := func(room *Room, client *Client, content ChatContent) {
+		room.router(context.Background(), client, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  client.info(),
+			ChatContent: content,
+		}})
+	}
+
+	historyLen := func(room *Room) int {
+		room.mu.RLock()
+		defer room.mu.RUnlock()
+		return room.chatHistory.Len()
+	}
+
+	// next waits for the client's next message.
+	next := func(t *testing.T, c *Client) wireMessage {
+		t.Helper()
+		select {
+		case raw := <-c.send:
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(raw, &msg))
+			return msg
+		case <-time.After(time.Second):
+			t.Fatalf("%s received nothing", c.ID)
+			return wireMessage{}
+		}
+	}
+
+	expectRejected := func(t *testing.T, c *Client, reason string) {
+		t.Helper()
+		msg := next(t, c)
+		require.Equal(t, EventError, msg.Event)
+		var rejection ErrorPayload
+		require.NoError(t, json.Unmarshal(msg.Payload, &rejection))
+		assert.Equal(t, ErrorCodeChatRejected, rejection.Code)
+		assert.Equal(t, reason, rejection.Message)
+	}
+
+	t.Run("allowed messages are delivered", func(t *testing.T) {
+		var checked ChatContent
+		room, alice, bob := setup(moderationFunc(func(ctx context.Context, content ChatContent) (bool, string, error) {
+			checked = content
+			return true, "", nil
+		}), false)
+
+		send(room, alice, "hello")
+
+		assert.Equal(t, EventAddChat, next(t, bob).Event)
+		assert.Equal(t, ChatContent("hello"), checked)
+		assert.Equal(t, 1, historyLen(room))
+	})
+
+	t.Run("rejected messages are dropped and the sender is told why", func(t *testing.T) {
+		room, alice, bob := setup(moderationFunc(func(ctx context.Context, content ChatContent) (bool, string, error) {
+			return false, "contains profanity", nil
+		}), false)
+
+		send(room, alice, "something rude")
+
+		expectRejected(t, alice, "contains profanity")
+		assert.Equal(t, 0, historyLen(room))
+		assert.Empty(t, bob.send, "Nothing should be broadcast")
+	})
+
+	t.Run("service errors deliver the message when failing open", func(t *testing.T) {
+		room, alice, bob := setup(moderationFunc(func(ctx context.Context, content ChatContent) (bool, string, error) {
+			return false, "", errors.New("webhook timed out")
+		}), true)
+
+		send(room, alice, "hello")
+
+		assert.Equal(t, EventAddChat, next(t, bob).Event)
+		assert.Equal(t, 1, historyLen(room))
+	})
+
+	t.Run("service errors reject the message when failing closed", func(t *testing.T) {
+		room, alice, bob := setup(moderationFunc(func(ctx context.Context, content ChatContent) (bool, string, error) {
+			return true, "", errors.New("webhook timed out")
+		}), false)
+
+		send(room, alice, "hello")
+
+		expectRejected(t, alice, "message could not be checked")
+		assert.Equal(t, 0, historyLen(room))
+		assert.Empty(t, bob.send)
+	})
+
+	t.Run("checks do not hold the room lock", func(t *testing.T) {
+		release := make(chan struct{})
+		room, alice, bob := setup(moderationFunc(func(ctx context.Context, content ChatContent) (bool, string, error) {
+			<-release
+			return true, "", nil
+		}), false)
+
+		send(room, alice, "hello")
+		room.router(context.Background(), bob, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(bob.info())})
+		assert.Equal(t, EventRaiseHand, next(t, alice).Event, "The room should keep working during the check")
+
+		assert.Equal(t, EventRaiseHand, next(t, bob).Event)
+
+		close(release)
+		assert.Equal(t, EventAddChat, next(t, bob).Event)
+	})
+
+	t.Run("disconnecting cancels the check", func(t *testing.T) {
+		cancelled := make(chan struct{})
+		room, alice, bob := setup(moderationFunc(func(ctx context.Context, content ChatContent) (bool, string, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return false, "", ctx.Err()
+		}), true)
+		alice.ctx, alice.cancel = context.WithCancel(context.Background())
+
+		send(room, alice, "hello")
+		alice.cancelContext()
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("The check should be cancelled with the connection")
+		}
+		assert.Never(t, func() bool { return historyLen(room) > 0 }, 50*time.Millisecond, 5*time.Millisecond,
+			"A departed sender's message should not be delivered")
+		assert.Empty(t, bob.send)
+	})
+}
@@ -0,0 +1,249 @@
+// Package session - move.go
+//
+// This file implements moving a connected client from one room to another on
+// the same hub without reconnecting, such as returning everyone from a
+// breakout room to the main meeting. The client keeps its WebSocket
+// connection and send channel; only its room changes.
+//
+// Placement:
+// The client leaves the source room as if it had left intentionally, so the
+// others there see EventParticipantLeft. It then joins the destination the
+// same way a new connection would, so the destination's waiting room,
+// designated hosts and capacity all apply. Finally it is sent
+// EventRoomMoved with its new role and the destination's state.
+//
+// Lock Ordering:
+// The move holds the hub lock and both room locks, so the client is never in
+// both rooms or in neither. The hub lock is taken first, as everywhere else;
+// the two room locks are then taken in room-id order, so two moves in
+// opposite directions cannot deadlock.
+//
+// In-Flight Messages:
+// A message the client sent just before the move may reach the source room
+// after it. The router drops it rather than act on it with the role the
+// client now holds elsewhere. If the connection closes mid-move, the source
+// room hands the disconnect on to the destination.
+package session
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Errors returned by Hub.MoveClient.
+var (
+	errRoomNotFound     = errors.New("room not found")
+	errClientNotInRoom  = errors.New("client is not in the source room")
+	errSameRoom         = errors.New("source and destination rooms are the same")
+	errTooManyUserRooms = errors.New("move would exceed the per-user room limit")
+)
+
+// MoveClient moves a connected client from one room to another on this hub
+// without reconnecting. The destination is created if it does not exist.
+//
+// Thread Safety: Acquires the hub lock, then the locks of both rooms in
+// room-id order, and looks the client up in the source room under them. Must
+// be called without any room lock held, so it cannot be called from an event
+// handler directly. The ConnectionObserver is told of the new connection once
+// every lock is released.
+//
+// Parameters:
+//   - clientId: The client to move
+//   - from: The room the client is in
+//   - to: The room to move it to
+//
+// Returns:
+//   - error: If from does not exist or does not hold the client, the rooms
+//     are the same, the destination could not be created or is closed, or
+//     the move would exceed HubConfig.MaxRoomsPerUser
+func (h *Hub) MoveClient(clientId ClientIdType, from, to RoomIdType) error {
+	if from == to {
+		return errSameRoom
+	}
+
+	placed, err := h.moveClientLocked(clientId, from, to)
+	if err != nil {
+		return err
+	}
+	if placed {
+		h.config.Room.ConnectionObserver.OnConnect(to, clientId, string(clientId))
+	}
+	return nil
+}
+
+// moveClientLocked does the work of MoveClient under the hub lock and both
+// room locks, releasing them all before it returns.
+//
+// Returns:
+//   - bool: Whether the client was placed in the destination rather than
+//     refused by it
+//   - error: As for MoveClient
+func (h *Hub) moveClientLocked(clientId ClientIdType, from, to RoomIdType) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	source, ok := h.rooms[from]
+	if !ok {
+		return false, errRoomNotFound
+	}
+	_, existed := h.rooms[to]
+	destination, err := h.getOrCreateRoomLocked(to)
+	if err != nil {
+		return false, err
+	}
+	// A destination created for a move that fails has no one to leave it, so
+	// it is removed here rather than left counting towards MaxRooms. This
+	// runs after the room locks are released, with the hub lock still held.
+	moved := false
+	defer func() {
+		if !existed && !moved {
+			delete(h.rooms, to)
+			destination.close()
+		}
+	}()
+
+	unlock := lockRoomPair(source, destination)
+	defer unlock()
+
+	client, ok := source.findMember(clientId)
+	if !ok || !source.holds(client) {
+		return false, errClientNotInRoom
+	}
+	if destination.closed {
+		return false, errRoomNotFound
+	}
+	if !h.moveMembershipLocked(client.ID, from, to) {
+		return false, errTooManyUserRooms
+	}
+	moved = true
+
+	source.releaseMovedClient(client)
+	client.setRoom(destination)
+	resetRoomScopedState(client)
+	destination.placeClient(client)
+	// A refused client was sent an error and its connection is closing, so
+	// it is not told it was moved
+	if !client.refused {
+		destination.sendRoomMoved(client, from)
+	}
+
+	slog.Info("Moved client between rooms", "ClientId", client.ID, "from", from, "to", to, "role", client.Role)
+	return !client.refused, nil
+}
+
+// lockRoomPair locks two distinct rooms in room-id order and returns a
+// function that unlocks them.
+func lockRoomPair(a, b *Room) (unlock func()) {
+	if b.ID < a.ID {
+		a, b = b, a
+	}
+	a.mu.Lock()
+	b.mu.Lock()
+	return func() {
+		b.mu.Unlock()
+		a.mu.Unlock()
+	}
+}
+
+// holds reports whether client is currently placed in the room and has not
+// begun leaving it.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) holds(client *Client) bool {
+	if client.leaving || client.kicked || client.refused || client.movedFrom(r) {
+		return false
+	}
+	return r.roleMembers(client.Role)[client.ID] == client
+}
+
+// findMember looks up a client in the room by id, including clients still
+// in the waiting room.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Returns:
+//   - *Client: The client, or nil if not found
+//   - bool: Whether the client was found
+func (r *Room) findMember(clientId ClientIdType) (*Client, bool) {
+	if c, ok := r.waiting[clientId]; ok {
+		return c, true
+	}
+	return r.findPeer(clientId)
+}
+
+// releaseMovedClient removes a client the hub is moving elsewhere, announcing
+// it as an intentional leave.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) releaseMovedClient(client *Client) {
+	notify := r.notifyJoinLeave()
+	r.disconnectClient(client)
+	r.config.ConnectionObserver.OnDisconnect(r.ID, client.ID, DisconnectReasonMoved)
+	r.broadcast(r.ctx, EventParticipantLeft, ParticipantLeftPayload{
+		ClientInfo: client.info(),
+		Notify:     notify,
+	}, nil)
+	r.releaseIfEmpty()
+	r.checkHostless()
+}
+
+// sendRoomMoved tells a client that has just been placed in the room that it
+// was moved here, with what it needs to rebuild its view.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) sendRoomMoved(client *Client, from RoomIdType) {
+	r.replyTo(client, EventRoomMoved, RoomMovedPayload{
+		From:         from,
+		Role:         client.Role,
+		Capabilities: r.capabilities(client.Role),
+		State:        r.roomState(),
+	})
+}
+
+// resetRoomScopedState clears the limits and history a client accumulated in
+// its previous room, so they do not carry over to the next.
+//
+// Thread Safety: The caller must hold the locks of both rooms.
+func resetRoomScopedState(client *Client) {
+	client.chatWindowStart = time.Time{}
+	client.chatCount = 0
+	client.lastWaitingRequest = time.Time{}
+	client.recentEvents = nil
+	client.admitApprovals = nil
+}
+
+// moveMembershipLocked moves one of the subject's connections from one room
+// to another in the per-user room counts. The caller must hold h.mu.
+//
+// Returns:
+//   - false if the subject would then be in more than MaxRoomsPerUser rooms
+func (h *Hub) moveMembershipLocked(subject ClientIdType, from, to RoomIdType) bool {
+	rooms := h.userRooms[subject]
+	if rooms == nil {
+		// Clients placed without ServeWs, as in tests, are not counted
+		return true
+	}
+	joined := len(rooms)
+	if rooms[to] == 0 {
+		joined++
+	}
+	if rooms[from] <= 1 {
+		joined--
+	}
+	if limit := h.config.MaxRoomsPerUser; limit > 0 && joined > limit {
+		return false
+	}
+
+	if rooms[from] <= 1 {
+		delete(rooms, from)
+	} else {
+		rooms[from]--
+	}
+	rooms[to]++
+	return true
+}
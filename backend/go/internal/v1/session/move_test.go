@@ -0,0 +1,287 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveClient(t *testing.T) {
+	// join places a new client in the hub's room, as ServeWs would.
+	join := func(t *testing.T, hub *Hub, roomId RoomIdType, id ClientIdType) (*Room, *Client) {
+		t.Helper()
+		client := newTestClientWithName(id, DisplayNameType(id))
+		room, err := hub.joinRoom(roomId, client)
+		require.NoError(t, err)
+		return room, client
+	}
+
+	// drain empties a client's send channel.
+	drain := func(clients ...*Client) {
+		for _, c := range clients {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+	}
+
+	// readMoved returns the EventRoomMoved sent to client, skipping anything else.
+	readMoved := func(t *testing.T, client *Client) RoomMovedPayload {
+		t.Helper()
+		for len(client.send) > 0 {
+			var msg struct {
+				Event   Event            `json:"event"`
+				Payload RoomMovedPayload `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(<-client.send, &msg))
+			if msg.Event == EventRoomMoved {
+				return msg.Payload
+			}
+		}
+		require.Fail(t, "client was not sent room_moved")
+		return RoomMovedPayload{}
+	}
+
+	// roomIds lists the rooms the hub holds.
+	roomIds := func(hub *Hub) []RoomIdType {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		ids := make([]RoomIdType, 0, len(hub.rooms))
+		for id := range hub.rooms {
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	t.Run("client leaves the source roster and appears in the destination", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		main, _ := join(t, hub, "main", "main-host")
+		breakout, breakoutHost := join(t, hub, "breakout", "breakout-host")
+		_, mover := join(t, hub, "breakout", "mover")
+		breakout.router(context.Background(), breakoutHost, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: mover.ID}})
+		drain(breakoutHost, mover)
+
+		require.NoError(t, hub.MoveClient(mover.ID, "breakout", "main"))
+
+		assert.Equal(t, Roomer(main), mover.currentRoom())
+		breakoutState := breakout.getRoomState()
+		assert.Empty(t, breakoutState.Participants)
+		assert.Empty(t, breakoutState.WaitingUsers)
+		mainState := main.getRoomState()
+		assert.Equal(t, []ClientInfo{mover.info()}, mainState.WaitingUsers, "The destination's waiting room applies")
+
+		moved := readMoved(t, mover)
+		assert.Equal(t, RoomIdType("breakout"), moved.From)
+		assert.Equal(t, RoleTypeWaiting, moved.Role)
+		assert.Equal(t, RoomIdType("main"), moved.State.RoomID)
+		assert.Equal(t, []ClientInfo{mover.info()}, moved.State.WaitingUsers)
+
+		require.NotEmpty(t, breakoutHost.send)
+		var left struct {
+			Event Event `json:"event"`
+		}
+		require.NoError(t, json.Unmarshal(<-breakoutHost.send, &left))
+		assert.Equal(t, EventParticipantLeft, left.Event)
+	})
+
+	t.Run("client is admitted directly when the destination has no waiting room", func(t *testing.T) {
+		config := DefaultRoomConfig()
+		config.Features = &RoomFeatures{ChatEnabled: true}
+		hub := NewTestHub(nil, WithRoomConfig(config))
+		main, _ := join(t, hub, "main", "main-host")
+		_, mover := join(t, hub, "breakout", "mover")
+
+		require.NoError(t, hub.MoveClient(mover.ID, "breakout", "main"))
+
+		assert.Equal(t, RoleTypeParticipant, mover.Role)
+		assert.Equal(t, []ClientInfo{mover.info()}, main.getRoomState().Participants)
+		moved := readMoved(t, mover)
+		assert.Equal(t, RoleTypeParticipant, moved.Role)
+		assert.Contains(t, moved.Capabilities, EventAddChat)
+	})
+
+	t.Run("emptied source room is removed and a missing destination is created", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		_, mover := join(t, hub, "breakout", "mover")
+
+		require.NoError(t, hub.MoveClient(mover.ID, "breakout", "new-room"))
+
+		assert.Equal(t, RoleTypeHost, mover.Role, "The first client in a new room hosts it")
+		assert.Eventually(t, func() bool {
+			ids := roomIds(hub)
+			return len(ids) == 1 && ids[0] == "new-room"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("messages and disconnects reaching the source after a move", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		main, _ := join(t, hub, "main", "main-host")
+		breakout, _ := join(t, hub, "breakout", "breakout-host")
+		_, mover := join(t, hub, "breakout", "mover")
+		require.NoError(t, hub.MoveClient(mover.ID, "breakout", "main"))
+
+		breakout.mu.Lock()
+		result, err := breakout.route(context.Background(), mover, Message{Event: EventLeave, Payload: LeavePayload{}})
+		breakout.mu.Unlock()
+		assert.Equal(t, routePermissionDenied, result)
+		assert.ErrorContains(t, err, "moved")
+		assert.False(t, mover.leaving)
+
+		// A readPump that looked up the room before the move hands its
+		// disconnect to the source, which passes it on.
+		breakout.handleClientDisconnect(mover)
+		assert.Empty(t, main.getRoomState().WaitingUsers)
+		assert.Len(t, breakout.getRoomState().Hosts, 1)
+	})
+
+	t.Run("per-user room counts follow the client", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		join(t, hub, "main", "main-host")
+		_, mover := join(t, hub, "breakout", "mover")
+		require.True(t, hub.acquireMembership(mover.ID, "breakout"))
+
+		require.NoError(t, hub.MoveClient(mover.ID, "breakout", "main"))
+
+		assert.Equal(t, []RoomIdType{"main"}, hub.WhereIs(mover.ID))
+	})
+
+	t.Run("invalid moves are refused", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		main, mainHost := join(t, hub, "main", "main-host")
+		_, mover := join(t, hub, "breakout", "mover")
+
+		assert.ErrorIs(t, hub.MoveClient(mover.ID, "breakout", "breakout"), errSameRoom)
+		assert.ErrorIs(t, hub.MoveClient(mover.ID, "nowhere", "main"), errRoomNotFound)
+		assert.ErrorIs(t, hub.MoveClient(mainHost.ID, "breakout", "main"), errClientNotInRoom)
+
+		assert.ErrorIs(t, hub.MoveClient("stranger", "breakout", "main"), errClientNotInRoom)
+
+		mover.leaving = true
+		assert.ErrorIs(t, hub.MoveClient(mover.ID, "breakout", "main"), errClientNotInRoom)
+		assert.Len(t, main.getRoomState().Hosts, 1)
+	})
+
+	t.Run("a failed move leaves no destination room behind", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		join(t, hub, "breakout", "breakout-host")
+
+		assert.ErrorIs(t, hub.MoveClient("stranger", "breakout", "new-room"), errClientNotInRoom)
+
+		assert.Equal(t, []RoomIdType{"breakout"}, roomIds(hub))
+	})
+
+	t.Run("a client refused by the destination is not told it moved", func(t *testing.T) {
+		config := DefaultRoomConfig()
+		config.SingleHostConnection = true
+		hub := NewTestHub(nil, WithRoomConfig(config))
+		join(t, hub, "main", "host")
+		_, second := join(t, hub, "breakout", "host")
+		drain(second)
+
+		require.NoError(t, hub.MoveClient(second.ID, "breakout", "main"))
+
+		require.True(t, second.refused)
+		for len(second.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-second.send, &msg))
+			assert.NotEqual(t, EventRoomMoved, msg.Event)
+		}
+	})
+
+	t.Run("chat checked before a move is not delivered to the source", func(t *testing.T) {
+		config := DefaultRoomConfig()
+		config.Features = &RoomFeatures{ChatEnabled: true}
+		checking := make(chan struct{})
+		release := make(chan struct{})
+		config.Moderation = moderationFunc(func(ctx context.Context, content ChatContent) (bool, string, error) {
+			close(checking)
+			<-release
+			return true, "", nil
+		})
+		hub := NewTestHub(nil, WithRoomConfig(config))
+		join(t, hub, "main", "main-host")
+		breakout, breakoutHost := join(t, hub, "breakout", "breakout-host")
+		_, mover := join(t, hub, "breakout", "mover")
+
+		breakout.router(context.Background(), mover, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  mover.info(),
+			ChatContent: "hello",
+		}})
+		<-checking
+		require.NoError(t, hub.MoveClient(mover.ID, "breakout", "main"))
+		drain(breakoutHost)
+		close(release)
+
+		assert.Never(t, func() bool {
+			breakout.mu.RLock()
+			defer breakout.mu.RUnlock()
+			return breakout.chatHistory.Len() > 0 || len(breakoutHost.send) > 0
+		}, 50*time.Millisecond, 5*time.Millisecond, "The source room never sees the message")
+	})
+
+	t.Run("the observer is told of the move with no lock held", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		observer := &lockingObserver{hub: hub}
+		hub.config.Room.ConnectionObserver = observer
+		join(t, hub, "main", "main-host")
+		_, mover := join(t, hub, "breakout", "mover")
+		require.True(t, hub.acquireMembership(mover.ID, "breakout"))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			assert.NoError(t, hub.MoveClient(mover.ID, "breakout", "main"))
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("OnConnect ran under the hub lock")
+		}
+		assert.Equal(t, []RoomIdType{"main"}, observer.rooms)
+	})
+
+	t.Run("moves in opposite directions do not deadlock", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		join(t, hub, "room-a", "host-a")
+		join(t, hub, "room-b", "host-b")
+		_, first := join(t, hub, "room-a", "first")
+		_, second := join(t, hub, "room-b", "second")
+
+		shuttle := func(client *Client, from, to RoomIdType) {
+			for range 50 {
+				assert.NoError(t, hub.MoveClient(client.ID, from, to))
+				from, to = to, from
+				drain(client)
+			}
+		}
+		var wg sync.WaitGroup
+		wg.Add(2)
+		done := make(chan struct{})
+		go func() { defer wg.Done(); shuttle(first, "room-a", "room-b") }()
+		go func() { defer wg.Done(); shuttle(second, "room-b", "room-a") }()
+		go func() { wg.Wait(); close(done) }()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("moves deadlocked")
+		}
+	})
+}
+
+// lockingObserver records where each connecting client is, which takes the
+// hub lock.
+type lockingObserver struct {
+	NoopConnectionObserver
+	hub   *Hub
+	rooms []RoomIdType
+}
+
+func (o *lockingObserver) OnConnect(roomId RoomIdType, clientId ClientIdType, subject string) {
+	o.rooms = append(o.rooms, o.hub.WhereIs(clientId)...)
+}
@@ -0,0 +1,44 @@
+// Package session - observer.go
+//
+// This file defines the ConnectionObserver extension point, which tells
+// integrations such as analytics or external presence services when clients
+// connect to and disconnect from rooms, without those side effects living in
+// the core connection logic.
+//
+// Observer Contract:
+// OnDisconnect is called while the room lock is held, so implementations must
+// never block. Slow integrations should hand events off to their own goroutine.
+package session
+
+// DisconnectReason describes why a client left a room.
+type DisconnectReason string
+
+// Reasons reported to ConnectionObserver.OnDisconnect.
+const (
+	DisconnectReasonLeft    DisconnectReason = "left"    // The client announced an intentional leave
+	DisconnectReasonKicked  DisconnectReason = "kicked"  // A host removed the client
+	DisconnectReasonDropped DisconnectReason = "dropped" // The connection closed without a leave
+	DisconnectReasonMoved   DisconnectReason = "moved"   // The client was moved to another room; see Hub.MoveClient
+)
+
+// ConnectionObserver is notified as clients connect to and disconnect from rooms.
+type ConnectionObserver interface {
+	// OnConnect is called from Hub.ServeWs once the client has been placed in
+	// its room, and from Hub.MoveClient once it has been placed in another,
+	// with no lock held. subject is the authenticated token subject.
+	OnConnect(roomId RoomIdType, clientId ClientIdType, subject string)
+
+	// OnDisconnect is called once the client has been removed from its room.
+	// It runs while the room lock is held and must not block.
+	OnDisconnect(roomId RoomIdType, clientId ClientIdType, reason DisconnectReason)
+}
+
+// NoopConnectionObserver ignores every notification. It is the default observer.
+type NoopConnectionObserver struct{}
+
+// OnConnect does nothing.
+func (NoopConnectionObserver) OnConnect(roomId RoomIdType, clientId ClientIdType, subject string) {}
+
+// OnDisconnect does nothing.
+func (NoopConnectionObserver) OnDisconnect(roomId RoomIdType, clientId ClientIdType, reason DisconnectReason) {
+}
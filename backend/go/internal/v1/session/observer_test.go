@@ -0,0 +1,94 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// observedEvent is one notification received by a recordingObserver.
+type observedEvent struct {
+	hook     string
+	roomId   RoomIdType
+	clientId ClientIdType
+	detail   string // Subject for connects, reason for disconnects
+}
+
+// recordingObserver records every notification for later assertions.
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []observedEvent
+}
+
+func (o *recordingObserver) OnConnect(roomId RoomIdType, clientId ClientIdType, subject string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, observedEvent{"connect", roomId, clientId, subject})
+}
+
+func (o *recordingObserver) OnDisconnect(roomId RoomIdType, clientId ClientIdType, reason DisconnectReason) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, observedEvent{"disconnect", roomId, clientId, string(reason)})
+}
+
+func (o *recordingObserver) recorded() []observedEvent {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]observedEvent(nil), o.events...)
+}
+
+func TestConnectionObserver(t *testing.T) {
+	t.Run("hooks fire across a connect and disconnect cycle", func(t *testing.T) {
+		observer := &recordingObserver{}
+		config := DefaultHubConfig()
+		config.Room.ConnectionObserver = observer
+		server := newTestServer(t, config, "host", "guest")
+
+		host := server.dial(t, "room-1", "host")
+		guest := server.dial(t, "room-1", "guest")
+		admit(t, host, guest, "guest")
+
+		guest.send(EventLeave, LeavePayload{ClientId: "guest"})
+		host.expect(EventParticipantLeft, nil)
+		require.NoError(t, host.conn.Close())
+
+		require.Eventually(t, func() bool { return len(observer.recorded()) == 4 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, []observedEvent{
+			{"connect", "room-1", "host", "host"},
+			{"connect", "room-1", "guest", "guest"},
+			{"disconnect", "room-1", "guest", "left"},
+			{"disconnect", "room-1", "host", "dropped"},
+		}, observer.recorded())
+	})
+
+	t.Run("kicked clients are reported as kicked", func(t *testing.T) {
+		observer := &recordingObserver{}
+		config := DefaultRoomConfig()
+		config.ConnectionObserver = observer
+		room := NewRoomWithConfig("test-room", config, func(RoomIdType) {})
+		host := newTestClient("host")
+		participant := newTestClient("participant")
+		room.handleClientConnect(host)
+		room.addParticipant(participant)
+
+		room.router(context.Background(), host, Message{Event: EventKick, Payload: KickPayload{ClientInfo: ClientInfo{ClientId: participant.ID}}})
+		room.handleClientDisconnect(participant)
+
+		assert.Equal(t, []observedEvent{{"disconnect", "test-room", "participant", "kicked"}}, observer.recorded())
+	})
+
+	t.Run("a nil observer defaults to no-op", func(t *testing.T) {
+		config := DefaultRoomConfig()
+		config.ConnectionObserver = nil
+		room := NewRoomWithConfig("test-room", config, func(RoomIdType) {})
+		client := newTestClient("client")
+		room.handleClientConnect(client)
+
+		assert.NotPanics(t, func() { room.handleClientDisconnect(client) })
+	})
+}
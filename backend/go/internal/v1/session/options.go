@@ -0,0 +1,122 @@
+// Package session - options.go
+//
+// This file defines HubOption, the functional options accepted by NewHub.
+// Each option changes one part of the HubConfig the hub is built from, so new
+// settings can be offered without changing NewHub's signature.
+//
+// Ordering:
+// Options start from DefaultHubConfig and are applied in the order given.
+// WithHubConfig and WithRoomConfig replace everything they cover, so they
+// should come before the options that adjust individual settings.
+package session
+
+import (
+	"log/slog"
+
+	"k8s.io/utils/clock"
+	"k8s.io/utils/set"
+)
+
+// HubOption adjusts the configuration of a hub created by NewHub.
+type HubOption func(*HubConfig)
+
+// WithHubConfig replaces the whole hub configuration, such as one built by
+// LoadHubConfigFromEnv.
+func WithHubConfig(config HubConfig) HubOption {
+	return func(c *HubConfig) { *c = config }
+}
+
+// WithRoomConfig replaces the configuration applied to every room.
+func WithRoomConfig(config RoomConfig) HubOption {
+	return func(c *HubConfig) { c.Room = config }
+}
+
+// WithAllowedEvents limits rooms to the given events, as for a feature
+// endpoint; see RoomConfig.AllowedEvents.
+func WithAllowedEvents(events set.Set[Event]) HubOption {
+	return func(c *HubConfig) { c.Room.AllowedEvents = events }
+}
+
+// WithEventPermissions replaces the table of which roles may send each
+// event, such as one read by LoadPermissionMatrix; see
+// RoomConfig.EventPermissions.
+func WithEventPermissions(permissions map[Event]set.Set[RoleType]) HubOption {
+	return func(c *HubConfig) { c.Room.EventPermissions = permissions }
+}
+
+// WithRoomBus shares room broadcasts with other hub instances over bus,
+// under the endpoint's namespace; see RoomConfig.Bus and
+// RoomConfig.BusNamespace.
+func WithRoomBus(bus RoomBus, namespace string) HubOption {
+	return func(c *HubConfig) {
+		c.Room.Bus = bus
+		c.Room.BusNamespace = namespace
+	}
+}
+
+// WithChatStore persists chat messages to store; see RoomConfig.ChatStore.
+func WithChatStore(store ChatStore) HubOption {
+	return func(c *HubConfig) { c.Room.ChatStore = store }
+}
+
+// WithEventSink mirrors durable events to sink; see RoomConfig.EventSink.
+func WithEventSink(sink EventSink) HubOption {
+	return func(c *HubConfig) { c.Room.EventSink = sink }
+}
+
+// WithConnectionObserver reports connects and disconnects to observer; see
+// RoomConfig.ConnectionObserver.
+func WithConnectionObserver(observer ConnectionObserver) HubOption {
+	return func(c *HubConfig) { c.Room.ConnectionObserver = observer }
+}
+
+// WithTracer records spans with tracer; see RoomConfig.Tracer.
+func WithTracer(tracer Tracer) HubOption {
+	return func(c *HubConfig) { c.Room.Tracer = tracer }
+}
+
+// WithHandlerMetrics records handler latency with metrics; see
+// RoomConfig.HandlerMetrics.
+func WithHandlerMetrics(metrics HandlerMetrics) HubOption {
+	return func(c *HubConfig) { c.Room.HandlerMetrics = metrics }
+}
+
+// WithHandlerLogger sends handler, rejection and room lifecycle logs to
+// logger; see HandlerLogConfig.Logger.
+func WithHandlerLogger(logger *slog.Logger) HubOption {
+	return func(c *HubConfig) { c.Room.HandlerLog.Logger = logger }
+}
+
+// WithClock drives room timers and connection limits from clk.
+func WithClock(clk clock.WithTickerAndDelayedExecution) HubOption {
+	return func(c *HubConfig) { c.Room.Clock = clk }
+}
+
+// WithMaxParticipants caps the hosts and participants in each room; see
+// RoomConfig.MaxParticipants.
+func WithMaxParticipants(n int) HubOption {
+	return func(c *HubConfig) { c.Room.MaxParticipants = n }
+}
+
+// WithMaxRooms caps the rooms the hub holds at once; see HubConfig.MaxRooms.
+func WithMaxRooms(n int) HubOption {
+	return func(c *HubConfig) { c.MaxRooms = n }
+}
+
+// WithMaxRoomsPerUser caps the rooms one subject may be in at once; see
+// HubConfig.MaxRoomsPerUser.
+func WithMaxRoomsPerUser(n int) HubOption {
+	return func(c *HubConfig) { c.MaxRoomsPerUser = n }
+}
+
+// WithSessionLinker links connections across the hubs sharing linker; see
+// HubConfig.Sessions.
+func WithSessionLinker(linker *SessionLinker) HubOption {
+	return func(c *HubConfig) { c.Sessions = linker }
+}
+
+// WithCompression negotiates per-message deflate; see
+// HubConfig.EnableCompression.
+func WithCompression(enabled bool) HubOption {
+	return func(c *HubConfig) { c.EnableCompression = enabled }
+}
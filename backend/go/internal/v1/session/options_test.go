@@ -0,0 +1,84 @@
+package session
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/set"
+)
+
+// savedChats is a ChatStore that keeps the messages it is given.
+type savedChats struct {
+	mu    sync.Mutex
+	chats []ChatInfo
+}
+
+func (s *savedChats) SaveChat(ctx context.Context, roomId RoomIdType, chat ChatInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats = append(s.chats, chat)
+	return nil
+}
+
+// Chats returns the messages saved so far.
+func (s *savedChats) Chats() []ChatInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.chats)
+}
+
+func TestHubOptions(t *testing.T) {
+	t.Run("no options uses the default configuration", func(t *testing.T) {
+		hub := NewHub(&MockValidator{})
+
+		assert.Equal(t, DefaultHubConfig().MaxRooms, hub.config.MaxRooms)
+		assert.Equal(t, DefaultRoomConfig().MaxChatHistory, hub.config.Room.MaxChatHistory)
+	})
+
+	t.Run("several options take effect together", func(t *testing.T) {
+		store := &savedChats{}
+		tracer := NewRecordingTracer()
+		hub := NewTestHub(nil,
+			WithMaxRooms(1),
+			WithMaxParticipants(5),
+			WithChatStore(store),
+			WithTracer(tracer),
+			WithAllowedEvents(ChatEndpointEvents()),
+		)
+
+		room, err := hub.getOrCreateRoom("room-1")
+		require.NoError(t, err)
+		_, err = hub.getOrCreateRoom("room-2")
+		assert.ErrorIs(t, err, errTooManyRooms, "WithMaxRooms caps the hub")
+		assert.Equal(t, 5, room.config.MaxParticipants)
+		assert.True(t, room.config.AllowedEvents.Equal(ChatEndpointEvents()))
+
+		host := newTestClientWithName("host", "Host")
+		room.handleClientConnect(host)
+		room.router(context.Background(), host, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo: host.info(), ChatId: "chat-1", ChatContent: "hello",
+		}})
+		assert.Eventually(t, func() bool { return len(store.Chats()) == 1 }, time.Second, 5*time.Millisecond, "WithChatStore persists chat")
+		assert.NotEmpty(t, tracer.Spans(), "WithTracer records spans")
+
+		room.router(context.Background(), host, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(host.info())})
+		assert.NotContains(t, room.raisingHand, host.ID, "WithAllowedEvents scopes the rooms")
+	})
+
+	t.Run("options apply in order", func(t *testing.T) {
+		config := DefaultHubConfig()
+		config.MaxRooms = 3
+		config.Room.MaxParticipants = 7
+
+		hub := NewHub(&MockValidator{}, WithMaxRooms(10), WithHubConfig(config), WithAllowedEvents(set.New(EventAddChat)))
+
+		assert.Equal(t, 3, hub.config.MaxRooms, "WithHubConfig replaces earlier options")
+		assert.Equal(t, 7, hub.config.Room.MaxParticipants)
+		assert.True(t, hub.config.Room.AllowedEvents.Has(EventAddChat), "Later options adjust the replacement")
+	})
+}
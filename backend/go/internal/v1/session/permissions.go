@@ -6,9 +6,10 @@
 //
 // Permission Hierarchy (from least to most privileged):
 //  1. Waiting: Users awaiting admission to the room
-//  2. Participant: Active participants in the video call
-//  3. Screenshare: Participants currently sharing their screen
-//  4. Host: Room administrators with full control
+//  2. Spectator: Webinar attendees who watch and listen but cannot speak
+//  3. Participant: Active participants in the video call
+//  4. Screenshare: Participants currently sharing their screen
+//  5. Host: Room administrators with full control
 //
 // Design Philosophy:
 // Permissions are cumulative - higher roles include all permissions of lower roles.
@@ -46,6 +47,16 @@ func HasWaitingPermission() set.Set[RoleType] {
 	return set.New(RoleTypeWaiting)
 }
 
+// HasSpectatorPermission returns the set of roles that may receive the room's
+// media. Spectators answer offers and exchange candidates so they can watch,
+// but they cannot chat, raise hands or request the floor for screen sharing.
+//
+// Returns:
+//   - Set containing RoleTypeSpectator and every participant-level role
+func HasSpectatorPermission() set.Set[RoleType] {
+	return HasParticipantPermission().Insert(RoleTypeSpectator)
+}
+
 // HasParticipantPermission returns the set of roles with participant-level permissions.
 // This permission level includes all active meeting participants who can
 // engage in the full video conferencing experience.
@@ -151,3 +162,255 @@ func HasHostPermission() set.Set[RoleType] {
 func HasPermission(role RoleType, permissions set.Set[RoleType]) bool {
 	return permissions.Has(role)
 }
+
+// eventPermissions is the declarative table of which roles may send each event.
+// The router consults this table, or the room's RoomConfig.EventPermissions
+// replacing it, before dispatching to a handler, and the validation mode
+// consults it to report permission failures without side effects.
+//
+// Events that are not present in this table are unknown to the room and are
+// rejected by the router.
+var eventPermissions = map[Event]set.Set[RoleType]{
+	// Chat
+	EventAddChat:         HasParticipantPermission(),
+	EventDeleteChat:      HasParticipantPermission(),
+	EventDeleteUserChats: HasHostPermission(),
+	EventPinChat:         HasHostPermission(),
+	EventUnpinChat:       HasHostPermission(),
+	EventGetRecentChats:  HasParticipantPermission(),
+	EventGetChatsByRange: HasParticipantPermission(),
+
+	// Hand raising
+	EventRaiseHand:     HasParticipantPermission(),
+	EventLowerHand:     HasParticipantPermission(),
+	EventLowerAllHands: HasHostPermission(),
+
+	// Reactions - spectators may react without being able to speak
+	EventReaction: HasSpectatorPermission(),
+
+	// Active speaker and media state reports and captions come from clients
+	// publishing audio
+	EventActiveSpeaker: HasParticipantPermission(),
+	EventMediaState:    HasParticipantPermission(),
+	EventCaption:       HasParticipantPermission(),
+
+	// Connection diagnostics - everyone in the call reports, only hosts read
+	EventConnectionStats:  HasSpectatorPermission(),
+	EventConnectionReport: HasHostPermission(),
+
+	// Waiting room
+	EventRequestWaiting: HasWaitingPermission(),
+	EventAcceptWaiting:  HasHostPermission(),
+	EventDenyWaiting:    HasHostPermission(),
+
+	// Screen sharing - sharers cannot request again; a host's request starts its share
+	EventRequestScreenshare: set.New(RoleTypeHost, RoleTypeParticipant),
+	EventAcceptScreenshare:  HasHostPermission(),
+	EventDenyScreenshare:    HasHostPermission(),
+	EventStopScreenshare:    set.New(RoleTypeHost, RoleTypeScreenshare),
+
+	// Remote control - anyone in the call may ask a sharer, only sharers answer
+	EventRequestRemoteControl: HasParticipantPermission(),
+	EventGrantRemoteControl:   HasScreensharePermission(),
+	EventDenyRemoteControl:    HasScreensharePermission(),
+
+	// Spotlight
+	EventSpotlight:      HasHostPermission(),
+	EventClearSpotlight: HasHostPermission(),
+
+	// Webinar speaking - spectators ask, hosts decide
+	EventRequestSpeak: set.New(RoleTypeSpectator),
+	EventGrantSpeak:   HasHostPermission(),
+	EventRevokeSpeak:  HasHostPermission(),
+
+	// WebRTC signaling - spectators answer offers but never publish their own
+	EventOffer:       HasParticipantPermission(),
+	EventAnswer:      HasSpectatorPermission(),
+	EventCandidate:   HasSpectatorPermission(),
+	EventRenegotiate: HasParticipantPermission(),
+	EventPauseVideo:  HasSpectatorPermission(),
+	EventResumeVideo: HasSpectatorPermission(),
+	EventIsPresent:   HasParticipantPermission(),
+
+	// Moderation
+	EventKick:       HasHostPermission(),
+	EventSetPolicy:  HasHostPermission(),
+	EventSetWelcome: HasHostPermission(),
+
+	// Host transfer - the previous host is a participant by the time it reclaims
+	EventTransferHost: HasHostPermission(),
+	EventReclaimHost:  HasParticipantPermission(),
+
+	// Connection lifecycle - any connected client may leave
+	EventLeave: HasSpectatorPermission().Union(HasWaitingPermission()),
+
+	// Session restore - any admitted client may rebuild its view after reconnecting
+	EventRestoreSession: HasSpectatorPermission(),
+
+	// Development - any connected client may dry-run a message
+	EventValidate: HasSpectatorPermission().Union(HasWaitingPermission()),
+}
+
+// waitingEvents are the only events a waiting client may send: asking to be
+// admitted, leaving, and dry-running a message, which has no side effects.
+// The router rejects anything else from a waiting client before consulting
+// eventPermissions, so an event later granted to a broader role set never
+// becomes reachable from the waiting room by accident. See
+// RoomConfig.DisableWaitingGuard.
+var waitingEvents = set.New(EventRequestWaiting, EventLeave, EventValidate)
+
+// waitingGuardRejects reports whether the waiting room guard refuses event
+// from a client in role.
+func (r *Room) waitingGuardRejects(role RoleType, event Event) bool {
+	return !r.config.DisableWaitingGuard && role == RoleTypeWaiting && !waitingEvents.Has(event)
+}
+
+// DefaultEventPermissions returns a copy of the built-in permission table,
+// which rooms use unless RoomConfig.EventPermissions replaces it.
+//
+// Returns:
+//   - map: The roles that may send each event, keyed by event
+func DefaultEventPermissions() map[Event]set.Set[RoleType] {
+	permissions := make(map[Event]set.Set[RoleType], len(eventPermissions))
+	for event, roles := range eventPermissions {
+		permissions[event] = roles.Clone()
+	}
+	return permissions
+}
+
+// hasEventPermission checks whether a role is allowed to send the given event
+// in this room, consulting the room's permission table.
+//
+// Returns:
+//   - allowed: true if the role appears in the event's permission set
+//   - known: false if the event is not routable at all
+func (r *Room) hasEventPermission(role RoleType, event Event) (allowed bool, known bool) {
+	permissions, known := r.permissions[event]
+	if !known {
+		return false, false
+	}
+	return HasPermission(role, permissions), true
+}
+
+// HasEventPermission checks whether a role is allowed to send the given event
+// under the built-in permission table.
+//
+// Returns:
+//   - allowed: true if the role appears in the event's permission set
+//   - known: false if the event is not routable at all
+func HasEventPermission(role RoleType, event Event) (allowed bool, known bool) {
+	permissions, known := eventPermissions[event]
+	if !known {
+		return false, false
+	}
+	return HasPermission(role, permissions), true
+}
+
+// DefaultParticipantPolicy returns a ParticipantPolicy that allows everything.
+func DefaultParticipantPolicy() ParticipantPolicy {
+	return ParticipantPolicy{AllowChat: true, AllowScreenshare: true, AllowReactions: true}
+}
+
+// allows reports whether the policy lets a client in the given role send the
+// event. Hosts set the policy and are never restricted by it, and events the
+// policy does not cover are always allowed.
+func (p ParticipantPolicy) allows(role RoleType, event Event) bool {
+	if role == RoleTypeHost {
+		return true
+	}
+	switch event {
+	case EventAddChat:
+		return p.AllowChat
+	case EventRequestScreenshare:
+		return p.AllowScreenshare
+	case EventReaction:
+		return p.AllowReactions
+	default:
+		return true
+	}
+}
+
+// --- Outbound Visibility ---
+
+// DefaultHiddenEvents returns the roles that do not receive each event when it
+// is broadcast to everyone. Waiting users have not been admitted, so they are
+// not told who drops out of or leaves the meeting.
+//
+// Broadcasts addressed to explicit roles are unaffected; the policy only
+// narrows send-to-all broadcasts.
+//
+// Returns:
+//   - map: Roles excluded from send-to-all broadcasts, keyed by event
+func DefaultHiddenEvents() map[Event]set.Set[RoleType] {
+	return map[Event]set.Set[RoleType]{
+		EventDisconnect:      set.New(RoleTypeWaiting),
+		EventParticipantLeft: set.New(RoleTypeWaiting),
+	}
+}
+
+// --- Endpoint Scopes ---
+//
+// Each WebSocket endpoint serves one feature. A hub configured with an endpoint
+// scope (RoomConfig.AllowedEvents) rejects events outside it, so a client on
+// the chat endpoint cannot send WebRTC offers and vice versa. Every scope
+// includes the waiting room and connection lifecycle events, since admission
+// and leaving work the same on every endpoint.
+
+// lifecycleEvents returns the events every endpoint scope permits.
+func lifecycleEvents() set.Set[Event] {
+	return set.New(
+		EventRequestWaiting, EventAcceptWaiting, EventDenyWaiting,
+		EventLeave, EventKick, EventSetPolicy, EventSetWelcome, EventTransferHost, EventReclaimHost,
+		EventRestoreSession, EventValidate,
+	)
+}
+
+// queryEvents are the events whose handlers only read room state and reply to
+// the sender. The router handles them under the room's read lock, so their
+// handlers must never modify room or other clients' state; replying on the
+// sender's send channel is safe.
+//
+// Audit notes:
+//   - handleGetRecentChats, handleGetChatsByRange: read chatHistory only
+//   - handleValidate: checks permissions and payloads without side effects
+//   - handleConnectionReport: reads connectionStats only
+//   - handleRestoreSession: reads room state and chatHistory only
+//   - handleIsPresent: reads the role maps only
+//   - logHelper: the sampling counter is atomic
+var queryEvents = set.New(
+	EventGetRecentChats,
+	EventGetChatsByRange,
+	EventValidate,
+	EventConnectionReport,
+	EventRestoreSession,
+	EventIsPresent,
+)
+
+// ChatEndpointEvents returns the events accepted on the chat endpoint.
+func ChatEndpointEvents() set.Set[Event] {
+	return lifecycleEvents().Insert(
+		EventAddChat, EventDeleteChat, EventDeleteUserChats, EventGetRecentChats, EventGetChatsByRange,
+		EventPinChat, EventUnpinChat,
+	)
+}
+
+// MediaEndpointEvents returns the events accepted on the audio/video endpoint.
+func MediaEndpointEvents() set.Set[Event] {
+	return lifecycleEvents().Insert(
+		EventRaiseHand, EventLowerHand, EventLowerAllHands, EventReaction, EventActiveSpeaker, EventMediaState, EventCaption,
+		EventConnectionStats, EventConnectionReport, EventSpotlight, EventClearSpotlight,
+		EventRequestSpeak, EventGrantSpeak, EventRevokeSpeak,
+		EventOffer, EventAnswer, EventCandidate, EventRenegotiate,
+		EventPauseVideo, EventResumeVideo, EventIsPresent,
+	)
+}
+
+// ScreenshareEndpointEvents returns the events accepted on the screenshare endpoint.
+// Screens are streamed over WebRTC, so signaling events are included.
+func ScreenshareEndpointEvents() set.Set[Event] {
+	return lifecycleEvents().Insert(
+		EventRequestScreenshare, EventAcceptScreenshare, EventDenyScreenshare, EventStopScreenshare,
+		EventRequestRemoteControl, EventGrantRemoteControl, EventDenyRemoteControl,
+		EventOffer, EventAnswer, EventCandidate, EventRenegotiate, EventIsPresent,
+	)
+}
@@ -0,0 +1,107 @@
+// Package session - presence.go
+//
+// This file implements presence lookups: which rooms on a hub a user is
+// currently connected to, for dashboards that show whether contacts are in a
+// meeting.
+//
+// Data Source:
+// The hub already counts each subject's open connections per room to enforce
+// MaxRoomsPerUser. Presence reads the same index, so it is maintained on
+// connect and disconnect at no extra cost and counts waiting clients too.
+//
+// Endpoints:
+// Each feature endpoint runs its own hub, so a user connected only to /ws/chat
+// is present on the chat hub alone. ServePresence merges the rooms from every
+// hub it is given.
+//
+// Privacy:
+// Where someone is meeting is personal information. Callers may always look
+// themselves up; looking up anyone else requires HubConfig.PresenceVisible to
+// allow it, such as when the viewer follows the subject. PublicPresence, set
+// from PUBLIC_PRESENCE, lets any authenticated user look up anyone.
+package session
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresencePayload lists the rooms a user is connected to.
+type PresencePayload struct {
+	ClientId ClientIdType `json:"clientId"` // The user looked up
+	Rooms    []RoomIdType `json:"rooms"`    // Rooms with an open connection, ordered by id
+}
+
+// WhereIs returns the rooms on this hub that the subject has an open
+// connection to, including rooms where they are still waiting for admission.
+//
+// Thread Safety: Acquires the hub lock.
+//
+// Parameters:
+//   - subject: The authenticated subject to look up
+//
+// Returns:
+//   - []RoomIdType: Room ids ordered by id; empty if the subject is not connected
+func (h *Hub) WhereIs(subject ClientIdType) []RoomIdType {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rooms := make([]RoomIdType, 0, len(h.userRooms[subject]))
+	for roomId := range h.userRooms[subject] {
+		rooms = append(rooms, roomId)
+	}
+	slices.Sort(rooms)
+	return rooms
+}
+
+// ServePresence returns a handler that lists the rooms a user is connected to
+// on any of the given hubs. The token may be supplied as a Bearer
+// Authorization header or as the token query parameter. The first hub
+// authenticates the request and its HubConfig.PresenceVisible decides who may
+// be looked up.
+//
+// Parameters:
+//   - hubs: The hubs to search; at least one
+//
+// Responses:
+//   - 401 Unauthorized if the token is missing or invalid.
+//   - 403 Forbidden if the caller may not see the user's presence.
+//   - 200 OK with a PresencePayload otherwise.
+func ServePresence(hubs ...*Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := requestToken(c)
+		if tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token not provided"})
+			return
+		}
+
+		claims, err := hubs[0].validator.ValidateToken(tokenString)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		viewer := ClientIdType(claims.Subject)
+		subject := ClientIdType(c.Param("clientId"))
+		visible := hubs[0].config.PresenceVisible
+		if viewer != subject && (visible == nil || !visible(viewer, subject)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "presence not visible"})
+			return
+		}
+
+		rooms := []RoomIdType{}
+		for _, h := range hubs {
+			rooms = append(rooms, h.WhereIs(subject)...)
+		}
+		slices.Sort(rooms)
+		c.JSON(http.StatusOK, PresencePayload{ClientId: subject, Rooms: slices.Compact(rooms)})
+	}
+}
+
+// PublicPresence is a HubConfig.PresenceVisible policy that lets any
+// authenticated user see which rooms anyone else is in.
+func PublicPresence(viewer, subject ClientIdType) bool {
+	return true
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhereIs(t *testing.T) {
+	server := newTestServer(t, DefaultHubConfig(), "alice", "bob")
+	assert.Empty(t, server.hub.WhereIs("alice"), "Not connected anywhere yet")
+
+	alice1 := server.dial(t, "room-b", "alice")
+	server.dial(t, "room-a", "alice")
+	server.dial(t, "room-a", "bob")
+
+	assert.Equal(t, []RoomIdType{"room-a", "room-b"}, server.hub.WhereIs("alice"))
+	assert.Equal(t, []RoomIdType{"room-a"}, server.hub.WhereIs("bob"))
+
+	require.NoError(t, alice1.conn.Close())
+	require.Eventually(t, func() bool {
+		rooms := server.hub.WhereIs("alice")
+		return len(rooms) == 1 && rooms[0] == "room-a"
+	}, time.Second, 5*time.Millisecond, "Leaving one room keeps the others")
+	assert.Equal(t, []RoomIdType{"room-a"}, server.hub.WhereIs("bob"), "Other users are unaffected")
+}
+
+func TestServePresence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(visible func(viewer, subject ClientIdType) bool) *gin.Engine {
+		config := DefaultHubConfig()
+		config.PresenceVisible = visible
+		hub := NewHubWithConfig(tokenTable{
+			"valid-token": {RegisteredClaims: jwt.RegisteredClaims{Subject: "test-user"}},
+		}, config)
+		hub.userRooms["other-user"] = map[RoomIdType]int{"room-1": 1}
+		hub.userRooms["test-user"] = map[RoomIdType]int{"room-2": 1}
+		router := gin.New()
+		router.GET("/users/:clientId/rooms", ServePresence(hub))
+		return router
+	}
+
+	get := func(router *gin.Engine, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("requires a valid token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, get(newRouter(nil), "/users/test-user/rooms", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, get(newRouter(nil), "/users/test-user/rooms", "forged").Code)
+	})
+
+	t.Run("users may see their own presence", func(t *testing.T) {
+		w := get(newRouter(nil), "/users/test-user/rooms", "valid-token")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var presence PresencePayload
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &presence))
+		assert.Equal(t, PresencePayload{ClientId: "test-user", Rooms: []RoomIdType{"room-2"}}, presence)
+	})
+
+	t.Run("other users are hidden by default", func(t *testing.T) {
+		w := get(newRouter(nil), "/users/other-user/rooms", "valid-token")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("rooms on every hub are listed", func(t *testing.T) {
+		validator := tokenTable{"valid-token": {RegisteredClaims: jwt.RegisteredClaims{Subject: "test-user"}}}
+		hub := NewHubWithConfig(validator, DefaultHubConfig())
+		chatHub := NewHubWithConfig(validator, DefaultHubConfig())
+		hub.userRooms["test-user"] = map[RoomIdType]int{"room-2": 1}
+		chatHub.userRooms["test-user"] = map[RoomIdType]int{"room-1": 1, "room-2": 1}
+		router := gin.New()
+		router.GET("/users/:clientId/rooms", ServePresence(hub, chatHub))
+
+		w := get(router, "/users/test-user/rooms", "valid-token")
+		require.Equal(t, http.StatusOK, w.Code)
+		var presence PresencePayload
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &presence))
+		assert.Equal(t, []RoomIdType{"room-1", "room-2"}, presence.Rooms, "Rooms are merged and listed once")
+	})
+
+	t.Run("public presence reveals everyone", func(t *testing.T) {
+		w := get(newRouter(PublicPresence), "/users/other-user/rooms", "valid-token")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("visibility policy can reveal other users", func(t *testing.T) {
+		follows := func(viewer, subject ClientIdType) bool {
+			return viewer == "test-user" && subject == "other-user"
+		}
+		w := get(newRouter(follows), "/users/other-user/rooms", "valid-token")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var presence PresencePayload
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &presence))
+		assert.Equal(t, []RoomIdType{"room-1"}, presence.Rooms)
+	})
+}
@@ -0,0 +1,106 @@
+// Package session - ratelimit.go
+//
+// This file implements the per-IP connection rate limiter applied by
+// Hub.ServeWs, which throttles connection storms from a single source before
+// any token validation or WebSocket upgrade work is done.
+//
+// Algorithm:
+// Each source IP has a token bucket holding up to Burst tokens that refills at
+// PerMinute tokens per minute. Every connection attempt spends one token, and
+// attempts with no token available are refused.
+package session
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// ipLimiterSweepInterval is how often buckets that have refilled completely are
+// forgotten, bounding memory to the IPs seen recently.
+const ipLimiterSweepInterval = time.Minute
+
+// tokenBucket is one source IP's allowance.
+type tokenBucket struct {
+	tokens float64   // Tokens available as of update
+	update time.Time // When tokens was last brought up to date
+}
+
+// ipRateLimiter is a set of token buckets keyed by source IP.
+//
+// Thread Safety: All methods are safe for concurrent use.
+type ipRateLimiter struct {
+	mu        sync.Mutex
+	clock     clock.PassiveClock
+	perSecond float64 // Refill rate
+	burst     float64 // Bucket capacity
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// newIPRateLimiter returns a limiter allowing perMinute connections per IP per
+// minute with bursts of up to burst, or nil if perMinute is not positive. A
+// burst below one is raised to one.
+func newIPRateLimiter(perMinute, burst int, clk clock.PassiveClock) *ipRateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	return &ipRateLimiter{
+		clock:     clk,
+		perSecond: float64(perMinute) / 60,
+		burst:     float64(max(burst, 1)),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: clk.Now(),
+	}
+}
+
+// allow spends a token from the IP's bucket.
+//
+// Parameters:
+//   - ip: The source IP of the connection attempt
+//
+// Returns:
+//   - true if the attempt is within the limit
+func (l *ipRateLimiter) allow(ip string) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[ip]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, update: now}
+		l.buckets[ip] = bucket
+	}
+	bucket.tokens = min(l.burst, bucket.tokens+now.Sub(bucket.update).Seconds()*l.perSecond)
+	bucket.update = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// sweep forgets buckets that would have refilled completely, since a new
+// bucket starts full anyway. It runs at most once per ipLimiterSweepInterval.
+// The caller must hold l.mu.
+func (l *ipRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < ipLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for ip, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.update).Seconds()*l.perSecond >= l.burst {
+			delete(l.buckets, ip)
+		}
+	}
+}
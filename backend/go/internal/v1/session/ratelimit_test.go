@@ -0,0 +1,70 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	testclock "k8s.io/utils/clock/testing"
+)
+
+func TestConnectRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newServer := func(perMinute, burst int) (*gin.Engine, *testclock.FakeClock) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultHubConfig()
+		config.Room.Clock = fakeClock
+		config.ConnectRatePerIP = perMinute
+		config.ConnectBurstPerIP = burst
+		hub := NewHubWithConfig(&MockValidator{}, config)
+		router := gin.New()
+		router.GET("/ws/:roomId", hub.ServeWs)
+		return router, fakeClock
+	}
+
+	// connect attempts a connection from the given client IP. No token is sent,
+	// so attempts within the limit fail authentication with 401 instead.
+	connect := func(router *gin.Engine, ip string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ws/room-1", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("rapid connections from one IP are throttled", func(t *testing.T) {
+		router, _ := newServer(60, 3)
+		for range 3 {
+			assert.Equal(t, http.StatusUnauthorized, connect(router, "203.0.113.1"))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, connect(router, "203.0.113.1"))
+	})
+
+	t.Run("a different IP is unaffected", func(t *testing.T) {
+		router, _ := newServer(60, 1)
+		assert.Equal(t, http.StatusUnauthorized, connect(router, "203.0.113.1"))
+		assert.Equal(t, http.StatusTooManyRequests, connect(router, "203.0.113.1"))
+
+		assert.Equal(t, http.StatusUnauthorized, connect(router, "198.51.100.7"))
+	})
+
+	t.Run("allowance refills over time", func(t *testing.T) {
+		router, fakeClock := newServer(60, 1)
+		assert.Equal(t, http.StatusUnauthorized, connect(router, "203.0.113.1"))
+		assert.Equal(t, http.StatusTooManyRequests, connect(router, "203.0.113.1"))
+
+		fakeClock.Step(time.Second)
+		assert.Equal(t, http.StatusUnauthorized, connect(router, "203.0.113.1"))
+	})
+
+	t.Run("zero rate is unlimited", func(t *testing.T) {
+		router, _ := newServer(0, 0)
+		for range 20 {
+			assert.Equal(t, http.StatusUnauthorized, connect(router, "203.0.113.1"))
+		}
+	})
+}
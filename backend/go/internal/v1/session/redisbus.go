@@ -0,0 +1,418 @@
+// Package session - redisbus.go
+//
+// This file implements RedisRoomBus, a RoomBus backed by Redis pub/sub, so
+// hub instances in different processes or on different machines can share
+// rooms. It speaks the Redis protocol (RESP) directly over TCP and needs only
+// PUBLISH, SUBSCRIBE, UNSUBSCRIBE and, with a password, AUTH.
+//
+// Connections:
+// The bus keeps two connections: one publishing and one subscribed, as Redis
+// requires of a connection in subscribe mode. Each is redialed after an
+// error, waiting RedisBusConfig.RetryInterval between attempts, and the
+// subscribed connection resubscribes to every topic still in use. Messages
+// published while Redis is unreachable are lost; rooms carry on serving their
+// local clients.
+//
+// Delivery:
+// Publish encodes the BusMessage as JSON and queues it for the publishing
+// connection, so it never blocks the room lock. Received messages are handed
+// to each local subscriber's own queue and goroutine, as MemoryRoomBus does,
+// so a slow room never delays the others. Full queues drop messages with a
+// warning.
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisBusConfig configures a RedisRoomBus.
+type RedisBusConfig struct {
+	// Addr is the Redis server's host:port.
+	Addr string
+
+	// Password authenticates with AUTH when set.
+	Password string
+
+	// ChannelPrefix is prepended to each topic to name its Redis channel, so
+	// several applications can share one server. Empty uses "session:".
+	ChannelPrefix string
+
+	// BufferSize is how many messages may wait to be published, and how many
+	// may wait for each subscriber. Values below one use 256.
+	BufferSize int
+
+	// DialTimeout bounds connecting to Redis. Zero uses five seconds.
+	DialTimeout time.Duration
+
+	// RetryInterval is how long to wait before redialing a lost connection.
+	// Zero uses one second.
+	RetryInterval time.Duration
+}
+
+// RedisRoomBus is a RoomBus carried over Redis pub/sub; see redisbus.go.
+//
+// Thread Safety: All methods are safe for concurrent use.
+type RedisRoomBus struct {
+	config  RedisBusConfig
+	publish chan BusMessage
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	subs    map[string]map[*busSubscription]struct{} // Local subscribers by topic
+	subConn net.Conn                                 // The subscribed connection, nil while redialing
+	closed  bool
+}
+
+// NewRedisRoomBus creates a RedisRoomBus and starts connecting to Redis in
+// the background. Call Close to disconnect.
+//
+// Parameters:
+//   - config: The server to use and how to use it
+//
+// Returns:
+//   - *RedisRoomBus: The bus, usable at once
+func NewRedisRoomBus(config RedisBusConfig) *RedisRoomBus {
+	if config.ChannelPrefix == "" {
+		config.ChannelPrefix = "session:"
+	}
+	if config.BufferSize < 1 {
+		config.BufferSize = 256
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = time.Second
+	}
+	b := &RedisRoomBus{
+		config:  config,
+		publish: make(chan BusMessage, config.BufferSize),
+		done:    make(chan struct{}),
+		subs:    make(map[string]map[*busSubscription]struct{}),
+	}
+	b.wg.Add(2)
+	go b.runPublisher()
+	go b.runSubscriber()
+	return b
+}
+
+// Publish queues msg for Redis without blocking.
+func (b *RedisRoomBus) Publish(msg BusMessage) {
+	select {
+	case b.publish <- msg:
+	default:
+		slog.Warn("Redis room bus publish queue full, dropping message", "topic", msg.Topic, "event", msg.Event)
+	}
+}
+
+// Subscribe delivers messages on topic to handler on a new goroutine until
+// the returned function is called. The first local subscriber to a topic
+// subscribes to its Redis channel; the last to leave unsubscribes.
+func (b *RedisRoomBus) Subscribe(topic string, handler func(BusMessage)) func() {
+	sub := &busSubscription{queue: make(chan BusMessage, b.config.BufferSize)}
+	b.mu.Lock()
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[*busSubscription]struct{})
+		b.sendSubscriptionLocked("SUBSCRIBE", topic)
+	}
+	b.subs[topic][sub] = struct{}{}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case msg := <-sub.queue:
+				handler(msg)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[topic], sub)
+			if len(b.subs[topic]) == 0 {
+				delete(b.subs, topic)
+				b.sendSubscriptionLocked("UNSUBSCRIBE", topic)
+			}
+			b.mu.Unlock()
+			close(done)
+		})
+	}
+}
+
+// Close disconnects from Redis and stops the bus's goroutines. Messages not
+// yet published are discarded. It is safe to call more than once.
+func (b *RedisRoomBus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.done)
+	if b.subConn != nil {
+		b.subConn.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// channel names the Redis channel for a topic.
+func (b *RedisRoomBus) channel(topic string) string {
+	return b.config.ChannelPrefix + topic
+}
+
+// sendSubscriptionLocked sends SUBSCRIBE or UNSUBSCRIBE for topic on the
+// subscribed connection. While there is none, nothing is sent: the topics in
+// use are subscribed when it reconnects. The caller must hold b.mu.
+func (b *RedisRoomBus) sendSubscriptionLocked(command, topic string) {
+	if b.subConn == nil {
+		return
+	}
+	if err := writeRESPCommand(b.subConn, command, b.channel(topic)); err != nil {
+		// The read loop sees the broken connection and redials
+		slog.Warn("Failed to update Redis room bus subscription", "command", command, "topic", topic, "error", err)
+		b.subConn.Close()
+	}
+}
+
+// dial connects and authenticates a new connection to Redis.
+func (b *RedisRoomBus) dial() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", b.config.Addr, b.config.DialTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	if b.config.Password != "" {
+		if err := writeRESPCommand(conn, "AUTH", b.config.Password); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		if _, err := readRESP(reader); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("redis AUTH: %w", err)
+		}
+	}
+	return conn, reader, nil
+}
+
+// wait pauses for the retry interval, reporting false if the bus closed.
+func (b *RedisRoomBus) wait() bool {
+	select {
+	case <-b.done:
+		return false
+	case <-time.After(b.config.RetryInterval):
+		return true
+	}
+}
+
+// runPublisher sends queued messages on the publishing connection until the
+// bus closes, redialing after errors.
+func (b *RedisRoomBus) runPublisher() {
+	defer b.wg.Done()
+	var conn net.Conn
+	var reader *bufio.Reader
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		var msg BusMessage
+		select {
+		case <-b.done:
+			return
+		case msg = <-b.publish:
+		}
+
+		data, err := json.Marshal(msg)
+		if err != nil {
+			slog.Error("Failed to encode room bus message", "topic", msg.Topic, "event", msg.Event, "error", err)
+			continue
+		}
+		if conn == nil {
+			if conn, reader, err = b.dial(); err != nil {
+				slog.Warn("Redis room bus unreachable, dropping message", "topic", msg.Topic, "event", msg.Event, "error", err)
+				if !b.wait() {
+					return
+				}
+				continue
+			}
+		}
+		if err = writeRESPCommand(conn, "PUBLISH", b.channel(msg.Topic), string(data)); err == nil {
+			_, err = readRESP(reader)
+		}
+		if err != nil {
+			slog.Warn("Failed to publish room bus message", "topic", msg.Topic, "event", msg.Event, "error", err)
+			conn.Close()
+			conn = nil
+		}
+	}
+}
+
+// runSubscriber keeps a subscribed connection open until the bus closes,
+// subscribing to every topic in use each time it connects and handing each
+// message received to the topic's local subscribers.
+func (b *RedisRoomBus) runSubscriber() {
+	defer b.wg.Done()
+	for {
+		conn, reader, err := b.dial()
+		if err != nil {
+			slog.Warn("Failed to connect room bus subscriber to Redis", "error", err)
+			if !b.wait() {
+				return
+			}
+			continue
+		}
+
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			conn.Close()
+			return
+		}
+		b.subConn = conn
+		for topic := range b.subs {
+			b.sendSubscriptionLocked("SUBSCRIBE", topic)
+		}
+		b.mu.Unlock()
+
+		err = b.receive(reader)
+
+		b.mu.Lock()
+		b.subConn = nil
+		closed := b.closed
+		b.mu.Unlock()
+		conn.Close()
+		if closed {
+			return
+		}
+		slog.Warn("Room bus subscriber lost its Redis connection", "error", err)
+		if !b.wait() {
+			return
+		}
+	}
+}
+
+// receive reads from the subscribed connection until it fails, delivering
+// each published message.
+func (b *RedisRoomBus) receive(reader *bufio.Reader) error {
+	for {
+		reply, err := readRESP(reader)
+		if err != nil {
+			return err
+		}
+		// Pushes are arrays; only "message" carries a publication. Replies
+		// to SUBSCRIBE and UNSUBSCRIBE are ignored.
+		push, ok := reply.([]any)
+		if !ok || len(push) != 3 {
+			continue
+		}
+		if kind, _ := push[0].(string); kind != "message" {
+			continue
+		}
+		data, _ := push[2].(string)
+		var msg BusMessage
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			slog.Warn("Ignoring malformed room bus message", "error", err)
+			continue
+		}
+		b.deliver(msg)
+	}
+}
+
+// deliver queues msg for every local subscriber of its topic without blocking.
+func (b *RedisRoomBus) deliver(msg BusMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs[msg.Topic] {
+		select {
+		case sub.queue <- msg:
+		default:
+			slog.Warn("Room bus subscriber queue full, dropping message", "RoomId", msg.RoomId, "event", msg.Event)
+		}
+	}
+}
+
+// --- RESP ---
+
+// errRESP is returned for a reply Redis sent as an error.
+var errRESP = errors.New("redis error")
+
+// writeRESPCommand writes a command as a RESP array of bulk strings.
+func writeRESPCommand(conn net.Conn, args ...string) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, "\r\n"...)
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	_, err := conn.Write(buf)
+	return err
+}
+
+// readRESP reads one RESP value. Simple and bulk strings are returned as
+// string, integers as int64, arrays as []any, and nil bulk strings and
+// arrays as nil. An error reply is returned as an error wrapping errRESP.
+func readRESP(reader *bufio.Reader) (any, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed RESP line %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, fmt.Errorf("%w: %s", errRESP, body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(body)
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(body)
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		items := make([]any, count)
+		for i := range items {
+			if items[i], err = readRESP(reader); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown RESP type %q", kind)
+}
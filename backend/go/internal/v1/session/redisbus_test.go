@@ -0,0 +1,254 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is a minimal Redis server supporting the pub/sub commands
+// RedisRoomBus uses.
+type fakeRedis struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	conns       map[net.Conn]struct{}
+	subscribers map[string]map[net.Conn]struct{} // By channel
+	passwords   []string                         // Every AUTH received
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeRedis{
+		listener:    listener,
+		conns:       make(map[net.Conn]struct{}),
+		subscribers: make(map[string]map[net.Conn]struct{}),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropSubscribers()
+	})
+	return s
+}
+
+func (s *fakeRedis) addr() string { return s.listener.Addr().String() }
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		for _, subs := range s.subscribers {
+			delete(subs, conn)
+		}
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	for {
+		value, err := readRESP(reader)
+		if err != nil {
+			return
+		}
+		args, _ := value.([]any)
+		if len(args) < 2 {
+			return
+		}
+		command, _ := args[0].(string)
+		arg, _ := args[1].(string)
+
+		s.mu.Lock()
+		switch command {
+		case "AUTH":
+			s.passwords = append(s.passwords, arg)
+			fmt.Fprint(conn, "+OK\r\n")
+		case "SUBSCRIBE":
+			if s.subscribers[arg] == nil {
+				s.subscribers[arg] = make(map[net.Conn]struct{})
+			}
+			s.subscribers[arg][conn] = struct{}{}
+			fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(arg), arg)
+		case "UNSUBSCRIBE":
+			delete(s.subscribers[arg], conn)
+			fmt.Fprintf(conn, "*3\r\n$11\r\nunsubscribe\r\n$%d\r\n%s\r\n:0\r\n", len(arg), arg)
+		case "PUBLISH":
+			data, _ := args[2].(string)
+			for sub := range s.subscribers[arg] {
+				fmt.Fprintf(sub, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(arg), arg, len(data), data)
+			}
+			fmt.Fprintf(conn, ":%d\r\n", len(s.subscribers[arg]))
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", command)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// subscribed reports how many connections are subscribed to channel.
+func (s *fakeRedis) subscribed(channel string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers[channel])
+}
+
+// dropSubscribers closes every subscribed connection, as a restarting server
+// would.
+func (s *fakeRedis) dropSubscribers() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, subs := range s.subscribers {
+		for conn := range subs {
+			conn.Close()
+		}
+	}
+}
+
+func TestRedisRoomBus(t *testing.T) {
+	newBus := func(t *testing.T, server *fakeRedis, password string) *RedisRoomBus {
+		bus := NewRedisRoomBus(RedisBusConfig{Addr: server.addr(), Password: password, RetryInterval: 10 * time.Millisecond})
+		t.Cleanup(bus.Close)
+		return bus
+	}
+
+	// receive collects the messages a subscription is handed.
+	receive := func(bus RoomBus, topic string) (<-chan BusMessage, func()) {
+		got := make(chan BusMessage, 16)
+		unsubscribe := bus.Subscribe(topic, func(msg BusMessage) { got <- msg })
+		return got, unsubscribe
+	}
+
+	// publishUntil publishes msg until it arrives, since the publisher's
+	// connection may still be opening.
+	publishUntil := func(t *testing.T, bus RoomBus, msg BusMessage, got <-chan BusMessage) BusMessage {
+		t.Helper()
+		deadline := time.After(2 * time.Second)
+		for {
+			bus.Publish(msg)
+			select {
+			case received := <-got:
+				return received
+			case <-time.After(20 * time.Millisecond):
+			case <-deadline:
+				t.Fatal("message never arrived")
+				return BusMessage{}
+			}
+		}
+	}
+
+	t.Run("messages cross between buses on the prefixed channel", func(t *testing.T) {
+		server := newFakeRedis(t)
+		publisher, subscriber := newBus(t, server, ""), newBus(t, server, "")
+		got, _ := receive(subscriber, "zoom:meeting")
+		require.Eventually(t, func() bool { return server.subscribed("session:zoom:meeting") == 1 }, time.Second, 5*time.Millisecond)
+
+		sent := BusMessage{Topic: "zoom:meeting", Origin: "a", RoomId: "meeting", Event: EventAddChat, Roles: []RoleType{RoleTypeHost}, Data: []byte(`{"event":"add_chat"}`)}
+		received := publishUntil(t, publisher, sent, got)
+
+		assert.Equal(t, sent, received)
+	})
+
+	t.Run("other topics receive nothing", func(t *testing.T) {
+		server := newFakeRedis(t)
+		publisher, subscriber := newBus(t, server, ""), newBus(t, server, "")
+		got, _ := receive(subscriber, "zoom:meeting")
+		other, _ := receive(subscriber, "chat:meeting")
+		require.Eventually(t, func() bool { return server.subscribed("session:zoom:meeting") == 1 }, time.Second, 5*time.Millisecond)
+
+		publishUntil(t, publisher, BusMessage{Topic: "zoom:meeting", Event: EventAddChat}, got)
+
+		assert.Empty(t, other)
+	})
+
+	t.Run("the last local subscriber leaving unsubscribes", func(t *testing.T) {
+		server := newFakeRedis(t)
+		bus := newBus(t, server, "")
+		_, first := receive(bus, "meeting")
+		_, second := receive(bus, "meeting")
+		require.Eventually(t, func() bool { return server.subscribed("session:meeting") == 1 }, time.Second, 5*time.Millisecond)
+
+		first()
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, 1, server.subscribed("session:meeting"), "One subscriber remains")
+
+		second()
+		assert.Eventually(t, func() bool { return server.subscribed("session:meeting") == 0 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("connections authenticate when a password is set", func(t *testing.T) {
+		server := newFakeRedis(t)
+		bus := newBus(t, server, "secret")
+		got, _ := receive(bus, "meeting")
+		require.Eventually(t, func() bool { return server.subscribed("session:meeting") == 1 }, time.Second, 5*time.Millisecond)
+
+		publishUntil(t, bus, BusMessage{Topic: "meeting"}, got)
+
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		assert.Equal(t, []string{"secret", "secret"}, server.passwords, "Both connections authenticate")
+	})
+
+	t.Run("topics are resubscribed after the connection is lost", func(t *testing.T) {
+		server := newFakeRedis(t)
+		publisher, subscriber := newBus(t, server, ""), newBus(t, server, "")
+		got, _ := receive(subscriber, "meeting")
+		require.Eventually(t, func() bool { return server.subscribed("session:meeting") == 1 }, time.Second, 5*time.Millisecond)
+
+		server.dropSubscribers()
+		require.Eventually(t, func() bool { return server.subscribed("session:meeting") == 1 }, time.Second, 5*time.Millisecond)
+
+		received := publishUntil(t, publisher, BusMessage{Topic: "meeting", Event: EventAddChat}, got)
+		assert.Equal(t, EventAddChat, received.Event)
+	})
+
+	t.Run("rooms on hubs with separate buses share broadcasts", func(t *testing.T) {
+		server := newFakeRedis(t)
+		hubA := NewHub(&MockValidator{}, WithRoomBus(newBus(t, server, ""), "zoom"))
+		hubB := NewHub(&MockValidator{}, WithRoomBus(newBus(t, server, ""), "zoom"))
+		roomA, _ := hubA.getOrCreateRoom("meeting")
+		roomB, _ := hubB.getOrCreateRoom("meeting")
+		defer roomA.close()
+		defer roomB.close()
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		roomA.handleClientConnect(alice)
+		roomB.handleClientConnect(bob)
+		require.Eventually(t, func() bool { return server.subscribed("session:zoom:meeting") == 2 }, time.Second, 5*time.Millisecond)
+
+		// The first publish opens the publishing connection, so retry until
+		// the chat arrives.
+		assert.Eventually(t, func() bool {
+			roomA.router(context.Background(), alice, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: alice.info(), ChatContent: "hello from A"}})
+			for {
+				select {
+				case raw := <-bob.send:
+					var msg wireMessage
+					if json.Unmarshal(raw, &msg) == nil && msg.Event == EventAddChat {
+						return true
+					}
+				case <-time.After(20 * time.Millisecond):
+					return false
+				}
+			}
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,320 @@
+// Package session - remote.go
+//
+// This file lets copies of a room on different hub instances act as one
+// meeting over RoomConfig.Bus, beyond sharing broadcasts (see bus.go).
+//
+// Rosters:
+// Each copy publishes its own clients, with their roles, whenever they
+// change, and keeps the latest roster of every other copy. Room state lists
+// the clients of every copy. Admission counts them too: a joiner becomes the
+// first host only if no copy has admitted anyone, the hostless grace period
+// runs only while no copy has a host, and hosts on any copy count towards
+// RoomConfig.WaitingApprovals. A new copy asks the others for their rosters,
+// and a closing copy publishes an empty one.
+//
+// Direct Messages:
+// WebRTC signaling, video pause and remote control addressed to a client of
+// another copy are published for that client alone, and its copy delivers
+// them.
+//
+// Host Actions:
+// Accepting, denying and kicking a client of another copy are forwarded to
+// it. The host's copy checks the host's permission as for any message, and
+// the target's copy checks it again against its own permission matrix before
+// acting on the host's behalf.
+//
+// Limits:
+// Glare detection, candidate batching, capacity and room settings such as
+// the policy and spotlight are per copy. A copy that stops without closing
+// its room, such as on a crashed instance, stays listed until the room
+// closes on the others.
+package session
+
+import (
+	"encoding/json"
+	"log/slog"
+	"slices"
+)
+
+// publishBus publishes msg on the room's topic as this copy. Nothing is sent
+// when the room has no bus.
+//
+// Thread Safety: Safe without the room's lock; the topic and origin are set
+// once, when the room is created.
+func (r *Room) publishBus(msg BusMessage) {
+	if r.config.Bus == nil {
+		return
+	}
+	msg.Topic = r.busTopic
+	msg.Origin = r.busOrigin
+	msg.RoomId = r.ID
+	r.config.Bus.Publish(msg)
+}
+
+// localMembers lists this copy's clients for its roster: hosts,
+// participants, screensharers, spectators and waiting clients, each in join
+// order.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+func (r *Room) localMembers() []BusMember {
+	var members []BusMember
+	for _, group := range []struct {
+		role    RoleType
+		clients map[ClientIdType]*Client
+	}{
+		{RoleTypeHost, r.hosts},
+		{RoleTypeParticipant, r.participants},
+		{RoleTypeScreenshare, r.sharingScreen},
+		{RoleTypeSpectator, r.spectators},
+		{RoleTypeWaiting, r.waiting},
+	} {
+		for _, c := range clientsMapToSlice(group.clients) {
+			_, handRaised := r.raisingHand[c.ID]
+			members = append(members, BusMember{
+				ClientInfo: c.info(),
+				Role:       group.role,
+				Sharing:    r.isSharing(c.ID),
+				HandRaised: handRaised,
+			})
+		}
+	}
+	return members
+}
+
+// shareRoster publishes this copy's roster if it changed since it was last
+// published. Call it after any change to the role maps, screen sharing or
+// raised hands.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) shareRoster() {
+	if r.config.Bus == nil {
+		return
+	}
+	members := r.localMembers()
+	if slices.Equal(members, r.sharedRoster) {
+		return
+	}
+	r.sharedRoster = members
+	r.publishBus(BusMessage{Kind: BusKindRoster, Members: members})
+}
+
+// receiveRoster replaces another copy's roster. An empty roster removes the
+// copy, which has no clients left or has closed.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) receiveRoster(msg BusMessage) {
+	if len(msg.Members) == 0 {
+		delete(r.remoteMembers, msg.Origin)
+	} else {
+		if r.remoteMembers == nil {
+			r.remoteMembers = make(map[string][]BusMember)
+		}
+		r.remoteMembers[msg.Origin] = msg.Members
+	}
+	// A host arriving or leaving on another copy starts or stops the
+	// hostless grace period here
+	r.checkHostless()
+}
+
+// remoteMember looks up a client of another copy of the room.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+func (r *Room) remoteMember(clientId ClientIdType) (BusMember, bool) {
+	for _, members := range r.remoteMembers {
+		for _, m := range members {
+			if m.ClientId == clientId {
+				return m, true
+			}
+		}
+	}
+	return BusMember{}, false
+}
+
+// remotePeer looks up a client of another copy that can take part in
+// signaling, which, as for findPeer, excludes waiting clients.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+func (r *Room) remotePeer(clientId ClientIdType) (BusMember, bool) {
+	m, ok := r.remoteMember(clientId)
+	if !ok || m.Role == RoleTypeWaiting {
+		return BusMember{}, false
+	}
+	return m, true
+}
+
+// remoteCount counts the clients of other copies in any of roles.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+func (r *Room) remoteCount(roles ...RoleType) int {
+	count := 0
+	for _, members := range r.remoteMembers {
+		for _, m := range members {
+			if slices.Contains(roles, m.Role) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// isHost reports whether a client is a host on any copy of the room.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+func (r *Room) isHost(clientId ClientIdType) bool {
+	if _, ok := r.hosts[clientId]; ok {
+		return true
+	}
+	m, ok := r.remoteMember(clientId)
+	return ok && m.Role == RoleTypeHost
+}
+
+// sendDirect publishes a message for a client of another copy of the room,
+// whose copy delivers it.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - to: The client of another copy, as found by remotePeer
+//   - event: The event to send
+//   - payload: The payload, already stamped with its sender
+func (r *Room) sendDirect(to ClientIdType, event Event, payload any) {
+	rawMsg, err := marshalMessage(event, payload)
+	if err != nil {
+		slog.Error("Failed to marshal direct message", "error", err, "TargetClientId", to, "RoomId", r.ID)
+		return
+	}
+	r.publishBus(BusMessage{Kind: BusKindDirect, Event: event, To: to, Data: rawMsg})
+}
+
+// deliverDirect hands a direct message from another copy to its addressee,
+// if the addressee is a client of this copy.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+func (r *Room) deliverDirect(msg BusMessage) {
+	target, ok := r.findPeer(msg.To)
+	if !ok {
+		return
+	}
+	select {
+	case target.send <- msg.Data:
+	default:
+		slog.Warn("Failed to deliver direct message from another instance - target client channel full",
+			"event", msg.Event, "TargetClientId", msg.To, "RoomId", r.ID)
+	}
+}
+
+// forwardAction forwards a host's action on a client of another copy of the
+// room to that copy. Handlers call it when the target is not one of their
+// own clients.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - host: The host taking the action
+//   - to: The client acted on
+//   - event: The host's event
+//   - payload: The host's payload
+//
+// Returns:
+//   - bool: true if another copy holds the target and the action was forwarded
+func (r *Room) forwardAction(host *Client, to ClientIdType, event Event, payload any) bool {
+	if _, ok := r.remoteMember(to); !ok {
+		return false
+	}
+	rawMsg, err := marshalMessage(event, payload)
+	if err != nil {
+		slog.Error("Failed to marshal forwarded action", "error", err, "event", event, "TargetClientId", to, "RoomId", r.ID)
+		return true
+	}
+	from := BusMember{ClientInfo: host.info(), Role: host.Role}
+	r.publishBus(BusMessage{Kind: BusKindAction, Event: event, To: to, From: &from, Data: rawMsg})
+	slog.Info("Forwarded host action to another instance", "event", event, "ClientId", host.ID, "TargetClientId", to, "RoomId", r.ID)
+	return true
+}
+
+// runRemoteAction carries out a host action forwarded by another copy, if
+// the target is a client of this copy and the host's role may take it here.
+// The handler acts for a stand-in for the host, which receives nothing.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) runRemoteAction(msg BusMessage) {
+	if msg.From == nil {
+		return
+	}
+	if _, ok := r.findMember(msg.To); !ok {
+		return
+	}
+	if allowed, _ := r.hasEventPermission(msg.From.Role, msg.Event); !allowed {
+		slog.Warn("Refused forwarded action the host's role may not take", "event", msg.Event, "ClientId", msg.From.ClientId, "RoomId", r.ID)
+		return
+	}
+	var m Message
+	if err := json.Unmarshal(msg.Data, &m); err != nil || m.Event != msg.Event {
+		slog.Warn("Ignoring malformed forwarded action", "event", msg.Event, "RoomId", r.ID, "error", err)
+		return
+	}
+
+	host := &Client{
+		ID:          msg.From.ClientId,
+		DisplayName: msg.From.DisplayName,
+		AvatarURL:   msg.From.AvatarURL,
+		Pronouns:    msg.From.Pronouns,
+		Role:        msg.From.Role,
+	}
+	ctx := r.ctx
+	switch m.Event {
+	case EventAcceptWaiting:
+		r.handleAcceptWaiting(ctx, host, m.Event, m.Payload)
+	case EventDenyWaiting:
+		r.handleDenyWaiting(ctx, host, m.Event, m.Payload)
+	case EventKick:
+		r.handleKick(ctx, host, m.Event, m.Payload)
+	default:
+		slog.Warn("Ignoring forwarded action that cannot be taken remotely", "event", m.Event, "RoomId", r.ID)
+	}
+}
+
+// addRemoteMembers adds the clients of other copies to a room state, after
+// this copy's own.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+func (r *Room) addRemoteMembers(state *RoomStatePayload) {
+	origins := make([]string, 0, len(r.remoteMembers))
+	for origin := range r.remoteMembers {
+		origins = append(origins, origin)
+	}
+	slices.Sort(origins)
+
+	for _, origin := range origins {
+		for _, m := range r.remoteMembers[origin] {
+			switch m.Role {
+			case RoleTypeHost:
+				state.Hosts = append(state.Hosts, m.ClientInfo)
+			case RoleTypeParticipant:
+				state.Participants = append(state.Participants, m.ClientInfo)
+			case RoleTypeSpectator:
+				state.Spectators = append(state.Spectators, m.ClientInfo)
+			case RoleTypeWaiting:
+				state.WaitingUsers = append(state.WaitingUsers, m.ClientInfo)
+			}
+			if m.Sharing {
+				state.SharingScreen = append(state.SharingScreen, m.ClientInfo)
+			}
+			if m.HandRaised {
+				state.HandsRaised = append(state.HandsRaised, m.ClientInfo)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteMembers(t *testing.T) {
+	// newRooms creates the same room on two hubs sharing one bus, as two
+	// backend instances would.
+	newRooms := func(t *testing.T, configure ...func(*HubConfig)) (*Room, *Room) {
+		config := DefaultHubConfig()
+		config.Room.Bus = NewMemoryRoomBus(64)
+		for _, c := range configure {
+			c(&config)
+		}
+		roomA, _ := NewHubWithConfig(&MockValidator{}, config).getOrCreateRoom("meeting")
+		roomB, _ := NewHubWithConfig(&MockValidator{}, config).getOrCreateRoom("meeting")
+		t.Cleanup(roomA.close)
+		t.Cleanup(roomB.close)
+		return roomA, roomB
+	}
+
+	// listed reports whether a room's state lists clientId among the
+	// clients picked out by field.
+	listed := func(room *Room, clientId ClientIdType, field func(RoomStatePayload) []ClientInfo) func() bool {
+		return func() bool {
+			for _, info := range field(room.getRoomState()) {
+				if info.ClientId == clientId {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	hosts := func(s RoomStatePayload) []ClientInfo { return s.Hosts }
+	participants := func(s RoomStatePayload) []ClientInfo { return s.Participants }
+	waiting := func(s RoomStatePayload) []ClientInfo { return s.WaitingUsers }
+
+	// roleOf reads a client's role under its room's lock.
+	roleOf := func(room *Room, c *Client) func() RoleType {
+		return func() RoleType {
+			room.mu.RLock()
+			defer room.mu.RUnlock()
+			return c.Role
+		}
+	}
+
+	// received returns the first message of event sent to c within a short wait.
+	received := func(t *testing.T, c *Client, event Event) json.RawMessage {
+		t.Helper()
+		deadline := time.After(time.Second)
+		for {
+			select {
+			case raw := <-c.send:
+				var msg wireMessage
+				if json.Unmarshal(raw, &msg) == nil && msg.Event == event {
+					return msg.Payload
+				}
+			case <-deadline:
+				require.Failf(t, "message not received", "%s never received %q", c.ID, event)
+				return nil
+			}
+		}
+	}
+
+	t.Run("each copy lists the other's clients and joiners wait for its host", func(t *testing.T) {
+		roomA, roomB := newRooms(t)
+		alice := newTestClientWithName("alice", "Alice")
+		roomA.handleClientConnect(alice)
+		require.Eventually(t, listed(roomB, "alice", hosts), time.Second, 5*time.Millisecond)
+
+		bob := newTestClientWithName("bob", "Bob")
+		roomB.handleClientConnect(bob)
+
+		assert.Equal(t, RoleTypeWaiting, roleOf(roomB, bob)(), "A host on the other copy means bob is not the first to join")
+		assert.Eventually(t, listed(roomA, "bob", waiting), time.Second, 5*time.Millisecond, "Alice's copy lists bob as waiting")
+	})
+
+	t.Run("a copy that joins later asks for the rosters", func(t *testing.T) {
+		config := DefaultHubConfig()
+		config.Room.Bus = NewMemoryRoomBus(64)
+		roomA, _ := NewHubWithConfig(&MockValidator{}, config).getOrCreateRoom("meeting")
+		defer roomA.close()
+		roomA.handleClientConnect(newTestClientWithName("alice", "Alice"))
+
+		roomB, _ := NewHubWithConfig(&MockValidator{}, config).getOrCreateRoom("meeting")
+		defer roomB.close()
+
+		assert.Eventually(t, listed(roomB, "alice", hosts), time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("closing a copy removes its clients from the others", func(t *testing.T) {
+		roomA, roomB := newRooms(t)
+		roomA.handleClientConnect(newTestClientWithName("alice", "Alice"))
+		require.Eventually(t, listed(roomB, "alice", hosts), time.Second, 5*time.Millisecond)
+
+		roomA.close()
+
+		assert.Eventually(t, func() bool { return !listed(roomB, "alice", hosts)() }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("a host admits and kicks clients of another copy", func(t *testing.T) {
+		roomA, roomB := newRooms(t)
+		alice := newTestClientWithName("alice", "Alice")
+		roomA.handleClientConnect(alice)
+		require.Eventually(t, listed(roomB, "alice", hosts), time.Second, 5*time.Millisecond)
+		bob := newTestClientWithName("bob", "Bob")
+		roomB.handleClientConnect(bob)
+		require.Eventually(t, listed(roomA, "bob", waiting), time.Second, 5*time.Millisecond)
+
+		roomA.router(context.Background(), alice, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: "bob"}})
+		assert.Eventually(t, func() bool { return roleOf(roomB, bob)() == RoleTypeParticipant }, time.Second, 5*time.Millisecond)
+		assert.Eventually(t, listed(roomA, "bob", participants), time.Second, 5*time.Millisecond)
+
+		roomA.router(context.Background(), alice, Message{Event: EventKick, Payload: KickPayload{ClientInfo: ClientInfo{ClientId: "bob"}}})
+		received(t, bob, EventKicked)
+		assert.Eventually(t, func() bool { return !listed(roomA, "bob", participants)() }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("forwarded actions need the host's permission", func(t *testing.T) {
+		roomA, roomB := newRooms(t)
+		roomA.handleClientConnect(newTestClientWithName("alice", "Alice"))
+		require.Eventually(t, listed(roomB, "alice", hosts), time.Second, 5*time.Millisecond)
+		bob := newTestClientWithName("bob", "Bob")
+		roomB.handleClientConnect(bob)
+
+		roomB.deliverFromBus(BusMessage{
+			Kind:   BusKindAction,
+			Origin: "impostor",
+			Event:  EventAcceptWaiting,
+			To:     "bob",
+			From:   &BusMember{ClientInfo: ClientInfo{ClientId: "mallory"}, Role: RoleTypeParticipant},
+			Data:   []byte(`{"event":"accept_waiting","payload":{"clientId":"bob"}}`),
+		})
+
+		assert.Equal(t, RoleTypeWaiting, roleOf(roomB, bob)())
+	})
+
+	t.Run("signaling reaches a peer on another copy", func(t *testing.T) {
+		roomA, roomB := newRooms(t, func(c *HubConfig) {
+			features := DefaultRoomFeatures()
+			features.WaitingRoomEnabled = false
+			c.Room.Features = &features
+		})
+		alice := newTestClientWithName("alice", "Alice")
+		roomA.handleClientConnect(alice)
+		require.Eventually(t, listed(roomB, "alice", hosts), time.Second, 5*time.Millisecond)
+		bob := newTestClientWithName("bob", "Bob")
+		roomB.handleClientConnect(bob)
+		require.Eventually(t, listed(roomA, "bob", participants), time.Second, 5*time.Millisecond)
+
+		roomA.router(context.Background(), alice, Message{Event: EventOffer, Payload: WebRTCOfferPayload{TargetClientId: "bob", SDP: "v=0", Type: "offer"}})
+		var offer WebRTCOfferPayload
+		require.NoError(t, json.Unmarshal(received(t, bob, EventOffer), &offer))
+		assert.Equal(t, ClientIdType("alice"), offer.ClientId, "The sender is stamped by its own copy")
+		assert.Equal(t, uint64(1), offer.Seq)
+
+		roomB.router(context.Background(), bob, Message{Event: EventAnswer, Payload: WebRTCAnswerPayload{TargetClientId: "alice", SDP: "v=0", Type: "answer"}})
+		received(t, alice, EventAnswer)
+
+		roomA.router(context.Background(), alice, Message{Event: EventIsPresent, Payload: IsPresentPayload{ClientId: "bob"}})
+		var present IsPresentPayload
+		require.NoError(t, json.Unmarshal(received(t, alice, EventIsPresent), &present))
+		assert.True(t, present.Present)
+		assert.Equal(t, RoleTypeParticipant, present.Role)
+	})
+}
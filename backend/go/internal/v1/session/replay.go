@@ -0,0 +1,138 @@
+// Package session - replay.go
+//
+// This file implements the optional per-room event history, a record of the
+// last messages the router saw, for support engineers investigating a
+// complaint such as "the host couldn't admit me". Each entry records who sent
+// which event, when, and whether it was handled or why it was refused.
+//
+// Opt-In and Bounds:
+// History is off unless RoomConfig.EventHistorySize is positive. Each room
+// keeps at most that many entries in a ring buffer, overwriting the oldest,
+// so memory stays fixed however long a meeting runs.
+//
+// Privacy:
+// Payloads can carry chat text and SDP, so they are left out unless
+// RoomConfig.EventHistoryPayloads is set.
+package session
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// RoutedEvent is one message in a room's event history.
+type RoutedEvent struct {
+	Event     Event           `json:"event"`             // The event sent; empty if the message was malformed
+	ClientId  ClientIdType    `json:"clientId"`          // The client that sent it
+	Timestamp time.Time       `json:"timestamp"`         // When the router finished with it
+	Result    string          `json:"result"`            // "handled", or why it was refused, such as "permission_denied"
+	Payload   json.RawMessage `json:"payload,omitempty"` // The payload; only with RoomConfig.EventHistoryPayloads
+}
+
+// eventHistory is a fixed-size ring of the most recent routed events.
+//
+// Thread Safety: All methods are safe for concurrent use, since query events
+// are recorded by several routers holding the room's read lock at once.
+type eventHistory struct {
+	mu      sync.Mutex
+	entries []RoutedEvent
+	next    int  // Index the next entry is written to
+	full    bool // Whether entries has wrapped around
+}
+
+// newEventHistory returns a history holding up to size events, or nil if size
+// is not positive.
+func newEventHistory(size int) *eventHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &eventHistory{entries: make([]RoutedEvent, size)}
+}
+
+// record appends e, overwriting the oldest entry once the history is full.
+func (h *eventHistory) record(e RoutedEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries[h.next] = e
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// events returns a copy of the history, oldest first.
+func (h *eventHistory) events() []RoutedEvent {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.full {
+		return append([]RoutedEvent(nil), h.entries[:h.next]...)
+	}
+	events := make([]RoutedEvent, 0, len(h.entries))
+	events = append(events, h.entries[h.next:]...)
+	return append(events, h.entries[:h.next]...)
+}
+
+// recordRoutedEvent adds a routed message to the room's event history, if it
+// keeps one.
+//
+// Thread Safety: Called by the router while it holds the room's lock, so
+// entries appear in the order messages were handled. The read lock is
+// sufficient; the history has its own lock.
+//
+// Parameters:
+//   - client: The client that sent the message
+//   - data: The decoded message
+//   - result: What the router did with it
+func (r *Room) recordRoutedEvent(client *Client, data any, result routeResult) {
+	if r.history == nil {
+		return
+	}
+	entry := RoutedEvent{
+		ClientId:  client.ID,
+		Timestamp: r.config.Clock.Now(),
+		Result:    result.String(),
+	}
+	if msg, ok := data.(Message); ok {
+		entry.Event = msg.Event
+		if r.config.EventHistoryPayloads && msg.Payload != nil {
+			if raw, err := json.Marshal(msg.Payload); err == nil {
+				entry.Payload = raw
+			} else {
+				slog.Warn("Failed to record event payload", "error", err, "event", msg.Event, "RoomId", r.ID)
+			}
+		}
+	}
+	r.history.record(entry)
+}
+
+// EventHistory returns the room's most recent routed events, oldest first,
+// or nil if RoomConfig.EventHistorySize is not set.
+//
+// Thread Safety: Safe to call without the room's lock.
+func (r *Room) EventHistory() []RoutedEvent {
+	return r.history.events()
+}
+
+// EventHistory returns the event history of the room with the given id.
+//
+// Thread Safety: Acquires the hub lock.
+//
+// Returns:
+//   - []RoutedEvent: The room's history, oldest first; nil if history is off
+//   - bool: false if the hub holds no such room
+func (h *Hub) EventHistory(roomId RoomIdType) ([]RoutedEvent, bool) {
+	h.mu.Lock()
+	room, ok := h.rooms[roomId]
+	h.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return room.EventHistory(), true
+}
@@ -0,0 +1,141 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventHistory(t *testing.T) {
+	// withHistory turns on event history for the hub's rooms.
+	withHistory := func(size int, payloads bool) HubOption {
+		return func(c *HubConfig) {
+			c.Room.EventHistorySize = size
+			c.Room.EventHistoryPayloads = payloads
+			c.Room.DuplicateEventWindow = 0
+		}
+	}
+
+	// setup creates a room with a host and an admitted participant.
+	setup := func(t *testing.T, hub *Hub) (*Room, *Client, *Client) {
+		t.Helper()
+		room, err := hub.getOrCreateRoom("room-1")
+		require.NoError(t, err)
+		host := newTestClient("host")
+		participant := newTestClient("participant")
+		room.handleClientConnect(host)
+		room.handleClientConnect(participant)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: participant.ID}})
+		return room, host, participant
+	}
+
+	// chat sends a chat message from client.
+	chat := func(room *Room, client *Client, i int) {
+		room.router(context.Background(), client, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  client.info(),
+			ChatId:      ChatId(fmt.Sprintf("chat-%d", i)),
+			ChatContent: "secret plans",
+		}})
+	}
+
+	t.Run("records routed events in order with their outcome", func(t *testing.T) {
+		hub := NewTestHub(nil, withHistory(10, false))
+		room, host, participant := setup(t, hub)
+
+		chat(room, participant, 0)
+		room.router(context.Background(), participant, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: host.ID}})
+		room.router(context.Background(), host, Message{Event: EventGetRecentChats, Payload: GetRecentChatsPayload{}})
+
+		history := room.EventHistory()
+		require.Len(t, history, 4)
+		assert.Equal(t, RoutedEvent{Event: EventAcceptWaiting, ClientId: host.ID, Result: "handled"}, stripTimestamp(history[0]))
+		assert.Equal(t, RoutedEvent{Event: EventAddChat, ClientId: participant.ID, Result: "handled"}, stripTimestamp(history[1]))
+		assert.Equal(t, RoutedEvent{Event: EventAcceptWaiting, ClientId: participant.ID, Result: "permission_denied"}, stripTimestamp(history[2]))
+		assert.Equal(t, RoutedEvent{Event: EventGetRecentChats, ClientId: host.ID, Result: "handled"}, stripTimestamp(history[3]))
+		for _, e := range history {
+			assert.False(t, e.Timestamp.IsZero())
+		}
+	})
+
+	t.Run("keeps only the most recent events", func(t *testing.T) {
+		hub := NewTestHub(nil, withHistory(3, false))
+		room, _, participant := setup(t, hub)
+
+		for i := range 5 {
+			chat(room, participant, i)
+		}
+		room.router(context.Background(), participant, "not a message")
+
+		history := room.EventHistory()
+		require.Len(t, history, 3)
+		assert.Equal(t, EventAddChat, history[0].Event)
+		assert.Equal(t, EventAddChat, history[1].Event)
+		assert.Equal(t, RoutedEvent{ClientId: participant.ID, Result: "malformed"}, stripTimestamp(history[2]))
+	})
+
+	t.Run("leaves payloads out unless enabled", func(t *testing.T) {
+		room, _, participant := setup(t, NewTestHub(nil, withHistory(5, false)))
+		chat(room, participant, 0)
+		assert.Nil(t, room.EventHistory()[1].Payload)
+
+		room, _, participant = setup(t, NewTestHub(nil, withHistory(5, true)))
+		chat(room, participant, 0)
+		var payload AddChatPayload
+		require.NoError(t, json.Unmarshal(room.EventHistory()[1].Payload, &payload))
+		assert.Equal(t, ChatContent("secret plans"), payload.ChatContent)
+	})
+
+	t.Run("records nothing when disabled", func(t *testing.T) {
+		room, _, participant := setup(t, NewTestHub(nil))
+		chat(room, participant, 0)
+
+		assert.Nil(t, room.history)
+		assert.Empty(t, room.EventHistory())
+	})
+
+	t.Run("admin endpoint serves a room's history", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		enabled := NewTestHub(nil, withHistory(5, false))
+		setup(t, enabled)
+		disabled := NewTestHub(nil)
+		setup(t, disabled)
+
+		router := gin.New()
+		router.GET("/admin/events/:hub/:roomId", ServeEventHistory("secret", map[string]*Hub{"hub": enabled, "chat": disabled}))
+
+		get := func(path, token string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, get("/admin/events/hub/room-1", "wrong").Code)
+		assert.Equal(t, http.StatusNotFound, get("/admin/events/other/room-1", "secret").Code)
+		assert.Equal(t, http.StatusNotFound, get("/admin/events/hub/room-2", "secret").Code)
+		assert.Equal(t, http.StatusNotFound, get("/admin/events/chat/room-1", "secret").Code)
+
+		w := get("/admin/events/hub/room-1", "secret")
+		require.Equal(t, http.StatusOK, w.Code)
+		var events []RoutedEvent
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &events))
+		require.Len(t, events, 1)
+		assert.Equal(t, EventAcceptWaiting, events[0].Event)
+		assert.NotContains(t, w.Body.String(), "payload")
+	})
+}
+
+// stripTimestamp clears e's timestamp so it can be compared whole.
+func stripTimestamp(e RoutedEvent) RoutedEvent {
+	e.Timestamp = time.Time{}
+	return e
+}
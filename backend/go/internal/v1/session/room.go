@@ -1,11 +1,20 @@
 package session
 
 import (
+	"cmp"
 	"container/list"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/gorilla/websocket"
+	"k8s.io/utils/clock"
 	"k8s.io/utils/set"
 )
 
@@ -35,16 +44,29 @@ import (
 
 type Room struct {
 	// --- Core Identity and Configuration ---
-	ID                   RoomIdType   // Unique identifier for this room
-	mu                   sync.RWMutex // Read-write mutex for thread safety
-	chatHistory          *list.List   // Chronologically ordered chat messages
-	maxChatHistoryLength int          // Maximum number of chat messages to retain
+	ID                   RoomIdType                  // Unique identifier for this room
+	mu                   sync.RWMutex                // Read-write mutex for thread safety
+	chatHistory          *list.List                  // Chronologically ordered chat messages
+	transcript           *list.List                  // Chronologically ordered captions; nil until the first
+	maxChatHistoryLength int                         // Maximum number of chat messages to retain
+	config               RoomConfig                  // Settings and dependencies applied at creation
+	features             RoomFeatures                // Meeting features enabled for this room
+	hiddenEvents         map[Event]set.Set[RoleType] // Roles excluded from send-to-all broadcasts
+	maxPayloadSizes      map[Event]int               // Largest encoded payload accepted per event, in bytes
+	permissions          map[Event]set.Set[RoleType] // Roles that may send each event
+	policy               ParticipantPolicy           // What hosts currently allow everyone else to do
+	durableEvents        set.Set[Event]              // Broadcast events mirrored to the EventSink
+	pinnedChats          set.Set[ChatId]             // Pinned messages still in chatHistory; nil until the first pin
+	chatSweepAt          time.Time                   // Latest scheduled MaxChatHistoryAge sweep; zero if none yet
+	querySlots           chan struct{}               // Semaphore bounding concurrent query handlers; nil if unbounded
+	history              *eventHistory               // Recent routed messages for support; nil unless EventHistorySize is set
 
 	// --- Role-Based Client Management ---
 	// These maps define the permission hierarchy within the room
 	hosts        map[ClientIdType]*Client // Clients with administrative privileges
 	participants map[ClientIdType]*Client // Active meeting participants
 	waiting      map[ClientIdType]*Client // Clients awaiting host approval
+	spectators   map[ClientIdType]*Client // Webinar attendees admitted to watch only
 
 	// --- User Interface Draw Order Management ---
 	// These data structures control the visual ordering of clients in the UI
@@ -64,13 +86,97 @@ type Room struct {
 	// --- Real-Time Activity State ---
 	// These maps track current participant activities for UI indicators and permissions
 	raisingHand   map[ClientIdType]*Client // Participants requesting to speak
-	sharingScreen map[ClientIdType]*Client // Participants currently sharing their screen
+	sharingScreen map[ClientIdType]*Client // Participants currently sharing their screen; never hosts, see addScreenshare
+	sharingHosts  map[ClientIdType]*Client // Hosts currently sharing their screen, which keep the host role
 	unmuted       map[ClientIdType]*Client // Participants with microphone enabled
 	cameraOn      map[ClientIdType]*Client // Participants with camera enabled
+	spotlight     ClientIdType             // Participant every client shows as the main view; empty for none
+	welcome       string                   // Sanitized message sent to each client on admission; empty for none
+
+	// Participants whose latest screenshare request was denied. A new request
+	// clears the entry, so a repeated denial of the same request is a no-op.
+	deniedScreenshare set.Set[ClientIdType]
+
+	// Clients reporting that they are speaking, mapped to when they started as
+	// a sequence number, so the most recent can be picked as dominant
+	activeSpeakers map[ClientIdType]uint64
+	speakerSeq     uint64
+	speakersSentAt time.Time   // When EventActiveSpeakers was last broadcast
+	speakersTimer  clock.Timer // Pending coalesced EventActiveSpeakers broadcast, if any
+
+	// Latest connection statistics reported by each client; nil until the first
+	connectionStats map[ClientIdType]ConnectionStatsPayload
+
+	// --- WebRTC Signaling State ---
+	// ICE candidates awaiting coalescing into a batch, keyed by sender and target
+	pendingCandidates map[peerRoute]*candidateBatch
+	// Offers forwarded but not yet answered, keyed by offerer and target
+	pendingOffers map[peerRoute]struct{}
+	// Last sequence number stamped on signaling for each sender and target
+	signalSeq map[peerRoute]uint64
+
+	// --- Observability ---
+	// Successful handler calls seen so far, used to sample handler logs.
+	// Atomic because query events are handled under the read lock.
+	handlerLogCalls atomic.Uint64
+
+	// Number of clients that have joined, used to stamp each client's join order
+	joinCounter uint64
+
+	// Timestamp of the newest chat message, so chat timestamps never go backwards
+	lastChatTimestamp Timestamp
+
+	// Last ChatId assigned by the server
+	chatIdCounter uint64
+
+	// Held while a chat message is stamped, saved and delivered off the room
+	// lock, so saves run one at a time in the order they are delivered
+	chatSaveMu sync.Mutex
+
+	// Captions waiting for the TranscriptStore; nil until the first is queued
+	captionSaves chan CaptionPayload
+
+	// Pending promotion of a waiting client while the room has no host
+	hostlessTimer clock.Timer
+
+	// The latest host transfer, which its previous host may undo within
+	// HostReclaimWindow; nil once undone or if there has been none
+	lastTransfer *hostTransfer
+
+	// Roles of clients whose connections dropped, restored if they reconnect
+	// within ReconnectWindow. Expired entries are purged on access.
+	recentlyDisconnected map[ClientIdType]recentDisconnect
+
+	// --- Statistics ---
+	// Read through Stats; see stats.go
+	createdAt        time.Time // When the room was created
+	peakParticipants int       // Most admitted clients present at once
+	totalChats       uint64    // Chat messages sent over the room's lifetime
 
 	// --- Lifecycle Management ---
+	// Cancelled by close when the hub removes the room. Every room timer
+	// checks it before running, so nothing fires for a removed room.
+	ctx    context.Context
+	cancel context.CancelFunc
 	// Callback function invoked when the room becomes empty to trigger cleanup
 	onEmpty func(RoomIdType)
+
+	// Identity of this copy of the room on RoomConfig.Bus, the topic it
+	// shares with the other copies, and the function that ends its
+	// subscription; all unset when the room has no bus
+	busOrigin      string
+	busTopic       string
+	busUnsubscribe func()
+	// The roster this copy last published, and the latest roster of each
+	// other copy by origin; see remote.go
+	sharedRoster  []BusMember
+	remoteMembers map[string][]BusMember
+	// Set once the room has emptied so cleanup is triggered exactly once;
+	// cleared when a client joins before the room is removed
+	emptied bool
+	// Set by the hub, under the lock, when it removes the room; joins are
+	// refused from then on
+	closed bool
 }
 
 // handleClientConnect manages the initial connection logic when a client joins the room.
@@ -86,7 +192,9 @@ type Room struct {
 // during the critical client admission process.
 //
 // Role Assignment:
-// The first client receives immediate host privileges, allowing them to:
+// When RoomConfig.HostClientIds names hosts for this room, only those clients
+// become hosts and everyone else waits, even if they join first. Otherwise the
+// first client receives immediate host privileges, allowing them to:
 //   - Accept or deny future participants
 //   - Manage screen sharing permissions
 //   - Administrative control over room settings
@@ -97,53 +205,232 @@ type Room struct {
 //   - Wait for host approval to join
 //   - May be denied access by the host
 //
+// When RoomFeatures.WaitingRoomEnabled is false, non-host clients are admitted
+// directly as participants while the room has space.
+//
+// When RoomConfig.ReconnectWindow is set, a client whose connection dropped
+// and who reconnects within the window gets its previous role back.
+//
+// When RoomConfig.UniqueDisplayNames is set, a joiner whose display name is
+// already in use is renamed with a numeric suffix before being placed.
+//
+// When RoomConfig.SingleHostConnection is set, a joiner whose subject is
+// already hosting the room on another connection is refused; see refuseClient.
+//
+// Removal:
+// A room that has emptied stays in the hub until its onEmpty callback runs,
+// so a reconnecting client may join it in the meantime; the room is then in
+// use again and is not removed. Once the hub has removed the room it is
+// closed and joins are turned away, so a joiner never attaches to a room the
+// hub no longer holds. The caller then joins a fresh room from the hub.
+//
 // Parameters:
 //   - client: The newly connected client to be processed
-func (r *Room) handleClientConnect(client *Client) {
+//
+// Returns:
+//   - bool: false if the room is closed and the client was not placed
+func (r *Room) handleClientConnect(client *Client) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	return r.placeClient(client)
+}
+
+// placeClient places a client joining the room, as described for
+// handleClientConnect.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Returns:
+//   - bool: false if the room is closed and the client was not placed
+func (r *Room) placeClient(client *Client) bool {
+	if r.closed {
+		return false
+	}
+	// The room may have emptied and be awaiting removal; it is in use again,
+	// so it must trigger cleanup afresh when it next empties.
+	r.emptied = false
+
+	if host, ok := r.hosts[client.ID]; ok && host != client && r.config.SingleHostConnection {
+		slog.Warn("Refused a second connection from a connected host", "room", r.ID, "ClientId", client.ID)
+		r.refuseClient(client, ErrorCodeHostConnected, "already hosting this room from another connection")
+		return true
+	}
+
+	r.joinCounter++
+	client.joinSeq = r.joinCounter
+	r.resetIdleTimer(client)
+	if r.config.UniqueDisplayNames {
+		client.DisplayName = r.uniqueDisplayName(client)
+	}
+
+	if role, ok := r.reclaimRole(client); ok {
+		r.restoreRole(client, role)
+		return true
+	}
 
-	// First user to join becomes the host.
-	if len(r.participants) == 0 && len(r.hosts) == 0 {
+	// Designated organizers host regardless of join order; everyone else waits.
+	if hostIds := r.config.HostClientIds[r.ID]; len(hostIds) > 0 {
+		if slices.Contains(hostIds, client.ID) {
+			slog.Info("Designated host joined.", "room", r.ID, "ClientId", client.ID)
+			r.addHost(client)
+			r.checkHostless()
+			r.sendAdmissionNotices(client)
+			return true
+		}
+		r.admitOrWait(client)
+		return true
+	}
+
+	// First user to join becomes the host. There are no peers yet, so no
+	// media state snapshot is sent.
+	if len(r.participants) == 0 && len(r.hosts) == 0 && r.remoteCount(RoleTypeHost, RoleTypeParticipant) == 0 {
 		slog.Info("First user joined, making them host.", "room", r.ID, "ClientId", client.ID)
 		r.addHost(client)
+		r.sendCodecPolicy(client)
+		r.sendWelcome(client)
+		return true
+	}
+	r.admitOrWait(client)
+	return true
+}
+
+// refuseClient turns a joining client away without placing it in the room,
+// sending it an error with code before closing its connection. The client is
+// given no role and the router drops anything it sends before the close.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) refuseClient(client *Client, code ErrorCode, message string) {
+	client.refused = true
+	client.Role = ""
+	msg, err := marshalMessage(EventError, ErrorPayload{Code: code, Message: message})
+	if err != nil {
+		slog.Error("Failed to marshal refusal notice", "error", err, "ClientId", client.ID, "RoomId", r.ID)
+		return
+	}
+	client.closeWithNotice(msg, websocket.ClosePolicyViolation, string(code))
+}
+
+// admitOrWait places a joiner who is not becoming host. Joiners wait for host
+// admission unless the room's waiting room is disabled and there is space.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) admitOrWait(client *Client) {
+	if !r.features.WaitingRoomEnabled && (r.admittedRole() == RoleTypeSpectator || !r.isRoomFull()) {
+		slog.Info("Waiting room disabled, admitting joiner directly.", "room", r.ID, "ClientId", client.ID)
+		if r.admittedRole() == RoleTypeSpectator {
+			r.addSpectator(client)
+		} else {
+			r.addParticipant(client)
+		}
+		r.sendAdmissionNotices(client)
 		return
 	}
 	r.addWaiting(client)
+	r.checkHostless()
 }
 
 // handleClientLeft manages cleanup when a client disconnects.
 // It removes the client from all room-related states.
-// If the client was the last participant, it triggers the onEmpty callback to clean up the room itself.
+// If the client was the last participant, it closes the connections of any
+// remaining waiting users and triggers the onEmpty callback exactly once to
+// clean up the room itself.
 // Otherwise, it broadcasts the updated room state to remaining clients.
 func (r *Room) handleClientDisconnect(client *Client) {
 	r.mu.Lock()
+	// The client was moved to another room after its connection closed but
+	// before this ran; that room holds it now.
+	if client.movedFrom(r) {
+		r.mu.Unlock()
+		client.currentRoom().handleClientDisconnect(client)
+		return
+	}
 	defer r.mu.Unlock()
 
-	r.disconnectClient(client)
-	slog.Info("Client disconnected and removed from room", "room", r.ID, "ClientId", client.ID)
+	// A refused client was never placed, and its id may belong to a client
+	// that was, so there is nothing to remove or announce.
+	if client.refused {
+		return
+	}
 
-	payload := ClientDisconnectPayload{
-		ClientId:    client.ID,
-		DisplayName: client.DisplayName,
+	if !client.leaving && !client.kicked {
+		r.rememberDisconnect(client)
 	}
+	// Counted before removal, so a leave is as loud as the join that made
+	// the room this size.
+	notify := r.notifyJoinLeave()
+	r.disconnectClient(client)
+	slog.Info("Client disconnected and removed from room", "room", r.ID, "ClientId", client.ID, "intentional", client.leaving)
 
-	// Broadcast to remaining clients
-	r.broadcast(Event(EventDisconnect), payload, nil)
+	// Broadcast to remaining clients, distinguishing a graceful leave from a dropped connection.
+	// A kicked client's removal was already announced by handleKick.
+	switch {
+	case client.kicked:
+		r.config.ConnectionObserver.OnDisconnect(r.ID, client.ID, DisconnectReasonKicked)
+	case client.leaving:
+		r.config.ConnectionObserver.OnDisconnect(r.ID, client.ID, DisconnectReasonLeft)
+		r.broadcast(r.ctx, EventParticipantLeft, ParticipantLeftPayload{
+			ClientInfo: client.info(),
+			Notify:     notify,
+		}, nil)
+	default:
+		r.config.ConnectionObserver.OnDisconnect(r.ID, client.ID, DisconnectReasonDropped)
+		r.broadcast(r.ctx, EventDisconnect, ClientDisconnectPayload{
+			ClientInfo: client.info(),
+			Notify:     notify,
+		}, nil)
+	}
 
 	// Check if room is empty AFTER broadcasting
+	r.releaseIfEmpty()
+
+	// A departing host may leave waiting users with nobody to admit them.
+	r.checkHostless()
+}
+
+// releaseIfEmpty starts the room's removal once its last host or participant
+// has gone: waiting users are evicted and the onEmpty callback is run, once,
+// in a goroutine. Nothing happens if the room is still in use.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) releaseIfEmpty() {
 	if r.isRoomEmpty() {
-		if r.onEmpty == nil {
-			slog.Error("onEmpty callback not defined. This will cause a memory leak.", "RoomId", r.ID)
+		// Disconnects of clients evicted below re-enter this path; cleanup
+		// has already been triggered for them.
+		if r.emptied {
 			return
 		}
-		// Run in a goroutine to avoid potential deadlocks
+		r.emptied = true
+
+		// Nobody is left to admit waiting users, so release them now
+		// rather than leaving them attached to a room being torn down.
+		r.evictWaiting()
+
+		// Run in a goroutine to avoid potential deadlocks. The onEmpty
+		// callback hands over final stats once it has removed the room.
+		var stats RoomStats
+		if r.onEmpty == nil {
+			stats = r.stats()
+		}
 		go func() {
 			defer func() {
 				if recover() != nil {
 					slog.Error("Panic in onEmpty callback", "RoomId", r.ID)
 				}
 			}()
+			if r.onEmpty == nil {
+				// Hub rooms always have a callback. Without one nothing
+				// will remove the room, so at least stop what it runs.
+				slog.Error("onEmpty callback not defined; closing the room in place. Whatever holds it must drop it to avoid a leak.", "RoomId", r.ID)
+				r.close()
+				if r.config.OnClose != nil {
+					r.config.OnClose(stats)
+				}
+				return
+			}
 			r.onEmpty(r.ID)
 		}()
 	}
@@ -152,6 +439,10 @@ func (r *Room) handleClientDisconnect(client *Client) {
 // NewRoom creates and returns a new Room instance with the specified ID and an onEmpty callback.
 // The Room is initialized with empty participant, waiting room, hands raised, hosts, and sharingScreen maps.
 // The onEmptyCallback is called when the room becomes empty, preventing a memory leak.
+// It should only be nil for rooms the caller manages itself: such a room
+// closes itself when it empties, and the caller must drop its reference.
+//
+// The room uses DefaultRoomConfig; use NewRoomWithConfig to customize it.
 //
 // Parameters:
 //   - id: the unique identifier for the room.
@@ -160,14 +451,78 @@ func (r *Room) handleClientDisconnect(client *Client) {
 // Returns:
 //   - A pointer to the newly created Room.
 func NewRoom(id RoomIdType, onEmptyCallback func(RoomIdType)) *Room {
-	return &Room{
+	return NewRoomWithConfig(id, DefaultRoomConfig(), onEmptyCallback)
+}
+
+// NewRoomWithConfig creates a new Room like NewRoom, applying the given configuration.
+// Nil dependencies in the configuration are replaced with their defaults.
+func NewRoomWithConfig(id RoomIdType, config RoomConfig, onEmptyCallback func(RoomIdType)) *Room {
+	if config.EventSink == nil {
+		config.EventSink = NoopEventSink{}
+	}
+	if config.ConnectionObserver == nil {
+		config.ConnectionObserver = NoopConnectionObserver{}
+	}
+	if config.ChatStore == nil {
+		config.ChatStore = NoopChatStore{}
+	}
+	if config.Tracer == nil {
+		config.Tracer = NoopTracer{}
+	}
+	if config.HandlerMetrics == nil {
+		config.HandlerMetrics = NoopHandlerMetrics{}
+	}
+	if config.Clock == nil {
+		config.Clock = clock.RealClock{}
+	}
+	if config.MaxChatHistory <= 0 {
+		config.MaxChatHistory = DefaultRoomConfig().MaxChatHistory
+	}
+	if config.MaxTranscript <= 0 {
+		config.MaxTranscript = DefaultRoomConfig().MaxTranscript
+	}
+	if config.TranscriptStore == nil {
+		config.TranscriptStore = NoopTranscriptStore{}
+	}
+	features := DefaultRoomFeatures()
+	if config.Features != nil {
+		features = *config.Features
+	}
+	hiddenEvents := config.HiddenEvents
+	if hiddenEvents == nil {
+		hiddenEvents = DefaultHiddenEvents()
+	}
+	maxPayloadSizes := config.MaxPayloadSizes
+	if maxPayloadSizes == nil {
+		maxPayloadSizes = DefaultMaxPayloadSizes()
+	}
+	durableEvents := config.DurableEvents
+	if durableEvents == nil {
+		durableEvents = DefaultDurableEvents()
+	}
+	permissions := config.EventPermissions
+	if permissions == nil {
+		permissions = DefaultEventPermissions()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := &Room{
 		ID:                   id,
 		chatHistory:          list.New(),
-		maxChatHistoryLength: 100, // Default to 100 messages
+		maxChatHistoryLength: config.MaxChatHistory,
+		config:               config,
+		features:             features,
+		hiddenEvents:         hiddenEvents,
+		maxPayloadSizes:      maxPayloadSizes,
+		permissions:          permissions,
+		policy:               DefaultParticipantPolicy(),
+		durableEvents:        durableEvents,
+		welcome:              sanitizeNotice(config.WelcomeMessage, maxWelcomeMessageLength),
 
 		hosts:        make(map[ClientIdType]*Client),
 		participants: make(map[ClientIdType]*Client),
 		waiting:      make(map[ClientIdType]*Client),
+		spectators:   make(map[ClientIdType]*Client),
 
 		waitingDrawOrderStack: list.New(),
 		clientDrawOrderQueue:  list.New(),
@@ -175,134 +530,592 @@ func NewRoom(id RoomIdType, onEmptyCallback func(RoomIdType)) *Room {
 
 		raisingHand:   make(map[ClientIdType]*Client),
 		sharingScreen: make(map[ClientIdType]*Client),
+		sharingHosts:  make(map[ClientIdType]*Client),
 		unmuted:       make(map[ClientIdType]*Client),
 		cameraOn:      make(map[ClientIdType]*Client),
 
-		onEmpty: onEmptyCallback,
+		createdAt: config.Clock.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+		onEmpty:   onEmptyCallback,
+	}
+	if config.MaxConcurrentQueries > 0 {
+		r.querySlots = make(chan struct{}, config.MaxConcurrentQueries)
+	}
+	r.history = newEventHistory(config.EventHistorySize)
+	if config.Bus != nil {
+		r.busOrigin = newBusOrigin()
+		r.busTopic = busTopic(config.BusNamespace, id)
+		r.busUnsubscribe = config.Bus.Subscribe(r.busTopic, r.deliverFromBus)
+		// Copies already serving the meeting answer with their rosters
+		r.publishBus(BusMessage{Kind: BusKindRosterRequest})
 	}
+	return r
 }
 
-// router is the central router for all incoming messages from clients.
-// router calls the speficied handler for the given type if the client
-// has the required permissions.
+// close shuts down everything the room runs in the background: its context
+// is cancelled and every pending timer is stopped. Later joins are turned
+// away. The hub calls it after removing the room, and it is safe to call
+// more than once.
 //
-// It acquires a lock to ensure thread safety.
-func (r *Room) router(client *Client, data any) {
+// Thread Safety: Acquires the room lock. Callers may hold the hub lock, since
+// the lock order is hub then room.
+func (r *Room) close() {
+	r.cancel()
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.closed = true
+	if r.busUnsubscribe != nil {
+		// The other copies stop listing this one's clients
+		r.publishBus(BusMessage{Kind: BusKindRoster})
+		r.busUnsubscribe()
+	}
+	if r.hostlessTimer != nil {
+		r.hostlessTimer.Stop()
+		r.hostlessTimer = nil
+	}
+	if r.speakersTimer != nil {
+		r.speakersTimer.Stop()
+		r.speakersTimer = nil
+	}
+	for route, batch := range r.pendingCandidates {
+		batch.timer.Stop()
+		delete(r.pendingCandidates, route)
+	}
+	// A room is empty when the hub removes it, so this only finds clients if
+	// close is called on a room still in use.
+	for _, members := range []map[ClientIdType]*Client{r.hosts, r.participants, r.waiting, r.spectators} {
+		for _, client := range members {
+			if client.idleTimer != nil {
+				client.idleTimer.Stop()
+				client.idleTimer = nil
+			}
+			if client.waitingTimer != nil {
+				client.waitingTimer.Stop()
+				client.waitingTimer = nil
+			}
+		}
+	}
+	slog.Info("Closed room", "RoomId", r.ID)
+}
+
+// afterFunc runs f after d on the room's clock, unless the room has been
+// closed by then. Every room timer is started through it.
+//
+// Parameters:
+//   - d: How long to wait
+//   - f: The callback; it must take the room lock itself if it needs it
+//
+// Returns:
+//   - clock.Timer: The timer, which the caller may stop or reset
+func (r *Room) afterFunc(d time.Duration, f func()) clock.Timer {
+	return r.config.Clock.AfterFunc(d, func() {
+		if r.ctx.Err() != nil {
+			return
+		}
+		f()
+	})
+}
+
+// routeResult describes what the router did with an incoming message.
+type routeResult int
+
+// Possible outcomes of routing a message.
+const (
+	routeHandled          routeResult = iota // The message was dispatched to its handler
+	routeMalformed                           // The data was not a Message
+	routeUnknownEvent                        // The event is not routable
+	routeNotAdmitted                         // The client is waiting and the event is not a waiting room event
+	routeOutOfScope                          // The event is outside the endpoint's AllowedEvents
+	routeFeatureDisabled                     // The event's feature is turned off for the room
+	routePermissionDenied                    // The client's role may not send the event
+	routePolicyDenied                        // The host's participant policy forbids the event
+	routeInvalidPayload                      // The payload is not the type the handler expects
+	routeDuplicate                           // The message repeats one the client just sent
+)
+
+// String returns a readable name for logging.
+func (r routeResult) String() string {
+	switch r {
+	case routeHandled:
+		return "handled"
+	case routeMalformed:
+		return "malformed"
+	case routeUnknownEvent:
+		return "unknown_event"
+	case routeNotAdmitted:
+		return "not_admitted"
+	case routeOutOfScope:
+		return "out_of_scope"
+	case routeFeatureDisabled:
+		return "feature_disabled"
+	case routePermissionDenied:
+		return "permission_denied"
+	case routePolicyDenied:
+		return "policy_denied"
+	case routeInvalidPayload:
+		return "invalid_payload"
+	case routeDuplicate:
+		return "duplicate"
+	default:
+		return fmt.Sprintf("routeResult(%d)", int(r))
+	}
+}
+
+// router is the central router for all incoming messages from clients.
+// router calls the speficied handler for the given type if the client
+// has the required permissions, as declared in the room's permission table.
+//
+// It acquires a lock to ensure thread safety, delegates to route, and logs
+// why a message was dropped when it was not handled.
+//
+// Ordering:
+// Each client's readPump calls router synchronously, so one client's messages
+// are always handled in the order they were sent. Messages from different
+// clients are serialized by the room lock in the order they acquire it; Go's
+// sync.RWMutex hands the lock to waiters in roughly FIFO order once they have
+// waited over a millisecond, so a busy client cannot starve the others.
+//
+// Lock Paths:
+// Query events (see queryEvents) only read room state, so they are handled
+// under the read lock and clients reading history do not wait on each other.
+// At most RoomConfig.MaxConcurrentQueries of them hold the lock at once; the
+// rest wait for a slot without touching the lock, so writers queue behind a
+// bounded number of readers. A query whose client disconnects while waiting
+// for a slot is dropped. Every other event mutates state and takes the write
+// lock.
+//
+// Context:
+// ctx is scoped to this one message and derived from the client's connection
+// context, so it is cancelled if the client disconnects while the message is
+// being handled. Handlers pass it to anything that may block, such as the
+// room's ChatStore. Each message is traced as a SpanRoute span carried by
+// ctx, covering the wait for the room lock and the handler.
+func (r *Room) router(ctx context.Context, client *Client, data any) {
+	attrs := []Attribute{{AttrRoomId, string(r.ID)}, {AttrClientId, string(client.ID)}}
+	if msg, ok := data.(Message); ok {
+		attrs = append(attrs, Attribute{AttrEvent, string(msg.Event)})
+	}
+	ctx, span := r.config.Tracer.Start(ctx, SpanRoute, attrs...)
+	defer span.End()
+
+	var result routeResult
+	var err error
+	if msg, ok := data.(Message); ok && queryEvents.Has(msg.Event) {
+		if slotErr := r.acquireQuerySlot(ctx); slotErr != nil {
+			span.RecordError(slotErr)
+			slog.Debug("Dropped query while waiting for a slot", "event", msg.Event, "ClientId", client.ID, "RoomId", r.ID, "error", slotErr)
+			return
+		}
+		r.mu.RLock()
+		result, err = r.route(ctx, client, data)
+		r.recordRoutedEvent(client, data, result)
+		r.mu.RUnlock()
+		r.releaseQuerySlot()
+	} else {
+		r.mu.Lock()
+		result, err = r.route(ctx, client, data)
+		r.recordRoutedEvent(client, data, result)
+		r.mu.Unlock()
+	}
+
+	if result != routeHandled {
+		span.RecordError(errors.Join(fmt.Errorf("message not handled: %v", result), err))
+	}
+
+	switch result {
+	case routeHandled:
+	case routeDuplicate:
+		slog.Debug("Suppressed duplicate message", "ClientId", client.ID, "RoomId", r.ID, "error", err)
+	case routeMalformed:
+		slog.Error("router failed to marshal incoming message to type Message", "id", client.ID, "error", err)
+	case routeInvalidPayload:
+		r.config.HandlerLog.logger().Error("Rejected message with invalid payload", "ClientId", client.ID, "RoomId", r.ID, "error", err)
+	default:
+		slog.Warn("Message not handled", "result", result, "ClientId", client.ID, "RoomId", r.ID, "error", err)
+	}
+}
+
+// acquireQuerySlot waits until fewer than RoomConfig.MaxConcurrentQueries
+// queries are being handled and takes a slot. It must be called without the
+// room lock held, and each successful call paired with releaseQuerySlot.
+//
+// Returns:
+//   - error: ctx's error if it was cancelled before a slot freed up
+func (r *Room) acquireQuerySlot(ctx context.Context) error {
+	if r.querySlots == nil {
+		return nil
+	}
+	select {
+	case r.querySlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releaseQuerySlot returns a slot taken by acquireQuerySlot.
+func (r *Room) releaseQuerySlot() {
+	if r.querySlots != nil {
+		<-r.querySlots
+	}
+}
+
+// eventRejection says why checkEvent turned a message away.
+type eventRejection struct {
+	result  routeResult
+	code    ErrorCode // Sent to the client with message, when set
+	message string
+	err     error
+}
+
+// checkEvent runs the checks a message must pass before its handler runs, in
+// order: known event, the waiting room guard, endpoint scope, room features,
+// role permission, participant policy, payload size, then payload type. The
+// router and validation mode both use it, so a validated message is accepted
+// or refused exactly as it would be if sent.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+//
+// Parameters:
+//   - role: The sending client's role
+//   - event: The message's event
+//   - payload: The message's payload
+//   - rules: Whether to check the payload against its handler's rules as
+//     well as its type; see checkEventPayload
+//
+// Returns:
+//   - *eventRejection: The first check failed, or nil if the message passes
+func (r *Room) checkEvent(role RoleType, event Event, payload any, rules bool) *eventRejection {
+	allowed, known := r.hasEventPermission(role, event)
+	if !known {
+		return &eventRejection{result: routeUnknownEvent, err: fmt.Errorf("unknown event %q", event)}
+	}
+	if r.waitingGuardRejects(role, event) {
+		return &eventRejection{result: routeNotAdmitted, code: ErrorCodeNotAdmitted, message: "waiting for the host to admit you",
+			err: fmt.Errorf("waiting clients may not send %q until admitted", event)}
+	}
+	if r.config.AllowedEvents != nil && !r.config.AllowedEvents.Has(event) {
+		return &eventRejection{result: routeOutOfScope, code: ErrorCodeEventNotAllowed, message: "event is not available on this endpoint",
+			err: fmt.Errorf("event %q is not available on this endpoint", event)}
+	}
+	if !r.features.allows(event) {
+		return &eventRejection{result: routeFeatureDisabled, code: ErrorCodeFeatureDisabled, message: "feature is disabled in this room",
+			err: fmt.Errorf("event %q belongs to a disabled feature", event)}
+	}
+	if !allowed {
+		return &eventRejection{result: routePermissionDenied, err: fmt.Errorf("role %q is not permitted to send %q", role, event)}
+	}
+	if !r.policy.allows(role, event) {
+		return &eventRejection{result: routePolicyDenied, code: ErrorCodePolicyDenied, message: "the host has disabled this for participants",
+			err: fmt.Errorf("participant policy forbids %q", event)}
+	}
+	if err := r.checkPayloadSize(event, payload); err != nil {
+		return &eventRejection{result: routeInvalidPayload, code: ErrorCodePayloadTooLarge, message: err.Error(), err: err}
+	}
+	if err := checkEventPayload(event, payload, rules); err != nil {
+		return &eventRejection{result: routeInvalidPayload, err: err}
+	}
+	return nil
+}
+
+// route checks an incoming message and dispatches it to its handler.
+// Checks run in order: message shape, then those of checkEvent; the first
+// failure is returned.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held. The read lock is sufficient for
+// queryEvents: route itself only writes the sending client's own idle timer,
+// which no other client's messages touch.
+//
+// Parameters:
+//   - ctx: The message's context, passed on to its handler
+//   - client: The client that sent the message
+//   - data: The decoded message, expected to be a Message
+//
+// Returns:
+//   - routeResult: What happened to the message
+//   - error: Detail on why the message was not handled, or nil
+func (r *Room) route(ctx context.Context, client *Client, data any) (routeResult, error) {
 	msg, ok := data.(Message)
 	if !ok {
-		slog.Error("router failed to marshal incoming message to type Message", "msg", msg, "id", client.ID)
-		return
+		return routeMalformed, fmt.Errorf("unexpected message type %T", data)
+	}
+	if client.kicked || client.refused {
+		return routePermissionDenied, fmt.Errorf("client %s has been removed from the room", client.ID)
+	}
+	if client.movedFrom(r) {
+		return routePermissionDenied, fmt.Errorf("client %s has moved to another room", client.ID)
 	}
+	r.resetIdleTimer(client)
 
-	role := client.Role
-	isHost := HasPermission(role, HasHostPermission())
-	isParticipant := HasPermission(role, HasParticipantPermission())
-	isWaiting := HasPermission(role, HasWaitingPermission())
+	if rejection := r.checkEvent(client.Role, msg.Event, msg.Payload, false); rejection != nil {
+		if rejection.code != "" {
+			client.sendError(rejection.code, rejection.message, msg.Event)
+		}
+		return rejection.result, rejection.err
+	}
+	duplicate, forget := r.checkDuplicateEvent(client, msg)
+	if duplicate {
+		return routeDuplicate, fmt.Errorf("duplicate %q within %v", msg.Event, r.config.DuplicateEventWindow)
+	}
+	defer forget()
 
+	handlerStart := r.config.Clock.Now()
 	switch msg.Event {
 	case EventAddChat:
-		if isParticipant {
-			r.handleAddChat(client, msg.Event, msg.Payload)
-		}
-
+		r.handleAddChat(ctx, client, msg.Event, msg.Payload)
 	case EventDeleteChat:
-		if isParticipant {
-			r.handleDeleteChat(client, msg.Event, msg.Payload)
-		}
-
+		r.handleDeleteChat(ctx, client, msg.Event, msg.Payload)
+	case EventDeleteUserChats:
+		r.handleDeleteUserChats(ctx, client, msg.Event, msg.Payload)
+	case EventPinChat:
+		r.handlePinChat(ctx, client, msg.Event, msg.Payload)
+	case EventUnpinChat:
+		r.handleUnpinChat(ctx, client, msg.Event, msg.Payload)
 	case EventGetRecentChats:
-		if isParticipant {
-			r.handleGetRecentChats(client, msg.Event, msg.Payload)
-		}
+		r.handleGetRecentChats(ctx, client, msg.Event, msg.Payload)
+	case EventGetChatsByRange:
+		r.handleGetChatsByRange(ctx, client, msg.Event, msg.Payload)
 
+	case EventRequestSpeak:
+		r.handleRequestSpeak(ctx, client, msg.Event, msg.Payload)
+	case EventGrantSpeak:
+		r.handleGrantSpeak(ctx, client, msg.Event, msg.Payload)
+	case EventRevokeSpeak:
+		r.handleRevokeSpeak(ctx, client, msg.Event, msg.Payload)
 	case EventRaiseHand:
-		if isParticipant {
-			r.handleRaiseHand(client, msg.Event, msg.Payload)
-		}
+		r.handleRaiseHand(ctx, client, msg.Event, msg.Payload)
 	case EventLowerHand:
-		if isParticipant {
-			r.handleLowerHand(client, msg.Event, msg.Payload)
-		}
+		r.handleLowerHand(ctx, client, msg.Event, msg.Payload)
+	case EventLowerAllHands:
+		r.handleLowerAllHands(ctx, client, msg.Event, msg.Payload)
+	case EventReaction:
+		r.handleReaction(ctx, client, msg.Event, msg.Payload)
+	case EventActiveSpeaker:
+		r.handleActiveSpeaker(ctx, client, msg.Event, msg.Payload)
+	case EventMediaState:
+		r.handleMediaState(ctx, client, msg.Event, msg.Payload)
+	case EventCaption:
+		r.handleCaption(ctx, client, msg.Event, msg.Payload)
+	case EventSpotlight:
+		r.handleSpotlight(ctx, client, msg.Event, msg.Payload)
+	case EventClearSpotlight:
+		r.handleClearSpotlight(ctx, client, msg.Event, msg.Payload)
+	case EventConnectionStats:
+		r.handleConnectionStats(ctx, client, msg.Event, msg.Payload)
+	case EventConnectionReport:
+		r.handleConnectionReport(ctx, client, msg.Event, msg.Payload)
 
 	case EventRequestWaiting:
-		if isWaiting {
-			r.handleRequestWaiting(client, msg.Event, msg.Payload)
-		}
-
+		r.handleRequestWaiting(ctx, client, msg.Event, msg.Payload)
 	case EventAcceptWaiting:
-		if isHost {
-			r.handleAcceptWaiting(client, msg.Event, msg.Payload)
-		}
-
+		r.handleAcceptWaiting(ctx, client, msg.Event, msg.Payload)
 	case EventDenyWaiting:
-		if isHost {
-			r.handleDenyWaiting(client, msg.Event, msg.Payload)
-		}
+		r.handleDenyWaiting(ctx, client, msg.Event, msg.Payload)
 
 	case EventRequestScreenshare:
-		if (role != RoleTypeScreenshare) &&
-			isParticipant {
-			r.handleRequestScreenshare(client, msg.Event, msg.Payload)
-		}
-
+		r.handleRequestScreenshare(ctx, client, msg.Event, msg.Payload)
 	case EventAcceptScreenshare:
-		if isHost {
-			r.handleAcceptScreenshare(client, msg.Event, msg.Payload)
-		}
-
+		r.handleAcceptScreenshare(ctx, client, msg.Event, msg.Payload)
 	case EventDenyScreenshare:
-		if isHost {
-			r.handleDenyScreenshare(client, msg.Event, msg.Payload)
-		}
+		r.handleDenyScreenshare(ctx, client, msg.Event, msg.Payload)
+	case EventStopScreenshare:
+		r.handleStopScreenshare(ctx, client, msg.Event, msg.Payload)
+	case EventRequestRemoteControl, EventGrantRemoteControl, EventDenyRemoteControl:
+		r.handleRemoteControl(ctx, client, msg.Event, msg.Payload)
 
 	// WebRTC signaling events - available to participants and hosts
 	case EventOffer:
-		if isParticipant || isHost {
-			r.handleWebRTCOffer(client, msg.Event, msg.Payload)
-		}
-
+		r.handleWebRTCOffer(ctx, client, msg.Event, msg.Payload)
 	case EventAnswer:
-		if isParticipant || isHost {
-			r.handleWebRTCAnswer(client, msg.Event, msg.Payload)
-		}
-
+		r.handleWebRTCAnswer(ctx, client, msg.Event, msg.Payload)
 	case EventCandidate:
-		if isParticipant || isHost {
-			r.handleWebRTCCandidate(client, msg.Event, msg.Payload)
-		}
-
+		r.handleWebRTCCandidate(ctx, client, msg.Event, msg.Payload)
 	case EventRenegotiate:
-		if isParticipant || isHost {
-			r.handleWebRTCRenegotiate(client, msg.Event, msg.Payload)
-		}
+		r.handleWebRTCRenegotiate(ctx, client, msg.Event, msg.Payload)
+	case EventPauseVideo, EventResumeVideo:
+		r.handleVideoPause(ctx, client, msg.Event, msg.Payload)
+	case EventIsPresent:
+		r.handleIsPresent(ctx, client, msg.Event, msg.Payload)
+
+	case EventLeave:
+		r.handleLeave(ctx, client, msg.Event, msg.Payload)
+	case EventKick:
+		r.handleKick(ctx, client, msg.Event, msg.Payload)
+	case EventSetPolicy:
+		r.handleSetPolicy(ctx, client, msg.Event, msg.Payload)
+	case EventSetWelcome:
+		r.handleSetWelcome(ctx, client, msg.Event, msg.Payload)
+	case EventTransferHost:
+		r.handleTransferHost(ctx, client, msg.Event, msg.Payload)
+	case EventReclaimHost:
+		r.handleReclaimHost(ctx, client, msg.Event, msg.Payload)
+
+	case EventRestoreSession:
+		r.handleRestoreSession(ctx, client, msg.Event, msg.Payload)
+	case EventValidate:
+		r.handleValidate(ctx, client, msg.Event, msg.Payload)
 
 	default:
-		slog.Warn("Received unknown message event", "event", msg.Event)
+		return routeUnknownEvent, fmt.Errorf("event %q has no handler", msg.Event)
+	}
+	r.config.HandlerMetrics.ObserveHandler(msg.Event, r.config.Clock.Since(handlerStart))
+	return routeHandled, nil
+}
+
+// marshalMessage encodes an event and payload as a wire Message.
+//
+// Returns:
+//   - []byte: The encoded message
+//   - error: Names the event and payload type if the payload cannot be encoded
+func marshalMessage(event Event, payload any) ([]byte, error) {
+	raw, err := json.Marshal(Message{Event: event, Payload: payload})
+	if err != nil {
+		return nil, fmt.Errorf("marshal %q payload of type %T: %w", event, payload, err)
 	}
+	return raw, nil
 }
 
 // broadcast sends a message of the specified event and payload to clients in the room.
 // This method assumes the caller already holds the appropriate lock.
-func (r *Room) broadcast(event Event, payload any, roles set.Set[RoleType]) {
-	msg := Message{Event: event, Payload: payload}
-	rawMsg, err := json.Marshal(msg)
+//
+// A payload that cannot be encoded is a programming error. Nobody receives a
+// partial message; the broadcast is dropped and logged at Error level with the
+// event and payload type. Handlers that change state before broadcasting should
+// encode first with marshalMessage and call broadcastRaw, so a bad payload is
+// caught before any state changes.
+//
+// A nil roles set sends to everyone except the roles RoomConfig.HiddenEvents
+// hides the event from.
+//
+// ctx carries the trace of the work causing the broadcast: the handler's
+// context, or r.ctx for timers and disconnects.
+//
+// Returns:
+//   - error: Non-nil if the payload could not be encoded and nothing was sent
+func (r *Room) broadcast(ctx context.Context, event Event, payload any, roles set.Set[RoleType]) error {
+	rawMsg, err := marshalMessage(event, payload)
 	if err != nil {
-		slog.Error("Failed to marshal broadcast message", "payload", payload, "error", err)
+		r.config.HandlerLog.logger().Error("Dropped broadcast: payload cannot be marshaled to JSON",
+			"event", event,
+			"payloadType", fmt.Sprintf("%T", payload),
+			"RoomId", r.ID,
+			"error", err,
+		)
+		return err
+	}
+	r.broadcastRaw(ctx, event, payload, rawMsg, roles)
+	return nil
+}
+
+// broadcastRaw sends an already encoded message to clients in the room. The
+// payload is only used to mirror durable events to the room's EventSink.
+// This method assumes the caller already holds the appropriate lock.
+func (r *Room) broadcastRaw(ctx context.Context, event Event, payload any, rawMsg []byte, roles set.Set[RoleType]) {
+	ctx, span := r.config.Tracer.Start(ctx, SpanBroadcast, Attribute{AttrRoomId, string(r.ID)}, Attribute{AttrEvent, string(event)})
+	defer span.End()
+
+	// Mirror durable events to the external sink. Sinks never block the room lock.
+	if r.durableEvents.Has(event) {
+		_, publishSpan := r.config.Tracer.Start(ctx, SpanPublish, Attribute{AttrRoomId, string(r.ID)}, Attribute{AttrEvent, string(event)})
+		r.config.EventSink.Publish(r.ID, event, payload)
+		publishSpan.End()
+	}
+
+	// Copies of the room on other hub instances deliver to their own clients.
+	r.publishBus(BusMessage{Event: event, Roles: busRoles(roles), Data: rawMsg})
+	r.fanOut(event, rawMsg, roles)
+}
+
+// deliverFromBus handles a message from a copy of this room on another hub
+// instance. A broadcast is delivered to this copy's clients; the sending copy
+// already mirrored it to the EventSink, so only local delivery happens here.
+// Direct messages, host actions and rosters are handled as remote.go
+// describes. Messages this copy published itself are ignored, since they were
+// handled when sent.
+//
+// Thread Safety: Acquires the room's write lock for rosters and host actions,
+// and its read lock otherwise.
+func (r *Room) deliverFromBus(msg BusMessage) {
+	if msg.Origin == r.busOrigin {
+		return
+	}
+	if msg.Kind == BusKindRoster || msg.Kind == BusKindAction {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+	} else {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+	}
+	if r.closed {
 		return
 	}
 
+	switch msg.Kind {
+	case BusKindBroadcast:
+		var roles set.Set[RoleType]
+		if len(msg.Roles) > 0 {
+			roles = set.New(msg.Roles...)
+		}
+		r.fanOut(msg.Event, msg.Data, roles)
+	case BusKindDirect:
+		r.deliverDirect(msg)
+	case BusKindAction:
+		r.runRemoteAction(msg)
+	case BusKindRoster:
+		r.receiveRoster(msg)
+	case BusKindRosterRequest:
+		if members := r.localMembers(); len(members) > 0 {
+			r.publishBus(BusMessage{Kind: BusKindRoster, Members: members})
+		}
+	}
+}
+
+// fanOut queues an encoded message for the room's clients in roles, or for
+// every client the event is not hidden from when roles is nil. Clients whose
+// queues are full are skipped.
+//
+// Each client receives the message at most once, even if it is in more than
+// one role map, such as a client lingering in hosts after it started sharing.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+func (r *Room) fanOut(event Event, rawMsg []byte, roles set.Set[RoleType]) {
+	sent := make(map[ClientIdType]struct{})
+	deliver := func(p *Client) {
+		if _, ok := sent[p.ID]; ok {
+			return
+		}
+		sent[p.ID] = struct{}{}
+		select {
+		case p.send <- rawMsg:
+		default:
+			// Prevent a slow client from blocking the whole broadcast.
+		}
+	}
+
 	if roles == nil {
-		// Send to all roles
-		for _, m := range []map[ClientIdType]*Client{r.hosts, r.sharingScreen, r.participants, r.waiting} {
-			for _, p := range m {
-				select {
-				case p.send <- rawMsg:
-				default:
-					// Prevent a slow client from blocking the whole broadcast.
-				}
+		// Send to all roles except those the visibility policy hides the event from
+		hidden := r.hiddenEvents[event]
+		for _, group := range []struct {
+			role    RoleType
+			members map[ClientIdType]*Client
+		}{
+			{RoleTypeHost, r.hosts},
+			{RoleTypeScreenshare, r.sharingScreen},
+			{RoleTypeParticipant, r.participants},
+			{RoleTypeSpectator, r.spectators},
+			{RoleTypeWaiting, r.waiting},
+		} {
+			if hidden.Has(group.role) {
+				continue
+			}
+			for _, p := range group.members {
+				deliver(p)
 			}
 		}
 
@@ -316,87 +1129,103 @@ func (r *Room) broadcast(event Event, payload any, roles set.Set[RoleType]) {
 				clients = clientsMapToSlice(r.sharingScreen)
 			case RoleTypeParticipant:
 				clients = clientsMapToSlice(r.participants)
+			case RoleTypeSpectator:
+				clients = clientsMapToSlice(r.spectators)
 			case RoleTypeWaiting:
 				clients = clientsMapToSlice(r.waiting)
 			default:
 				continue
 			}
 			for _, p := range clients {
-				select {
-				case p.send <- rawMsg:
-				default:
-					// Prevent a slow client from blocking the whole broadcast.
-				}
+				deliver(p)
 			}
 		}
 	}
 }
 
 // clientsMapToSlice converts a map of ClientIdType to *Client into a slice of *Client.
-// It iterates over the map and appends each client pointer to a new slice, which is then returned.
-// The order of clients in the resulting slice is not guaranteed.
+// Clients are ordered by when they joined the room, with ties (clients that never
+// went through handleClientConnect) broken by ID, so rosters built from the
+// slice are stable across updates instead of following Go's random map order.
 func clientsMapToSlice(m map[ClientIdType]*Client) []*Client {
 	clients := make([]*Client, 0, len(m))
 	for _, c := range m {
 		clients = append(clients, c)
 	}
+	slices.SortFunc(clients, func(a, b *Client) int {
+		if c := cmp.Compare(a.joinSeq, b.joinSeq); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
 	return clients
 }
 
-// getRoomState returns the current state of the room including all participants, hosts, etc.
-// This method is thread-safe and can be called concurrently.
-func (r *Room) getRoomState() RoomStatePayload {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	// Convert client maps to slices of ClientInfo
-	hosts := make([]ClientInfo, 0, len(r.hosts))
-	for _, client := range r.hosts {
-		hosts = append(hosts, ClientInfo{
-			ClientId:    client.ID,
-			DisplayName: client.DisplayName,
-		})
-	}
-
-	participants := make([]ClientInfo, 0, len(r.participants))
-	for _, client := range r.participants {
-		participants = append(participants, ClientInfo{
-			ClientId:    client.ID,
-			DisplayName: client.DisplayName,
-		})
+// clientInfos converts clients to the ClientInfo sent in room state, preserving order.
+func clientInfos(clients []*Client) []ClientInfo {
+	infos := make([]ClientInfo, 0, len(clients))
+	for _, client := range clients {
+		infos = append(infos, client.info())
 	}
+	return infos
+}
 
-	waitingUsers := make([]ClientInfo, 0, len(r.waiting))
-	for _, client := range r.waiting {
-		waitingUsers = append(waitingUsers, ClientInfo{
-			ClientId:    client.ID,
-			DisplayName: client.DisplayName,
-		})
+// raisedHandsInOrder returns clients with raised hands in the order they raised them,
+// following the hand-raise queue.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) raisedHandsInOrder() []*Client {
+	clients := make([]*Client, 0, len(r.raisingHand))
+	seen := make(map[ClientIdType]bool, len(r.raisingHand))
+	for e := r.handDrawOrderQueue.Front(); e != nil; e = e.Next() {
+		c, ok := e.Value.(*Client)
+		if !ok || seen[c.ID] || r.raisingHand[c.ID] != c {
+			continue
+		}
+		seen[c.ID] = true
+		clients = append(clients, c)
 	}
-
-	handsRaised := make([]ClientInfo, 0, len(r.raisingHand))
-	for _, client := range r.raisingHand {
-		handsRaised = append(handsRaised, ClientInfo{
-			ClientId:    client.ID,
-			DisplayName: client.DisplayName,
-		})
+	// Include any raised hands missing from the queue so none are dropped.
+	for _, c := range clientsMapToSlice(r.raisingHand) {
+		if !seen[c.ID] {
+			clients = append(clients, c)
+		}
 	}
+	return clients
+}
 
-	sharingScreen := make([]ClientInfo, 0, len(r.sharingScreen))
-	for _, client := range r.sharingScreen {
-		sharingScreen = append(sharingScreen, ClientInfo{
-			ClientId:    client.ID,
-			DisplayName: client.DisplayName,
-		})
-	}
+// getRoomState returns the current state of the room including all participants, hosts, etc.
+// This method is thread-safe and can be called concurrently.
+//
+// Ordering:
+// Hosts, participants, waiting users and screensharers are listed in join order;
+// raised hands are listed in the order they were raised.
+func (r *Room) getRoomState() RoomStatePayload {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.roomState()
+}
 
-	return RoomStatePayload{
+// roomState builds the room state snapshot returned by getRoomState.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+func (r *Room) roomState() RoomStatePayload {
+	state := RoomStatePayload{
 		ClientInfo:    ClientInfo{}, // This will be set by the caller if needed
 		RoomID:        r.ID,
-		Hosts:         hosts,
-		Participants:  participants,
-		HandsRaised:   handsRaised,
-		WaitingUsers:  waitingUsers,
-		SharingScreen: sharingScreen,
+		Hosts:         clientInfos(clientsMapToSlice(r.hosts)),
+		Participants:  clientInfos(clientsMapToSlice(r.participants)),
+		HandsRaised:   clientInfos(r.raisedHandsInOrder()),
+		WaitingUsers:  clientInfos(clientsMapToSlice(r.waiting)),
+		SharingScreen: clientInfos(r.screenSharers()),
+		Spectators:    clientInfos(clientsMapToSlice(r.spectators)),
+		Policy:        r.policy,
+		PinnedChatIds: r.pinnedChatIds(),
+		Spotlight:     r.spotlight,
+		Welcome:       r.welcome,
 	}
+	r.addRemoteMembers(&state)
+	return state
 }
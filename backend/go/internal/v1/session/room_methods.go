@@ -18,7 +18,22 @@
 // preventing deadlocks and ensuring consistent state updates.
 package session
 
-import "container/list"
+import (
+	"cmp"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"k8s.io/utils/clock"
+	"k8s.io/utils/set"
+)
 
 // addParticipant promotes a client to participant status and adds them to the main meeting.
 // This method updates the client's role, adds them to the participants map, and places
@@ -37,6 +52,8 @@ func (r *Room) addParticipant(client *Client) {
 	element := r.clientDrawOrderQueue.PushBack(client)
 	client.drawOrderElement = element
 	r.participants[client.ID] = client
+	r.notePeak()
+	r.shareRoster()
 }
 
 // deleteParticipant removes a client from participant status and the main meeting.
@@ -54,6 +71,7 @@ func (r *Room) deleteParticipant(client *Client) {
 		r.clientDrawOrderQueue.Remove(client.drawOrderElement)
 		client.drawOrderElement = nil
 	}
+	r.shareRoster()
 }
 
 // addHost promotes a client to host status, granting them administrative privileges.
@@ -68,13 +86,22 @@ func (r *Room) deleteParticipant(client *Client) {
 // Thread Safety: This method is NOT thread-safe and must only be called when
 // the room's mutex lock is already held.
 //
+// A client that was sharing its screen leaves sharingScreen but keeps
+// sharing, as a host; see addScreenshare.
+//
 // Parameters:
 //   - client: The client to promote to host status
 func (r *Room) addHost(client *Client) {
+	if _, sharing := r.sharingScreen[client.ID]; sharing {
+		r.deleteScreenshare(client)
+		r.sharingHosts[client.ID] = client
+	}
 	client.Role = RoleTypeHost
 	element := r.clientDrawOrderQueue.PushBack(client)
 	client.drawOrderElement = element
 	r.hosts[client.ID] = client
+	r.notePeak()
+	r.shareRoster()
 }
 
 // deleteHost removes a client from host status and revokes their administrative privileges.
@@ -84,6 +111,8 @@ func (r *Room) addHost(client *Client) {
 // Note: Removing all hosts from a room may leave it without administrative control.
 // Consider the implications before calling this method.
 //
+// A host that was sharing its screen no longer counts as sharing.
+//
 // Thread Safety: This method is NOT thread-safe and must only be called when
 // the room's mutex lock is already held.
 //
@@ -91,10 +120,12 @@ func (r *Room) addHost(client *Client) {
 //   - client: The client to remove from host status
 func (r *Room) deleteHost(client *Client) {
 	delete(r.hosts, client.ID)
+	delete(r.sharingHosts, client.ID)
 	if client.drawOrderElement != nil {
 		r.clientDrawOrderQueue.Remove(client.drawOrderElement)
 		client.drawOrderElement = nil
 	}
+	r.shareRoster()
 }
 
 // addWaiting places a client in the waiting room, requiring host approval to join.
@@ -114,6 +145,15 @@ func (r *Room) addWaiting(client *Client) {
 	element := r.waitingDrawOrderStack.PushFront(client)
 	client.drawOrderElement = element
 	r.waiting[client.ID] = client
+
+	if timeout := r.config.WaitingTimeout; timeout > 0 {
+		client.waitingTimer = r.afterFunc(timeout, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timeoutWaiting(client)
+		})
+	}
+	r.shareRoster()
 }
 
 // deleteWaiting removes a client from the waiting room.
@@ -131,11 +171,17 @@ func (r *Room) addWaiting(client *Client) {
 // Parameters:
 //   - client: The client to remove from the waiting room
 func (r *Room) deleteWaiting(client *Client) {
+	client.admitApprovals = nil
+	if client.waitingTimer != nil {
+		client.waitingTimer.Stop()
+		client.waitingTimer = nil
+	}
 	delete(r.waiting, client.ID)
 	if client.drawOrderElement != nil {
 		r.waitingDrawOrderStack.Remove(client.drawOrderElement)
 		client.drawOrderElement = nil
 	}
+	r.shareRoster()
 }
 
 // addScreenshare grants a client screen sharing privileges and updates their role.
@@ -145,12 +191,26 @@ func (r *Room) deleteWaiting(client *Client) {
 // Screen sharing permissions are usually granted by hosts and may be limited
 // to one or a small number of concurrent screen sharers.
 //
+// Role Model:
+// Every admitted client is in exactly one role map, the one matching its
+// Role, and sharingScreen is the map for RoleTypeScreenshare. A host that
+// shares its screen stays in hosts: the host role already carries every
+// screenshare permission, and demoting it would cost the room its moderator.
+// It is recorded in sharingHosts instead, which is not a role map, so it is
+// never messaged twice by a broadcast. Use isSharing and screenSharers to ask
+// who is sharing, whatever their role.
+//
 // Thread Safety: This method is NOT thread-safe and must only be called when
 // the room's mutex lock is already held.
 //
 // Parameters:
 //   - client: The client to grant screen sharing privileges
 func (r *Room) addScreenshare(client *Client) {
+	defer r.shareRoster()
+	if _, isHost := r.hosts[client.ID]; isHost {
+		r.sharingHosts[client.ID] = client
+		return
+	}
 	client.Role = RoleTypeScreenshare
 	element := r.clientDrawOrderQueue.PushBack(client)
 	client.drawOrderElement = element
@@ -166,12 +226,22 @@ func (r *Room) addScreenshare(client *Client) {
 //   - A host revokes their screen sharing permission
 //   - The client disconnects while screen sharing
 //
+// The client's Role is left as it was, so callers must give the client its
+// next role straight away. Stopping a share goes through transitionRole,
+// which returns the client to the participant role; addHost replaces it with
+// host. A sharing host only leaves sharingHosts and stays a host.
+//
 // Thread Safety: This method is NOT thread-safe and must only be called when
 // the room's mutex lock is already held.
 //
 // Parameters:
 //   - client: The client whose screen sharing privileges should be revoked
 func (r *Room) deleteScreenshare(client *Client) {
+	defer r.shareRoster()
+	if _, isHost := r.sharingHosts[client.ID]; isHost {
+		delete(r.sharingHosts, client.ID)
+		return
+	}
 	delete(r.sharingScreen, client.ID)
 	if client.drawOrderElement != nil {
 		r.clientDrawOrderQueue.Remove(client.drawOrderElement)
@@ -179,6 +249,234 @@ func (r *Room) deleteScreenshare(client *Client) {
 	}
 }
 
+// isSharing reports whether a client is sharing its screen, either in the
+// screenshare role or as a host.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) isSharing(clientId ClientIdType) bool {
+	_, sharing := r.sharingScreen[clientId]
+	_, hostSharing := r.sharingHosts[clientId]
+	return sharing || hostSharing
+}
+
+// screenSharers returns every client sharing its screen, hosts included, in
+// join order.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) screenSharers() []*Client {
+	sharers := make(map[ClientIdType]*Client, len(r.sharingScreen)+len(r.sharingHosts))
+	maps.Copy(sharers, r.sharingScreen)
+	maps.Copy(sharers, r.sharingHosts)
+	return clientsMapToSlice(sharers)
+}
+
+// addSpectator admits a client to watch a webinar without speaking.
+// Spectators are not drawn in the main view, so no draw order element is kept.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client to admit as a spectator
+func (r *Room) addSpectator(client *Client) {
+	client.Role = RoleTypeSpectator
+	r.spectators[client.ID] = client
+	r.notePeak()
+	r.shareRoster()
+}
+
+// admittedRole is the role a joiner receives on admission: spectator in
+// webinar mode, participant otherwise.
+//
+// Thread Safety: Safe without the room's lock, since config is immutable.
+func (r *Room) admittedRole() RoleType {
+	if r.config.WebinarMode {
+		return RoleTypeSpectator
+	}
+	return RoleTypeParticipant
+}
+
+// deleteSpectator removes a client from the room's spectators.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The spectator to remove
+func (r *Room) deleteSpectator(client *Client) {
+	delete(r.spectators, client.ID)
+	r.shareRoster()
+}
+
+// findPeer looks up an admitted client that can take part in WebRTC signaling.
+// Hosts, participants, screensharers and spectators are searched; waiting
+// clients are not.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - clientId: The client to look up
+//
+// Returns:
+//   - *Client: The client, or nil if not found
+//   - bool: Whether the client was found
+func (r *Room) findPeer(clientId ClientIdType) (*Client, bool) {
+	for _, m := range []map[ClientIdType]*Client{r.participants, r.hosts, r.sharingScreen, r.spectators} {
+		if c, ok := m[clientId]; ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// errRoleTransition is returned when a role transition's preconditions do not hold.
+var errRoleTransition = errors.New("invalid role transition")
+
+// transitionRole moves a client from one role to another as a single step.
+// All preconditions are checked before any state changes, so a rejected
+// transition leaves the client exactly where it was and an accepted one
+// can never leave the client in neither role map.
+//
+// Validation:
+//   - The client's Role must equal from
+//   - The client must be present in the room's map for from
+//   - from and to must be different, known roles
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client changing role
+//   - from: The role the client is expected to hold
+//   - to: The role the client should end up with
+//
+// Returns:
+//   - error: Wrapping errRoleTransition if the transition was rejected
+func (r *Room) transitionRole(client *Client, from, to RoleType) error {
+	if from == to {
+		return fmt.Errorf("%w: client %s is already %s", errRoleTransition, client.ID, to)
+	}
+	members := r.roleMembers(from)
+	if members == nil || r.roleMembers(to) == nil {
+		return fmt.Errorf("%w: unknown role in %s -> %s", errRoleTransition, from, to)
+	}
+	if client.Role != from || members[client.ID] != client {
+		return fmt.Errorf("%w: client %s is %s, not %s", errRoleTransition, client.ID, client.Role, from)
+	}
+
+	// Spectators may not lower a hand, so one raised as a speaker is lowered
+	// for them rather than left stuck in the queue.
+	if to == RoleTypeSpectator {
+		r.lowerHand(LowerHandPayload(client.info()))
+	}
+
+	// A sharer promoted to host keeps sharing, as a host. A host that loses
+	// the role while sharing stops, and the room is told as if it had.
+	keepSharing := from == RoleTypeScreenshare && to == RoleTypeHost
+	_, stopSharing := r.sharingHosts[client.ID]
+
+	switch from {
+	case RoleTypeWaiting:
+		r.deleteWaiting(client)
+	case RoleTypeParticipant:
+		r.deleteParticipant(client)
+	case RoleTypeScreenshare:
+		r.deleteScreenshare(client)
+	case RoleTypeHost:
+		r.deleteHost(client)
+	case RoleTypeSpectator:
+		r.deleteSpectator(client)
+	}
+
+	switch to {
+	case RoleTypeWaiting:
+		r.addWaiting(client)
+	case RoleTypeParticipant:
+		r.addParticipant(client)
+	case RoleTypeScreenshare:
+		r.addScreenshare(client)
+	case RoleTypeHost:
+		r.addHost(client)
+	case RoleTypeSpectator:
+		r.addSpectator(client)
+	}
+	if keepSharing {
+		r.sharingHosts[client.ID] = client
+		r.shareRoster()
+	}
+	r.sendRoleChanged(client)
+	if stopSharing && to != RoleTypeScreenshare {
+		r.broadcast(r.ctx, EventStopScreenshare, StopScreensharePayload(client.info()), nil)
+	}
+	return nil
+}
+
+// sendRoleChanged tells client its current role and the events it may now
+// send. Every role change inside the room goes through transitionRole, which
+// calls this, so clients never need to infer their own role from broadcasts.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client whose role changed
+func (r *Room) sendRoleChanged(client *Client) {
+	payload := RoleChangedPayload{Role: client.Role, Capabilities: r.capabilities(client.Role)}
+	r.replyTo(client, EventRoleChanged, payload)
+}
+
+// capabilities lists the events a client in role may currently send: those
+// its role is permitted that are also within the endpoint's scope, enabled
+// for the room, allowed by the participant policy and, for waiting clients,
+// allowed by the waiting room guard. The router applies the same checks, so
+// the list matches what would actually be accepted.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Returns:
+//   - The events, sorted
+func (r *Room) capabilities(role RoleType) []Event {
+	events := make([]Event, 0, len(r.permissions))
+	for event, roles := range r.permissions {
+		if !HasPermission(role, roles) || r.waitingGuardRejects(role, event) {
+			continue
+		}
+		if r.config.AllowedEvents != nil && !r.config.AllowedEvents.Has(event) {
+			continue
+		}
+		if !r.features.allows(event) || !r.policy.allows(role, event) {
+			continue
+		}
+		events = append(events, event)
+	}
+	slices.Sort(events)
+	return events
+}
+
+// roleMembers returns the room's membership map for a role, or nil for unknown roles.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) roleMembers(role RoleType) map[ClientIdType]*Client {
+	switch role {
+	case RoleTypeWaiting:
+		return r.waiting
+	case RoleTypeParticipant:
+		return r.participants
+	case RoleTypeScreenshare:
+		return r.sharingScreen
+	case RoleTypeHost:
+		return r.hosts
+	case RoleTypeSpectator:
+		return r.spectators
+	}
+	return nil
+}
+
 // addChat adds a new chat message to the room's chat history.
 // This method appends the message to the end of the chat history list and
 // enforces the maximum chat history length by removing older messages if necessary.
@@ -190,6 +488,8 @@ func (r *Room) deleteScreenshare(client *Client) {
 // Memory Management:
 // If maxChatHistoryLength is set (> 0), older messages are automatically
 // removed when the history exceeds the limit, preventing unbounded memory growth.
+// If RoomConfig.MaxChatHistoryAge is set, messages that have reached the age
+// are removed now, and a sweep is scheduled to remove this one when it does.
 //
 // Thread Safety: This method is NOT thread-safe and must only be called when
 // the room's mutex lock is already held.
@@ -208,11 +508,130 @@ func (r *Room) addChat(payload AddChatPayload) {
 	// Enforce max chat history length
 	if r.maxChatHistoryLength > 0 {
 		for r.chatHistory.Len() > r.maxChatHistoryLength {
-			r.chatHistory.Remove(r.chatHistory.Front())
+			if evicted, ok := r.chatHistory.Remove(r.chatHistory.Front()).(AddChatPayload); ok {
+				r.pinnedChats.Delete(evicted.ChatId)
+			}
+		}
+	}
+
+	// Enforce max chat history age
+	if r.config.MaxChatHistoryAge > 0 {
+		now := r.config.Clock.Now()
+		r.expireChats(now)
+		r.scheduleChatExpiry(now, time.UnixMilli(int64(payload.Timestamp)).Add(r.config.MaxChatHistoryAge))
+	}
+}
+
+// chatExpirySlack is how late a message may outlive RoomConfig.MaxChatHistoryAge.
+// Messages that expire within this long of each other share one sweep, so a
+// busy room starts at most one timer per interval rather than one per message.
+const chatExpirySlack = time.Second
+
+// expireChats removes chat messages older than RoomConfig.MaxChatHistoryAge
+// from the front of the history, unpinning them.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - now: The current time; passed in so timer callbacks never read the clock
+func (r *Room) expireChats(now time.Time) {
+	cutoff := Timestamp(now.Add(-r.config.MaxChatHistoryAge).UnixMilli())
+	for e := r.chatHistory.Front(); e != nil; e = r.chatHistory.Front() {
+		chat, ok := e.Value.(AddChatPayload)
+		if !ok || chat.Timestamp >= cutoff {
+			return
 		}
+		r.chatHistory.Remove(e)
+		r.pinnedChats.Delete(chat.ChatId)
+	}
+}
+
+// scheduleChatExpiry makes sure a sweep runs once a message expires, even if
+// no further message arrives to trigger one. Sweeps are only scheduled here,
+// never from a sweep, because a fake clock runs callbacks while holding the
+// lock its AfterFunc needs.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - now: The current time
+//   - expires: When the message being added reaches MaxChatHistoryAge
+func (r *Room) scheduleChatExpiry(now, expires time.Time) {
+	if !r.chatSweepAt.Before(expires) {
+		return // A pending sweep runs after this message expires
+	}
+	at := expires.Add(chatExpirySlack)
+	r.chatSweepAt = at
+	r.afterFunc(at.Sub(now), func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.expireChats(at)
+	})
+}
+
+// addCaption appends a caption to the room's transcript, discarding the
+// oldest once more than RoomConfig.MaxTranscript are held.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) addCaption(caption CaptionPayload) {
+	if r.transcript == nil {
+		r.transcript = list.New()
+	}
+	r.transcript.PushBack(caption)
+	for r.config.MaxTranscript > 0 && r.transcript.Len() > r.config.MaxTranscript {
+		r.transcript.Remove(r.transcript.Front())
 	}
 }
 
+// clearSpotlight removes the spotlight from a client, telling the room with
+// EventClearSpotlight. Nothing happens if the client is not spotlighted, so it
+// is safe to call whenever a client stops being a participant.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - ctx: Context for the broadcast
+//   - clientId: The client to take out of the spotlight
+func (r *Room) clearSpotlight(ctx context.Context, clientId ClientIdType) {
+	if clientId == "" || r.spotlight != clientId {
+		return
+	}
+	r.spotlight = ""
+	r.broadcast(ctx, EventClearSpotlight, ClearSpotlightPayload{ClientId: clientId}, HasSpectatorPermission())
+}
+
+// setConnectionStats records a client's latest connection statistics,
+// replacing any it reported before.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) setConnectionStats(stats ConnectionStatsPayload) {
+	if r.connectionStats == nil {
+		r.connectionStats = make(map[ClientIdType]ConnectionStatsPayload)
+	}
+	r.connectionStats[stats.ClientId] = stats
+}
+
+// connectionReport returns the latest connection statistics of every client
+// that has reported, ordered by client ID.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock (read or write) is already held.
+func (r *Room) connectionReport() []ConnectionStatsPayload {
+	stats := make([]ConnectionStatsPayload, 0, len(r.connectionStats))
+	for _, s := range r.connectionStats {
+		stats = append(stats, s)
+	}
+	slices.SortFunc(stats, func(a, b ConnectionStatsPayload) int {
+		return cmp.Compare(a.ClientId, b.ClientId)
+	})
+	return stats
+}
+
 // deleteChat removes a specific chat message from the room's chat history by ID.
 // This method searches through the chat history to find a message with the matching
 // ChatId and removes it from the list. Only the first matching message is removed.
@@ -242,125 +661,719 @@ func (r *Room) deleteChat(payload DeleteChatPayload) {
 		if chatMsg, ok := e.Value.(AddChatPayload); ok {
 			if chatMsg.ChatId == payload.ChatId {
 				r.chatHistory.Remove(e)
+				r.pinnedChats.Delete(chatMsg.ChatId)
 				return
 			}
 		}
 	}
 }
 
-// getRecentChats retrieves the most recent chat messages from the room's history.
-// This method converts the chat history linked list to a slice and returns
-// the most recent messages up to the configured limit.
-//
-// Message Ordering:
-// Messages are returned in chronological order (oldest first, newest last)
-// to maintain conversation flow when displayed in the UI.
-//
-// Limit Behavior:
-// Currently defaults to returning the last 50 messages. This limit helps
-// control memory usage and network bandwidth when sending chat history
-// to newly connected clients.
+// deleteUserChats removes every message in the chat history sent by the
+// client and returns their ids, oldest first.
 //
-// Performance Characteristics:
-// - Time complexity: O(n) where n is the total number of messages
-// - Space complexity: O(min(n, limit)) for the returned slice
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) deleteUserChats(clientId ClientIdType) []ChatId {
+	if r.chatHistory == nil {
+		return nil
+	}
+
+	var removed []ChatId
+	for e := r.chatHistory.Front(); e != nil; {
+		next := e.Next()
+		if chatMsg, ok := e.Value.(AddChatPayload); ok && chatMsg.ClientId == clientId {
+			removed = append(removed, chatMsg.ChatId)
+			r.chatHistory.Remove(e)
+			r.pinnedChats.Delete(chatMsg.ChatId)
+		}
+		e = next
+	}
+	return removed
+}
+
+// hasChat reports whether a message with the id is in the chat history.
 //
 // Thread Safety: This method is NOT thread-safe and must only be called when
 // the room's mutex lock is already held.
+func (r *Room) hasChat(chatId ChatId) bool {
+	_, found := r.findChat(chatId)
+	return found
+}
+
+// findChat returns the message with the id from the chat history.
 //
-// Parameters:
-//   - payload: Request payload (currently unused but reserved for future filtering)
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
 //
 // Returns:
-//   - Slice of chat messages in chronological order
-func (r *Room) getRecentChats(payload GetRecentChatsPayload) []AddChatPayload {
+//   - The message, and false if it is not in the history
+func (r *Room) findChat(chatId ChatId) (AddChatPayload, bool) {
 	if r.chatHistory == nil {
-		return []AddChatPayload{}
+		return AddChatPayload{}, false
 	}
-
-	// Convert list to slice for easier handling
-	messages := make([]AddChatPayload, 0, r.chatHistory.Len())
 	for e := r.chatHistory.Front(); e != nil; e = e.Next() {
-		if chatMsg, ok := e.Value.(AddChatPayload); ok {
-			messages = append(messages, chatMsg)
+		if chatMsg, ok := e.Value.(AddChatPayload); ok && chatMsg.ChatId == chatId {
+			return chatMsg, true
 		}
 	}
-
-	// Return the most recent messages (from the end of the slice)
-	// Default to last 50 messages if no specific limit is set
-	limit := 50
-	if len(messages) <= limit {
-		return messages
-	}
-	return messages[len(messages)-limit:]
+	return AddChatPayload{}, false
 }
 
-// disconnectClient performs comprehensive cleanup when a client leaves the room.
-// This method removes the client from all possible room states to prevent
-// memory leaks and ensure consistent room state after disconnection.
-//
-// Cleanup Operations:
-//   - Removes from all role-based maps (hosts, participants, waiting)
-//   - Removes from all state maps (raising hand, sharing screen, audio/video states)
-//   - Cleans up draw order queue references
-//   - Prevents dangling pointers and memory leaks
-//
-// Design Note:
-// This method is designed to be safe to call multiple times for the same client.
-// It will cleanly handle cases where the client isn't present in certain maps.
+// pinChat marks a message as pinned.
 //
 // Thread Safety: This method is NOT thread-safe and must only be called when
 // the room's mutex lock is already held.
 //
-// Parameters:
-//   - client: The client to completely remove from the room
-func (r *Room) disconnectClient(client *Client) {
-	// Remove from role-based maps
-	r.deleteHost(client)
-	r.deleteParticipant(client)
-	r.deleteWaiting(client)
-
-	// Remove from state maps
-	delete(r.raisingHand, client.ID)
-	delete(r.sharingScreen, client.ID)
-	delete(r.unmuted, client.ID)
-	delete(r.cameraOn, client.ID)
-
-	// Remove from hand raise queue if present
-	if client.drawOrderElement != nil {
-		r.handDrawOrderQueue.Remove(client.drawOrderElement)
-		client.drawOrderElement = nil
+// Returns:
+//   - false if the message was already pinned
+func (r *Room) pinChat(chatId ChatId) bool {
+	if r.pinnedChats.Has(chatId) {
+		return false
 	}
+	if r.pinnedChats == nil {
+		r.pinnedChats = set.New[ChatId]()
+	}
+	r.pinnedChats.Insert(chatId)
+	return true
 }
 
-// isRoomEmpty determines whether the room has any active participants.
-// A room is considered empty if it has no hosts, participants, or screen sharers.
-// Waiting users are NOT counted as they haven't been admitted to the main meeting.
-//
-// Use Cases:
-//   - Triggering room cleanup when the last participant leaves
-//   - Deciding whether to close/archive empty rooms
-//   - Resource management and memory cleanup
+// pinnedChatIds returns the ids of pinned messages in history order, or nil
+// if nothing is pinned.
 //
-// Business Logic:
-// Waiting room users don't count toward room occupancy because they haven't
-// been admitted to the actual meeting. This prevents rooms from staying
-// active indefinitely due to unapproved waiting users.
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) pinnedChatIds() []ChatId {
+	if r.pinnedChats.Len() == 0 {
+		return nil
+	}
+	ids := make([]ChatId, 0, r.pinnedChats.Len())
+	for e := r.chatHistory.Front(); e != nil; e = e.Next() {
+		if chatMsg, ok := e.Value.(AddChatPayload); ok && r.pinnedChats.Has(chatMsg.ChatId) {
+			ids = append(ids, chatMsg.ChatId)
+		}
+	}
+	return ids
+}
+
+// setSpeaking records whether a client is speaking.
 //
 // Thread Safety: This method is NOT thread-safe and must only be called when
 // the room's mutex lock is already held.
 //
 // Returns:
-//   - true if the room has no active participants (only waiting users or completely empty)
-//   - false if there are any hosts, participants, or screen sharers
-func (r *Room) isRoomEmpty() bool {
-	return len(r.hosts) == 0 &&
-		len(r.participants) == 0 &&
-		len(r.sharingScreen) == 0
+//   - bool: true if the client's speaking state changed
+func (r *Room) setSpeaking(clientId ClientIdType, speaking bool) bool {
+	_, wasSpeaking := r.activeSpeakers[clientId]
+	if speaking == wasSpeaking {
+		return false
+	}
+	if !speaking {
+		delete(r.activeSpeakers, clientId)
+		return true
+	}
+	if r.activeSpeakers == nil {
+		r.activeSpeakers = make(map[ClientIdType]uint64)
+	}
+	r.speakerSeq++
+	r.activeSpeakers[clientId] = r.speakerSeq
+	return true
 }
 
-// raiseHand adds a participant to the hand-raising queue and updates their status.
-// This method allows participants to request speaking time or attention from hosts.
+// publishActiveSpeakers tells the room who is speaking, no more often than
+// RoomConfig.ActiveSpeakerInterval. Within the interval a single broadcast is
+// scheduled for its end, carrying whatever the state is by then.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) publishActiveSpeakers(ctx context.Context) {
+	if r.speakersTimer != nil {
+		return // A coalesced broadcast is already scheduled
+	}
+	interval := r.config.ActiveSpeakerInterval
+	now := r.config.Clock.Now()
+	due := r.speakersSentAt.Add(interval)
+	if interval <= 0 || r.speakersSentAt.IsZero() || !now.Before(due) {
+		r.broadcastActiveSpeakers(ctx, now)
+		return
+	}
+	r.speakersTimer = r.afterFunc(due.Sub(now), func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.speakersTimer = nil
+		r.broadcastActiveSpeakers(r.ctx, due)
+	})
+}
+
+// broadcastActiveSpeakers sends EventActiveSpeakers to every admitted client.
+// Speakers are listed most recent first; the most recent is the dominant one.
+// now is passed in because timer callbacks must not read the clock.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) broadcastActiveSpeakers(ctx context.Context, now time.Time) {
+	ids := make([]ClientIdType, 0, len(r.activeSpeakers))
+	for id := range r.activeSpeakers {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b ClientIdType) int {
+		return cmp.Compare(r.activeSpeakers[b], r.activeSpeakers[a])
+	})
+
+	payload := ActiveSpeakersPayload{Speakers: make([]ClientInfo, 0, len(ids))}
+	for _, id := range ids {
+		if speaker, ok := r.findPeer(id); ok {
+			payload.Speakers = append(payload.Speakers, speaker.info())
+		}
+	}
+	if len(payload.Speakers) > 0 {
+		payload.Dominant = payload.Speakers[0].ClientId
+	}
+
+	r.speakersSentAt = now
+	r.broadcast(ctx, EventActiveSpeakers, payload, HasSpectatorPermission())
+}
+
+// allowsReaction reports whether the emoji is on the room's reaction allowlist.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) allowsReaction(emoji string) bool {
+	if r.config.AllowedReactions == nil {
+		return DefaultAllowedReactions().Has(emoji)
+	}
+	return r.config.AllowedReactions.Has(emoji)
+}
+
+// nextChatId assigns the id of a new chat message. Ids are unique within the
+// room for its lifetime, including ids of messages since deleted or evicted.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) nextChatId() ChatId {
+	r.chatIdCounter++
+	return ChatId(strconv.FormatUint(r.chatIdCounter, 10))
+}
+
+// nextChatTimestamp stamps a new chat message with the room clock's current
+// time in Unix milliseconds. Timestamps never go backwards, even if the clock
+// does, so history order always matches arrival order.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Returns:
+//   - Timestamp: The timestamp for the new message
+func (r *Room) nextChatTimestamp() Timestamp {
+	ts := max(Timestamp(r.config.Clock.Now().UnixMilli()), r.lastChatTimestamp)
+	r.lastChatTimestamp = ts
+	return ts
+}
+
+// maxChatRangeResults caps how many messages a single range request returns.
+const maxChatRangeResults = 200
+
+// getChatsByRange returns the retained chat messages whose Timestamp falls within
+// [from, to], oldest first. At most maxChatRangeResults messages are returned,
+// starting from the beginning of the range. An inverted range returns no messages.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held (a read lock is sufficient).
+//
+// Parameters:
+//   - from: Inclusive start of the range
+//   - to: Inclusive end of the range
+//
+// Returns:
+//   - []ChatInfo: The matching messages in chronological order
+func (r *Room) getChatsByRange(from, to Timestamp) []ChatInfo {
+	chats := []ChatInfo{}
+	if from > to || r.chatHistory == nil {
+		return chats
+	}
+	for e := r.chatHistory.Front(); e != nil && len(chats) < maxChatRangeResults; e = e.Next() {
+		chat, ok := e.Value.(ChatInfo)
+		if ok && chat.Timestamp >= from && chat.Timestamp <= to {
+			chats = append(chats, chat)
+		}
+	}
+	return chats
+}
+
+// getChatHistory returns every retained chat message, oldest first.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held (a read lock is sufficient).
+//
+// Returns:
+//   - []ChatInfo: A copy of the chat history
+func (r *Room) getChatHistory() []ChatInfo {
+	if r.chatHistory == nil {
+		return []ChatInfo{}
+	}
+	chats := make([]ChatInfo, 0, r.chatHistory.Len())
+	for e := r.chatHistory.Front(); e != nil; e = e.Next() {
+		if chat, ok := e.Value.(ChatInfo); ok {
+			chats = append(chats, chat)
+		}
+	}
+	return chats
+}
+
+// getRecentChats retrieves the most recent chat messages from the room's history.
+// This method converts the chat history linked list to a slice and returns
+// the most recent messages up to the configured limit.
+//
+// Message Ordering:
+// Messages are returned in chronological order (oldest first, newest last)
+// to maintain conversation flow when displayed in the UI.
+//
+// Limit Behavior:
+// Currently defaults to returning the last 50 messages. This limit helps
+// control memory usage and network bandwidth when sending chat history
+// to newly connected clients.
+//
+// Performance Characteristics:
+// - Time complexity: O(n) where n is the total number of messages
+// - Space complexity: O(min(n, limit)) for the returned slice
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - payload: Request payload (currently unused but reserved for future filtering)
+//
+// Returns:
+//   - Slice of chat messages in chronological order
+func (r *Room) getRecentChats(payload GetRecentChatsPayload) []AddChatPayload {
+	if r.chatHistory == nil {
+		return []AddChatPayload{}
+	}
+
+	// Convert list to slice for easier handling
+	messages := make([]AddChatPayload, 0, r.chatHistory.Len())
+	for e := r.chatHistory.Front(); e != nil; e = e.Next() {
+		if chatMsg, ok := e.Value.(AddChatPayload); ok {
+			messages = append(messages, chatMsg)
+		}
+	}
+
+	// Return the most recent messages (from the end of the slice)
+	// Default to last 50 messages if no specific limit is set
+	limit := 50
+	if len(messages) <= limit {
+		return messages
+	}
+	return messages[len(messages)-limit:]
+}
+
+// disconnectClient performs comprehensive cleanup when a client leaves the room.
+// This method removes the client from all possible room states to prevent
+// memory leaks and ensure consistent room state after disconnection.
+//
+// Cleanup Operations:
+//   - Removes from all role-based maps (hosts, participants, waiting)
+//   - Removes from all state maps (raising hand, sharing screen, audio/video states)
+//   - Cleans up draw order queue references
+//   - Prevents dangling pointers and memory leaks
+//
+// Design Note:
+// This method is designed to be safe to call multiple times for the same client.
+// It will cleanly handle cases where the client isn't present in certain maps.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client to completely remove from the room
+func (r *Room) disconnectClient(client *Client) {
+	// Remove from role-based maps
+	r.deleteHost(client)
+	r.deleteParticipant(client)
+	r.deleteWaiting(client)
+	r.deleteSpectator(client)
+
+	// Remove from state maps
+	delete(r.raisingHand, client.ID)
+	delete(r.sharingScreen, client.ID)
+	delete(r.sharingHosts, client.ID)
+	delete(r.deniedScreenshare, client.ID)
+	delete(r.unmuted, client.ID)
+	delete(r.cameraOn, client.ID)
+	if r.setSpeaking(client.ID, false) {
+		r.publishActiveSpeakers(r.ctx)
+	}
+	delete(r.connectionStats, client.ID)
+	r.clearSpotlight(r.ctx, client.ID)
+
+	// Discard any ICE candidates still waiting to be batched
+	r.dropPendingCandidates(client.ID)
+
+	// Forget unanswered offers so the peers can negotiate again later
+	r.dropPendingOffers(client.ID)
+
+	// Restart signaling sequences; a rejoining client negotiates afresh
+	r.dropSignalSeq(client.ID)
+
+	// Stop watching for inactivity
+	if client.idleTimer != nil {
+		client.idleTimer.Stop()
+		client.idleTimer = nil
+	}
+
+	// Remove from hand raise queue if present
+	if client.drawOrderElement != nil {
+		r.handDrawOrderQueue.Remove(client.drawOrderElement)
+		client.drawOrderElement = nil
+	}
+
+	// Other copies of the room stop listing the client
+	r.shareRoster()
+}
+
+// evictWaiting removes every client from the waiting room and closes their connections.
+// This is used when the room empties, since waiting users can never be admitted
+// once no host remains. Each evicted client's readPump will observe the closed
+// connection and run its normal disconnect path.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) evictWaiting() {
+	for _, waitingClient := range clientsMapToSlice(r.waiting) {
+		r.deleteWaiting(waitingClient)
+		if waitingClient.conn != nil {
+			waitingClient.conn.Close()
+		}
+		slog.Info("Evicted waiting client from emptied room", "ClientId", waitingClient.ID, "RoomId", r.ID)
+	}
+}
+
+// approveWaiting records a host's approval of a waiting client and reports
+// whether enough distinct hosts have now approved for the client to be admitted.
+// Repeated approvals from the same host are counted once.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - waitingClient: The client awaiting admission
+//   - host: The host approving the client
+//
+// Returns:
+//   - bool: true if the client should be admitted now
+func (r *Room) approveWaiting(waitingClient, host *Client) bool {
+	required := min(r.config.WaitingApprovals, len(r.hosts)+r.remoteCount(RoleTypeHost))
+	if required <= 1 {
+		return true
+	}
+	if waitingClient.admitApprovals == nil {
+		waitingClient.admitApprovals = set.New[ClientIdType]()
+	}
+	waitingClient.admitApprovals.Insert(host.ID)
+
+	// Approvals from hosts who have since left no longer count.
+	approved := 0
+	for id := range waitingClient.admitApprovals {
+		if r.isHost(id) {
+			approved++
+		}
+	}
+	return approved >= required
+}
+
+// uniqueDisplayName returns the client's display name, suffixed with the
+// lowest free number from 2 upwards, such as "Alex (2)", if another client in
+// the room already uses it.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The joining client, which must not yet be in the room
+//
+// Returns:
+//   - DisplayNameType: A display name no other client in the room uses
+func (r *Room) uniqueDisplayName(client *Client) DisplayNameType {
+	taken := set.New[DisplayNameType]()
+	for _, members := range []map[ClientIdType]*Client{r.hosts, r.participants, r.sharingScreen, r.spectators, r.waiting} {
+		for id, other := range members {
+			if id != client.ID {
+				taken.Insert(other.DisplayName)
+			}
+		}
+	}
+
+	name := client.DisplayName
+	for n := 2; taken.Has(name); n++ {
+		name = DisplayNameType(fmt.Sprintf("%s (%d)", client.DisplayName, n))
+	}
+	return name
+}
+
+// sendMediaStateSnapshot sends a newly admitted client the camera, microphone
+// and screen share state of every other admitted client in the room.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client that was just admitted
+func (r *Room) sendMediaStateSnapshot(client *Client) {
+	admitted := make(map[ClientIdType]*Client, len(r.hosts)+len(r.participants)+len(r.sharingScreen))
+	for _, members := range []map[ClientIdType]*Client{r.hosts, r.participants, r.sharingScreen} {
+		for id, peer := range members {
+			admitted[id] = peer
+		}
+	}
+	delete(admitted, client.ID)
+
+	payload := MediaStateSnapshotPayload{Peers: make([]PeerMediaState, 0, len(admitted)), Policy: r.policy}
+	for _, peer := range clientsMapToSlice(admitted) {
+		_, cameraOn := r.cameraOn[peer.ID]
+		_, unmuted := r.unmuted[peer.ID]
+		sharing := r.isSharing(peer.ID)
+		payload.Peers = append(payload.Peers, PeerMediaState{
+			ClientInfo:    peer.info(),
+			CameraOn:      cameraOn,
+			Unmuted:       unmuted,
+			SharingScreen: sharing,
+		})
+	}
+
+	r.replyTo(client, EventMediaStateSnapshot, payload)
+}
+
+// sendAdmissionNotices sends a newly admitted client what it needs before it
+// takes part: the codec policy, the media state snapshot, the welcome message
+// and, when the room mutes on join, the instruction to keep its microphone
+// off. The codec policy comes first so it is known before any negotiation.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client that was just admitted
+func (r *Room) sendAdmissionNotices(client *Client) {
+	r.sendCodecPolicy(client)
+	r.sendMediaStateSnapshot(client)
+	r.sendWelcome(client)
+	if r.config.MuteOnJoin && client.Role == RoleTypeParticipant {
+		r.forceMute(client, ForceMuteReasonJoin)
+	}
+}
+
+// forceMute marks client as muted and instructs it to turn its microphone
+// off. The client stays muted until it reports, with EventMediaState, that it
+// unmuted.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client to mute
+//   - reason: Why the client is being muted, for its UI
+func (r *Room) forceMute(client *Client, reason ForceMuteReason) {
+	delete(r.unmuted, client.ID)
+	r.replyTo(client, EventForceMute, ForceMutePayload{Reason: reason})
+}
+
+// replyTo sends event and payload to client alone, such as the answer to a
+// query or a decision the client is waiting on. The send never blocks: if the
+// client's send channel is full the message is dropped and logged, so a slow
+// client cannot stall the room while its lock is held.
+//
+// Thread Safety: Safe to call with or without the room's lock held.
+//
+// Parameters:
+//   - client: The client to send to
+//   - event: The event type of the message
+//   - payload: The message payload
+//
+// Returns:
+//   - true if the message was queued for the client
+func (r *Room) replyTo(client *Client, event Event, payload any) bool {
+	msg, err := json.Marshal(Message{Event: event, Payload: payload})
+	if err != nil {
+		slog.Error("Failed to marshal direct message", "error", err, "event", event, "ClientId", client.ID, "RoomId", r.ID)
+		return false
+	}
+	select {
+	case client.send <- msg:
+		return true
+	default:
+		slog.Warn("Failed to send direct message - client channel full", "event", event, "ClientId", client.ID, "RoomId", r.ID)
+		return false
+	}
+}
+
+// forwardTo relays a message from one client to another, as WebRTC
+// signaling, video pause and remote control do. It sends with replyTo, so a
+// target whose send channel is full misses the message and the drop is
+// logged.
+//
+// Thread Safety: Safe to call with or without the room's lock held.
+//
+// Parameters:
+//   - from: The client the message comes from
+//   - target: The client to send to
+//   - event: The event type of the message
+//   - payload: The message payload, already stamped with its sender
+//
+// Returns:
+//   - true if the message was queued for the target
+func (r *Room) forwardTo(from ClientIdType, target *Client, event Event, payload any) bool {
+	if !r.replyTo(target, event, payload) {
+		return false
+	}
+	slog.Debug("Forwarded message", "event", event, "SourceClientId", from, "TargetClientId", target.ID, "RoomId", r.ID)
+	return true
+}
+
+// sendCodecPolicy sends client the room's codec policy, if it has one.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client to send the policy to
+func (r *Room) sendCodecPolicy(client *Client) {
+	if r.config.CodecPolicy.IsZero() {
+		return
+	}
+	r.replyTo(client, EventCodecPolicy, CodecPolicyPayload(r.config.CodecPolicy))
+}
+
+// sendWelcome sends client the room's welcome message, if it has one. It is
+// sent directly rather than as chat, so it is never kept in chat history.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client that was just admitted
+func (r *Room) sendWelcome(client *Client) {
+	if r.welcome == "" {
+		return
+	}
+	r.replyTo(client, EventWelcome, WelcomePayload{Message: r.welcome})
+}
+
+// checkHostless starts the HostlessGracePeriod timer when the room has waiting
+// users but no host, and cancels it once a host is present or nobody is waiting.
+// Call it after any change to the host or waiting lists.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) checkHostless() {
+	grace := r.config.HostlessGracePeriod
+	if grace <= 0 {
+		return
+	}
+
+	if len(r.hosts) > 0 || r.remoteCount(RoleTypeHost) > 0 || len(r.waiting) == 0 {
+		if r.hostlessTimer != nil {
+			r.hostlessTimer.Stop()
+			r.hostlessTimer = nil
+		}
+		return
+	}
+	if r.hostlessTimer != nil {
+		return // Already counting down from when the room lost its host
+	}
+
+	r.hostlessTimer = r.afterFunc(grace, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.promoteHostless()
+	})
+}
+
+// promoteHostless promotes the longest-waiting client to host once the room
+// has gone HostlessGracePeriod without one. Every client is told with
+// EventHostPromoted so UIs can show the new host.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) promoteHostless() {
+	// The timer has already fired, so clear it rather than stopping it.
+	r.hostlessTimer = nil
+	if len(r.hosts) > 0 || r.remoteCount(RoleTypeHost) > 0 || len(r.waiting) == 0 {
+		return // A host arrived or the waiting room emptied while the timer was firing
+	}
+
+	// clientsMapToSlice orders by join order, so the first client has waited longest.
+	oldest := clientsMapToSlice(r.waiting)[0]
+
+	// Detach the waiting timeout rather than stopping it from inside a timer
+	// callback; timeoutWaiting ignores clients that are no longer waiting.
+	oldest.waitingTimer = nil
+	if err := r.transitionRole(oldest, RoleTypeWaiting, RoleTypeHost); err != nil {
+		slog.Error("Failed to promote waiting client in hostless room", "error", err, "ClientId", oldest.ID, "RoomId", r.ID)
+		return
+	}
+	slog.Info("Promoted longest-waiting client to host in hostless room", "ClientId", oldest.ID, "RoomId", r.ID)
+	r.sendAdmissionNotices(oldest)
+
+	r.broadcast(r.ctx, EventHostPromoted, HostPromotedPayload{ClientId: oldest.ID, DisplayName: oldest.DisplayName}, nil)
+}
+
+// timeoutWaiting removes a client that was not admitted within the room's
+// WaitingTimeout. The client is told why with EventWaitingTimeout, hosts are
+// notified so their waiting lists update, and the connection is closed so the
+// client's readPump runs the normal disconnect path.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The waiting client whose timer fired
+func (r *Room) timeoutWaiting(client *Client) {
+	if r.waiting[client.ID] != client {
+		return // Admitted, denied or disconnected while the timer was firing
+	}
+
+	// The timer has already fired, so clear it rather than stopping it.
+	client.waitingTimer = nil
+	r.deleteWaiting(client)
+	slog.Info("Waiting client timed out", "ClientId", client.ID, "RoomId", r.ID)
+
+	payload := WaitingTimeoutPayload{ClientId: client.ID, DisplayName: client.DisplayName}
+	r.replyTo(client, EventWaitingTimeout, payload)
+	r.broadcast(r.ctx, EventWaitingTimeout, payload, HasHostPermission())
+
+	if client.conn != nil {
+		client.conn.Close()
+	}
+}
+
+// isRoomEmpty determines whether the room has any active participants.
+// A room is considered empty if it has no hosts, participants, screen sharers or spectators.
+// Waiting users are NOT counted as they haven't been admitted to the main meeting.
+//
+// Use Cases:
+//   - Triggering room cleanup when the last participant leaves
+//   - Deciding whether to close/archive empty rooms
+//   - Resource management and memory cleanup
+//
+// Business Logic:
+// Waiting room users don't count toward room occupancy because they haven't
+// been admitted to the actual meeting. This prevents rooms from staying
+// active indefinitely due to unapproved waiting users.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Returns:
+//   - true if the room has no active participants (only waiting users or completely empty)
+//   - false if there are any hosts, participants, or screen sharers
+func (r *Room) isRoomEmpty() bool {
+	return len(r.hosts) == 0 &&
+		len(r.participants) == 0 &&
+		len(r.sharingScreen) == 0 &&
+		len(r.spectators) == 0
+}
+
+// raiseHand adds a participant to the hand-raising queue and updates their status.
+// This method allows participants to request speaking time or attention from hosts.
 // The participant is added to both the raising hand map and the hand draw order queue.
 //
 // Queue Behavior:
@@ -391,12 +1404,18 @@ func (r *Room) raiseHand(payload RaiseHandPayload) {
 	}
 
 	if client != nil {
+		// Raising an already raised hand keeps its place in the queue
+		if _, raised := r.raisingHand[client.ID]; raised {
+			return
+		}
+
 		// Add to raising hand map
 		r.raisingHand[client.ID] = client
 
 		// Add to hand raise queue
 		element := r.handDrawOrderQueue.PushBack(client)
 		client.drawOrderElement = element
+		r.shareRoster()
 	}
 }
 
@@ -442,5 +1461,440 @@ func (r *Room) lowerHand(payload LowerHandPayload) {
 			r.handDrawOrderQueue.Remove(client.drawOrderElement)
 			client.drawOrderElement = nil
 		}
+		r.shareRoster()
+	}
+}
+
+// lowerAllHands empties the hand-raising queue, clearing the draw order
+// element of every client in it. The lowered clients are collected before any
+// state changes, so nothing is removed from the map or queue while ranging
+// over it.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Returns:
+//   - []*Client: The clients whose hands were lowered, in the order raised
+func (r *Room) lowerAllHands() []*Client {
+	lowered := r.raisedHandsInOrder()
+	// Elements survive list.Init still pointing at the list, so a client left
+	// holding one would later remove it from the emptied queue.
+	for e := r.handDrawOrderQueue.Front(); e != nil; e = e.Next() {
+		if c, ok := e.Value.(*Client); ok && c.drawOrderElement == e {
+			c.drawOrderElement = nil
+		}
+	}
+	clear(r.raisingHand)
+	r.handDrawOrderQueue.Init()
+	return lowered
+}
+
+// peerRoute identifies a directed signaling path from one client to another.
+// It keys per-pair signaling state such as batched candidates and pending offers.
+type peerRoute struct {
+	from ClientIdType
+	to   ClientIdType
+}
+
+// candidateBatch accumulates ICE candidates for one route until its window elapses.
+type candidateBatch struct {
+	sender     ClientInfo
+	candidates []WebRTCCandidatePayload
+	timer      clock.Timer
+}
+
+// queueCandidate adds an ICE candidate to the pending batch for its route.
+// The first candidate on a route starts a timer; when the configured
+// CandidateBatchWindow elapses, every candidate queued on that route is
+// delivered to the target in a single message, preserving send order.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held. The flush timer acquires the lock itself.
+//
+// Parameters:
+//   - sender: The client that produced the candidate
+//   - payload: The candidate to forward
+func (r *Room) queueCandidate(sender *Client, payload WebRTCCandidatePayload) {
+	if r.pendingCandidates == nil {
+		r.pendingCandidates = make(map[peerRoute]*candidateBatch)
+	}
+
+	route := peerRoute{from: sender.ID, to: payload.TargetClientId}
+	if batch, ok := r.pendingCandidates[route]; ok {
+		batch.candidates = append(batch.candidates, payload)
+		return
+	}
+
+	batch := &candidateBatch{
+		sender:     sender.info(),
+		candidates: []WebRTCCandidatePayload{payload},
+	}
+	batch.timer = r.afterFunc(r.config.CandidateBatchWindow, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.flushCandidates(route)
+	})
+	r.pendingCandidates[route] = batch
+}
+
+// flushCandidates delivers the pending batch for a route to its target.
+// A batch holding a single candidate is forwarded as a plain EventCandidate;
+// larger batches are sent as one EventCandidateBatch. If the target has left
+// the room in the meantime, the batch is discarded.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - route: The sender and target whose batch should be delivered
+func (r *Room) flushCandidates(route peerRoute) {
+	batch, ok := r.pendingCandidates[route]
+	if !ok {
+		return
+	}
+	delete(r.pendingCandidates, route)
+
+	target, ok := r.findPeer(route.to)
+	if !ok {
+		slog.Warn("Dropping candidate batch - target left the room", "SourceClientId", route.from, "TargetClientId", route.to, "RoomId", r.ID)
+		return
+	}
+
+	var msg Message
+	if len(batch.candidates) == 1 {
+		msg = Message{Event: EventCandidate, Payload: batch.candidates[0]}
+	} else {
+		msg = Message{Event: EventCandidateBatch, Payload: WebRTCCandidateBatchPayload{
+			ClientInfo:     batch.sender,
+			TargetClientId: route.to,
+			Candidates:     batch.candidates,
+		}}
+	}
+
+	r.forwardTo(route.from, target, msg.Event, msg.Payload)
+}
+
+// dropPendingCandidates discards every pending batch sent by or addressed to a client.
+// This is called when a client leaves so no timers fire for departed peers.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - clientId: The client whose pending candidates should be discarded
+func (r *Room) dropPendingCandidates(clientId ClientIdType) {
+	for route, batch := range r.pendingCandidates {
+		if route.from == clientId || route.to == clientId {
+			batch.timer.Stop()
+			delete(r.pendingCandidates, route)
+		}
+	}
+}
+
+// trackOffer records an offer from one client to another and resolves glare.
+// If the target already has an unanswered offer pending towards the sender,
+// the peer with the lexically smaller ID is impolite and its offer wins:
+//   - If the sender is impolite, the target's offer is discarded, the target
+//     receives EventGlareDetected, and the sender's offer proceeds.
+//   - If the sender is polite, the sender receives EventGlareDetected and its
+//     offer is dropped.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - sender: The client sending the offer
+//   - target: The client the offer is addressed to
+//
+// Returns:
+//   - bool: true if the offer should be forwarded, false if it lost to glare
+func (r *Room) trackOffer(sender, target *Client) bool {
+	if r.pendingOffers == nil {
+		r.pendingOffers = make(map[peerRoute]struct{})
+	}
+
+	reverse := peerRoute{from: target.ID, to: sender.ID}
+	if _, ok := r.pendingOffers[reverse]; ok {
+		if sender.ID > target.ID {
+			slog.Info("WebRTC glare detected - dropping polite peer's offer",
+				"PoliteClientId", sender.ID, "ImpoliteClientId", target.ID, "RoomId", r.ID)
+			r.sendGlareDetected(sender, target.ID)
+			return false
+		}
+		slog.Info("WebRTC glare detected - superseding polite peer's offer",
+			"PoliteClientId", target.ID, "ImpoliteClientId", sender.ID, "RoomId", r.ID)
+		delete(r.pendingOffers, reverse)
+		r.sendGlareDetected(target, sender.ID)
+	}
+
+	r.pendingOffers[peerRoute{from: sender.ID, to: target.ID}] = struct{}{}
+	return true
+}
+
+// sendGlareDetected tells the polite peer of a colliding pair to roll back its offer.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - polite: The client whose offer lost
+//   - peerId: The client whose offer takes precedence
+func (r *Room) sendGlareDetected(polite *Client, peerId ClientIdType) {
+	r.replyTo(polite, EventGlareDetected, GlareDetectedPayload{
+		PeerClientId: peerId,
+		Polite:       true,
+	})
+}
+
+// dropPendingOffers forgets every unanswered offer sent by or addressed to a client.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - clientId: The client whose pending offers should be forgotten
+func (r *Room) dropPendingOffers(clientId ClientIdType) {
+	for route := range r.pendingOffers {
+		if route.from == clientId || route.to == clientId {
+			delete(r.pendingOffers, route)
+		}
+	}
+}
+
+// nextSignalSeq returns the next sequence number for signaling on a route.
+// Numbers start at 1 and increase by one per message the sender addresses to
+// the target, so the target can check it handles signaling in send order.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) nextSignalSeq(route peerRoute) uint64 {
+	if r.signalSeq == nil {
+		r.signalSeq = make(map[peerRoute]uint64)
+	}
+	r.signalSeq[route]++
+	return r.signalSeq[route]
+}
+
+// sequenceSignal prepares a route for forwarding a signaling message other
+// than a candidate. Candidates still waiting to be batched on the route were
+// sent earlier, so they are delivered first.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Returns:
+//   - uint64: The sequence number for the message
+func (r *Room) sequenceSignal(route peerRoute) uint64 {
+	if batch, ok := r.pendingCandidates[route]; ok {
+		batch.timer.Stop()
+		r.flushCandidates(route)
+	}
+	return r.nextSignalSeq(route)
+}
+
+// dropSignalSeq forgets the sequence numbers of every route to or from a client.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) dropSignalSeq(clientId ClientIdType) {
+	for route := range r.signalSeq {
+		if route.from == clientId || route.to == clientId {
+			delete(r.signalSeq, route)
+		}
+	}
+}
+
+// isRoomFull reports whether the room has reached its configured participant capacity.
+// Hosts, participants and screensharers count towards the limit; waiting users
+// and spectators do not.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Returns:
+//   - bool: true if no further clients can be admitted
+func (r *Room) isRoomFull() bool {
+	limit := r.config.MaxParticipants
+	return limit > 0 && len(r.hosts)+len(r.participants)+len(r.sharingScreen) >= limit
+}
+
+// notifyJoinLeave reports whether join and leave announcements should ask
+// clients to chime: not when RoomConfig.SilentJoinLeave is set, nor while more
+// clients are admitted than RoomConfig.JoinLeaveNotifyLimit.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) notifyJoinLeave() bool {
+	if r.config.SilentJoinLeave {
+		return false
+	}
+	limit := r.config.JoinLeaveNotifyLimit
+	admitted := len(r.hosts) + len(r.participants) + len(r.sharingScreen) + len(r.spectators)
+	return limit == 0 || admitted <= limit
+}
+
+// isHandQueueFull reports whether the room has reached its MaxRaisedHands limit.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) isHandQueueFull() bool {
+	limit := r.config.MaxRaisedHands
+	return limit > 0 && len(r.raisingHand) >= limit
+}
+
+// allowChat reports whether a client may send another chat message under the
+// room's ChatRateLimit, counting the message if so. Limits are applied per
+// client in fixed one-minute windows.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client sending a chat message
+//
+// Returns:
+//   - bool: true if the message is within the limit
+func (r *Room) allowChat(client *Client) bool {
+	limit := r.config.ChatRateLimit
+	if limit <= 0 {
+		return true
+	}
+	now := r.config.Clock.Now()
+	if now.Sub(client.chatWindowStart) >= time.Minute {
+		client.chatWindowStart = now
+		client.chatCount = 0
+	}
+	if client.chatCount >= limit {
+		return false
+	}
+	client.chatCount++
+	return true
+}
+
+// resetIdleTimer restarts a client's inactivity countdown.
+// When the room's IdleTimeout elapses without another reset, the client's
+// connection is closed and its readPump runs the normal disconnect path.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client that just connected or sent a message
+func (r *Room) resetIdleTimer(client *Client) {
+	timeout := r.config.IdleTimeout
+	if timeout <= 0 {
+		return
+	}
+	if client.idleTimer != nil {
+		client.idleTimer.Reset(timeout)
+		return
+	}
+	conn := client.conn
+	client.idleTimer = r.afterFunc(timeout, func() {
+		slog.Info("Closing idle client connection", "ClientId", client.ID, "RoomId", r.ID)
+		client.cancelContext()
+		if conn != nil {
+			conn.Close()
+		}
+	})
+}
+
+// hostTransfer records a host handing the host role to a participant.
+type hostTransfer struct {
+	from ClientIdType // The host who gave up the role
+	to   ClientIdType // The participant who received it
+	at   time.Time
+}
+
+// recentDisconnect records the role a client held when its connection dropped.
+type recentDisconnect struct {
+	role RoleType
+	at   time.Time
+}
+
+// rememberDisconnect records an admitted client's role as its connection
+// drops, so it can be restored by reclaimRole. Waiting clients are not
+// recorded since they hold nothing worth restoring.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - client: The client whose connection dropped, before it is removed
+func (r *Room) rememberDisconnect(client *Client) {
+	if r.config.ReconnectWindow <= 0 || client.Role == RoleTypeWaiting || client.Role == "" {
+		return
+	}
+	now := r.config.Clock.Now()
+	r.purgeDisconnects(now)
+	if r.recentlyDisconnected == nil {
+		r.recentlyDisconnected = make(map[ClientIdType]recentDisconnect)
+	}
+	r.recentlyDisconnected[client.ID] = recentDisconnect{role: client.Role, at: now}
+}
+
+// reclaimRole returns the role a reconnecting client held before its
+// connection dropped, consuming the record. Records older than
+// ReconnectWindow are not honored.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Returns:
+//   - role: The client's previous role
+//   - ok: false if there is no unexpired record for the client
+func (r *Room) reclaimRole(client *Client) (RoleType, bool) {
+	if len(r.recentlyDisconnected) == 0 {
+		return "", false
+	}
+	r.purgeDisconnects(r.config.Clock.Now())
+	entry, ok := r.recentlyDisconnected[client.ID]
+	if !ok {
+		return "", false
+	}
+	delete(r.recentlyDisconnected, client.ID)
+	return entry.role, true
+}
+
+// purgeDisconnects forgets disconnect records older than ReconnectWindow.
+// Purging on every access keeps the map bounded by the clients that dropped
+// within the last window, however long the room lives.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) purgeDisconnects(now time.Time) {
+	for id, entry := range r.recentlyDisconnected {
+		if now.Sub(entry.at) > r.config.ReconnectWindow {
+			delete(r.recentlyDisconnected, id)
+		}
+	}
+}
+
+// restoreRole places a reconnecting client back in the role it held. A
+// screensharer returns as a participant, since its share ended with the
+// connection. If the room filled up while a participant was away, it waits
+// for admission instead, as any other joiner would; hosts are restored
+// regardless, as designated hosts are admitted regardless.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) restoreRole(client *Client, role RoleType) {
+	if role != RoleTypeHost && role != RoleTypeSpectator && r.isRoomFull() {
+		slog.Info("Client reconnected to a full room, waiting for admission.", "room", r.ID, "ClientId", client.ID, "role", role)
+		r.addWaiting(client)
+		r.checkHostless()
+		return
+	}
+
+	slog.Info("Client reconnected, restoring previous role.", "room", r.ID, "ClientId", client.ID, "role", role)
+	switch role {
+	case RoleTypeHost:
+		r.addHost(client)
+		r.checkHostless()
+	case RoleTypeSpectator:
+		r.addSpectator(client)
+	default:
+		r.addParticipant(client)
 	}
+	r.sendAdmissionNotices(client)
 }
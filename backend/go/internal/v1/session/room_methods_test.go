@@ -1,6 +1,9 @@
 package session
 
 import (
+	"context"
+	"encoding/json"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -136,6 +139,72 @@ func TestDeleteScreenshare(t *testing.T) {
 	assert.Equal(t, 0, room.clientDrawOrderQueue.Len(), "Draw order queue should be empty")
 }
 
+func TestSharingHostRoleModel(t *testing.T) {
+	t.Run("a host that starts sharing stays in hosts only", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+		room.addHost(host)
+
+		room.addScreenshare(host)
+
+		assert.Equal(t, RoleTypeHost, host.Role)
+		assert.Contains(t, room.hosts, host.ID)
+		assert.NotContains(t, room.sharingScreen, host.ID)
+		assert.True(t, room.isSharing(host.ID), "The host is recorded as sharing")
+		assert.Equal(t, []*Client{host}, room.screenSharers())
+		assert.Equal(t, 1, room.clientDrawOrderQueue.Len(), "The host keeps its single draw order entry")
+
+		room.broadcast(context.Background(), Event("test-event"), map[string]string{"data": "once"}, nil)
+		assert.Len(t, host.send, 1)
+	})
+
+	t.Run("a sharer promoted to host leaves sharingScreen but keeps sharing", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		sharer := newTestClient("sharer")
+		room.addScreenshare(sharer)
+
+		room.addHost(sharer)
+
+		assert.Equal(t, RoleTypeHost, sharer.Role)
+		assert.Contains(t, room.hosts, sharer.ID)
+		assert.NotContains(t, room.sharingScreen, sharer.ID)
+		assert.True(t, room.isSharing(sharer.ID))
+		assert.Equal(t, 1, room.clientDrawOrderQueue.Len())
+
+		room.broadcast(context.Background(), Event("test-event"), map[string]string{"data": "once"}, HasScreensharePermission())
+		assert.Len(t, sharer.send, 1)
+	})
+
+	t.Run("a sharer promoted through transitionRole keeps sharing", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		sharer := newTestClient("sharer")
+		room.addScreenshare(sharer)
+
+		require.NoError(t, room.transitionRole(sharer, RoleTypeScreenshare, RoleTypeHost))
+
+		assert.True(t, room.isSharing(sharer.ID))
+		assert.Equal(t, []ClientInfo{sharer.info()}, room.roomState().SharingScreen)
+	})
+
+	t.Run("a sharing host that loses the role stops sharing", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+		viewer := newTestClient("viewer")
+		room.addHost(host)
+		room.addParticipant(viewer)
+		room.addScreenshare(host)
+
+		require.NoError(t, room.transitionRole(host, RoleTypeHost, RoleTypeParticipant))
+
+		assert.False(t, room.isSharing(host.ID))
+		assert.Empty(t, room.roomState().SharingScreen)
+		var msg wireMessage
+		require.NotEmpty(t, viewer.send)
+		require.NoError(t, json.Unmarshal(<-viewer.send, &msg))
+		assert.Equal(t, EventStopScreenshare, msg.Event, "The room is told the share stopped")
+	})
+}
+
 func TestIsRoomEmpty(t *testing.T) {
 	t.Run("should be empty when no one is present", func(t *testing.T) {
 		room := NewTestRoom("test-room", nil)
@@ -160,4 +229,120 @@ func TestIsRoomEmpty(t *testing.T) {
 		room.waiting["wait-1"] = newTestClient("wait-1")
 		assert.True(t, room.isRoomEmpty(), "Room with only waiting clients should be considered empty")
 	})
-}
\ No newline at end of file
+}
+func TestTransitionRole(t *testing.T) {
+	t.Run("valid transitions move the client between role maps", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClient("client1")
+		room.addWaiting(client)
+
+		require.NoError(t, room.transitionRole(client, RoleTypeWaiting, RoleTypeParticipant))
+		assert.Equal(t, RoleTypeParticipant, client.Role)
+		assert.NotContains(t, room.waiting, client.ID)
+		assert.Contains(t, room.participants, client.ID)
+
+		require.NoError(t, room.transitionRole(client, RoleTypeParticipant, RoleTypeScreenshare))
+		assert.Equal(t, RoleTypeScreenshare, client.Role)
+		assert.NotContains(t, room.participants, client.ID)
+		assert.Contains(t, room.sharingScreen, client.ID)
+
+		require.NoError(t, room.transitionRole(client, RoleTypeScreenshare, RoleTypeHost))
+		assert.Contains(t, room.hosts, client.ID)
+		assert.Equal(t, 1, room.clientDrawOrderQueue.Len(), "Client should appear once in the draw order")
+	})
+
+	t.Run("transition is rejected when the client is not in the source state", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClient("client1")
+		room.addParticipant(client)
+
+		err := room.transitionRole(client, RoleTypeWaiting, RoleTypeParticipant)
+		assert.ErrorIs(t, err, errRoleTransition)
+		assert.Equal(t, RoleTypeParticipant, client.Role, "Rejected transition should not change the role")
+		assert.Contains(t, room.participants, client.ID, "Rejected transition should not change membership")
+	})
+
+	t.Run("transition is rejected when the role field and maps disagree", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClient("client1")
+		client.Role = RoleTypeWaiting // Claims to be waiting but was never added
+
+		err := room.transitionRole(client, RoleTypeWaiting, RoleTypeParticipant)
+		assert.ErrorIs(t, err, errRoleTransition)
+		assert.NotContains(t, room.participants, client.ID)
+	})
+
+	t.Run("transition to the same or an unknown role is rejected", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClient("client1")
+		room.addParticipant(client)
+
+		assert.ErrorIs(t, room.transitionRole(client, RoleTypeParticipant, RoleTypeParticipant), errRoleTransition)
+		assert.ErrorIs(t, room.transitionRole(client, RoleTypeParticipant, RoleType("owner")), errRoleTransition)
+		assert.Contains(t, room.participants, client.ID)
+	})
+}
+
+func TestRoleChangedNotice(t *testing.T) {
+	// roleChanges returns the role change notices among everything c was sent.
+	roleChanges := func(t *testing.T, c *Client) []RoleChangedPayload {
+		t.Helper()
+		var got []RoleChangedPayload
+		for len(c.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			if msg.Event == EventRoleChanged {
+				var changed RoleChangedPayload
+				require.NoError(t, json.Unmarshal(msg.Payload, &changed))
+				got = append(got, changed)
+			}
+		}
+		return got
+	}
+
+	t.Run("an admitted waiting user is told it is a participant", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host")
+		guest := newTestClient("guest")
+		room.addHost(host)
+		room.addWaiting(guest)
+		for len(host.send) > 0 {
+			<-host.send
+		}
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: guest.ID}})
+
+		changes := roleChanges(t, guest)
+		require.Len(t, changes, 1)
+		assert.Equal(t, RoleTypeParticipant, changes[0].Role)
+		assert.Contains(t, changes[0].Capabilities, EventAddChat)
+		assert.NotContains(t, changes[0].Capabilities, EventAcceptWaiting)
+		assert.True(t, slices.IsSorted(changes[0].Capabilities))
+		assert.Empty(t, roleChanges(t, host), "Only the affected client is told")
+	})
+
+	t.Run("capabilities follow the room's policy and features", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		room.policy = ParticipantPolicy{AllowScreenshare: true}
+		room.features.ChatEnabled = false
+		client := newTestClient("client1")
+		room.addWaiting(client)
+
+		require.NoError(t, room.transitionRole(client, RoleTypeWaiting, RoleTypeParticipant))
+
+		changes := roleChanges(t, client)
+		require.Len(t, changes, 1)
+		assert.NotContains(t, changes[0].Capabilities, EventAddChat)
+		assert.NotContains(t, changes[0].Capabilities, EventReaction)
+		assert.Contains(t, changes[0].Capabilities, EventRaiseHand)
+	})
+
+	t.Run("rejected transitions send nothing", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClient("client1")
+		room.addParticipant(client)
+
+		assert.Error(t, room.transitionRole(client, RoleTypeWaiting, RoleTypeHost))
+		assert.Empty(t, roleChanges(t, client))
+	})
+}
@@ -19,13 +19,23 @@ package session
 
 import (
 	"container/list"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	testclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/set"
 )
 
 // room_test.go contains unit tests for the primary business logic of a Room,
@@ -43,15 +53,24 @@ func newTestClient(id ClientIdType) *Client {
 // NewTestRoom creates a new, stateful room for testing purposes.
 // Exported globally for use accross the codebase.
 func NewTestRoom(id RoomIdType, onEmptyCallback func(RoomIdType)) *Room {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Room{
 		ID:                   id,
 		mu:                   sync.RWMutex{},
 		chatHistory:          list.New(),
 		maxChatHistoryLength: 10,
+		config:               DefaultRoomConfig(),
+		features:             DefaultRoomFeatures(),
+		hiddenEvents:         DefaultHiddenEvents(),
+		maxPayloadSizes:      DefaultMaxPayloadSizes(),
+		permissions:          DefaultEventPermissions(),
+		policy:               DefaultParticipantPolicy(),
+		durableEvents:        DefaultDurableEvents(),
 
 		hosts:        make(map[ClientIdType]*Client),
 		participants: make(map[ClientIdType]*Client),
 		waiting:      make(map[ClientIdType]*Client),
+		spectators:   make(map[ClientIdType]*Client),
 
 		waitingDrawOrderStack: list.New(),
 		clientDrawOrderQueue:  list.New(),
@@ -59,10 +78,14 @@ func NewTestRoom(id RoomIdType, onEmptyCallback func(RoomIdType)) *Room {
 
 		raisingHand:   make(map[ClientIdType]*Client),
 		sharingScreen: make(map[ClientIdType]*Client),
+		sharingHosts:  make(map[ClientIdType]*Client),
 		unmuted:       make(map[ClientIdType]*Client),
 		cameraOn:      make(map[ClientIdType]*Client),
 
-		onEmpty: onEmptyCallback,
+		createdAt: time.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+		onEmpty:   onEmptyCallback,
 	}
 }
 
@@ -120,8 +143,8 @@ func TestHandleClientDisconnect(t *testing.T) {
 		room.deleteHost(client)
 
 		// Create payload for broadcast
-		payload := ClientDisconnectPayload{ClientId: client.ID, DisplayName: client.DisplayName}
-		room.broadcast(Event(EventDisconnect), payload, nil)
+		payload := ClientDisconnectPayload{ClientInfo: client.info()}
+		room.broadcast(context.Background(), Event(EventDisconnect), payload, nil)
 
 		// Check if room is empty AFTER broadcasting
 		if room.isRoomEmpty() {
@@ -182,7 +205,7 @@ func TestRouterPermissions(t *testing.T) {
 		// Test that router doesn't panic and processes the message
 		// Since participant has participant permissions, this should succeed
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic for participant adding chat")
 	})
 
@@ -199,15 +222,18 @@ func TestRouterPermissions(t *testing.T) {
 		}
 		msg := Message{Event: EventAddChat, Payload: payload}
 
-		// Track if any handlers were called by checking if the client's send channel received messages
-		// Since waiting clients don't have participant permissions, no broadcast should occur
-		initialChannelLen := len(client.send)
+		room.router(context.Background(), client, msg)
 
-		room.router(client, msg)
-
-		// No messages should be broadcast since waiting client can't add chat
-		assert.Equal(t, initialChannelLen, len(client.send),
-			"Waiting client should not be able to add chat - no broadcasts should occur")
+		// The waiting room guard answers with an error and nothing is broadcast
+		require.Len(t, client.send, 1, "Waiting client should only receive the rejection")
+		var reply struct {
+			Event   Event        `json:"event"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-client.send, &reply))
+		assert.Equal(t, EventError, reply.Event)
+		assert.Equal(t, ErrorCodeNotAdmitted, reply.Payload.Code)
+		assert.Zero(t, room.chatHistory.Len(), "Waiting client should not be able to add chat")
 	})
 
 	t.Run("host can accept waiting", func(t *testing.T) {
@@ -227,7 +253,7 @@ func TestRouterPermissions(t *testing.T) {
 
 		// Host should be able to accept waiting clients
 		assert.NotPanics(t, func() {
-			room.router(host, msg)
+			room.router(context.Background(), host, msg)
 		}, "Host should be able to accept waiting clients")
 	})
 
@@ -250,7 +276,7 @@ func TestRouterPermissions(t *testing.T) {
 		initialWaitingCount := len(room.waiting)
 		initialParticipantCount := len(room.participants)
 
-		room.router(participant, msg)
+		room.router(context.Background(), participant, msg)
 
 		// Waiting client should still be waiting (not moved to participants)
 		assert.Equal(t, initialWaitingCount, len(room.waiting),
@@ -272,7 +298,7 @@ func TestBroadcast(t *testing.T) {
 		room.addWaiting(waiting) // Assumes addWaiting is fixed to add to the 'waiting' map
 
 		payload := map[string]string{"data": "hello all"}
-		room.broadcast(Event("test-event"), payload, nil) // nil roles
+		room.broadcast(context.Background(), Event("test-event"), payload, nil) // nil roles
 
 		assert.Len(t, host.send, 1, "Host should receive message")
 		assert.Len(t, participant.send, 1, "Participant should receive message")
@@ -292,11 +318,1683 @@ func TestBroadcast(t *testing.T) {
 		room.addWaiting(waiting)
 
 		payload := map[string]string{"data": "hello hosts"}
-		room.broadcast(Event("host-event"), payload, HasHostPermission())
+		room.broadcast(context.Background(), Event("host-event"), payload, HasHostPermission())
 
 		assert.Len(t, host1.send, 1, "Host 1 should receive message")
 		assert.Len(t, host2.send, 1, "Host 2 should receive message")
 		assert.Len(t, participant.send, 0, "Participant should NOT receive message")
 		assert.Len(t, waiting.send, 0, "Waiting client should NOT receive message")
 	})
+
+	t.Run("a client in two role maps receives one copy", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("h1")
+		participant := newTestClient("p1")
+		room.addHost(host)
+		room.addParticipant(participant)
+		room.sharingScreen[host.ID] = host
+
+		room.broadcast(context.Background(), Event("test-event"), map[string]string{"data": "once"}, nil)
+		room.broadcast(context.Background(), Event("test-event"), map[string]string{"data": "once"}, HasScreensharePermission())
+
+		assert.Len(t, host.send, 2, "Each broadcast should reach the host once")
+		assert.Len(t, participant.send, 1)
+	})
+
+	t.Run("send-to-all skips roles the event is hidden from", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("h1")
+		participant := newTestClient("p1")
+		waiting := newTestClient("w1")
+		room.addHost(host)
+		room.addParticipant(participant)
+		room.addWaiting(waiting)
+
+		room.broadcast(context.Background(), EventDisconnect, ClientDisconnectPayload{ClientInfo: ClientInfo{ClientId: "gone"}}, nil)
+
+		assert.Len(t, host.send, 1, "Host should receive message")
+		assert.Len(t, participant.send, 1, "Participant should receive message")
+		assert.Len(t, waiting.send, 0, "Disconnects are hidden from waiting users by default")
+	})
+
+	t.Run("configured policy replaces the default", func(t *testing.T) {
+		config := DefaultRoomConfig()
+		config.HiddenEvents = map[Event]set.Set[RoleType]{
+			EventAddChat: set.New(RoleTypeParticipant),
+		}
+		room := NewRoomWithConfig("test-room", config, nil)
+		host := newTestClient("h1")
+		participant := newTestClient("p1")
+		waiting := newTestClient("w1")
+		room.addHost(host)
+		room.addParticipant(participant)
+		room.addWaiting(waiting)
+
+		room.broadcast(context.Background(), EventAddChat, map[string]string{"data": "hosts only"}, nil)
+		room.broadcast(context.Background(), EventDisconnect, ClientDisconnectPayload{ClientInfo: ClientInfo{ClientId: "gone"}}, nil)
+
+		assert.Len(t, host.send, 2)
+		assert.Len(t, participant.send, 1, "Participant should only receive the disconnect")
+		assert.Len(t, waiting.send, 2, "The default policy no longer applies")
+	})
+
+	t.Run("explicit roles ignore the policy", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		waiting := newTestClient("w1")
+		room.addWaiting(waiting)
+
+		room.broadcast(context.Background(), EventDisconnect, ClientDisconnectPayload{ClientInfo: ClientInfo{ClientId: "gone"}}, HasWaitingPermission())
+
+		assert.Len(t, waiting.send, 1)
+	})
+}
+
+func TestHostLeavesWithWaitingUsers(t *testing.T) {
+	t.Run("cleanup fires exactly once and waiting connections are closed", func(t *testing.T) {
+		var mu sync.Mutex
+		onEmptyCalls := 0
+		onEmptyFunc := func(id RoomIdType) {
+			mu.Lock()
+			defer mu.Unlock()
+			onEmptyCalls++
+		}
+
+		room := NewTestRoom("test-room", onEmptyFunc)
+		host := newTestClient("host-1")
+		room.handleClientConnect(host)
+
+		waiting1 := newTestClient("waiting-1")
+		waiting1.conn = newMockConn()
+		waiting2 := newTestClient("waiting-2")
+		waiting2.conn = newMockConn()
+		room.handleClientConnect(waiting1)
+		room.handleClientConnect(waiting2)
+		require.Len(t, room.waiting, 2)
+
+		// Host denies one waiting user, then leaves
+		room.router(context.Background(), host, Message{Event: EventDenyWaiting, Payload: DenyWaitingPayload{ClientId: waiting1.ID}})
+		room.handleClientDisconnect(host)
+
+		assert.Empty(t, room.waiting, "Remaining waiting users should be evicted")
+		for _, c := range []*Client{waiting2} {
+			select {
+			case <-c.conn.(*MockConn).CloseCalled:
+			case <-time.After(100 * time.Millisecond):
+				t.Fatalf("waiting client %s connection should be closed", c.ID)
+			}
+		}
+
+		// The evicted and denied clients' read pumps will disconnect them afterwards
+		room.handleClientDisconnect(waiting2)
+		room.handleClientDisconnect(waiting1)
+
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 1, onEmptyCalls, "onEmpty should fire exactly once")
+	})
+
+	t.Run("waiting user leaving while host remains does not trigger cleanup", func(t *testing.T) {
+		onEmptyCalled := make(chan RoomIdType, 1)
+		room := NewTestRoom("test-room", func(id RoomIdType) { onEmptyCalled <- id })
+		host := newTestClient("host-1")
+		waiting := newTestClient("waiting-1")
+		room.handleClientConnect(host)
+		room.handleClientConnect(waiting)
+
+		room.handleClientDisconnect(waiting)
+
+		select {
+		case <-onEmptyCalled:
+			t.Fatal("onEmpty should not fire while a host remains")
+		case <-time.After(20 * time.Millisecond):
+		}
+		assert.Contains(t, room.hosts, host.ID)
+	})
+}
+
+func TestLeaveVersusDisconnect(t *testing.T) {
+	// runPump connects a client with a mock connection to the room and
+	// starts its read pump, returning the connection for driving it.
+	runPump := func(room *Room, client *Client) *MockConn {
+		conn := newMockConn()
+		client.conn = conn
+		client.room = room
+		go client.readPump()
+		return conn
+	}
+
+	// nextEvent returns the next event received by the client.
+	nextEvent := func(t *testing.T, client *Client) Event {
+		t.Helper()
+		select {
+		case raw := <-client.send:
+			var msg Message
+			require.NoError(t, json.Unmarshal(raw, &msg))
+			return msg.Event
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("expected a message")
+			return ""
+		}
+	}
+
+	t.Run("client-initiated leave broadcasts participant_left", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host-1")
+		leaver := newTestClient("participant-1")
+		room.handleClientConnect(host)
+		room.addParticipant(leaver)
+
+		conn := runPump(room, leaver)
+		conn.ReadMessages <- []byte(`{"event":"leave","payload":{}}`)
+
+		select {
+		case <-conn.CloseCalled:
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("leave should close the client's connection")
+		}
+		// The closed socket surfaces as a read error in the pump.
+		close(conn.ReadMessages)
+
+		assert.Equal(t, EventParticipantLeft, nextEvent(t, host))
+		room.mu.RLock()
+		defer room.mu.RUnlock()
+		assert.NotContains(t, room.participants, leaver.ID)
+	})
+
+	t.Run("read error without leave broadcasts disconnect", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("host-1")
+		dropped := newTestClient("participant-1")
+		room.handleClientConnect(host)
+		room.addParticipant(dropped)
+
+		conn := runPump(room, dropped)
+		close(conn.ReadMessages)
+
+		assert.Equal(t, EventDisconnect, nextEvent(t, host))
+		room.mu.RLock()
+		defer room.mu.RUnlock()
+		assert.NotContains(t, room.participants, dropped.ID)
+	})
+}
+
+func TestDesignatedHosts(t *testing.T) {
+	newScheduledRoom := func(hostIds ...ClientIdType) *Room {
+		config := DefaultRoomConfig()
+		config.HostClientIds = map[RoomIdType][]ClientIdType{"test-room": hostIds}
+		return NewRoomWithConfig("test-room", config, nil)
+	}
+
+	t.Run("non-organizer joining first waits until the organizer joins", func(t *testing.T) {
+		room := newScheduledRoom("organizer")
+		early := newTestClient("early-bird")
+		organizer := newTestClient("organizer")
+
+		room.handleClientConnect(early)
+		assert.Empty(t, room.hosts, "A non-organizer should not become host")
+		assert.Contains(t, room.waiting, early.ID)
+		assert.Equal(t, RoleTypeWaiting, early.Role)
+
+		room.handleClientConnect(organizer)
+		assert.Contains(t, room.hosts, organizer.ID)
+		assert.Equal(t, RoleTypeHost, organizer.Role)
+		assert.Contains(t, room.waiting, early.ID, "Early joiner should still be waiting for admission")
+	})
+
+	t.Run("every designated organizer becomes host", func(t *testing.T) {
+		room := newScheduledRoom("organizer-1", "organizer-2")
+		room.handleClientConnect(newTestClient("organizer-1"))
+		room.handleClientConnect(newTestClient("organizer-2"))
+
+		assert.Len(t, room.hosts, 2)
+	})
+
+	t.Run("empty list falls back to first joiner hosting", func(t *testing.T) {
+		room := newScheduledRoom()
+		first := newTestClient("first")
+		room.handleClientConnect(first)
+
+		assert.Contains(t, room.hosts, first.ID)
+	})
+
+	t.Run("each room has its own organizers", func(t *testing.T) {
+		config := DefaultRoomConfig()
+		config.HostClientIds = map[RoomIdType][]ClientIdType{
+			"standup": {"alice"},
+			"review":  {"bob"},
+		}
+		standup := NewRoomWithConfig("standup", config, nil)
+		review := NewRoomWithConfig("review", config, nil)
+		unscheduled := NewRoomWithConfig("unscheduled", config, nil)
+
+		standup.handleClientConnect(newTestClient("bob"))
+		review.handleClientConnect(newTestClient("bob"))
+		unscheduled.handleClientConnect(newTestClient("carol"))
+
+		assert.Empty(t, standup.hosts, "Bob does not organize the standup")
+		assert.Contains(t, review.hosts, ClientIdType("bob"))
+		assert.Contains(t, unscheduled.hosts, ClientIdType("carol"), "Rooms without organizers host their first joiner")
+	})
+}
+
+func TestSingleHostConnection(t *testing.T) {
+	newRoom := func(enforce bool, hostIds ...ClientIdType) *Room {
+		config := DefaultRoomConfig()
+		config.HostClientIds = map[RoomIdType][]ClientIdType{"test-room": hostIds}
+		config.SingleHostConnection = enforce
+		return NewRoomWithConfig("test-room", config, nil)
+	}
+
+	expectRefused := func(t *testing.T, c *Client) {
+		t.Helper()
+		require.Len(t, c.send, 1)
+		var msg struct {
+			Event   Event        `json:"event"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-c.send, &msg))
+		assert.Equal(t, EventError, msg.Event)
+		assert.Equal(t, ErrorCodeHostConnected, msg.Payload.Code)
+	}
+
+	t.Run("a designated host's second tab is refused", func(t *testing.T) {
+		room := newRoom(true, "organizer")
+		first := newTestClient("organizer")
+		second := newTestClient("organizer")
+		guest := newTestClient("guest")
+		room.handleClientConnect(first)
+		room.handleClientConnect(guest)
+
+		room.handleClientConnect(second)
+
+		expectRefused(t, second)
+		assert.Same(t, first, room.hosts["organizer"], "The first connection keeps hosting")
+		assert.Empty(t, second.Role)
+
+		room.router(context.Background(), second, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: guest.ID}})
+		assert.Contains(t, room.waiting, guest.ID, "The refused connection has no host privileges")
+
+		room.handleClientDisconnect(second)
+		assert.Same(t, first, room.hosts["organizer"], "Closing the refused tab leaves the host in place")
+	})
+
+	t.Run("the first joiner's second tab is refused", func(t *testing.T) {
+		room := newRoom(true)
+		first := newTestClient("host")
+		second := newTestClient("host")
+		room.handleClientConnect(first)
+
+		room.handleClientConnect(second)
+
+		expectRefused(t, second)
+		assert.NotContains(t, room.waiting, second.ID)
+		assert.Len(t, room.hosts, 1)
+	})
+
+	t.Run("the host may reconnect once the first connection is gone", func(t *testing.T) {
+		room := newRoom(true, "organizer")
+		room.handleClientConnect(newTestClient("guest"))
+		first := newTestClient("organizer")
+		room.handleClientConnect(first)
+		room.handleClientDisconnect(first)
+
+		again := newTestClient("organizer")
+		room.handleClientConnect(again)
+
+		assert.Same(t, again, room.hosts["organizer"])
+		assert.Equal(t, RoleTypeHost, again.Role)
+	})
+
+	t.Run("without enforcement both tabs host", func(t *testing.T) {
+		room := newRoom(false, "organizer")
+		room.handleClientConnect(newTestClient("organizer"))
+		second := newTestClient("organizer")
+
+		room.handleClientConnect(second)
+
+		assert.Equal(t, RoleTypeHost, second.Role)
+	})
+}
+
+func TestWaitingTimeout(t *testing.T) {
+	newTimedRoom := func(timeout time.Duration) (*Room, *testclock.FakeClock) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultRoomConfig()
+		config.Clock = fakeClock
+		config.WaitingTimeout = timeout
+		return NewRoomWithConfig("test-room", config, nil), fakeClock
+	}
+
+	t.Run("waiting user is timed out and removed after the configured duration", func(t *testing.T) {
+		room, fakeClock := newTimedRoom(time.Minute)
+		host := newTestClient("host-1")
+		waiting := newTestClient("waiting-1")
+		conn := newMockConn()
+		waiting.conn = conn
+		room.handleClientConnect(host)
+		room.handleClientConnect(waiting)
+
+		fakeClock.Step(59 * time.Second)
+		assert.Contains(t, room.waiting, waiting.ID, "Should still be waiting before the timeout")
+
+		fakeClock.Step(time.Second)
+		assert.NotContains(t, room.waiting, waiting.ID)
+		assert.Len(t, conn.CloseCalled, 1, "Timed out client should be disconnected")
+
+		require.Len(t, waiting.send, 1)
+		var msg Message
+		require.NoError(t, json.Unmarshal(<-waiting.send, &msg))
+		assert.Equal(t, EventWaitingTimeout, msg.Event)
+
+		require.Len(t, host.send, 1, "Hosts should be told the waiting list changed")
+		require.NoError(t, json.Unmarshal(<-host.send, &msg))
+		assert.Equal(t, EventWaitingTimeout, msg.Event)
+	})
+
+	t.Run("admitted user is not timed out", func(t *testing.T) {
+		room, fakeClock := newTimedRoom(time.Minute)
+		host := newTestClient("host-1")
+		waiting := newTestClient("waiting-1")
+		conn := newMockConn()
+		waiting.conn = conn
+		room.handleClientConnect(host)
+		room.handleClientConnect(waiting)
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: waiting.ID}})
+		assert.False(t, fakeClock.HasWaiters(), "Admitting should cancel the timer")
+
+		fakeClock.Step(2 * time.Minute)
+		assert.Contains(t, room.participants, waiting.ID)
+		assert.Empty(t, conn.CloseCalled)
+	})
+
+	t.Run("denied user's timer is cancelled", func(t *testing.T) {
+		room, fakeClock := newTimedRoom(time.Minute)
+		host := newTestClient("host-1")
+		waiting := newTestClient("waiting-1")
+		room.handleClientConnect(host)
+		room.handleClientConnect(waiting)
+
+		room.router(context.Background(), host, Message{Event: EventDenyWaiting, Payload: DenyWaitingPayload{ClientId: waiting.ID}})
+		assert.False(t, fakeClock.HasWaiters(), "Denying should cancel the timer")
+	})
+}
+
+func TestHostlessPromotion(t *testing.T) {
+	newHostlessRoom := func(hosts ...ClientIdType) (*Room, *testclock.FakeClock) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultRoomConfig()
+		config.Clock = fakeClock
+		config.HostClientIds = map[RoomIdType][]ClientIdType{"test-room": hosts}
+		config.HostlessGracePeriod = time.Minute
+		config.WaitingTimeout = time.Hour
+		return NewRoomWithConfig("test-room", config, nil), fakeClock
+	}
+
+	t.Run("oldest waiting user is promoted after the grace period", func(t *testing.T) {
+		room, fakeClock := newHostlessRoom("organizer")
+		first := newTestClient("waiting-1")
+		second := newTestClient("waiting-2")
+		room.handleClientConnect(first)
+		fakeClock.Step(30 * time.Second)
+		room.handleClientConnect(second)
+
+		fakeClock.Step(29 * time.Second)
+		assert.Empty(t, room.hosts, "Should not promote before the grace period")
+
+		fakeClock.Step(time.Second)
+		assert.Contains(t, room.hosts, first.ID, "Longest-waiting client should become host")
+		assert.Equal(t, RoleTypeHost, first.Role)
+		assert.Contains(t, room.waiting, second.ID)
+
+		require.Len(t, second.send, 1)
+		var msg Message
+		require.NoError(t, json.Unmarshal(<-second.send, &msg))
+		assert.Equal(t, EventHostPromoted, msg.Event)
+
+		// The promoted host's waiting timeout no longer applies
+		fakeClock.Step(2 * time.Hour)
+		assert.Contains(t, room.hosts, first.ID)
+	})
+
+	t.Run("designated host arriving in time cancels promotion", func(t *testing.T) {
+		room, fakeClock := newHostlessRoom("organizer")
+		waiting := newTestClient("waiting-1")
+		room.handleClientConnect(waiting)
+		room.handleClientConnect(newTestClient("organizer"))
+
+		fakeClock.Step(2 * time.Minute)
+		assert.Contains(t, room.waiting, waiting.ID, "A present host should admit users themselves")
+		assert.Len(t, room.hosts, 1)
+	})
+
+	t.Run("host leaving with waiting users starts the grace period", func(t *testing.T) {
+		room, fakeClock := newHostlessRoom()
+		host := newTestClient("host-1")
+		participant := newTestClient("participant-1")
+		waiting := newTestClient("waiting-1")
+		room.handleClientConnect(host)
+		room.handleClientConnect(participant)
+		room.handleClientConnect(waiting)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: participant.ID}})
+
+		room.handleClientDisconnect(host)
+		assert.Empty(t, room.hosts)
+
+		fakeClock.Step(time.Minute)
+		assert.Contains(t, room.hosts, waiting.ID)
+	})
+
+	t.Run("promotion is disabled by default", func(t *testing.T) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultRoomConfig()
+		config.Clock = fakeClock
+		config.HostClientIds = map[RoomIdType][]ClientIdType{"test-room": {"organizer"}}
+		room := NewRoomWithConfig("test-room", config, nil)
+		room.handleClientConnect(newTestClient("waiting-1"))
+
+		assert.False(t, fakeClock.HasWaiters())
+		assert.Empty(t, room.hosts)
+	})
+}
+
+func TestRoomFeatures(t *testing.T) {
+	newFeatureRoom := func(features RoomFeatures) *Room {
+		config := DefaultRoomConfig()
+		config.Features = &features
+		return NewRoomWithConfig("test-room", config, nil)
+	}
+	without := func(disable func(*RoomFeatures)) RoomFeatures {
+		features := DefaultRoomFeatures()
+		disable(&features)
+		return features
+	}
+	lastError := func(t *testing.T, client *Client) ErrorPayload {
+		t.Helper()
+		require.Len(t, client.send, 1)
+		var msg struct {
+			Event   Event        `json:"event"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-client.send, &msg))
+		assert.Equal(t, EventError, msg.Event)
+		return msg.Payload
+	}
+	chat := func(c *Client) Message {
+		return Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  ClientInfo{ClientId: c.ID, DisplayName: c.DisplayName},
+			ChatId:      "chat-1",
+			Timestamp:   1234567890,
+			ChatContent: "hello",
+		}}
+	}
+	screenshare := func(c *Client) Message {
+		return Message{Event: EventRequestScreenshare, Payload: RequestScreensharePayload{ClientId: c.ID, DisplayName: c.DisplayName}}
+	}
+
+	t.Run("chat enabled", func(t *testing.T) {
+		room := newFeatureRoom(DefaultRoomFeatures())
+		client := newTestClientWithName("p1", "Participant")
+		room.addParticipant(client)
+
+		room.router(context.Background(), client, chat(client))
+		assert.Equal(t, 1, room.chatHistory.Len())
+	})
+
+	t.Run("chat disabled", func(t *testing.T) {
+		room := newFeatureRoom(without(func(f *RoomFeatures) { f.ChatEnabled = false }))
+		client := newTestClientWithName("p1", "Participant")
+		room.addParticipant(client)
+
+		room.router(context.Background(), client, chat(client))
+		assert.Equal(t, 0, room.chatHistory.Len())
+		assert.Equal(t, ErrorCodeFeatureDisabled, lastError(t, client).Code)
+	})
+
+	t.Run("screenshare enabled", func(t *testing.T) {
+		room := newFeatureRoom(DefaultRoomFeatures())
+		host := newTestClientWithName("h1", "Host")
+		client := newTestClientWithName("p1", "Participant")
+		room.addHost(host)
+		room.addParticipant(client)
+
+		room.router(context.Background(), client, screenshare(client))
+		assert.Len(t, host.send, 1, "Host should receive the screenshare request")
+	})
+
+	t.Run("screenshare disabled", func(t *testing.T) {
+		room := newFeatureRoom(without(func(f *RoomFeatures) { f.ScreenshareEnabled = false }))
+		host := newTestClientWithName("h1", "Host")
+		client := newTestClientWithName("p1", "Participant")
+		room.addHost(host)
+		room.addParticipant(client)
+
+		room.router(context.Background(), client, screenshare(client))
+		assert.Empty(t, host.send, "Request should not reach the host")
+		assert.Equal(t, ErrorCodeFeatureDisabled, lastError(t, client).Code)
+	})
+
+	t.Run("reactions disabled", func(t *testing.T) {
+		room := newFeatureRoom(without(func(f *RoomFeatures) { f.ReactionsEnabled = false }))
+		peer := newTestClientWithName("p1", "Peer")
+		client := newTestClientWithName("p2", "Participant")
+		room.addParticipant(peer)
+		room.addParticipant(client)
+
+		room.router(context.Background(), client, Message{Event: EventReaction, Payload: ReactionPayload{Emoji: "👍"}})
+		assert.Empty(t, peer.send, "The reaction should not be broadcast")
+		assert.Equal(t, ErrorCodeFeatureDisabled, lastError(t, client).Code)
+		assert.NotContains(t, room.capabilities(RoleTypeParticipant), EventReaction)
+	})
+
+	t.Run("waiting room enabled", func(t *testing.T) {
+		room := newFeatureRoom(DefaultRoomFeatures())
+		room.handleClientConnect(newTestClient("host-1"))
+		joiner := newTestClient("joiner-1")
+		room.handleClientConnect(joiner)
+
+		assert.Equal(t, RoleTypeWaiting, joiner.Role)
+		assert.Contains(t, room.waiting, joiner.ID)
+	})
+
+	t.Run("waiting room disabled admits joiners directly", func(t *testing.T) {
+		room := newFeatureRoom(without(func(f *RoomFeatures) { f.WaitingRoomEnabled = false }))
+		host := newTestClient("host-1")
+		room.handleClientConnect(host)
+		joiner := newTestClient("joiner-1")
+		room.handleClientConnect(joiner)
+
+		assert.Equal(t, RoleTypeHost, host.Role)
+		assert.Equal(t, RoleTypeParticipant, joiner.Role)
+		assert.Contains(t, room.participants, joiner.ID)
+		assert.Empty(t, room.waiting)
+	})
+
+	t.Run("waiting room disabled still holds joiners when full", func(t *testing.T) {
+		features := without(func(f *RoomFeatures) { f.WaitingRoomEnabled = false })
+		config := DefaultRoomConfig()
+		config.Features = &features
+		config.MaxParticipants = 1
+		room := NewRoomWithConfig("test-room", config, nil)
+		room.handleClientConnect(newTestClient("host-1"))
+		joiner := newTestClient("joiner-1")
+		room.handleClientConnect(joiner)
+
+		assert.Equal(t, RoleTypeWaiting, joiner.Role)
+	})
+
+	t.Run("nil features enable everything", func(t *testing.T) {
+		room := NewRoomWithConfig("test-room", DefaultRoomConfig(), nil)
+		assert.Equal(t, DefaultRoomFeatures(), room.features)
+	})
+}
+
+func TestMediaStateSnapshot(t *testing.T) {
+	// snapshot reads the joiner's messages and returns the media state snapshot.
+	snapshot := func(t *testing.T, client *Client) []PeerMediaState {
+		t.Helper()
+		for len(client.send) > 0 {
+			var msg struct {
+				Event   Event                     `json:"event"`
+				Payload MediaStateSnapshotPayload `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(<-client.send, &msg))
+			if msg.Event == EventMediaStateSnapshot {
+				return msg.Payload.Peers
+			}
+		}
+		t.Fatal("No media state snapshot was sent")
+		return nil
+	}
+
+	t.Run("admitted client receives every peer's media state", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host-1", "Host")
+		talker := newTestClientWithName("talker-1", "Talker")
+		sharer := newTestClientWithName("sharer-1", "Sharer")
+		joiner := newTestClientWithName("joiner-1", "Joiner")
+		room.handleClientConnect(host)
+		room.handleClientConnect(talker)
+		room.handleClientConnect(sharer)
+		room.handleClientConnect(joiner)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: talker.ID}})
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: sharer.ID}})
+		require.NoError(t, room.transitionRole(sharer, RoleTypeParticipant, RoleTypeScreenshare))
+		room.cameraOn[host.ID] = host
+		room.unmuted[talker.ID] = talker
+		room.cameraOn[talker.ID] = talker
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: joiner.ID}})
+
+		assert.Equal(t, []PeerMediaState{
+			{ClientInfo: host.info(), CameraOn: true},
+			{ClientInfo: talker.info(), CameraOn: true, Unmuted: true},
+			{ClientInfo: sharer.info(), SharingScreen: true},
+		}, snapshot(t, joiner))
+	})
+
+	t.Run("waiting clients are not listed or sent a snapshot", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host-1", "Host")
+		waiting := newTestClientWithName("waiting-1", "Waiting")
+		joiner := newTestClientWithName("joiner-1", "Joiner")
+		room.handleClientConnect(host)
+		room.handleClientConnect(waiting)
+		room.handleClientConnect(joiner)
+		assert.Empty(t, waiting.send)
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: joiner.ID}})
+
+		assert.Equal(t, []PeerMediaState{{ClientInfo: host.info()}}, snapshot(t, joiner))
+	})
+
+	t.Run("direct admission sends a snapshot", func(t *testing.T) {
+		config := DefaultRoomConfig()
+		config.Features = &RoomFeatures{WaitingRoomEnabled: false}
+		room := NewRoomWithConfig("test-room", config, nil)
+		host := newTestClientWithName("host-1", "Host")
+		joiner := newTestClientWithName("joiner-1", "Joiner")
+		room.handleClientConnect(host)
+		room.unmuted[host.ID] = host
+
+		room.handleClientConnect(joiner)
+
+		assert.Equal(t, []PeerMediaState{{ClientInfo: host.info(), Unmuted: true}}, snapshot(t, joiner))
+	})
+
+	t.Run("reported media state is broadcast and included in snapshots", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClientWithName("host-1", "Host")
+		talker := newTestClientWithName("talker-1", "Talker")
+		joiner := newTestClientWithName("joiner-1", "Joiner")
+		room.addHost(host)
+		room.addParticipant(talker)
+		room.addWaiting(joiner)
+
+		report := Message{Event: EventMediaState, Payload: MediaStatePayload{ClientInfo: ClientInfo{ClientId: "someone-else"}, Unmuted: true, CameraOn: true}}
+		room.router(context.Background(), talker, report)
+		room.router(context.Background(), talker, report)
+
+		require.Len(t, host.send, 1, "A report that changes nothing is not broadcast")
+		var msg struct {
+			Event   Event             `json:"event"`
+			Payload MediaStatePayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-host.send, &msg))
+		assert.Equal(t, EventMediaState, msg.Event)
+		assert.Equal(t, MediaStatePayload{ClientInfo: talker.info(), Unmuted: true, CameraOn: true}, msg.Payload, "The report describes its sender")
+		assert.Empty(t, joiner.send, "Waiting clients are not told")
+
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: joiner.ID}})
+		assert.Equal(t, []PeerMediaState{
+			{ClientInfo: host.info()},
+			{ClientInfo: talker.info(), CameraOn: true, Unmuted: true},
+		}, snapshot(t, joiner))
+
+		room.router(context.Background(), talker, Message{Event: EventMediaState, Payload: MediaStatePayload{}})
+		assert.NotContains(t, room.unmuted, talker.ID)
+		assert.NotContains(t, room.cameraOn, talker.ID)
+	})
+}
+
+func TestUniqueDisplayNames(t *testing.T) {
+	newRoom := func(unique bool) *Room {
+		config := DefaultRoomConfig()
+		config.UniqueDisplayNames = unique
+		config.Features = &RoomFeatures{WaitingRoomEnabled: false}
+		return NewRoomWithConfig("test-room", config, nil)
+	}
+	names := func(infos []ClientInfo) []DisplayNameType {
+		out := make([]DisplayNameType, 0, len(infos))
+		for _, info := range infos {
+			out = append(out, info.DisplayName)
+		}
+		return out
+	}
+
+	t.Run("duplicate names are suffixed in the roster", func(t *testing.T) {
+		room := newRoom(true)
+		room.handleClientConnect(newTestClientWithName("host-1", "Host"))
+		first := newTestClientWithName("alex-1", "Alex")
+		second := newTestClientWithName("alex-2", "Alex")
+		third := newTestClientWithName("alex-3", "Alex")
+		room.handleClientConnect(first)
+		room.handleClientConnect(second)
+		room.handleClientConnect(third)
+
+		assert.Equal(t, []DisplayNameType{"Alex", "Alex (2)", "Alex (3)"}, names(room.getRoomState().Participants))
+		assert.Equal(t, ClientIdType("alex-2"), second.ID, "Client ids are unchanged")
+	})
+
+	t.Run("freed suffixes are reused", func(t *testing.T) {
+		room := newRoom(true)
+		room.handleClientConnect(newTestClientWithName("host-1", "Host"))
+		first := newTestClientWithName("alex-1", "Alex")
+		second := newTestClientWithName("alex-2", "Alex")
+		room.handleClientConnect(first)
+		room.handleClientConnect(second)
+		room.handleClientDisconnect(second)
+
+		third := newTestClientWithName("alex-3", "Alex")
+		room.handleClientConnect(third)
+		assert.Equal(t, DisplayNameType("Alex (2)"), third.DisplayName)
+	})
+
+	t.Run("names in the waiting room count as taken", func(t *testing.T) {
+		config := DefaultRoomConfig()
+		config.UniqueDisplayNames = true
+		room := NewRoomWithConfig("test-room", config, nil)
+		room.handleClientConnect(newTestClientWithName("host-1", "Host"))
+		room.handleClientConnect(newTestClientWithName("alex-1", "Alex"))
+		second := newTestClientWithName("alex-2", "Alex")
+		room.handleClientConnect(second)
+
+		assert.Equal(t, DisplayNameType("Alex (2)"), second.DisplayName)
+	})
+
+	t.Run("duplicates are kept when disabled", func(t *testing.T) {
+		room := newRoom(false)
+		room.handleClientConnect(newTestClientWithName("host-1", "Host"))
+		room.handleClientConnect(newTestClientWithName("alex-1", "Alex"))
+		room.handleClientConnect(newTestClientWithName("alex-2", "Alex"))
+
+		assert.Equal(t, []DisplayNameType{"Alex", "Alex"}, names(room.getRoomState().Participants))
+	})
+}
+
+func TestEndpointScopes(t *testing.T) {
+	newScopedRoom := func(allowed set.Set[Event]) *Room {
+		config := DefaultRoomConfig()
+		config.AllowedEvents = allowed
+		return NewRoomWithConfig("test-room", config, nil)
+	}
+
+	offer := func(sender *Client, target ClientIdType) Message {
+		return Message{Event: EventOffer, Payload: WebRTCOfferPayload{
+			ClientInfo:     ClientInfo{ClientId: sender.ID, DisplayName: sender.DisplayName},
+			TargetClientId: target,
+			SDP:            "v=0...",
+			Type:           "offer",
+		}}
+	}
+
+	t.Run("WebRTC offer on the chat endpoint is rejected with an error", func(t *testing.T) {
+		room := newScopedRoom(ChatEndpointEvents())
+		sender := newTestClientWithName("sender1", "Sender")
+		target := newTestClientWithName("target1", "Target")
+		room.addParticipant(sender)
+		room.addParticipant(target)
+
+		room.router(context.Background(), sender, offer(sender, target.ID))
+
+		assert.Empty(t, target.send, "Offer should not be forwarded")
+		require.Len(t, sender.send, 1)
+		var msg struct {
+			Event   Event        `json:"event"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-sender.send, &msg))
+		assert.Equal(t, EventError, msg.Event)
+		assert.Equal(t, ErrorCodeEventNotAllowed, msg.Payload.Code)
+		assert.Equal(t, EventOffer, msg.Payload.Event)
+	})
+
+	t.Run("chat on the chat endpoint is processed", func(t *testing.T) {
+		room := newScopedRoom(ChatEndpointEvents())
+		client := newTestClientWithName("participant1", "John Doe")
+		room.addParticipant(client)
+
+		room.router(context.Background(), client, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+			ChatId:      "chat-1",
+			ChatContent: "hello",
+		}})
+
+		assert.Equal(t, 1, room.chatHistory.Len())
+	})
+
+	t.Run("chat on the media endpoint is rejected", func(t *testing.T) {
+		room := newScopedRoom(MediaEndpointEvents())
+		client := newTestClientWithName("participant1", "John Doe")
+		room.addParticipant(client)
+
+		room.router(context.Background(), client, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+			ChatId:      "chat-1",
+			ChatContent: "hello",
+		}})
+
+		assert.Equal(t, 0, room.chatHistory.Len())
+	})
+
+	t.Run("every scope allows the waiting room and leaving", func(t *testing.T) {
+		for _, scope := range []set.Set[Event]{ChatEndpointEvents(), MediaEndpointEvents(), ScreenshareEndpointEvents()} {
+			assert.True(t, scope.HasAll(EventRequestWaiting, EventAcceptWaiting, EventDenyWaiting, EventLeave))
+		}
+	})
+}
+
+func TestStableRosterOrder(t *testing.T) {
+	ids := func(clients []*Client) []ClientIdType {
+		out := make([]ClientIdType, len(clients))
+		for i, c := range clients {
+			out[i] = c.ID
+		}
+		return out
+	}
+	infoIds := func(infos []ClientInfo) []ClientIdType {
+		out := make([]ClientIdType, len(infos))
+		for i, c := range infos {
+			out[i] = c.ClientId
+		}
+		return out
+	}
+	// drawOrder returns the IDs in the client draw order queue, front to back.
+	drawOrder := func(room *Room) []ClientIdType {
+		var out []ClientIdType
+		for e := room.clientDrawOrderQueue.Front(); e != nil; e = e.Next() {
+			out = append(out, e.Value.(*Client).ID)
+		}
+		return out
+	}
+
+	t.Run("roster follows join and draw order, not map order", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		host := newTestClient("zz-host")
+		room.handleClientConnect(host)
+
+		// IDs deliberately sort differently from join order
+		joined := []*Client{newTestClient("mike"), newTestClient("alpha"), newTestClient("zulu"), newTestClient("bravo")}
+		for _, c := range joined {
+			room.handleClientConnect(c)
+		}
+		for _, c := range joined {
+			require.NoError(t, room.transitionRole(c, RoleTypeWaiting, RoleTypeParticipant))
+		}
+
+		want := ids(joined)
+		for i := 0; i < 20; i++ {
+			assert.Equal(t, want, ids(clientsMapToSlice(room.participants)))
+			assert.Equal(t, want, infoIds(room.getRoomState().Participants))
+		}
+		assert.Equal(t, append([]ClientIdType{host.ID}, want...), drawOrder(room),
+			"Join order should match the draw-order queue when admitted in order")
+	})
+
+	t.Run("waiting users are listed in join order", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		room.handleClientConnect(newTestClient("host"))
+		waiting := []*Client{newTestClient("c"), newTestClient("a"), newTestClient("b")}
+		for _, c := range waiting {
+			room.handleClientConnect(c)
+		}
+
+		assert.Equal(t, ids(waiting), infoIds(room.getRoomState().WaitingUsers))
+	})
+
+	t.Run("raised hands are listed in the order they were raised", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		raised := []*Client{newTestClient("c"), newTestClient("a"), newTestClient("b")}
+		for _, c := range raised {
+			room.addParticipant(c)
+		}
+		for _, c := range raised {
+			room.raiseHand(RaiseHandPayload{ClientId: c.ID})
+		}
+
+		assert.Equal(t, ids(raised), infoIds(room.getRoomState().HandsRaised))
+	})
+}
+
+func TestRouteResult(t *testing.T) {
+	chat := func(c *Client) AddChatPayload {
+		return AddChatPayload{
+			ClientInfo:  ClientInfo{ClientId: c.ID, DisplayName: c.DisplayName},
+			ChatId:      "chat-1",
+			Timestamp:   1234567890,
+			ChatContent: "hello",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		role    RoleType
+		allowed set.Set[Event]
+		data    func(c *Client) any
+		want    routeResult
+	}{
+		{
+			name: "permitted event is handled",
+			role: RoleTypeParticipant,
+			data: func(c *Client) any { return Message{Event: EventAddChat, Payload: chat(c)} },
+			want: routeHandled,
+		},
+		{
+			name: "non-message data is malformed",
+			role: RoleTypeParticipant,
+			data: func(c *Client) any { return "not a message" },
+			want: routeMalformed,
+		},
+		{
+			name: "unknown event",
+			role: RoleTypeParticipant,
+			data: func(c *Client) any { return Message{Event: "no_such_event"} },
+			want: routeUnknownEvent,
+		},
+		{
+			name:    "event outside endpoint scope",
+			role:    RoleTypeParticipant,
+			allowed: MediaEndpointEvents(),
+			data:    func(c *Client) any { return Message{Event: EventAddChat, Payload: chat(c)} },
+			want:    routeOutOfScope,
+		},
+		{
+			name: "waiting client cannot chat",
+			role: RoleTypeWaiting,
+			data: func(c *Client) any { return Message{Event: EventAddChat, Payload: chat(c)} },
+			want: routeNotAdmitted,
+		},
+		{
+			name: "spectator cannot chat",
+			role: RoleTypeSpectator,
+			data: func(c *Client) any { return Message{Event: EventAddChat, Payload: chat(c)} },
+			want: routePermissionDenied,
+		},
+		{
+			name: "payload of the wrong type",
+			role: RoleTypeParticipant,
+			data: func(c *Client) any { return Message{Event: EventAddChat, Payload: RaiseHandPayload{}} },
+			want: routeInvalidPayload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultRoomConfig()
+			config.AllowedEvents = tt.allowed
+			room := NewRoomWithConfig("test-room", config, nil)
+			client := newTestClientWithName("c1", "Client")
+			client.Role = tt.role
+
+			room.mu.Lock()
+			result, err := room.route(context.Background(), client, tt.data(client))
+			room.mu.Unlock()
+
+			assert.Equal(t, tt.want, result, "got %s", result)
+			if tt.want == routeHandled {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+
+	t.Run("invalid payload never reaches the handler", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		client := newTestClientWithName("c1", "Client")
+		room.addParticipant(client)
+
+		room.router(context.Background(), client, Message{Event: EventAddChat, Payload: RaiseHandPayload{}})
+
+		assert.Equal(t, 0, room.chatHistory.Len())
+	})
+}
+
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	config := DefaultRoomConfig()
+	config.MaxChatHistory = 1000
+	room := NewRoomWithConfig("test-room", config, nil)
+
+	const clients, messages = 4, 50
+	writers := make([]*Client, clients)
+	readers := make([]*Client, clients)
+	for i := range clients {
+		writers[i] = newTestClientWithName(ClientIdType(fmt.Sprintf("writer-%d", i)), "Writer")
+		readers[i] = newTestClientWithName(ClientIdType(fmt.Sprintf("reader-%d", i)), "Reader")
+		writers[i].send = make(chan []byte, clients*messages*2)
+		readers[i].send = make(chan []byte, clients*messages*2)
+		room.addParticipant(writers[i])
+		room.addParticipant(readers[i])
+	}
+
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(2)
+		go func(writer *Client) {
+			defer wg.Done()
+			for n := range messages {
+				room.router(context.Background(), writer, Message{Event: EventAddChat, Payload: AddChatPayload{
+					ClientInfo:  ClientInfo{ClientId: writer.ID, DisplayName: writer.DisplayName},
+					ChatContent: ChatContent(fmt.Sprintf("message %03d", n)),
+				}})
+			}
+		}(writers[i])
+		go func(reader *Client) {
+			defer wg.Done()
+			for range messages {
+				room.router(context.Background(), reader, Message{Event: EventGetRecentChats, Payload: GetRecentChatsPayload{
+					ClientInfo: ClientInfo{ClientId: reader.ID, DisplayName: reader.DisplayName},
+				}})
+			}
+		}(readers[i])
+	}
+	wg.Wait()
+
+	for _, reader := range readers {
+		replies := 0
+		for len(reader.send) > 0 {
+			var msg Message
+			require.NoError(t, json.Unmarshal(<-reader.send, &msg))
+			if msg.Event == EventGetRecentChats {
+				replies++
+			}
+		}
+		assert.Equal(t, messages, replies, "Every query should be answered")
+	}
+
+	// Each writer's messages are stored in the order it sent them
+	history := room.getChatHistory()
+	require.Len(t, history, clients*messages)
+	last := map[ClientIdType]ChatContent{}
+	for _, chat := range history {
+		assert.Greater(t, chat.ChatContent, last[chat.ClientId])
+		last[chat.ClientId] = chat.ChatContent
+	}
+}
+
+// BenchmarkRouterQueries measures chat history reads from many clients at once.
+// "read_lock" is the router's query path; "write_lock" routes the same query
+// under the write lock, as every event was before query events were split out.
+func BenchmarkRouterQueries(b *testing.B) {
+	newRoom := func(b *testing.B) *Room {
+		config := DefaultRoomConfig()
+		config.HandlerLog.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		room := NewRoomWithConfig("bench-room", config, nil)
+		for i := range config.MaxChatHistory {
+			room.addChat(AddChatPayload{ChatId: ChatId(fmt.Sprintf("chat-%d", i)), ChatContent: "message"})
+		}
+		return room
+	}
+
+	var clientCount atomic.Int64
+	newClient := func(room *Room) *Client {
+		client := newTestClientWithName(ClientIdType(fmt.Sprintf("reader-%d", clientCount.Add(1))), "Reader")
+		room.mu.Lock()
+		room.addParticipant(client)
+		room.mu.Unlock()
+		return client
+	}
+	query := func(client *Client) Message {
+		return Message{Event: EventGetRecentChats, Payload: GetRecentChatsPayload{
+			ClientInfo: ClientInfo{ClientId: client.ID, DisplayName: client.DisplayName},
+		}}
+	}
+
+	b.Run("read_lock", func(b *testing.B) {
+		room := newRoom(b)
+		b.RunParallel(func(pb *testing.PB) {
+			client := newClient(room)
+			msg := query(client)
+			for pb.Next() {
+				room.router(context.Background(), client, msg)
+				<-client.send
+			}
+		})
+	})
+
+	b.Run("write_lock", func(b *testing.B) {
+		room := newRoom(b)
+		b.RunParallel(func(pb *testing.PB) {
+			client := newClient(room)
+			msg := query(client)
+			for pb.Next() {
+				room.mu.Lock()
+				_, _ = room.route(context.Background(), client, msg)
+				room.mu.Unlock()
+				<-client.send
+			}
+		})
+	})
+}
+
+// BenchmarkRouterMixedTraffic measures chat writes while other clients fetch
+// the full chat history, reporting the 99th percentile write latency.
+// "bounded" limits concurrent queries to the default; "unbounded" lets every
+// reader take the read lock at once.
+func BenchmarkRouterMixedTraffic(b *testing.B) {
+	run := func(b *testing.B, maxQueries int) {
+		config := DefaultRoomConfig()
+		config.HandlerLog.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		config.DuplicateEventWindow = 0
+		config.MaxConcurrentQueries = maxQueries
+		room := NewRoomWithConfig("bench-room", config, nil)
+		for i := range config.MaxChatHistory {
+			room.addChat(AddChatPayload{ChatId: ChatId(fmt.Sprintf("chat-%d", i)), ChatContent: "message"})
+		}
+
+		// Readers fetch history until the writer is done.
+		ctx, cancel := context.WithCancel(context.Background())
+		var wg sync.WaitGroup
+		for i := range 4 * runtime.GOMAXPROCS(0) {
+			reader := newTestClientWithName(ClientIdType(fmt.Sprintf("reader-%d", i)), "Reader")
+			room.mu.Lock()
+			room.addParticipant(reader)
+			room.mu.Unlock()
+			query := Message{Event: EventGetRecentChats, Payload: GetRecentChatsPayload{ClientInfo: reader.info()}}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ctx.Err() == nil {
+					room.router(ctx, reader, query)
+					for len(reader.send) > 0 {
+						<-reader.send
+					}
+				}
+			}()
+		}
+
+		writer := newTestClientWithName("writer", "Writer")
+		room.mu.Lock()
+		room.addHost(writer)
+		room.mu.Unlock()
+		go func() {
+			for ctx.Err() == nil {
+				for len(writer.send) > 0 {
+					<-writer.send
+				}
+				runtime.Gosched()
+			}
+		}()
+
+		latencies := make([]time.Duration, 0, b.N)
+		b.ResetTimer()
+		for i := range b.N {
+			msg := Message{Event: EventAddChat, Payload: AddChatPayload{
+				ClientInfo:  writer.info(),
+				ChatId:      ChatId(fmt.Sprintf("write-%d", i)),
+				ChatContent: "message",
+			}}
+			start := time.Now()
+			room.router(context.Background(), writer, msg)
+			latencies = append(latencies, time.Since(start))
+		}
+		b.StopTimer()
+		cancel()
+		wg.Wait()
+
+		slices.Sort(latencies)
+		b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-write-ns")
+	}
+
+	b.Run("bounded", func(b *testing.B) { run(b, DefaultRoomConfig().MaxConcurrentQueries) })
+	b.Run("unbounded", func(b *testing.B) { run(b, 0) })
+}
+
+func TestMaxConcurrentQueries(t *testing.T) {
+	setup := func() (*Room, *Client) {
+		config := DefaultRoomConfig()
+		config.DuplicateEventWindow = 0
+		room := NewRoomWithConfig("test-room", config, nil)
+		reader := newTestClient("reader")
+		room.addParticipant(reader)
+		// Occupy every slot, as if other queries were in progress.
+		for range cap(room.querySlots) {
+			room.querySlots <- struct{}{}
+		}
+		return room, reader
+	}
+	query := func(c *Client) Message {
+		return Message{Event: EventGetRecentChats, Payload: GetRecentChatsPayload{ClientInfo: c.info()}}
+	}
+
+	t.Run("queries wait for a free slot", func(t *testing.T) {
+		room, reader := setup()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			room.router(context.Background(), reader, query(reader))
+		}()
+		select {
+		case <-done:
+			t.Fatal("query ran with every slot taken")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		room.releaseQuerySlot()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("query did not run once a slot was free")
+		}
+		assert.Len(t, reader.send, 1, "The query is answered")
+	})
+
+	t.Run("writes do not wait for query slots", func(t *testing.T) {
+		room, reader := setup()
+
+		room.router(context.Background(), reader, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(reader.info())})
+
+		assert.Contains(t, room.raisingHand, reader.ID)
+	})
+
+	t.Run("a query is dropped if its client leaves while waiting", func(t *testing.T) {
+		room, reader := setup()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		room.router(ctx, reader, query(reader))
+
+		assert.Empty(t, reader.send)
+		room.releaseQuerySlot()
+		assert.Len(t, room.querySlots, cap(room.querySlots)-1, "The dropped query holds no slot")
+	})
+
+	t.Run("zero is unlimited", func(t *testing.T) {
+		config := DefaultRoomConfig()
+		config.MaxConcurrentQueries = 0
+		room := NewRoomWithConfig("test-room", config, nil)
+		reader := newTestClient("reader")
+		room.addParticipant(reader)
+
+		room.router(context.Background(), reader, query(reader))
+
+		assert.Nil(t, room.querySlots)
+		assert.Len(t, reader.send, 1)
+	})
+}
+
+func TestReconnectWindow(t *testing.T) {
+	// newMeeting creates a room with a host and an admitted participant.
+	newMeeting := func() (*Room, *testclock.FakeClock, *Client, *Client) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultRoomConfig()
+		config.Clock = fakeClock
+		config.ReconnectWindow = time.Minute
+		room := NewRoomWithConfig("test-room", config, nil)
+		host := newTestClient("host")
+		participant := newTestClient("participant")
+		room.handleClientConnect(host)
+		room.handleClientConnect(participant)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: participant.ID}})
+		return room, fakeClock, host, participant
+	}
+
+	t.Run("a dropped participant reconnecting within the window skips the waiting room", func(t *testing.T) {
+		room, fakeClock, _, participant := newMeeting()
+		room.handleClientDisconnect(participant)
+
+		fakeClock.Step(30 * time.Second)
+		rejoined := newTestClient(participant.ID)
+		room.handleClientConnect(rejoined)
+
+		assert.Equal(t, RoleTypeParticipant, rejoined.Role)
+		assert.Contains(t, room.participants, rejoined.ID)
+		assert.Empty(t, room.recentlyDisconnected, "The record is consumed on reconnect")
+	})
+
+	t.Run("a participant reconnecting to a room that filled up waits", func(t *testing.T) {
+		room, _, host, participant := newMeeting()
+		room.config.MaxParticipants = 2
+		room.handleClientDisconnect(participant)
+
+		replacement := newTestClient("replacement")
+		room.handleClientConnect(replacement)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: replacement.ID}})
+		require.Equal(t, RoleTypeParticipant, replacement.Role)
+
+		rejoined := newTestClient(participant.ID)
+		room.handleClientConnect(rejoined)
+
+		assert.Equal(t, RoleTypeWaiting, rejoined.Role)
+		assert.Contains(t, room.waiting, rejoined.ID)
+		assert.Len(t, room.participants, 1, "The room stays within capacity")
+	})
+
+	t.Run("a dropped host gets host back", func(t *testing.T) {
+		room, _, host, _ := newMeeting()
+		room.handleClientDisconnect(host)
+
+		rejoined := newTestClient(host.ID)
+		room.handleClientConnect(rejoined)
+		assert.Equal(t, RoleTypeHost, rejoined.Role)
+	})
+
+	t.Run("an expired entry is not honored and is removed", func(t *testing.T) {
+		room, fakeClock, _, participant := newMeeting()
+		room.handleClientDisconnect(participant)
+		require.Contains(t, room.recentlyDisconnected, participant.ID)
+
+		fakeClock.Step(time.Minute + time.Second)
+		rejoined := newTestClient(participant.ID)
+		room.handleClientConnect(rejoined)
+
+		assert.Equal(t, RoleTypeWaiting, rejoined.Role)
+		assert.Empty(t, room.recentlyDisconnected)
+	})
+
+	t.Run("expired entries are purged as others disconnect", func(t *testing.T) {
+		room, fakeClock, host, participant := newMeeting()
+		other := newTestClient("other")
+		room.handleClientConnect(other)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: other.ID}})
+
+		room.handleClientDisconnect(participant)
+		fakeClock.Step(2 * time.Minute)
+		room.handleClientDisconnect(other)
+
+		assert.NotContains(t, room.recentlyDisconnected, participant.ID)
+		assert.Contains(t, room.recentlyDisconnected, other.ID)
+	})
+
+	t.Run("a graceful leave is not remembered", func(t *testing.T) {
+		room, _, _, participant := newMeeting()
+		participant.leaving = true
+		room.handleClientDisconnect(participant)
+
+		assert.Empty(t, room.recentlyDisconnected)
+	})
+}
+
+func TestRoomClose(t *testing.T) {
+	fakeClock := testclock.NewFakeClock(time.Now())
+	config := DefaultRoomConfig()
+	config.Clock = fakeClock
+	config.HostClientIds = map[RoomIdType][]ClientIdType{"test-room": {"organizer"}}
+	config.HostlessGracePeriod = time.Minute
+	config.WaitingTimeout = time.Minute
+	config.CandidateBatchWindow = time.Minute
+	config.IdleTimeout = time.Minute
+	room := NewRoomWithConfig("test-room", config, nil)
+
+	waiting := newTestClient("waiting-1")
+	room.handleClientConnect(waiting)
+	sender := newTestClientWithName("sender", "Sender")
+	target := newTestClientWithName("target", "Target")
+	room.addParticipant(sender)
+	room.addParticipant(target)
+	room.router(context.Background(), sender, Message{Event: EventCandidate, Payload: WebRTCCandidatePayload{
+		ClientInfo: sender.info(), TargetClientId: target.ID, Candidate: "candidate:1",
+	}})
+	require.True(t, fakeClock.HasWaiters(), "Precondition: the room has pending timers")
+
+	room.close()
+	room.close()
+
+	assert.Error(t, room.ctx.Err(), "The room context is cancelled")
+	assert.False(t, fakeClock.HasWaiters(), "Every room timer is stopped")
+
+	fakeClock.Step(time.Hour)
+	assert.Contains(t, room.waiting, waiting.ID, "No waiting timeout or hostless promotion fires")
+	assert.Empty(t, target.send, "No candidate batch is delivered")
+}
+
+func TestRoomAfterFuncSkipsClosedRoom(t *testing.T) {
+	fakeClock := testclock.NewFakeClock(time.Now())
+	config := DefaultRoomConfig()
+	config.Clock = fakeClock
+	room := NewRoomWithConfig("test-room", config, nil)
+
+	var fired atomic.Bool
+	room.afterFunc(time.Second, func() { fired.Store(true) })
+	room.cancel()
+	fakeClock.Step(time.Second)
+
+	assert.False(t, fired.Load(), "A timer that was not stopped still does nothing once the room is closed")
+}
+
+func TestRestoreSession(t *testing.T) {
+	// restore sends a restore request from c and returns the reply.
+	restore := func(t *testing.T, room *Room, c *Client, draft string) SessionRestoredPayload {
+		t.Helper()
+		for len(c.send) > 0 {
+			<-c.send
+		}
+		room.router(context.Background(), c, Message{Event: EventRestoreSession, Payload: RestoreSessionPayload{Draft: draft}})
+		require.Len(t, c.send, 1)
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-c.send, &msg))
+		require.Equal(t, EventRestoreSession, msg.Event)
+		var restored SessionRestoredPayload
+		require.NoError(t, json.Unmarshal(msg.Payload, &restored))
+		return restored
+	}
+
+	newMeeting := func() (*Room, *Client, *Client) {
+		config := DefaultRoomConfig()
+		config.ReconnectWindow = time.Minute
+		room := NewRoomWithConfig("test-room", config, nil)
+		host := newTestClientWithName("host", "Host")
+		participant := newTestClient("participant")
+		room.handleClientConnect(host)
+		room.handleClientConnect(participant)
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: participant.ID}})
+		room.router(context.Background(), host, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: host.info(), ChatContent: "before the drop"}})
+		return room, host, participant
+	}
+
+	t.Run("a reconnected participant gets its role, the state and recent chats at once", func(t *testing.T) {
+		room, host, participant := newMeeting()
+		defer room.close()
+		room.handleClientDisconnect(participant)
+		rejoined := newTestClient(participant.ID)
+		room.handleClientConnect(rejoined)
+
+		restored := restore(t, room, rejoined, "half a thought")
+
+		assert.Equal(t, RoleTypeParticipant, restored.Role)
+		assert.Contains(t, restored.Capabilities, EventAddChat)
+		assert.Equal(t, []ClientInfo{host.info()}, restored.State.Hosts)
+		assert.Equal(t, []ClientInfo{rejoined.info()}, restored.State.Participants)
+		require.Len(t, restored.RecentChats, 1)
+		assert.Equal(t, ChatContent("before the drop"), restored.RecentChats[0].ChatContent)
+		assert.Equal(t, "half a thought", restored.Draft)
+	})
+
+	t.Run("the draft is dropped when the client may no longer chat", func(t *testing.T) {
+		room, host, participant := newMeeting()
+		defer room.close()
+		room.router(context.Background(), host, Message{Event: EventSetPolicy, Payload: SetPolicyPayload{ParticipantPolicy: ParticipantPolicy{}}})
+
+		restored := restore(t, room, participant, "half a thought")
+
+		assert.NotContains(t, restored.Capabilities, EventAddChat)
+		assert.Empty(t, restored.Draft)
+		assert.Len(t, restored.RecentChats, 1, "History is still readable")
+	})
+
+	t.Run("waiting clients cannot restore a session", func(t *testing.T) {
+		room, _, _ := newMeeting()
+		defer room.close()
+		waiting := newTestClient("waiting")
+		room.handleClientConnect(waiting)
+
+		result, err := room.route(context.Background(), waiting, Message{Event: EventRestoreSession, Payload: RestoreSessionPayload{}})
+		assert.Error(t, err)
+		assert.Equal(t, routeNotAdmitted, result)
+	})
+}
+
+func TestRoomWithoutOnEmptyClosesItself(t *testing.T) {
+	closed := make(chan RoomStats, 1)
+	config := DefaultRoomConfig()
+	config.OnClose = func(stats RoomStats) { closed <- stats }
+	config.IdleTimeout = time.Hour
+	room := NewRoomWithConfig("test-room", config, nil)
+	host := newTestClient("host")
+	room.handleClientConnect(host)
+	room.handleClientDisconnect(host)
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		require.Fail(t, "OnClose was not called when the room emptied")
+	}
+	assert.Eventually(t, func() bool {
+		room.mu.RLock()
+		defer room.mu.RUnlock()
+		return room.closed
+	}, time.Second, 10*time.Millisecond, "The room closes itself when nothing will remove it")
+	assert.Error(t, room.ctx.Err(), "Background work is cancelled")
+	assert.False(t, room.handleClientConnect(newTestClient("late")), "A closed room turns joiners away")
+}
+
+func TestMaxPayloadSizes(t *testing.T) {
+	setup := func() (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+		return room, alice, bob
+	}
+
+	// rejection returns the error c was sent, if any.
+	rejection := func(t *testing.T, c *Client) ErrorPayload {
+		t.Helper()
+		require.Len(t, c.send, 1)
+		var msg struct {
+			Event   Event        `json:"event"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-c.send, &msg))
+		require.Equal(t, EventError, msg.Event)
+		return msg.Payload
+	}
+
+	t.Run("an oversized chat is rejected", func(t *testing.T) {
+		room, alice, bob := setup()
+
+		result, err := room.route(context.Background(), alice, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  alice.info(),
+			ChatContent: ChatContent(strings.Repeat("a", 10<<10)),
+		}})
+
+		assert.Error(t, err)
+		assert.Equal(t, routeInvalidPayload, result)
+		assert.Equal(t, ErrorCodePayloadTooLarge, rejection(t, alice).Code)
+		assert.Zero(t, room.chatHistory.Len())
+		assert.Zero(t, len(bob.send))
+	})
+
+	t.Run("an oversized reaction is rejected", func(t *testing.T) {
+		room, alice, bob := setup()
+
+		result, _ := room.route(context.Background(), alice, Message{Event: EventReaction, Payload: ReactionPayload{
+			ClientInfo: alice.info(),
+			Emoji:      strings.Repeat("👍", 512),
+		}})
+
+		assert.Equal(t, routeInvalidPayload, result)
+		assert.Equal(t, ErrorCodePayloadTooLarge, rejection(t, alice).Code)
+		assert.Zero(t, len(bob.send))
+	})
+
+	t.Run("a large SDP offer passes", func(t *testing.T) {
+		room, alice, bob := setup()
+
+		result, err := room.route(context.Background(), alice, Message{Event: EventOffer, Payload: WebRTCOfferPayload{
+			ClientInfo:     alice.info(),
+			TargetClientId: bob.ID,
+			SDP:            "v=0\r\n" + strings.Repeat("a=candidate:1 1 udp 2122260223 10.0.0.1 54321 typ host\r\n", 2000),
+			Type:           "offer",
+		}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, routeHandled, result)
+		assert.Len(t, bob.send, 1)
+	})
+
+	t.Run("an empty map bounds nothing", func(t *testing.T) {
+		room, _, _ := setup()
+		room.maxPayloadSizes = map[Event]int{}
+
+		assert.NoError(t, room.checkPayloadSize(EventReaction, ReactionPayload{Emoji: strings.Repeat("👍", 512)}))
+	})
+}
+
+func TestWaitingGuard(t *testing.T) {
+	// setup creates a room with a host and a waiting client, both drained.
+	setup := func(configure func(*RoomConfig)) (*Room, *Client, *Client) {
+		config := DefaultRoomConfig()
+		config.DuplicateEventWindow = 0
+		configure(&config)
+		room := NewRoomWithConfig("test-room", config, nil)
+		host := newTestClientWithName("host", "Host")
+		waiting := newTestClientWithName("waiting", "Waiting")
+		room.handleClientConnect(host)
+		room.handleClientConnect(waiting)
+		for _, c := range []*Client{host, waiting} {
+			for len(c.send) > 0 {
+				<-c.send
+			}
+		}
+		return room, host, waiting
+	}
+
+	blocked := []Message{
+		{Event: EventAddChat, Payload: AddChatPayload{ChatId: "chat-1", ChatContent: "let me in"}},
+		{Event: EventRaiseHand, Payload: RaiseHandPayload{ClientId: "waiting"}},
+		{Event: EventReaction, Payload: ReactionPayload{}},
+		{Event: EventOffer, Payload: WebRTCOfferPayload{TargetClientId: "host", SDP: "v=0", Type: "offer"}},
+		{Event: EventAnswer, Payload: WebRTCAnswerPayload{TargetClientId: "host", SDP: "v=0", Type: "answer"}},
+		{Event: EventCandidate, Payload: WebRTCCandidatePayload{TargetClientId: "host", Candidate: "candidate:1"}},
+		{Event: EventRestoreSession, Payload: RestoreSessionPayload{}},
+	}
+
+	t.Run("waiting clients are refused every non-waiting event with an error", func(t *testing.T) {
+		room, host, waiting := setup(func(*RoomConfig) {})
+
+		for _, msg := range blocked {
+			room.router(context.Background(), waiting, msg)
+
+			require.Len(t, waiting.send, 1, "%s should be answered with an error", msg.Event)
+			var reply struct {
+				Event   Event        `json:"event"`
+				Payload ErrorPayload `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(<-waiting.send, &reply))
+			assert.Equal(t, EventError, reply.Event)
+			assert.Equal(t, ErrorPayload{Code: ErrorCodeNotAdmitted, Message: "waiting for the host to admit you", Event: msg.Event}, reply.Payload)
+			assert.Empty(t, host.send, "%s should not reach the host", msg.Event)
+		}
+		assert.Empty(t, room.getRoomState().HandsRaised)
+		assert.Zero(t, room.chatHistory.Len())
+	})
+
+	t.Run("waiting room events still work", func(t *testing.T) {
+		room, host, waiting := setup(func(*RoomConfig) {})
+
+		room.router(context.Background(), waiting, Message{Event: EventRequestWaiting, Payload: RequestWaitingPayload{}})
+		assert.Len(t, host.send, 1)
+		assert.Empty(t, waiting.send)
+
+		room.router(context.Background(), waiting, Message{Event: EventValidate, Payload: ValidatePayload{Event: EventAddChat}})
+		require.Len(t, waiting.send, 1)
+		var reply struct {
+			Event   Event                   `json:"event"`
+			Payload ValidationResultPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-waiting.send, &reply))
+		assert.Equal(t, EventValidationResult, reply.Event)
+		assert.False(t, reply.Payload.Ok)
+		assert.Contains(t, reply.Payload.Errors, `waiting clients may not send "add_chat" until admitted`)
+
+		room.router(context.Background(), waiting, Message{Event: EventLeave, Payload: LeavePayload{}})
+		assert.True(t, waiting.leaving)
+	})
+
+	t.Run("disabling the guard leaves role permissions to refuse silently", func(t *testing.T) {
+		room, host, waiting := setup(func(c *RoomConfig) { c.DisableWaitingGuard = true })
+
+		for _, msg := range blocked {
+			room.mu.Lock()
+			result, _ := room.route(context.Background(), waiting, msg)
+			room.mu.Unlock()
+			assert.Equal(t, routePermissionDenied, result, "%s", msg.Event)
+		}
+		assert.Empty(t, waiting.send)
+		assert.Empty(t, host.send)
+	})
 }
@@ -0,0 +1,129 @@
+// Package session - sink.go
+//
+// This file defines the EventSink extension point, which mirrors room events to
+// external systems such as analytics pipelines or message buses (Kafka, NATS, Redis).
+//
+// Sink Contract:
+// Rooms publish to their sink while holding the room lock, so implementations
+// must never block. Slow integrations should be wrapped in an AsyncEventSink,
+// which queues events and delivers them from a dedicated goroutine.
+//
+// Provided Implementations:
+//   - NoopEventSink: Discards all events (the default)
+//   - ChannelEventSink: Delivers events to a buffered channel (integration tests)
+//   - AsyncEventSink: Decouples any sink from the room lock while preserving order
+package session
+
+import (
+	"log/slog"
+	"sync"
+
+	"k8s.io/utils/set"
+)
+
+// EventSink receives a copy of every durable event broadcast by a room; see
+// RoomConfig.DurableEvents. Publish is called while the room lock is held and
+// must not block.
+type EventSink interface {
+	Publish(roomId RoomIdType, event Event, payload any)
+}
+
+// DefaultDurableEvents returns the events that change a meeting's record:
+// chat messages, and clients being admitted, joining and leaving.
+func DefaultDurableEvents() set.Set[Event] {
+	return set.New(
+		EventAddChat, EventDeleteChat, EventDeleteUserChats, EventPinChat, EventUnpinChat,
+		EventAcceptWaiting, EventDenyWaiting, EventHostPromoted,
+		EventGrantSpeak, EventRevokeSpeak,
+		EventDisconnect, EventParticipantLeft, EventKick,
+	)
+}
+
+// SinkEvent is a single room event captured by a sink.
+type SinkEvent struct {
+	RoomId  RoomIdType `json:"roomId"`  // Room the event originated from
+	Event   Event      `json:"event"`   // The event type that was broadcast
+	Payload any        `json:"payload"` // The payload that was broadcast
+}
+
+// NoopEventSink discards every event. It is the default sink for rooms.
+type NoopEventSink struct{}
+
+// Publish discards the event.
+func (NoopEventSink) Publish(roomId RoomIdType, event Event, payload any) {}
+
+// ChannelEventSink delivers events to a buffered channel.
+// Events are dropped rather than blocking when the channel is full.
+type ChannelEventSink struct {
+	Events chan SinkEvent
+}
+
+// NewChannelEventSink creates a ChannelEventSink with the given buffer size.
+func NewChannelEventSink(bufferSize int) *ChannelEventSink {
+	return &ChannelEventSink{Events: make(chan SinkEvent, bufferSize)}
+}
+
+// Publish enqueues the event without blocking.
+func (s *ChannelEventSink) Publish(roomId RoomIdType, event Event, payload any) {
+	select {
+	case s.Events <- SinkEvent{RoomId: roomId, Event: event, Payload: payload}:
+	default:
+		slog.Warn("Event sink channel full, dropping event", "RoomId", roomId, "event", event)
+	}
+}
+
+// AsyncEventSink wraps another sink so that publishing never blocks the caller.
+// Events are queued and delivered to the wrapped sink by a single goroutine,
+// which preserves publish order across all rooms sharing the sink.
+//
+// If the queue is full the event is dropped and a warning is logged, so a
+// stalled downstream system can never stall a room.
+type AsyncEventSink struct {
+	inner     EventSink
+	queue     chan SinkEvent
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewAsyncEventSink starts delivering events to inner on a background goroutine.
+// Call Close to stop delivery once the sink is no longer needed.
+func NewAsyncEventSink(inner EventSink, bufferSize int) *AsyncEventSink {
+	s := &AsyncEventSink{
+		inner: inner,
+		queue: make(chan SinkEvent, bufferSize),
+		done:  make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Publish enqueues the event for delivery without blocking.
+func (s *AsyncEventSink) Publish(roomId RoomIdType, event Event, payload any) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	select {
+	case s.queue <- SinkEvent{RoomId: roomId, Event: event, Payload: payload}:
+	default:
+		slog.Warn("Async event sink queue full, dropping event", "RoomId", roomId, "event", event)
+	}
+}
+
+// Close stops delivery. Events still queued are discarded.
+func (s *AsyncEventSink) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// run delivers queued events to the wrapped sink in order until Close is called.
+func (s *AsyncEventSink) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case e := <-s.queue:
+			s.inner.Publish(e.RoomId, e.Event, e.Payload)
+		}
+	}
+}
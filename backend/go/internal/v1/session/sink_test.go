@@ -0,0 +1,153 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/set"
+)
+
+// blockingSink is an EventSink whose Publish blocks until released, simulating a stalled downstream system.
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s *blockingSink) Publish(roomId RoomIdType, event Event, payload any) {
+	<-s.release
+}
+
+func TestEventSink(t *testing.T) {
+	t.Run("room events are published to the sink in order", func(t *testing.T) {
+		sink := NewChannelEventSink(10)
+		room := NewRoomWithConfig("sink-room", RoomConfig{EventSink: sink}, nil)
+		host := newTestClientWithName("host1", "Host User")
+		participant := newTestClientWithName("participant1", "Participant User")
+		room.addHost(host)
+		room.addParticipant(participant)
+
+		chat := AddChatPayload{
+			ClientInfo:  ClientInfo{ClientId: participant.ID, DisplayName: participant.DisplayName},
+			ChatId:      "chat-1",
+			ChatContent: "hello",
+		}
+		room.router(context.Background(), participant, Message{Event: EventAddChat, Payload: chat})
+		room.router(context.Background(), participant, Message{Event: EventRaiseHand, Payload: RaiseHandPayload{ClientId: participant.ID}})
+		room.handleClientDisconnect(participant)
+
+		// Raised hands are ephemeral and are not published
+		expected := []Event{EventAddChat, EventDisconnect}
+		for _, want := range expected {
+			select {
+			case got := <-sink.Events:
+				assert.Equal(t, want, got.Event)
+				assert.Equal(t, room.ID, got.RoomId)
+			case <-time.After(100 * time.Millisecond):
+				t.Fatalf("expected %q to be published", want)
+			}
+		}
+	})
+
+	t.Run("direct responses are not published", func(t *testing.T) {
+		sink := NewChannelEventSink(10)
+		room := NewRoomWithConfig("sink-room", RoomConfig{EventSink: sink}, nil)
+		participant := newTestClientWithName("participant1", "Participant User")
+		room.addParticipant(participant)
+
+		room.router(context.Background(), participant, Message{Event: EventGetRecentChats, Payload: GetRecentChatsPayload{}})
+
+		assert.Len(t, sink.Events, 0, "Recent chats are a private reply, not a room event")
+	})
+
+	t.Run("only durable events are published", func(t *testing.T) {
+		sink := NewChannelEventSink(10)
+		room := NewRoomWithConfig("sink-room", RoomConfig{EventSink: sink}, nil)
+		participant := newTestClientWithName("participant1", "Participant User")
+		room.addParticipant(participant)
+
+		room.router(context.Background(), participant, Message{Event: EventReaction, Payload: ReactionPayload{Emoji: "👍"}})
+		room.router(context.Background(), participant, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: participant.info(), ChatContent: "hello"}})
+
+		require.Len(t, sink.Events, 1, "The reaction is ephemeral")
+		assert.Equal(t, EventAddChat, (<-sink.Events).Event)
+	})
+
+	t.Run("configured durable events replace the default", func(t *testing.T) {
+		sink := NewChannelEventSink(10)
+		room := NewRoomWithConfig("sink-room", RoomConfig{EventSink: sink, DurableEvents: set.New(EventReaction)}, nil)
+		participant := newTestClientWithName("participant1", "Participant User")
+		room.addParticipant(participant)
+
+		room.router(context.Background(), participant, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: participant.info(), ChatContent: "hello"}})
+		room.router(context.Background(), participant, Message{Event: EventReaction, Payload: ReactionPayload{Emoji: "👍"}})
+
+		require.Len(t, sink.Events, 1)
+		assert.Equal(t, EventReaction, (<-sink.Events).Event)
+	})
+
+	t.Run("async sink never blocks the publisher", func(t *testing.T) {
+		inner := &blockingSink{release: make(chan struct{})}
+		async := NewAsyncEventSink(inner, 1)
+		defer async.Close()
+		defer close(inner.release)
+
+		room := NewRoomWithConfig("sink-room", RoomConfig{EventSink: async}, nil)
+		participant := newTestClientWithName("participant1", "Participant User")
+		room.addParticipant(participant)
+
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < 10; i++ {
+				room.router(context.Background(), participant, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: participant.info(), ChatContent: "hello"}})
+			}
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("publishing to a stalled sink blocked the room")
+		}
+	})
+
+	t.Run("async sink delivers in order", func(t *testing.T) {
+		inner := NewChannelEventSink(10)
+		async := NewAsyncEventSink(inner, 10)
+		defer async.Close()
+
+		events := []Event{EventAddChat, EventDeleteChat, EventRaiseHand, EventLowerHand}
+		for _, e := range events {
+			async.Publish("room", e, nil)
+		}
+
+		for _, want := range events {
+			select {
+			case got := <-inner.Events:
+				assert.Equal(t, want, got.Event)
+			case <-time.After(100 * time.Millisecond):
+				t.Fatalf("expected %q to be delivered", want)
+			}
+		}
+	})
+
+	t.Run("hub injects its sink into created rooms", func(t *testing.T) {
+		sink := NewChannelEventSink(1)
+		hub := NewHubWithConfig(&MockValidator{}, HubConfig{Room: RoomConfig{EventSink: sink}})
+
+		room, _ := hub.getOrCreateRoom("hub-room")
+		require.NotNil(t, room)
+		assert.Same(t, sink, room.config.EventSink)
+	})
+
+	t.Run("nil sink falls back to noop", func(t *testing.T) {
+		room := NewRoomWithConfig("sink-room", RoomConfig{}, nil)
+		participant := newTestClientWithName("participant1", "Participant User")
+		room.addParticipant(participant)
+
+		assert.NotPanics(t, func() {
+			room.broadcast(context.Background(), EventRaiseHand, RaiseHandPayload{ClientId: participant.ID}, nil)
+		})
+	})
+}
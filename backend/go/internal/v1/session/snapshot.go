@@ -0,0 +1,111 @@
+// Package session - snapshot.go
+//
+// This file implements a consistent, point-in-time view of every room on a Hub
+// for support engineers debugging live meetings.
+//
+// Consistency:
+// Each room is copied under its own read lock, so no room changes part-way
+// through its copy. Rooms are copied one after another rather than all at
+// once, so a client moving between rooms during the snapshot may appear in
+// both or neither; the snapshot is a debugging aid, not an audit record.
+//
+// Cost:
+// The hub lock is held only long enough to list the rooms, and each room's
+// read lock only long enough to copy its rosters and counts. JSON encoding
+// happens afterwards, so live traffic is stalled for as little time as possible.
+package session
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// HubSnapshot is a point-in-time view of a Hub and all of its rooms.
+type HubSnapshot struct {
+	TakenAt  time.Time      `json:"takenAt"`  // When the snapshot was taken
+	Draining bool           `json:"draining"` // Whether the hub is refusing new connections
+	Config   ConfigSnapshot `json:"config"`   // Limits applied to the hub's rooms
+	Rooms    []RoomSnapshot `json:"rooms"`    // Every room, ordered by id
+}
+
+// ConfigSnapshot is the serializable subset of a hub's configuration.
+type ConfigSnapshot struct {
+	MaxParticipants int          `json:"maxParticipants"`
+	MaxChatHistory  int          `json:"maxChatHistory"`
+	ChatRateLimit   int          `json:"chatRateLimit"`
+	MaxRoomsPerUser int          `json:"maxRoomsPerUser"`
+	Features        RoomFeatures `json:"features"`
+}
+
+// RoomSnapshot is a point-in-time view of one room's rosters.
+type RoomSnapshot struct {
+	ID            RoomIdType   `json:"id"`
+	Hosts         []ClientInfo `json:"hosts"`
+	Participants  []ClientInfo `json:"participants"`
+	SharingScreen []ClientInfo `json:"sharingScreen"`
+	Spectators    []ClientInfo `json:"spectators"`
+	WaitingUsers  []ClientInfo `json:"waitingUsers"`
+	HandsRaised   []ClientInfo `json:"handsRaised"`
+	ChatMessages  int          `json:"chatMessages"` // Messages currently retained in history
+}
+
+// Snapshot returns a view of every room on the hub, each room consistent in
+// itself.
+//
+// Thread Safety:
+// Holds the hub lock only while listing the rooms, then read-locks each room
+// in turn while copying it, as BroadcastToAll does. Connects and room removals
+// are not blocked by the copy, and only one room's mutations wait at a time.
+//
+// Returns:
+//   - HubSnapshot: A deep copy that is safe to use after the locks are released
+func (h *Hub) Snapshot() HubSnapshot {
+	h.mu.Lock()
+	rooms := make([]*Room, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	h.mu.Unlock()
+	slices.SortFunc(rooms, func(a, b *Room) int { return cmp.Compare(a.ID, b.ID) })
+
+	snapshot := HubSnapshot{
+		TakenAt:  time.Now().UTC(),
+		Draining: h.IsDraining(),
+		Config: ConfigSnapshot{
+			MaxParticipants: h.config.Room.MaxParticipants,
+			MaxChatHistory:  h.config.Room.MaxChatHistory,
+			ChatRateLimit:   h.config.Room.ChatRateLimit,
+			MaxRoomsPerUser: h.config.MaxRoomsPerUser,
+			Features:        DefaultRoomFeatures(),
+		},
+		Rooms: make([]RoomSnapshot, 0, len(rooms)),
+	}
+	if h.config.Room.Features != nil {
+		snapshot.Config.Features = *h.config.Room.Features
+	}
+	for _, room := range rooms {
+		room.mu.RLock()
+		snapshot.Rooms = append(snapshot.Rooms, room.snapshot())
+		room.mu.RUnlock()
+	}
+
+	return snapshot
+}
+
+// snapshot copies the room's rosters and counts.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held (a read lock is sufficient).
+func (r *Room) snapshot() RoomSnapshot {
+	return RoomSnapshot{
+		ID:            r.ID,
+		Hosts:         clientInfos(clientsMapToSlice(r.hosts)),
+		Participants:  clientInfos(clientsMapToSlice(r.participants)),
+		SharingScreen: clientInfos(r.screenSharers()),
+		Spectators:    clientInfos(clientsMapToSlice(r.spectators)),
+		WaitingUsers:  clientInfos(clientsMapToSlice(r.waiting)),
+		HandsRaised:   clientInfos(r.raisedHandsInOrder()),
+		ChatMessages:  r.chatHistory.Len(),
+	}
+}
@@ -0,0 +1,119 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubSnapshot(t *testing.T) {
+	// populate creates a room with a host, an admitted participant who has
+	// chatted and a waiting client.
+	populate := func(hub *Hub, id RoomIdType, chats int) {
+		room, _ := hub.getOrCreateRoom(id)
+		host := newTestClientWithName(ClientIdType(string(id)+"-host"), "Host")
+		participant := newTestClientWithName(ClientIdType(string(id)+"-participant"), "Participant")
+		room.handleClientConnect(host)
+		room.handleClientConnect(participant)
+		room.handleClientConnect(newTestClientWithName(ClientIdType(string(id)+"-waiting"), "Waiting"))
+		room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: participant.ID}})
+		for i := range chats {
+			room.router(context.Background(), participant, Message{Event: EventAddChat, Payload: AddChatPayload{
+				ClientInfo:  participant.info(),
+				ChatId:      ChatId(fmt.Sprintf("chat-%d", i)),
+				ChatContent: "hello",
+			}})
+		}
+	}
+
+	t.Run("snapshot reflects the current rooms and counts", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		populate(hub, "room-b", 2)
+		populate(hub, "room-a", 0)
+
+		snapshot := hub.Snapshot()
+
+		require.Len(t, snapshot.Rooms, 2)
+		assert.Equal(t, RoomIdType("room-a"), snapshot.Rooms[0].ID, "Rooms are ordered by id")
+		room := snapshot.Rooms[1]
+		assert.Equal(t, RoomIdType("room-b"), room.ID)
+		assert.Len(t, room.Hosts, 1)
+		assert.Equal(t, []ClientInfo{{ClientId: "room-b-participant", DisplayName: "Participant"}}, room.Participants)
+		assert.Len(t, room.WaitingUsers, 1)
+		assert.Equal(t, 2, room.ChatMessages)
+		assert.Equal(t, 0, snapshot.Rooms[0].ChatMessages)
+		assert.Equal(t, DefaultRoomFeatures(), snapshot.Config.Features)
+	})
+
+	t.Run("snapshot is unaffected by later changes", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		populate(hub, "room-a", 1)
+		snapshot := hub.Snapshot()
+
+		populate(hub, "room-b", 0)
+		room, _ := hub.getOrCreateRoom("room-a")
+		room.handleClientConnect(newTestClient("late-waiting"))
+
+		require.Len(t, snapshot.Rooms, 1)
+		assert.Len(t, snapshot.Rooms[0].WaitingUsers, 1)
+		assert.Len(t, hub.Snapshot().Rooms, 2)
+	})
+
+	t.Run("a busy room does not block the rest of the hub", func(t *testing.T) {
+		hub := NewTestHub(nil)
+		populate(hub, "room-a", 0)
+		busy, _ := hub.getOrCreateRoom("room-a")
+
+		busy.mu.Lock()
+		done := make(chan HubSnapshot)
+		go func() { done <- hub.Snapshot() }()
+
+		created := make(chan struct{})
+		go func() {
+			hub.getOrCreateRoom("room-b")
+			close(created)
+		}()
+		select {
+		case <-created:
+		case <-time.After(time.Second):
+			t.Fatal("Creating a room waited for the snapshot")
+		}
+		busy.mu.Unlock()
+
+		assert.NotEmpty(t, (<-done).Rooms)
+	})
+
+	t.Run("admin endpoint serves snapshots by hub name", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		hub := NewTestHub(nil)
+		populate(hub, "room-a", 1)
+		router := gin.New()
+		router.GET("/admin/snapshot", ServeSnapshot("secret", map[string]*Hub{"hub": hub}))
+
+		get := func(token string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/admin/snapshot", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, get("wrong").Code)
+
+		w := get("secret")
+		require.Equal(t, http.StatusOK, w.Code)
+		var body map[string]HubSnapshot
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		require.Contains(t, body, "hub")
+		require.Len(t, body["hub"].Rooms, 1)
+		assert.Equal(t, 1, body["hub"].Rooms[0].ChatMessages)
+	})
+}
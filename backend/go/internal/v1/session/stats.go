@@ -0,0 +1,53 @@
+// Package session - stats.go
+//
+// This file implements per-room statistics for post-meeting analytics.
+// Counters are updated by the room's handlers under the room lock, read
+// through Room.Stats, and handed to RoomConfig.OnClose when the hub removes
+// the emptied room so they can be persisted.
+package session
+
+import "time"
+
+// RoomStats summarizes a room's activity over its lifetime.
+type RoomStats struct {
+	RoomID           RoomIdType    `json:"roomId"`
+	CreatedAt        time.Time     `json:"createdAt"`        // When the room was created
+	Duration         time.Duration `json:"duration"`         // Time from creation to when the stats were taken
+	PeakParticipants int           `json:"peakParticipants"` // Most admitted clients present at once
+	TotalJoins       uint64        `json:"totalJoins"`       // Connections to the room, including reconnects
+	TotalChats       uint64        `json:"totalChats"`       // Chat messages sent, including any since deleted
+}
+
+// Stats returns the room's statistics so far.
+//
+// Thread Safety: Acquires the room's read lock.
+func (r *Room) Stats() RoomStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.stats()
+}
+
+// stats returns the room's statistics so far.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held (a read lock is sufficient).
+func (r *Room) stats() RoomStats {
+	return RoomStats{
+		RoomID:           r.ID,
+		CreatedAt:        r.createdAt,
+		Duration:         r.config.Clock.Since(r.createdAt),
+		PeakParticipants: r.peakParticipants,
+		TotalJoins:       r.joinCounter,
+		TotalChats:       r.totalChats,
+	}
+}
+
+// notePeak records the current number of admitted clients if it is a new peak.
+// Call it after admitting a client.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+func (r *Room) notePeak() {
+	admitted := len(r.hosts) + len(r.participants) + len(r.sharingScreen) + len(r.spectators)
+	r.peakParticipants = max(r.peakParticipants, admitted)
+}
@@ -0,0 +1,71 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	testclock "k8s.io/utils/clock/testing"
+)
+
+func TestRoomStats(t *testing.T) {
+	t.Run("activity produces the expected peak and totals at room end", func(t *testing.T) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		closed := make(chan RoomStats, 1)
+		config := DefaultRoomConfig()
+		config.Clock = fakeClock
+		config.OnClose = func(stats RoomStats) { closed <- stats }
+		hub := NewTestHub(nil, WithRoomConfig(config))
+		room, err := hub.getOrCreateRoom("test-room")
+		require.NoError(t, err)
+
+		host := newTestClient("host")
+		room.handleClientConnect(host)
+		admit := func(id ClientIdType) *Client {
+			c := newTestClientWithName(id, DisplayNameType(id))
+			room.handleClientConnect(c)
+			room.router(context.Background(), host, Message{Event: EventAcceptWaiting, Payload: AcceptWaitingPayload{ClientId: id}})
+			return c
+		}
+		first := admit("first")
+		second := admit("second")
+		for _, c := range []*Client{first, second} {
+			room.router(context.Background(), c, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: c.info(), ChatId: ChatId(c.ID), ChatContent: "hi"}})
+		}
+
+		room.handleClientDisconnect(first)
+		third := admit("third")
+		fakeClock.Step(time.Hour)
+
+		stats := room.Stats()
+		assert.Equal(t, 3, stats.PeakParticipants, "Peak is the most present at once")
+		assert.Equal(t, uint64(4), stats.TotalJoins)
+		assert.Equal(t, uint64(2), stats.TotalChats)
+		assert.Equal(t, time.Hour, stats.Duration)
+
+		for _, c := range []*Client{second, third, host} {
+			room.handleClientDisconnect(c)
+		}
+		select {
+		case final := <-closed:
+			assert.Equal(t, RoomIdType("test-room"), final.RoomID)
+			assert.Equal(t, 3, final.PeakParticipants)
+			assert.Equal(t, uint64(4), final.TotalJoins)
+			assert.Equal(t, uint64(2), final.TotalChats)
+		case <-time.After(time.Second):
+			require.Fail(t, "OnClose was not called when the room emptied")
+		}
+	})
+
+	t.Run("rejected chats are not counted", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		participant := newTestClientWithName("participant", "Participant")
+		room.addParticipant(participant)
+
+		room.router(context.Background(), participant, Message{Event: EventAddChat, Payload: AddChatPayload{ClientInfo: participant.info(), ChatId: "chat"}})
+
+		assert.Zero(t, room.Stats().TotalChats)
+	})
+}
@@ -0,0 +1,187 @@
+// Package session - store.go
+//
+// This file defines the ChatStore and TranscriptStore extension points, which
+// persist chat messages and captions to durable storage such as a database,
+// so they survive the in-memory limits of RoomConfig.MaxChatHistory and
+// RoomConfig.MaxTranscript and server restarts.
+//
+// Store Contract:
+// Stores are never called while the room lock is held, so a slow database
+// delays only the messages being saved, never media signalling, hands or
+// admissions. Each call is bounded by RoomConfig.StoreTimeout, and its context
+// is also cancelled when the sender disconnects (chat) or the room closes
+// (captions). A store that honors ctx keeps its goroutines from piling up.
+//
+// Chat:
+// handleAddChat validates and rate limits a message under the room lock, then
+// saves it on another goroutine and takes the lock again to deliver it. A
+// message the store rejects or that times out is not delivered, so clients
+// never see a message that was not persisted. Saves run one at a time per
+// room, so history, the store and clients all see messages in the same order.
+//
+// Captions:
+// Captions are relayed first and then queued for the TranscriptStore, which a
+// per-room goroutine drains in order. Live captions are an accessibility aid,
+// so a store failure or a full queue leaves a gap in the saved transcript
+// rather than withholding them from the room.
+//
+// Provided Implementations:
+//   - NoopChatStore: Persists nothing (the default)
+//   - NoopTranscriptStore: Persists nothing (the default)
+package session
+
+import "context"
+
+// captionSaveQueue is how many captions may wait for a room's
+// TranscriptStore before new ones are dropped from the saved transcript.
+const captionSaveQueue = 256
+
+// ChatStore persists chat messages as they are sent.
+type ChatStore interface {
+	SaveChat(ctx context.Context, roomId RoomIdType, chat ChatInfo) error
+}
+
+// NoopChatStore persists nothing. It is the default store for rooms.
+type NoopChatStore struct{}
+
+// SaveChat discards the message.
+func (NoopChatStore) SaveChat(ctx context.Context, roomId RoomIdType, chat ChatInfo) error {
+	return nil
+}
+
+// TranscriptStore persists captions as they are relayed.
+type TranscriptStore interface {
+	SaveCaption(ctx context.Context, roomId RoomIdType, caption CaptionPayload) error
+}
+
+// NoopTranscriptStore persists nothing. It is the default store for rooms.
+type NoopTranscriptStore struct{}
+
+// SaveCaption discards the caption.
+func (NoopTranscriptStore) SaveCaption(ctx context.Context, roomId RoomIdType, caption CaptionPayload) error {
+	return nil
+}
+
+// persistsChat reports whether the room has a ChatStore to wait for before
+// delivering chat.
+func (r *Room) persistsChat() bool {
+	_, noop := r.config.ChatStore.(NoopChatStore)
+	return !noop
+}
+
+// storeContext bounds a store call by RoomConfig.StoreTimeout.
+//
+// Parameters:
+//   - parent: The context the call would otherwise use
+//
+// Returns:
+//   - context.Context: parent with the timeout applied, if one is set
+//   - context.CancelFunc: Releases the context's resources
+func (r *Room) storeContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if r.config.StoreTimeout > 0 {
+		return context.WithTimeout(parent, r.config.StoreTimeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// saveChat stamps a chat message, saves it to the room's ChatStore and, if
+// the save succeeds, delivers it. A failed save is reported to the sender with
+// ErrorCodeStoreFailed.
+//
+// Thread Safety: Must be called WITHOUT the room lock; it takes the lock to
+// stamp the message and again to deliver it, but not while saving.
+//
+// Parameters:
+//   - ctx: Cancelled when the sender disconnects
+//   - client: The sender
+//   - event: The event type (should be EventAddChat)
+//   - p: The validated message, not yet assigned an id
+func (r *Room) saveChat(ctx context.Context, client *Client, event Event, p AddChatPayload) {
+	r.chatSaveMu.Lock()
+	defer r.chatSaveMu.Unlock()
+
+	r.mu.Lock()
+	if client.kicked || client.movedFrom(r) || ctx.Err() != nil {
+		// The sender was removed or moved before the message could be saved
+		r.mu.Unlock()
+		return
+	}
+	p = r.stampChat(p)
+	r.mu.Unlock()
+
+	// Encode before storing so the store never holds a message nobody received
+	rawMsg, err := marshalMessage(event, p)
+	if err != nil {
+		r.config.HandlerLog.logger().Error("Rejected chat message: payload cannot be marshaled to JSON", "ClientId", client.ID, "RoomId", r.ID, "error", err)
+		return
+	}
+
+	storeCtx, cancel := r.storeContext(ctx)
+	storeCtx, span := r.config.Tracer.Start(storeCtx, SpanSaveChat, Attribute{AttrRoomId, string(r.ID)}, Attribute{AttrClientId, string(client.ID)})
+	err = r.config.ChatStore.SaveChat(storeCtx, r.ID, p)
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+	cancel()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.config.HandlerLog.logger().Error("Rejected chat message: store failed", "ClientId", client.ID, "RoomId", r.ID, "error", err)
+		client.sendError(ErrorCodeStoreFailed, "message could not be saved", event)
+		return
+	}
+	if client.kicked || client.movedFrom(r) {
+		// Removed or moved while the message was being saved
+		return
+	}
+	r.deliverChat(ctx, event, p, rawMsg)
+}
+
+// queueCaption hands a relayed caption to the room's TranscriptStore without
+// waiting for it to be saved. The first caption starts the goroutine that
+// saves them. If the store has fallen too far behind, the caption is dropped
+// from the saved transcript.
+//
+// Thread Safety: This method is NOT thread-safe and must only be called when
+// the room's mutex lock is already held.
+//
+// Parameters:
+//   - caption: The stamped caption, already relayed to the room
+func (r *Room) queueCaption(caption CaptionPayload) {
+	if _, noop := r.config.TranscriptStore.(NoopTranscriptStore); noop {
+		return
+	}
+	if r.captionSaves == nil {
+		r.captionSaves = make(chan CaptionPayload, captionSaveQueue)
+		go r.saveCaptions(r.captionSaves)
+	}
+	select {
+	case r.captionSaves <- caption:
+	default:
+		r.config.HandlerLog.logger().Warn("Transcript store is falling behind; caption not saved", "ClientId", caption.ClientId, "RoomId", r.ID)
+	}
+}
+
+// saveCaptions saves queued captions to the room's TranscriptStore, in order,
+// until the room closes.
+//
+// Thread Safety: Runs on its own goroutine without the room lock.
+//
+// Parameters:
+//   - queue: The room's caption queue
+func (r *Room) saveCaptions(queue <-chan CaptionPayload) {
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case caption := <-queue:
+			ctx, cancel := r.storeContext(r.ctx)
+			if err := r.config.TranscriptStore.SaveCaption(ctx, r.ID, caption); err != nil {
+				r.config.HandlerLog.logger().Error("Failed to save caption", "ClientId", caption.ClientId, "RoomId", r.ID, "error", err)
+			}
+			cancel()
+		}
+	}
+}
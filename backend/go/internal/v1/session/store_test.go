@@ -0,0 +1,159 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingChatStore holds every save until its context is cancelled.
+type blockingChatStore struct {
+	started chan struct{}
+}
+
+func (s *blockingChatStore) SaveChat(ctx context.Context, roomId RoomIdType, chat ChatInfo) error {
+	close(s.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// failingChatStore rejects every save.
+type failingChatStore struct{}
+
+func (failingChatStore) SaveChat(ctx context.Context, roomId RoomIdType, chat ChatInfo) error {
+	return errors.New("database unavailable")
+}
+
+func TestChatStore(t *testing.T) {
+	chat := func(client *Client) Message {
+		return Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  client.info(),
+			ChatContent: "hello",
+		}}
+	}
+
+	expectStoreFailed := func(t *testing.T, client *Client) {
+		t.Helper()
+		select {
+		case raw := <-client.send:
+			var msg struct {
+				Event   Event        `json:"event"`
+				Payload ErrorPayload `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(raw, &msg))
+			assert.Equal(t, EventError, msg.Event)
+			assert.Equal(t, ErrorCodeStoreFailed, msg.Payload.Code)
+		case <-time.After(time.Second):
+			t.Fatal("Sender should be told the message was not saved")
+		}
+	}
+
+	// historyLen reads the chat history length under the room lock.
+	historyLen := func(room *Room) int {
+		room.mu.RLock()
+		defer room.mu.RUnlock()
+		return room.chatHistory.Len()
+	}
+
+	t.Run("saved chats are delivered", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		store := &savedChats{}
+		room.config.ChatStore = store
+		client := newTestClientWithName("participant1", "John Doe")
+		room.handleClientConnect(client)
+
+		room.router(context.Background(), client, chat(client))
+
+		assert.Eventually(t, func() bool { return historyLen(room) == 1 }, time.Second, 5*time.Millisecond)
+		assert.Len(t, store.Chats(), 1)
+	})
+
+	t.Run("rejected chats are not delivered", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		room.config.ChatStore = failingChatStore{}
+		client := newTestClientWithName("participant1", "John Doe")
+		room.handleClientConnect(client)
+		for len(client.send) > 0 {
+			<-client.send
+		}
+
+		room.router(context.Background(), client, chat(client))
+
+		expectStoreFailed(t, client)
+		assert.Equal(t, 0, historyLen(room), "History should not hold an unsaved message")
+	})
+
+	t.Run("cancelling the connection aborts an in-flight save", func(t *testing.T) {
+		store := &blockingChatStore{started: make(chan struct{})}
+		room := NewTestRoom("test-room", nil)
+		room.config.ChatStore = store
+		client := newTestClientWithName("participant1", "John Doe")
+		client.ctx, client.cancel = context.WithCancel(context.Background())
+		room.handleClientConnect(client)
+		for len(client.send) > 0 {
+			<-client.send
+		}
+
+		msgCtx, cancelMsg := context.WithCancel(client.context())
+		room.router(msgCtx, client, chat(client))
+		cancelMsg()
+
+		select {
+		case <-store.started:
+		case <-time.After(time.Second):
+			t.Fatal("Handler never reached the store")
+		}
+		client.cancelContext()
+
+		expectStoreFailed(t, client)
+		assert.Equal(t, 0, historyLen(room), "An aborted save should not be delivered")
+	})
+
+	t.Run("a slow store does not hold the room lock", func(t *testing.T) {
+		store := &blockingChatStore{started: make(chan struct{})}
+		room := NewTestRoom("test-room", nil)
+		room.config.ChatStore = store
+		room.config.StoreTimeout = 50 * time.Millisecond
+		client := newTestClientWithName("participant1", "John Doe")
+		room.handleClientConnect(client)
+		for len(client.send) > 0 {
+			<-client.send
+		}
+
+		room.router(context.Background(), client, chat(client))
+		<-store.started
+		require.True(t, room.mu.TryLock(), "The room lock is free while the message is saved")
+		room.mu.Unlock()
+
+		expectStoreFailed(t, client)
+		assert.Equal(t, 0, historyLen(room), "A save that timed out should not be delivered")
+	})
+
+	t.Run("messages are saved and delivered in order", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		store := &savedChats{}
+		room.config.ChatStore = store
+		client := newTestClientWithName("participant1", "John Doe")
+		room.handleClientConnect(client)
+
+		for range 10 {
+			room.router(context.Background(), client, chat(client))
+		}
+
+		require.Eventually(t, func() bool { return historyLen(room) == 10 }, time.Second, 5*time.Millisecond)
+		room.mu.RLock()
+		history := room.getChatHistory()
+		room.mu.RUnlock()
+		assert.Equal(t, store.Chats(), history, "The store and history agree on the order")
+		stamps := make([]Timestamp, len(history))
+		for i, c := range history {
+			stamps[i] = c.Timestamp
+		}
+		assert.IsNonDecreasing(t, stamps, "Messages are stamped in the order they are saved")
+	})
+}
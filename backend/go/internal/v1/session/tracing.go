@@ -0,0 +1,143 @@
+// Package session - tracing.go
+//
+// This file defines the Tracer extension point, which records spans around
+// message handling so latency can be followed across the WebSocket pipeline:
+// one span per routed message, with child spans around chat storage,
+// broadcasts and event sink publishing.
+//
+// Propagation:
+// Each span is carried in the context passed to the router and handlers, so
+// child spans started from that context share the message's trace id. Work
+// that does not originate from a message, such as timers and disconnects,
+// starts from the room's context and begins a new trace.
+//
+// Provided Implementations:
+//   - NoopTracer: Records nothing (the default, used when no exporter is configured)
+//   - RecordingTracer: Keeps finished spans in memory (tests and debugging)
+//   - OTelTracer: Records spans with an OpenTelemetry tracer, built with the otel tag; see tracing_otel.go
+package session
+
+import (
+	"context"
+	"sync"
+)
+
+// Span names recorded by rooms.
+const (
+	SpanRoute     = "session.route"        // One routed client message, including the handler
+	SpanSaveChat  = "session.save_chat"    // ChatStore.SaveChat
+	SpanBroadcast = "session.broadcast"    // Delivering a message to room members
+	SpanPublish   = "session.sink_publish" // EventSink.Publish
+)
+
+// Span attribute keys recorded by rooms.
+const (
+	AttrEvent    = "session.event"     // The event being handled or broadcast
+	AttrRoomId   = "session.room_id"   // The room doing the work
+	AttrClientId = "session.client_id" // The client whose message is being handled
+)
+
+// Attribute is a key/value pair recorded on a span.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Tracer starts spans. Implementations must be safe for concurrent use and
+// must not block, since spans are started while the room lock is held.
+type Tracer interface {
+	// Start begins a span as a child of any span carried by ctx and returns
+	// a context carrying the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a unit of traced work. End must be called exactly once.
+type Span interface {
+	// RecordError marks the span as failed.
+	RecordError(err error)
+	// End finishes the span.
+	End()
+}
+
+// NoopTracer records nothing. It is the default tracer for rooms.
+type NoopTracer struct{}
+
+// Start returns ctx unchanged and a span that does nothing.
+func (NoopTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) RecordError(err error) {}
+func (noopSpan) End()                  {}
+
+// RecordedSpan is a finished span captured by a RecordingTracer.
+type RecordedSpan struct {
+	Name       string
+	TraceId    uint64            // Shared by every span started from the same root
+	SpanId     uint64            // Unique within the tracer
+	ParentId   uint64            // Zero for root spans
+	Attributes map[string]string // Attributes given to Start
+	Err        error             // Last error passed to RecordError, if any
+}
+
+// RecordingTracer keeps finished spans in memory, in the order they ended.
+type RecordingTracer struct {
+	mu     sync.Mutex
+	nextId uint64
+	spans  []RecordedSpan
+}
+
+// NewRecordingTracer creates an empty RecordingTracer.
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+type recordingSpanKey struct{}
+
+type recordingSpan struct {
+	tracer *RecordingTracer
+	span   RecordedSpan
+}
+
+// Start begins a span, inheriting the trace id of any RecordingTracer span
+// carried by ctx.
+func (t *RecordingTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	t.mu.Lock()
+	t.nextId++
+	id := t.nextId
+	t.mu.Unlock()
+
+	s := &recordingSpan{tracer: t, span: RecordedSpan{
+		Name:       name,
+		TraceId:    id,
+		SpanId:     id,
+		Attributes: make(map[string]string, len(attrs)),
+	}}
+	if parent, ok := ctx.Value(recordingSpanKey{}).(*recordingSpan); ok {
+		s.span.TraceId = parent.span.TraceId
+		s.span.ParentId = parent.span.SpanId
+	}
+	for _, a := range attrs {
+		s.span.Attributes[a.Key] = a.Value
+	}
+	return context.WithValue(ctx, recordingSpanKey{}, s), s
+}
+
+// Spans returns the finished spans in the order they ended.
+func (t *RecordingTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]RecordedSpan(nil), t.spans...)
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	s.span.Err = err
+}
+
+func (s *recordingSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, s.span)
+}
@@ -0,0 +1,67 @@
+//go:build otel
+
+// Package session - tracing_otel.go
+//
+// This file adapts an OpenTelemetry tracer to the Tracer extension point, so
+// room spans are exported through whatever OpenTelemetry SDK and exporter the
+// binary configures.
+//
+// Build:
+// The adapter is compiled only with the otel build tag, which keeps the
+// OpenTelemetry packages out of binaries that do not export traces:
+//
+//	go build -tags otel ./...
+//	go test -tags otel ./...
+package session
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTelTracer records room spans with an OpenTelemetry tracer. Spans started
+// from a context carrying an OpenTelemetry span, such as one from
+// instrumented HTTP middleware, join its trace.
+type OTelTracer struct {
+	tracer trace.Tracer
+}
+
+// NewOTelTracer adapts tracer, usually from a TracerProvider's Tracer method,
+// for use as RoomConfig.Tracer.
+//
+// Parameters:
+//   - tracer: The OpenTelemetry tracer to record spans with
+//
+// Returns:
+//   - OTelTracer: The adapter, safe for concurrent use
+func NewOTelTracer(tracer trace.Tracer) OTelTracer {
+	return OTelTracer{tracer: tracer}
+}
+
+// Start begins an OpenTelemetry span with attrs as string attributes.
+func (t OTelTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	kvs := make([]attribute.KeyValue, len(attrs))
+	for i, a := range attrs {
+		kvs[i] = attribute.String(a.Key, a.Value)
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(kvs...))
+	return ctx, otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+// RecordError records err as an exception event and sets the span's status
+// to Error.
+func (s otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s otelSpan) End() {
+	s.span.End()
+}
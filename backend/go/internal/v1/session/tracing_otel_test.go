@@ -0,0 +1,74 @@
+//go:build otel
+
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOTelTracer(t *testing.T) {
+	newTracer := func() (OTelTracer, *tracetest.InMemoryExporter) {
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		t.Cleanup(func() { provider.Shutdown(context.Background()) })
+		return NewOTelTracer(provider.Tracer("session")), exporter
+	}
+
+	spanNamed := func(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+		t.Helper()
+		for _, s := range spans {
+			if s.Name == name {
+				return s
+			}
+		}
+		require.Failf(t, "span not exported", "no %q span exported", name)
+		return tracetest.SpanStub{}
+	}
+
+	t.Run("routed messages export a trace with attributes", func(t *testing.T) {
+		tracer, exporter := newTracer()
+		room := NewTestRoom("test-room", nil)
+		room.config.Tracer = tracer
+		host := newTestClientWithName("host-1", "Host")
+		room.handleClientConnect(host)
+
+		room.router(context.Background(), host, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(host.info())})
+
+		spans := exporter.GetSpans()
+		route := spanNamed(t, spans, SpanRoute)
+		broadcast := spanNamed(t, spans, SpanBroadcast)
+		assert.ElementsMatch(t, []attribute.KeyValue{
+			attribute.String(AttrRoomId, "test-room"),
+			attribute.String(AttrClientId, "host-1"),
+			attribute.String(AttrEvent, string(EventRaiseHand)),
+		}, route.Attributes)
+		assert.False(t, route.Parent.IsValid(), "The routed message starts the trace")
+		assert.Equal(t, route.SpanContext.TraceID(), broadcast.SpanContext.TraceID())
+		assert.Equal(t, route.SpanContext.SpanID(), broadcast.Parent.SpanID(), "The broadcast is a child of the route")
+		assert.Equal(t, codes.Unset, route.Status.Code)
+	})
+
+	t.Run("recorded errors fail the span", func(t *testing.T) {
+		tracer, exporter := newTracer()
+
+		_, span := tracer.Start(context.Background(), SpanSaveChat)
+		span.RecordError(errors.New("store unavailable"))
+		span.End()
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.Equal(t, "store unavailable", spans[0].Status.Description)
+		require.Len(t, spans[0].Events, 1)
+		assert.Equal(t, "exception", spans[0].Events[0].Name)
+	})
+}
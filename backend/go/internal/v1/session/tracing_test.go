@@ -0,0 +1,105 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracing(t *testing.T) {
+	newTracedRoom := func() (*Room, *RecordingTracer, *Client) {
+		tracer := NewRecordingTracer()
+		room := NewTestRoom("test-room", nil)
+		room.config.Tracer = tracer
+		host := newTestClientWithName("host-1", "Host")
+		room.handleClientConnect(host)
+		return room, tracer, host
+	}
+
+	spanNamed := func(t *testing.T, spans []RecordedSpan, name string) RecordedSpan {
+		t.Helper()
+		for _, s := range spans {
+			if s.Name == name {
+				return s
+			}
+		}
+		require.Failf(t, "span not recorded", "no %q span in %v", name, spans)
+		return RecordedSpan{}
+	}
+
+	t.Run("records a span per routed message", func(t *testing.T) {
+		room, tracer, host := newTracedRoom()
+
+		room.router(context.Background(), host, Message{Event: EventRaiseHand, Payload: RaiseHandPayload(host.info())})
+		room.router(context.Background(), host, Message{Event: EventLowerHand, Payload: LowerHandPayload(host.info())})
+
+		var routes []RecordedSpan
+		for _, s := range tracer.Spans() {
+			if s.Name == SpanRoute {
+				routes = append(routes, s)
+			}
+		}
+		require.Len(t, routes, 2)
+		assert.Equal(t, map[string]string{
+			AttrEvent:    string(EventRaiseHand),
+			AttrRoomId:   "test-room",
+			AttrClientId: "host-1",
+		}, routes[0].Attributes)
+		assert.Equal(t, string(EventLowerHand), routes[1].Attributes[AttrEvent])
+		assert.NotEqual(t, routes[0].TraceId, routes[1].TraceId, "Each message starts its own trace")
+		assert.Zero(t, routes[0].ParentId)
+		assert.NoError(t, routes[0].Err)
+	})
+
+	t.Run("store, broadcast and sink spans share the message's trace", func(t *testing.T) {
+		room, tracer, host := newTracedRoom()
+		room.config.ChatStore = &savedChats{}
+
+		room.router(context.Background(), host, Message{Event: EventAddChat, Payload: AddChatPayload{
+			ClientInfo:  host.info(),
+			ChatContent: "hello",
+		}})
+
+		// The message is saved and broadcast after the router returns
+		require.Eventually(t, func() bool {
+			for _, s := range tracer.Spans() {
+				if s.Name == SpanPublish {
+					return true
+				}
+			}
+			return false
+		}, time.Second, 5*time.Millisecond)
+		spans := tracer.Spans()
+		route := spanNamed(t, spans, SpanRoute)
+		save := spanNamed(t, spans, SpanSaveChat)
+		broadcast := spanNamed(t, spans, SpanBroadcast)
+		publish := spanNamed(t, spans, SpanPublish)
+
+		for _, s := range []RecordedSpan{save, broadcast, publish} {
+			assert.Equal(t, route.TraceId, s.TraceId, "%s should be in the message's trace", s.Name)
+		}
+		assert.Equal(t, route.SpanId, save.ParentId)
+		assert.Equal(t, route.SpanId, broadcast.ParentId)
+		assert.Equal(t, broadcast.SpanId, publish.ParentId)
+		assert.Equal(t, string(EventAddChat), broadcast.Attributes[AttrEvent])
+	})
+
+	t.Run("unhandled messages are marked as failed", func(t *testing.T) {
+		room, tracer, host := newTracedRoom()
+
+		room.router(context.Background(), host, Message{Event: "not_an_event"})
+
+		route := spanNamed(t, tracer.Spans(), SpanRoute)
+		assert.Error(t, route.Err)
+	})
+
+	t.Run("the default tracer records nothing", func(t *testing.T) {
+		ctx := context.Background()
+		spanCtx, span := NoopTracer{}.Start(ctx, SpanRoute)
+		span.End()
+		assert.Equal(t, ctx, spanCtx, "No-op spans should not be added to the context")
+	})
+}
@@ -16,7 +16,11 @@
 //   - Waiting: Users waiting for admission to the room
 package session
 
-import "errors"
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
 
 // RoleType defines the different roles a client can have in a video conference session.
 // Each role has different permissions and capabilities within the room.
@@ -50,13 +54,16 @@ type DisplayNameType string
 type Event string
 
 // Timestamp represents a Unix timestamp for when an event occurred.
+// Server-stamped chat timestamps are in milliseconds.
 type Timestamp int64
 
 // ClientInfo contains the basic identifying information for a client.
 // This struct is embedded in many other payload types to identify the actor.
 type ClientInfo struct {
-	ClientId    ClientIdType    `json:"clientId"`    // Unique identifier for the client
-	DisplayName DisplayNameType `json:"displayName"` // Human-readable name for display in UI
+	ClientId    ClientIdType    `json:"clientId"`            // Unique identifier for the client
+	DisplayName DisplayNameType `json:"displayName"`         // Human-readable name for display in UI
+	AvatarURL   string          `json:"avatarUrl,omitempty"` // Profile picture from the client's token, if any
+	Pronouns    string          `json:"pronouns,omitempty"`  // Pronouns from the client's token, if any
 }
 
 // Role type constants define the hierarchy and permissions within a video conference room.
@@ -64,6 +71,7 @@ type ClientInfo struct {
 // waiting < participant < screenshare < host
 const (
 	RoleTypeWaiting     RoleType = "waiting"     // Users waiting for admission to the room
+	RoleTypeSpectator   RoleType = "spectator"   // Admitted webinar attendees who watch but cannot speak
 	RoleTypeParticipant RoleType = "participant" // Active participants in the video call
 	RoleTypeScreenshare RoleType = "screenshare" // Participants currently sharing their screen
 	RoleTypeHost        RoleType = "host"        // Room administrators with full control
@@ -73,33 +81,125 @@ const (
 // These events drive the entire real-time communication system.
 const (
 	// Chat-related events
-	EventAddChat        Event = "add_chat"     // Send a new chat message to the room
-	EventDeleteChat     Event = "delete_chat"  // Remove a chat message from the room
-	EventGetRecentChats Event = "recents_chat" // Request recent chat history
+	EventAddChat         Event = "add_chat"          // Send a new chat message to the room
+	EventDeleteChat      Event = "delete_chat"       // Remove a chat message from the room
+	EventDeleteUserChats Event = "delete_user_chats" // Host removes every message one client sent
+	EventPinChat         Event = "pin_chat"          // Host pins a message to the top of the chat
+	EventUnpinChat       Event = "unpin_chat"        // Host unpins a message
+	EventGetRecentChats  Event = "recents_chat"      // Request recent chat history
+	EventGetChatsByRange Event = "range_chat"        // Request chat history sent within a time range
 
 	// Hand raising events for participant management
-	EventRaiseHand Event = "raise_hand" // Participant requests to speak
-	EventLowerHand Event = "lower_hand" // Participant stops requesting to speak
+	EventRaiseHand     Event = "raise_hand"      // Participant requests to speak
+	EventLowerHand     Event = "lower_hand"      // Participant stops requesting to speak
+	EventLowerAllHands Event = "lower_all_hands" // Host clears every raised hand at once
+	EventReaction      Event = "reaction"        // Floating emoji reaction shown to the room
+
+	// Active speaker events
+	EventActiveSpeaker  Event = "active_speaker"  // Client reports whether it is speaking
+	EventActiveSpeakers Event = "active_speakers" // Server tells the room who is speaking
+
+	// Accessibility events
+	EventCaption Event = "caption" // Caption of a participant's speech, generated on their device
+
+	// Diagnostics events
+	EventConnectionStats  Event = "connection_stats"  // Client reports its latest WebRTC connection statistics
+	EventConnectionReport Event = "connection_report" // Host requests, and receives, every client's latest statistics
+
+	// Spotlight events
+	EventSpotlight      Event = "spotlight"       // Host makes one participant everyone's main view
+	EventClearSpotlight Event = "clear_spotlight" // Host, or the spotlighted participant leaving, clears the spotlight
+
+	// Webinar speaker events
+	EventRequestSpeak Event = "request_speak" // Spectator asks hosts to become a speaker
+	EventGrantSpeak   Event = "grant_speak"   // Host promotes a spectator to participant
+	EventRevokeSpeak  Event = "revoke_speak"  // Host demotes a speaker back to spectator
 
 	// Waiting room management events
 	EventRequestWaiting Event = "waiting_request" // Client requests to join the room
 	EventAcceptWaiting  Event = "accept_waiting"  // Host admits a waiting client
 	EventDenyWaiting    Event = "deny_waiting"    // Host denies a waiting client
+	EventWaitingTimeout Event = "waiting_timeout" // Waiting client was not admitted in time
+	EventHostPromoted   Event = "host_promoted"   // Waiting client was promoted because the room had no host
 
 	// Connection lifecycle events
-	EventConnect    Event = "connect"    // Client establishes connection to room
-	EventDisconnect Event = "disconnect" // Client leaves the room
+	EventConnect         Event = "connect"          // Client establishes connection to room
+	EventDisconnect      Event = "disconnect"       // Client's connection dropped without a graceful leave
+	EventLeave           Event = "leave"            // Client announces it is leaving intentionally
+	EventParticipantLeft Event = "participant_left" // Broadcast when a client left intentionally
+	EventKick            Event = "kick"             // Host removes a client; broadcast to those remaining
+	EventKicked          Event = "kicked"           // Sent to a removed client just before its connection closes
+	EventSessionEnded    Event = "session_ended"    // Sent before closing a connection whose linked connection on another hub ended the session
+	EventRoomMoved       Event = "room_moved"       // Sent to a client the server moved to another room, with that room's state
+	EventSetPolicy       Event = "set_policy"       // Host changes what participants may do; broadcast to the room
+	EventTransferHost    Event = "transfer_host"    // Host hands the host role to a participant and becomes a participant
+	EventReclaimHost     Event = "reclaim_host"     // Previous host undoes a transfer within HostReclaimWindow
+	EventSetWelcome      Event = "set_welcome"      // Host changes the welcome message; broadcast to hosts
+	EventWelcome         Event = "welcome"          // Sent to a client on admission with the room's welcome message
+	EventRestoreSession  Event = "restore_session"  // Reconnected client requests, and receives, its role, the room state and recent chats
 
 	// Screen sharing events
 	EventRequestScreenshare Event = "request_screenshare" // Request permission to share screen
 	EventAcceptScreenshare  Event = "accept_screenshare"  // Host grants screen sharing permission
 	EventDenyScreenshare    Event = "deny_screenshare"    // Host denies screen sharing permission
+	EventStopScreenshare    Event = "stop_screenshare"    // Sharer stops sharing, or a host stops it; broadcast to the room
+
+	// Remote control events, relayed between a viewer and a screen sharer
+	EventRequestRemoteControl Event = "request_remote_control" // Viewer asks a sharer for control of the shared screen
+	EventGrantRemoteControl   Event = "grant_remote_control"   // Sharer hands control to the requesting viewer
+	EventDenyRemoteControl    Event = "deny_remote_control"    // Sharer refuses the request
 
 	// WebRTC signaling events for peer-to-peer connection establishment
-	EventOffer       Event = "offer"       // WebRTC offer for establishing peer connection
-	EventAnswer      Event = "answer"      // WebRTC answer responding to an offer
-	EventCandidate   Event = "candidate"   // ICE candidate for connectivity establishment
-	EventRenegotiate Event = "renegotiate" // Request to renegotiate connection (for adding/removing streams)
+	EventOffer              Event = "offer"                // WebRTC offer for establishing peer connection
+	EventAnswer             Event = "answer"               // WebRTC answer responding to an offer
+	EventCandidate          Event = "candidate"            // ICE candidate for connectivity establishment
+	EventCandidateBatch     Event = "candidate_batch"      // Several ICE candidates coalesced for one target
+	EventGlareDetected      Event = "glare_detected"       // Sent to the polite peer when both peers offered simultaneously
+	EventRenegotiate        Event = "renegotiate"          // Request to renegotiate connection (for adding/removing streams)
+	EventPauseVideo         Event = "pause_video"          // Ask a peer to stop sending video the requester is not rendering
+	EventMediaStateSnapshot Event = "media_state_snapshot" // Sent to a newly admitted client with every peer's media state
+	EventMediaState         Event = "media_state"          // Client reports its microphone and camera state; broadcast to the room
+	EventResumeVideo        Event = "resume_video"         // Ask a peer to resume sending previously paused video
+	EventIsPresent          Event = "is_present"           // Client asks, and is told, whether a peer is still in the call
+	EventCodecPolicy        Event = "codec_policy"         // Sent to a client on admission with the codecs the room allows
+
+	// Error reporting events
+	EventError Event = "error" // Server-to-client notice that a message was rejected
+
+	// System events
+	EventSystemAnnouncement Event = "system_announcement" // Operator notice pushed to every connected client
+	EventRoleChanged        Event = "role_changed"        // Sent to a client whose role changed, with what it may now send
+	EventForceMute          Event = "force_mute"          // Tells a client to turn its microphone off
+
+	// Development and debugging events
+	EventValidate         Event = "validate"          // Dry-run a wrapped message without executing it
+	EventValidationResult Event = "validation_result" // Result of a dry-run validation sent to the requester
+)
+
+// ErrorCode classifies why the server rejected a client's message.
+type ErrorCode string
+
+// Error code constants sent in ErrorPayload.Code.
+const (
+	ErrorCodeParse           ErrorCode = "parse_error"       // The message was not valid JSON for a Message
+	ErrorCodeRoomFull        ErrorCode = "room_full"         // The room has reached its participant capacity
+	ErrorCodeRateLimited     ErrorCode = "rate_limited"      // The client sent too many messages of this kind
+	ErrorCodeEventNotAllowed ErrorCode = "event_not_allowed" // The event is outside this endpoint's scope
+	ErrorCodeFeatureDisabled ErrorCode = "feature_disabled"  // The event's feature is turned off for this room
+	ErrorCodeHandQueueFull   ErrorCode = "hand_queue_full"   // The room's raised-hand limit has been reached
+	ErrorCodeSelfTarget      ErrorCode = "self_target"       // A role change named the client making it
+	ErrorCodeReactionBlocked ErrorCode = "reaction_blocked"  // The reaction is not on the room's allowlist
+	ErrorCodePolicyDenied    ErrorCode = "policy_denied"     // The host's participant policy forbids the event
+	ErrorCodeStoreFailed     ErrorCode = "store_failed"      // The message could not be persisted
+	ErrorCodeChatRejected    ErrorCode = "chat_rejected"     // Moderation blocked the message; the reason is in the message
+	ErrorCodeChatNotFound    ErrorCode = "chat_not_found"    // The named message is not in the room's history
+	ErrorCodeClientNotFound  ErrorCode = "client_not_found"  // The named client is not in the room in a role the event applies to
+	ErrorCodeHostConnected   ErrorCode = "host_connected"    // The host is already connected to the room elsewhere
+	ErrorCodePayloadTooLarge ErrorCode = "payload_too_large" // The payload exceeds the event's size limit
+	ErrorCodeReclaimExpired  ErrorCode = "reclaim_expired"   // There is no host transfer the client may still undo
+	ErrorCodeNotAuthor       ErrorCode = "not_author"        // Only a message's author or a host may delete it
+	ErrorCodeNotAdmitted     ErrorCode = "not_admitted"      // The client is waiting and may only ask to be admitted or leave
+	ErrorCodeNotSharing      ErrorCode = "not_sharing"       // The named client is not sharing its screen
 )
 
 // Message is the top-level structure for all WebSocket communication.
@@ -118,31 +218,156 @@ type Message struct {
 type RaiseHandPayload = ClientInfo // Payload for requesting to speak
 type LowerHandPayload = ClientInfo // Payload for stopping request to speak
 
+// LowerAllHandsPayload announces that a host cleared the hand queue. The
+// request's payload is ignored; the server stamps the host and lists the
+// hands it lowered, in the order they were raised.
+type LowerAllHandsPayload struct {
+	ClientInfo              // The host who cleared the queue
+	Lowered    []ClientInfo `json:"lowered"` // Participants whose hands were lowered
+}
+
+// ReactionPayload is a floating emoji reaction. The sender is stamped by the
+// server, and Emoji must be on the room's allowlist.
+type ReactionPayload struct {
+	ClientInfo        // Who reacted
+	Emoji      string `json:"emoji"` // The reaction to display
+}
+
 // Waiting room management payloads
 type AcceptWaitingPayload = ClientInfo  // Payload for admitting a waiting client
 type DenyWaitingPayload = ClientInfo    // Payload for denying a waiting client
+type WaitingTimeoutPayload = ClientInfo // Sent when a waiting client times out
+type HostPromotedPayload = ClientInfo   // Sent when a waiting client is promoted to host
 type RequestWaitingPayload = ClientInfo // Payload for requesting room admission
 
+// Webinar speaker payload type aliases
+type RequestSpeakPayload = ClientInfo // Spectator asking to speak, stamped by the server
+type GrantSpeakPayload = ClientInfo   // Spectator being promoted to participant
+type RevokeSpeakPayload = ClientInfo  // Speaker being demoted to spectator
+
+// Spotlight payload type aliases
+type SpotlightPayload = ClientInfo      // Participant being spotlighted
+type ClearSpotlightPayload = ClientInfo // Participant whose spotlight was cleared; ignored in requests
+
+// JoinLeavePayload announces a client joining or leaving the room. Notify hints
+// whether clients should play an audible chime; rosters update either way.
+// See RoomConfig.SilentJoinLeave and RoomConfig.JoinLeaveNotifyLimit.
+type JoinLeavePayload struct {
+	ClientInfo      // The client that joined or left
+	Notify     bool `json:"notify"` // Whether clients should chime
+}
+
 // Connection lifecycle payloads
-type ParticipantJoinedPayload = ClientInfo // Broadcast when someone joins
-type ParticipantLeftPayload = ClientInfo   // Broadcast when someone leaves
-type ClientDisconnectPayload = ClientInfo  // Broadcast when someone disconnects
+type ParticipantJoinedPayload = JoinLeavePayload // Broadcast when someone is admitted
+type LeavePayload = ClientInfo                   // Payload for announcing an intentional leave
+type ParticipantLeftPayload = JoinLeavePayload   // Broadcast when someone leaves intentionally
+type ClientDisconnectPayload = JoinLeavePayload  // Broadcast when someone's connection drops
+
+// SessionEndedPayload tells a client why its connection is being closed along
+// with a linked connection on another hub; see linked.go.
+type SessionEndedPayload struct {
+	Reason DisconnectReason `json:"reason"` // How the connection that ended the session left
+}
+
+// RoomMovedPayload tells a client it now belongs to another room on the same
+// connection, such as when a breakout ends; see Hub.MoveClient. It carries
+// what the client needs to rebuild its view, like SessionRestoredPayload.
+type RoomMovedPayload struct {
+	From         RoomIdType       `json:"from"`         // The room the client left
+	Role         RoleType         `json:"role"`         // The client's role in its new room
+	Capabilities []Event          `json:"capabilities"` // Events the client may send, sorted
+	State        RoomStatePayload `json:"state"`        // The new room's state
+}
+
+// KickPayload names the client a host is removing and, optionally, why.
+type KickPayload struct {
+	ClientInfo        // The client being removed
+	Reason     string `json:"reason,omitempty"` // Shown to the removed client, if given
+}
+
+// KickedPayload tells a removed client who it is and why it was removed.
+type KickedPayload = KickPayload
+
+// ParticipantPolicy is what hosts currently allow everyone else in the room to
+// do. It applies to clients already admitted and to those admitted later.
+type ParticipantPolicy struct {
+	AllowChat        bool `json:"allowChat"`        // Send chat messages
+	AllowScreenshare bool `json:"allowScreenshare"` // Ask to share their screen
+	AllowReactions   bool `json:"allowReactions"`   // Send emoji reactions
+}
+
+// SetPolicyPayload replaces the room's participant policy. When broadcast,
+// ClientInfo names the host who changed it.
+type SetPolicyPayload struct {
+	ClientInfo
+	ParticipantPolicy
+}
+
+// maxWelcomeMessageLength is the longest welcome message, in characters, a
+// room keeps. Longer configured messages are cut to this length.
+const maxWelcomeMessageLength = 500
+
+// TransferHostPayload names the participant a host is handing the host role
+// to. When broadcast, ClientInfo is the new host, PreviousHostId the host who
+// transferred it, and ReclaimUntil when the previous host stops being able
+// to undo the transfer, omitted when the room allows no undo.
+type TransferHostPayload struct {
+	ClientInfo
+	PreviousHostId ClientIdType `json:"previousHostId,omitempty"`
+	ReclaimUntil   Timestamp    `json:"reclaimUntil,omitempty"`
+}
+
+// ReclaimHostPayload is broadcast when a previous host undoes a transfer.
+// ClientInfo is the host again; DemotedClientId is the host the role was
+// transferred to, omitted if that client has since left or lost the role.
+type ReclaimHostPayload struct {
+	ClientInfo
+	DemotedClientId ClientIdType `json:"demotedClientId,omitempty"`
+}
+
+// SetWelcomePayload replaces the room's welcome message; an empty message
+// turns it off. When broadcast, ClientInfo names the host who changed it and
+// Message is the message as stored, after sanitizing.
+type SetWelcomePayload struct {
+	ClientInfo
+	Message string `json:"message"`
+}
+
+// Validate rejects messages longer than maxWelcomeMessageLength.
+func (s SetWelcomePayload) Validate() error {
+	if utf8.RuneCountInString(s.Message) > maxWelcomeMessageLength {
+		return errors.New("welcome message cannot exceed 500 characters")
+	}
+	return nil
+}
+
+// WelcomePayload is the welcome message sent to a client on admission. It is
+// a system notice, not a chat message, and is not kept in chat history.
+type WelcomePayload struct {
+	Message string `json:"message"`
+}
 
 // Screen sharing payloads
 type RequestScreensharePayload = ClientInfo // Payload for requesting screen share permission
 type AcceptScreensharePayload = ClientInfo  // Payload for granting screen share permission
 type DenyScreensharePayload = ClientInfo    // Payload for denying screen share permission
+type StopScreensharePayload = ClientInfo    // Client whose share stopped; sharers may only stop their own
 
 // RoomStatePayload contains a comprehensive snapshot of the current room state.
 // This is typically sent to clients when they join or when significant changes occur.
 type RoomStatePayload struct {
-	ClientInfo                 // Information about the requesting client
-	RoomID        RoomIdType   `json:"roomId"`                  // Unique identifier for this room
-	Hosts         []ClientInfo `json:"hosts"`                   // All clients with host privileges
-	Participants  []ClientInfo `json:"participants"`            // All active participants in the call
-	HandsRaised   []ClientInfo `json:"handsRaised"`             // Participants currently requesting to speak
-	WaitingUsers  []ClientInfo `json:"waitingUsers"`            // Clients waiting for admission
-	SharingScreen []ClientInfo `json:"sharingScreen,omitempty"` // Clients currently sharing screen
+	ClientInfo                      // Information about the requesting client
+	RoomID        RoomIdType        `json:"roomId"`                  // Unique identifier for this room
+	Hosts         []ClientInfo      `json:"hosts"`                   // All clients with host privileges
+	Participants  []ClientInfo      `json:"participants"`            // All active participants in the call
+	HandsRaised   []ClientInfo      `json:"handsRaised"`             // Participants currently requesting to speak
+	WaitingUsers  []ClientInfo      `json:"waitingUsers"`            // Clients waiting for admission
+	SharingScreen []ClientInfo      `json:"sharingScreen,omitempty"` // Clients currently sharing screen
+	Spectators    []ClientInfo      `json:"spectators,omitempty"`    // Webinar attendees who are watching only
+	Policy        ParticipantPolicy `json:"policy"`                  // What hosts allow everyone else to do
+	PinnedChatIds []ChatId          `json:"pinnedChatIds,omitempty"` // Pinned messages, oldest first
+	Welcome       string            `json:"welcomeMessage,omitempty"`
+	Spotlight     ClientIdType      `json:"spotlightedClientId,omitempty"` // Participant everyone's main view shows, if any
 }
 
 // ChatInfo represents a complete chat message with all associated metadata.
@@ -150,7 +375,7 @@ type RoomStatePayload struct {
 type ChatInfo struct {
 	ClientInfo              // Who sent the message
 	ChatId      ChatId      `json:"chatId"`      // Unique identifier for this message
-	Timestamp   Timestamp   `json:"chatIndex"`   // When the message was sent
+	Timestamp   Timestamp   `json:"chatIndex"`   // When the server received the message
 	ChatContent ChatContent `json:"chatContent"` // The actual message content
 }
 
@@ -183,9 +408,33 @@ func (c ChatInfo) Validate() error {
 
 // Chat-related payload type aliases
 // These provide semantic meaning when ChatInfo is used in different contexts.
-type AddChatPayload = ChatInfo        // Payload for adding a new chat message
-type DeleteChatPayload = ChatInfo     // Payload for deleting an existing message
-type GetRecentChatsPayload = ChatInfo // Payload for requesting recent chat history
+type AddChatPayload = ChatInfo    // Payload for adding a new chat message
+type DeleteChatPayload = ChatInfo // Payload for deleting an existing message
+
+// DeleteUserChatsPayload names the client whose messages a host is removing.
+// The broadcast lists the ids of the messages that were removed.
+type DeleteUserChatsPayload struct {
+	ClientInfo          // The author whose messages are removed
+	ChatIds    []ChatId `json:"chatIds,omitempty"` // Removed messages; ignored in the request
+}
+
+// PinChatPayload names a message a host is pinning. The broadcast carries the
+// host who pinned it.
+type PinChatPayload struct {
+	ClientInfo        // The host making the change
+	ChatId     ChatId `json:"chatId"` // The message to pin
+}
+
+type UnpinChatPayload = PinChatPayload // Payload for unpinning a message
+type GetRecentChatsPayload = ChatInfo  // Payload for requesting recent chat history
+
+// GetChatsByRangePayload requests the chat messages whose Timestamp falls within
+// [FromTimestamp, ToTimestamp], for jumping to a point in a long meeting.
+type GetChatsByRangePayload struct {
+	ClientInfo              // Who is requesting the messages
+	FromTimestamp Timestamp `json:"fromTimestamp"` // Inclusive start of the range
+	ToTimestamp   Timestamp `json:"toTimestamp"`   // Inclusive end of the range
+}
 
 // --- WebRTC Signaling Payloads ---
 
@@ -196,6 +445,7 @@ type WebRTCOfferPayload struct {
 	TargetClientId ClientIdType `json:"targetClientId"` // ID of the client to establish connection with
 	SDP            string       `json:"sdp"`            // Session Description Protocol offer
 	Type           string       `json:"type"`           // Always "offer" for offer payloads
+	Seq            uint64       `json:"seq,omitempty"`  // Position on the sender-to-target route, stamped by the server
 }
 
 // WebRTCAnswerPayload contains the SDP answer responding to a WebRTC offer.
@@ -205,6 +455,7 @@ type WebRTCAnswerPayload struct {
 	TargetClientId ClientIdType `json:"targetClientId"` // ID of the client who sent the original offer
 	SDP            string       `json:"sdp"`            // Session Description Protocol answer
 	Type           string       `json:"type"`           // Always "answer" for answer payloads
+	Seq            uint64       `json:"seq,omitempty"`  // Position on the sender-to-target route, stamped by the server
 }
 
 // WebRTCCandidatePayload contains ICE candidate information for connectivity.
@@ -216,6 +467,25 @@ type WebRTCCandidatePayload struct {
 	Candidate      string       `json:"candidate"`      // ICE candidate string
 	SDPMid         *string      `json:"sdpMid"`         // Media stream identification
 	SDPMLineIndex  *int         `json:"sdpMLineIndex"`  // Media line index in SDP
+	Seq            uint64       `json:"seq,omitempty"`  // Position on the sender-to-target route, stamped by the server
+}
+
+// WebRTCCandidateBatchPayload carries several ICE candidates from one sender to one target.
+// It is produced by the server when candidate batching is enabled; candidates
+// appear in the order the sender produced them.
+type WebRTCCandidateBatchPayload struct {
+	ClientInfo                              // Information about the client that sent the candidates
+	TargetClientId ClientIdType             `json:"targetClientId"` // ID of the client receiving the candidates
+	Candidates     []WebRTCCandidatePayload `json:"candidates"`     // Candidates in send order
+}
+
+// GlareDetectedPayload tells a peer that its offer collided with one from PeerClientId.
+// It is only sent to the polite peer of the pair, which should roll back its
+// local offer and answer the peer's offer instead. The polite peer is the one
+// with the lexically greater client ID, so both sides agree without coordination.
+type GlareDetectedPayload struct {
+	PeerClientId ClientIdType `json:"peerClientId"` // ID of the peer whose offer takes precedence
+	Polite       bool         `json:"polite"`       // Always true; the recipient is the polite peer
 }
 
 // WebRTCRenegotiatePayload signals the need to renegotiate the peer connection.
@@ -224,4 +494,205 @@ type WebRTCRenegotiatePayload struct {
 	ClientInfo                  // Information about the client requesting renegotiation
 	TargetClientId ClientIdType `json:"targetClientId"` // ID of the client to renegotiate with
 	Reason         string       `json:"reason"`         // Reason for renegotiation (optional, for debugging)
+	Seq            uint64       `json:"seq,omitempty"`  // Position on the sender-to-target route, stamped by the server
+}
+
+// VideoPausePayload asks the target to pause or resume sending its video to the sender.
+// It is used with EventPauseVideo and EventResumeVideo when a participant's tile
+// scrolls out of view or back in, saving bandwidth for video nobody is watching.
+type VideoPausePayload struct {
+	ClientInfo                  // Information about the client that is not rendering the video
+	TargetClientId ClientIdType `json:"targetClientId"` // ID of the client whose video should pause or resume
+}
+
+// RemoteControlPayload carries a request for control of a shared screen from
+// a viewer to the sharer, and the sharer's grant or denial back. The server
+// only relays it; the clients carry the control itself. The sender is stamped
+// by the server.
+type RemoteControlPayload struct {
+	ClientInfo                  // The client sending the request or answer
+	TargetClientId ClientIdType `json:"targetClientId"` // The sharer for a request; the requesting viewer for an answer
+}
+
+// CodecPolicy lists the WebRTC codecs a room allows, most preferred first,
+// by MIME subtype such as "opus" or "VP8". It is advisory: clients reorder or
+// strip codecs in their own SDP, and the server never inspects media. An
+// empty list leaves that kind of media unconstrained.
+type CodecPolicy struct {
+	Audio []string `json:"audio,omitempty"` // Allowed audio codecs, most preferred first
+	Video []string `json:"video,omitempty"` // Allowed video codecs, most preferred first
+}
+
+// IsZero reports whether the policy constrains no codecs.
+func (c CodecPolicy) IsZero() bool {
+	return len(c.Audio) == 0 && len(c.Video) == 0
+}
+
+// CodecPolicyPayload is sent with EventCodecPolicy.
+type CodecPolicyPayload = CodecPolicy
+
+// IsPresentPayload asks whether a client is still in the call, such as
+// before sending it an offer, and carries the answer back to the requester.
+// Present and Role are only meaningful in the server's reply.
+type IsPresentPayload struct {
+	ClientId ClientIdType `json:"clientId"`       // The client asked about
+	Present  bool         `json:"present"`        // Whether the client is in the call
+	Role     RoleType     `json:"role,omitempty"` // The client's current role, if present
+}
+
+// ActiveSpeakerPayload is a client's report of its own speaking state, from
+// local audio level detection. Reports always describe the sender.
+type ActiveSpeakerPayload struct {
+	ClientInfo      // The reporting client; stamped by the server
+	Speaking   bool `json:"speaking"` // Whether the client's audio is above its speaking threshold
+}
+
+// ActiveSpeakersPayload tells the room who is currently speaking.
+type ActiveSpeakersPayload struct {
+	Speakers []ClientInfo `json:"speakers"`           // Current speakers, most recent to start first
+	Dominant ClientIdType `json:"dominant,omitempty"` // The speaker who started most recently, if any
+}
+
+// CaptionPayload is a line of a participant's speech transcribed on their own
+// device. The server stamps the speaker and timestamp before relaying it.
+type CaptionPayload struct {
+	ClientInfo           // The speaker; stamped by the server
+	Text       string    `json:"text"`      // The transcribed speech
+	Timestamp  Timestamp `json:"timestamp"` // When the server received the caption, in Unix milliseconds
+}
+
+// Validate rejects empty or oversized captions.
+func (c CaptionPayload) Validate() error {
+	if strings.TrimSpace(c.Text) == "" {
+		return errors.New("caption text cannot be empty")
+	}
+	if len(c.Text) > 1000 {
+		return errors.New("caption text cannot exceed 1000 characters")
+	}
+	return nil
+}
+
+// ConnectionStatsPayload is a client's summary of its WebRTC connection
+// quality, taken from its peer connections' getStats. The server stamps the
+// client and timestamp and keeps only the latest report from each client.
+type ConnectionStatsPayload struct {
+	ClientInfo            // The reporting client; stamped by the server
+	PacketLoss  float64   `json:"packetLoss"`  // Fraction of packets lost, from 0 to 1
+	RoundTripMs float64   `json:"roundTripMs"` // Round-trip time in milliseconds
+	JitterMs    float64   `json:"jitterMs"`    // Jitter in milliseconds
+	Timestamp   Timestamp `json:"timestamp"`   // When the server received the report, in Unix milliseconds
+}
+
+// Validate rejects statistics outside their possible ranges.
+func (c ConnectionStatsPayload) Validate() error {
+	if c.PacketLoss < 0 || c.PacketLoss > 1 {
+		return errors.New("packet loss must be between 0 and 1")
+	}
+	if c.RoundTripMs < 0 || c.JitterMs < 0 {
+		return errors.New("round-trip time and jitter cannot be negative")
+	}
+	return nil
+}
+
+// ConnectionReportPayload answers a host's EventConnectionReport with the
+// latest statistics of every client that has reported, ordered by client ID.
+type ConnectionReportPayload struct {
+	Stats []ConnectionStatsPayload `json:"stats"`
+}
+
+// MediaStatePayload is a client's report of its own microphone and camera
+// state. Reports always describe the sender.
+type MediaStatePayload struct {
+	ClientInfo      // The reporting client; stamped by the server
+	Unmuted    bool `json:"unmuted"`  // Microphone is enabled
+	CameraOn   bool `json:"cameraOn"` // Camera is enabled
+}
+
+// PeerMediaState describes which media a peer is currently sending.
+type PeerMediaState struct {
+	ClientInfo         // The peer the state belongs to
+	CameraOn      bool `json:"cameraOn"`      // Camera is enabled
+	Unmuted       bool `json:"unmuted"`       // Microphone is enabled
+	SharingScreen bool `json:"sharingScreen"` // Screen is being shared
+}
+
+// MediaStateSnapshotPayload lists the media state of every admitted peer, so a
+// newly admitted client can render peers correctly before any toggle event arrives.
+type MediaStateSnapshotPayload struct {
+	Peers  []PeerMediaState  `json:"peers"`  // Every other admitted client, in join order
+	Policy ParticipantPolicy `json:"policy"` // What hosts currently allow participants to do
+}
+
+// RoleChangedPayload tells a client its own new role, so it can update its UI
+// without inferring the change from room-wide broadcasts.
+type RoleChangedPayload struct {
+	Role         RoleType `json:"role"`         // The client's new role
+	Capabilities []Event  `json:"capabilities"` // Events the client may now send, sorted
+}
+
+// ForceMuteReason says why the server told a client to mute.
+type ForceMuteReason string
+
+const (
+	ForceMuteReasonJoin ForceMuteReason = "join" // The room mutes participants as they are admitted
+)
+
+// ForceMutePayload tells a client to turn its microphone off and keep it off
+// until the user unmutes.
+type ForceMutePayload struct {
+	Reason ForceMuteReason `json:"reason"`
+}
+
+// RestoreSessionPayload is sent by a client after reconnecting. Draft is the
+// chat message the user was composing when the connection dropped, if any.
+type RestoreSessionPayload struct {
+	ClientInfo
+	Draft string `json:"draft,omitempty"`
+}
+
+// Validate rejects drafts longer than a chat message may be.
+func (r RestoreSessionPayload) Validate() error {
+	if len(r.Draft) > 1000 {
+		return errors.New("draft cannot exceed 1000 characters")
+	}
+	return nil
+}
+
+// SessionRestoredPayload answers EventRestoreSession with everything a
+// reconnected client needs to rebuild its view, taken at a single moment so
+// the parts are consistent with each other.
+type SessionRestoredPayload struct {
+	Role         RoleType         `json:"role"`            // The client's role, restored if it reconnected within ReconnectWindow
+	Capabilities []Event          `json:"capabilities"`    // Events the client may send, sorted
+	State        RoomStatePayload `json:"state"`           // The room state
+	RecentChats  []AddChatPayload `json:"recentChats"`     // The same messages EventGetRecentChats returns; empty when chat is disabled
+	Draft        string           `json:"draft,omitempty"` // The request's draft, omitted when the client may no longer chat
+}
+
+// SystemAnnouncementPayload carries an operator notice, such as upcoming maintenance.
+type SystemAnnouncementPayload struct {
+	Message string `json:"message"` // Human-readable announcement text
+}
+
+// ErrorPayload describes why a client's message was rejected.
+// It is sent directly to the offending client with EventError.
+type ErrorPayload struct {
+	Code    ErrorCode `json:"code"`            // Machine-readable reason for the rejection
+	Message string    `json:"message"`         // Human-readable description for debugging
+	Event   Event     `json:"event,omitempty"` // The rejected event, when it could be determined
+}
+
+// --- Validation Payloads ---
+
+// ValidatePayload wraps the message a client wants to dry-run.
+// The wrapped message is checked against the same permission and payload
+// validation rules as a real message, but its handler is never executed.
+type ValidatePayload = Message
+
+// ValidationResultPayload reports the outcome of a dry-run validation.
+// It is sent only to the client that requested the validation.
+type ValidationResultPayload struct {
+	Event  Event    `json:"event"`            // The event that was validated
+	Ok     bool     `json:"ok"`               // Whether the message would be accepted
+	Errors []string `json:"errors,omitempty"` // Reasons the message would be rejected
 }
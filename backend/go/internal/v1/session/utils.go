@@ -16,6 +16,8 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // GetAllowedOriginsFromEnv reads and parses CORS allowed origins from environment variables.
@@ -67,7 +69,7 @@ func GetAllowedOriginsFromEnv(envVarName string, defaultEnvs []string) []string
 // Typically used in logging helpers and error reporting:
 //
 //	slog.Info("Handler called", "function", GetFuncName())
-//	logHelper(ok, client.ID, GetFuncName(), room.ID)
+//	r.logHelper(ok, client.ID, GetFuncName())
 //
 // Performance Considerations:
 // Runtime reflection has some overhead, so this function should primarily
@@ -80,3 +82,88 @@ func GetFuncName() string {
 	pc, _, _, _ := runtime.Caller(1)
 	return fmt.Sprintf("%s", runtime.FuncForPC(pc).Name())
 }
+
+// sanitizeDisplayName makes a user-supplied name safe to show in rosters and
+// chat. Line breaks, tabs and other whitespace collapse to single spaces;
+// control characters and bidirectional overrides, which could reorder or hide
+// neighbouring text, are removed; the result is trimmed and cut to at most
+// limit characters.
+//
+// Every path that accepts a display name from outside the server should pass
+// it through here before storing it on a client.
+//
+// Parameters:
+//   - name: The raw name, such as a token claim
+//   - limit: Maximum number of characters (runes) to keep
+//
+// Returns:
+//   - The cleaned name, or "" when nothing printable remains so the caller
+//     can fall back to a generated name
+func sanitizeDisplayName(name string, limit int) DisplayNameType {
+	var b strings.Builder
+	space := false
+	count := 0
+	for _, r := range name {
+		if count >= limit {
+			break
+		}
+		switch {
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+			continue
+		case unicode.IsControl(r), isBidiControl(r), r == utf8.RuneError:
+			continue
+		}
+		if space {
+			if count+1 >= limit {
+				break
+			}
+			b.WriteByte(' ')
+			count++
+			space = false
+		}
+		b.WriteRune(r)
+		count++
+	}
+	return DisplayNameType(b.String())
+}
+
+// sanitizeNotice makes host-written text, such as a welcome message, safe to
+// show to every client. It works like sanitizeDisplayName but keeps line
+// breaks, so a notice can span several lines: other control characters and
+// bidirectional overrides are removed, surrounding whitespace is trimmed and
+// the result is cut to at most limit characters.
+//
+// Parameters:
+//   - text: The raw text
+//   - limit: Maximum number of characters (runes) to keep
+//
+// Returns:
+//   - The cleaned text, or "" when nothing printable remains
+func sanitizeNotice(text string, limit int) string {
+	var b strings.Builder
+	count := 0
+	for _, r := range strings.TrimSpace(text) {
+		if count >= limit {
+			break
+		}
+		switch {
+		case r == '\r':
+			continue
+		case r == '\t':
+			r = ' '
+		case r == '\n':
+		case unicode.IsControl(r), isBidiControl(r), r == utf8.RuneError:
+			continue
+		}
+		b.WriteRune(r)
+		count++
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// isBidiControl reports whether r is one of the explicit bidirectional
+// formatting characters: embeddings, overrides, isolates and marks.
+func isBidiControl(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069') || r == '\u200e' || r == '\u200f' || r == '\u061c'
+}
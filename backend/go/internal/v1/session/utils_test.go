@@ -1,6 +1,7 @@
 package session
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -21,3 +22,28 @@ func TestGetAllowedOriginsFromEnv(t *testing.T) {
 		assert.Equal(t, allowedOrigins, []string{"localhost:5050", "localhost:1234"})
 	})
 }
+
+func TestSanitizeDisplayName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		limit int
+		want  DisplayNameType
+	}{
+		{name: "plain name is unchanged", input: "Ada Lovelace", limit: 64, want: "Ada Lovelace"},
+		{name: "surrounding whitespace is trimmed", input: "  Ada  ", limit: 64, want: "Ada"},
+		{name: "newlines and tabs collapse to one space", input: "Ada\n\n\tLovelace\r\n", limit: 64, want: "Ada Lovelace"},
+		{name: "control characters are removed", input: "Ad\x00a\x1b[31m\x7f", limit: 64, want: "Ada[31m"},
+		{name: "bidi overrides are removed", input: "Ada\u202egpj.exe", limit: 64, want: "Adagpj.exe"},
+		{name: "oversized name is cut", input: strings.Repeat("a", 10*1024), limit: 64, want: DisplayNameType(strings.Repeat("a", 64))},
+		{name: "cut counts characters, not bytes", input: "ééééé", limit: 3, want: "ééé"},
+		{name: "cut never leaves a trailing space", input: "Ada Lovelace", limit: 4, want: "Ada"},
+		{name: "all whitespace is empty", input: " \t\n  ", limit: 64, want: ""},
+		{name: "only control characters is empty", input: "\x00\x01\u202e", limit: 64, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizeDisplayName(tt.input, tt.limit))
+		})
+	}
+}
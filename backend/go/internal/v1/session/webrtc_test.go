@@ -1,12 +1,16 @@
 package session
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	testclock "k8s.io/utils/clock/testing"
 )
 
 // TestHandleWebRTCOffer tests WebRTC offer handling
@@ -40,7 +44,7 @@ func TestHandleWebRTCOffer(t *testing.T) {
 		msg := Message{Event: EventOffer, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(sender, msg)
+			room.router(context.Background(), sender, msg)
 		}, "Router should not panic for WebRTC offer")
 
 		// Check that target received the offer
@@ -70,7 +74,7 @@ func TestHandleWebRTCOffer(t *testing.T) {
 		msg := Message{Event: EventOffer, Payload: "invalid"}
 
 		assert.NotPanics(t, func() {
-			room.router(sender, msg)
+			room.router(context.Background(), sender, msg)
 		}, "Router should not panic with invalid payload")
 	})
 
@@ -93,7 +97,7 @@ func TestHandleWebRTCOffer(t *testing.T) {
 		msg := Message{Event: EventOffer, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(sender, msg)
+			room.router(context.Background(), sender, msg)
 		}, "Router should not panic for non-existent target")
 	})
 }
@@ -129,7 +133,7 @@ func TestHandleWebRTCAnswer(t *testing.T) {
 		msg := Message{Event: EventAnswer, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(answerer, msg)
+			room.router(context.Background(), answerer, msg)
 		}, "Router should not panic for WebRTC answer")
 
 		// Check that target received the answer
@@ -159,7 +163,7 @@ func TestHandleWebRTCAnswer(t *testing.T) {
 		msg := Message{Event: EventAnswer, Payload: nil}
 
 		assert.NotPanics(t, func() {
-			room.router(answerer, msg)
+			room.router(context.Background(), answerer, msg)
 		}, "Router should not panic with nil payload")
 	})
 }
@@ -198,7 +202,7 @@ func TestHandleWebRTCCandidate(t *testing.T) {
 		msg := Message{Event: EventCandidate, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(sender, msg)
+			room.router(context.Background(), sender, msg)
 		}, "Router should not panic for ICE candidate")
 
 		// Check that target received the candidate
@@ -246,7 +250,7 @@ func TestHandleWebRTCCandidate(t *testing.T) {
 		msg := Message{Event: EventCandidate, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(sender, msg)
+			room.router(context.Background(), sender, msg)
 		}, "Router should not panic for minimal candidate")
 
 		// Verify message is still forwarded
@@ -289,7 +293,7 @@ func TestHandleWebRTCRenegotiate(t *testing.T) {
 		msg := Message{Event: EventRenegotiate, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(initiator, msg)
+			room.router(context.Background(), initiator, msg)
 		}, "Router should not panic for renegotiation request")
 
 		// Check that target received the renegotiation request
@@ -335,7 +339,7 @@ func TestHandleWebRTCRenegotiate(t *testing.T) {
 		msg := Message{Event: EventRenegotiate, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(initiator, msg)
+			room.router(context.Background(), initiator, msg)
 		}, "Router should not panic for renegotiation without reason")
 
 		// Verify message is still forwarded
@@ -376,7 +380,7 @@ func TestWebRTCPermissions(t *testing.T) {
 		msg := Message{Event: EventOffer, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(waitingUser, msg)
+			room.router(context.Background(), waitingUser, msg)
 		}, "Router should not panic for waiting user WebRTC attempt")
 
 		// Host should not receive the offer (blocked by permissions)
@@ -415,7 +419,7 @@ func TestWebRTCPermissions(t *testing.T) {
 		}
 
 		msg := Message{Event: EventOffer, Payload: payload}
-		room.router(participant1, msg)
+		room.router(context.Background(), participant1, msg)
 
 		// Participant 2 should receive the offer
 		select {
@@ -452,7 +456,7 @@ func TestWebRTCPermissions(t *testing.T) {
 		}
 
 		msg := Message{Event: EventCandidate, Payload: payload}
-		room.router(host, msg)
+		room.router(context.Background(), host, msg)
 
 		// Participant should receive the candidate
 		select {
@@ -487,7 +491,7 @@ func TestWebRTCEdgeCases(t *testing.T) {
 		msg := Message{Event: EventOffer, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(client, msg)
+			room.router(context.Background(), client, msg)
 		}, "Router should not panic for self-targeting WebRTC")
 
 		// Client should not receive their own message (depends on implementation)
@@ -524,7 +528,7 @@ func TestWebRTCEdgeCases(t *testing.T) {
 		msg := Message{Event: EventOffer, Payload: payload}
 
 		assert.NotPanics(t, func() {
-			room.router(sender, msg)
+			room.router(context.Background(), sender, msg)
 		}, "Router should not panic for malformed SDP")
 
 		// Target should still receive the message (validation is client-side)
@@ -535,4 +539,718 @@ func TestWebRTCEdgeCases(t *testing.T) {
 			t.Fatal("Target should receive message even with malformed SDP")
 		}
 	})
+
+	t.Run("a target whose channel is full misses the signal without blocking", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		sender := newTestClient("sender1")
+		target := newTestClient("target1")
+		room.addParticipant(sender)
+		room.addParticipant(target)
+		for len(target.send) < cap(target.send) {
+			target.send <- []byte(`{}`)
+		}
+		offer := Message{Event: EventOffer, Payload: WebRTCOfferPayload{TargetClientId: target.ID, SDP: "v=0", Type: "offer"}}
+
+		done := make(chan struct{})
+		go func() {
+			room.router(context.Background(), sender, offer)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Forwarding to a full channel blocked the sender")
+		}
+
+		for len(target.send) > 0 {
+			<-target.send
+		}
+		room.router(context.Background(), sender, offer)
+		require.Len(t, target.send, 1, "Once the channel has room the next signal is delivered")
+		var msg struct {
+			Event   Event              `json:"event"`
+			Payload WebRTCOfferPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-target.send, &msg))
+		assert.Equal(t, EventOffer, msg.Event)
+		assert.Equal(t, sender.ID, msg.Payload.ClientId)
+	})
+}
+
+// TestCandidateBatching tests coalescing of rapid ICE candidates to the same target
+func TestCandidateBatching(t *testing.T) {
+	newBatchingRoom := func(window time.Duration) (*Room, *testclock.FakeClock) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultRoomConfig()
+		config.Clock = fakeClock
+		config.CandidateBatchWindow = window
+		return NewRoomWithConfig("test-room", config, nil), fakeClock
+	}
+
+	candidateMsg := func(sender *Client, target ClientIdType, candidate string) Message {
+		return Message{Event: EventCandidate, Payload: WebRTCCandidatePayload{
+			ClientInfo:     ClientInfo{ClientId: sender.ID, DisplayName: sender.DisplayName},
+			TargetClientId: target,
+			Candidate:      candidate,
+		}}
+	}
+
+	t.Run("rapid candidates to one target are batched in order", func(t *testing.T) {
+		room, fakeClock := newBatchingRoom(50 * time.Millisecond)
+		sender := newTestClientWithName("sender1", "Sender User")
+		target := newTestClientWithName("target1", "Target User")
+		room.addParticipant(sender)
+		room.addParticipant(target)
+
+		for _, c := range []string{"candidate:1", "candidate:2", "candidate:3"} {
+			room.router(context.Background(), sender, candidateMsg(sender, target.ID, c))
+		}
+		assert.Len(t, target.send, 0, "Candidates should be held until the window elapses")
+
+		fakeClock.Step(50 * time.Millisecond)
+
+		require.Len(t, target.send, 1, "Candidates should be delivered as a single message")
+		var msg struct {
+			Event   Event                       `json:"event"`
+			Payload WebRTCCandidateBatchPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-target.send, &msg))
+		assert.Equal(t, EventCandidateBatch, msg.Event)
+		assert.Equal(t, sender.ID, msg.Payload.ClientId)
+		assert.Equal(t, target.ID, msg.Payload.TargetClientId)
+		require.Len(t, msg.Payload.Candidates, 3)
+		for i, want := range []string{"candidate:1", "candidate:2", "candidate:3"} {
+			assert.Equal(t, want, msg.Payload.Candidates[i].Candidate)
+		}
+	})
+
+	t.Run("batches are kept separate per target", func(t *testing.T) {
+		room, fakeClock := newBatchingRoom(50 * time.Millisecond)
+		sender := newTestClientWithName("sender1", "Sender User")
+		target1 := newTestClientWithName("target1", "Target One")
+		target2 := newTestClientWithName("target2", "Target Two")
+		room.addParticipant(sender)
+		room.addParticipant(target1)
+		room.addHost(target2)
+
+		room.router(context.Background(), sender, candidateMsg(sender, target1.ID, "candidate:a"))
+		room.router(context.Background(), sender, candidateMsg(sender, target2.ID, "candidate:b"))
+		room.router(context.Background(), sender, candidateMsg(sender, target1.ID, "candidate:c"))
+		fakeClock.Step(50 * time.Millisecond)
+
+		require.Len(t, target1.send, 1)
+		require.Len(t, target2.send, 1)
+
+		var single Message
+		require.NoError(t, json.Unmarshal(<-target2.send, &single))
+		assert.Equal(t, EventCandidate, single.Event, "A lone candidate is forwarded as a plain candidate")
+	})
+
+	t.Run("candidates are forwarded individually when batching is disabled", func(t *testing.T) {
+		room, _ := newBatchingRoom(0)
+		sender := newTestClientWithName("sender1", "Sender User")
+		target := newTestClientWithName("target1", "Target User")
+		room.addParticipant(sender)
+		room.addParticipant(target)
+
+		room.router(context.Background(), sender, candidateMsg(sender, target.ID, "candidate:1"))
+		room.router(context.Background(), sender, candidateMsg(sender, target.ID, "candidate:2"))
+
+		assert.Len(t, target.send, 2, "Each candidate should be forwarded immediately")
+	})
+
+	t.Run("pending batches are dropped when a peer disconnects", func(t *testing.T) {
+		room, fakeClock := newBatchingRoom(50 * time.Millisecond)
+		sender := newTestClientWithName("sender1", "Sender User")
+		target := newTestClientWithName("target1", "Target User")
+		room.addParticipant(sender)
+		room.addParticipant(target)
+
+		room.router(context.Background(), sender, candidateMsg(sender, target.ID, "candidate:1"))
+		room.handleClientDisconnect(sender)
+		for len(target.send) > 0 {
+			<-target.send // discard the disconnect broadcast
+		}
+
+		assert.Empty(t, room.pendingCandidates)
+		assert.False(t, fakeClock.HasWaiters(), "The batch timer should be stopped")
+		fakeClock.Step(50 * time.Millisecond)
+		assert.Len(t, target.send, 0)
+	})
+}
+
+func TestGlareDetection(t *testing.T) {
+	newGlareRoom := func() *Room {
+		config := DefaultRoomConfig()
+		config.GlareDetection = true
+		return NewRoomWithConfig("test-room", config, nil)
+	}
+
+	offerMsg := func(sender *Client, target ClientIdType) Message {
+		return Message{Event: EventOffer, Payload: WebRTCOfferPayload{
+			ClientInfo:     ClientInfo{ClientId: sender.ID, DisplayName: sender.DisplayName},
+			TargetClientId: target,
+			SDP:            "v=0...",
+			Type:           "offer",
+		}}
+	}
+
+	// readEvent decodes the next message sent to a client.
+	readEvent := func(t *testing.T, client *Client) (Event, json.RawMessage) {
+		t.Helper()
+		require.NotEmpty(t, client.send, "client %s should have a pending message", client.ID)
+		var msg struct {
+			Event   Event           `json:"event"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-client.send, &msg))
+		return msg.Event, msg.Payload
+	}
+
+	t.Run("simultaneous offers are resolved in favor of the impolite peer", func(t *testing.T) {
+		for _, order := range []string{"impolite first", "polite first"} {
+			t.Run(order, func(t *testing.T) {
+				room := newGlareRoom()
+				impolite := newTestClientWithName("alice", "Alice")
+				polite := newTestClientWithName("bob", "Bob")
+				room.addParticipant(impolite)
+				room.addParticipant(polite)
+
+				if order == "impolite first" {
+					room.router(context.Background(), impolite, offerMsg(impolite, polite.ID))
+					room.router(context.Background(), polite, offerMsg(polite, impolite.ID))
+				} else {
+					room.router(context.Background(), polite, offerMsg(polite, impolite.ID))
+					room.router(context.Background(), impolite, offerMsg(impolite, polite.ID))
+				}
+
+				// The polite peer receives the impolite peer's offer and a glare hint
+				var events []Event
+				var hint GlareDetectedPayload
+				for len(polite.send) > 0 {
+					event, payload := readEvent(t, polite)
+					events = append(events, event)
+					if event == EventGlareDetected {
+						require.NoError(t, json.Unmarshal(payload, &hint))
+					}
+				}
+				assert.ElementsMatch(t, []Event{EventOffer, EventGlareDetected}, events)
+				assert.Equal(t, impolite.ID, hint.PeerClientId)
+				assert.True(t, hint.Polite)
+
+				// The impolite peer's view depends on order: when the polite offer
+				// arrived first it was forwarded before glare could be detected.
+				if order == "polite first" {
+					event, _ := readEvent(t, impolite)
+					assert.Equal(t, EventOffer, event)
+				}
+				assert.Empty(t, impolite.send, "Impolite peer should never receive a glare hint")
+			})
+		}
+	})
+
+	t.Run("answered offer does not cause glare", func(t *testing.T) {
+		room := newGlareRoom()
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+
+		room.router(context.Background(), bob, offerMsg(bob, alice.ID))
+		room.router(context.Background(), alice, Message{Event: EventAnswer, Payload: WebRTCAnswerPayload{
+			ClientInfo:     ClientInfo{ClientId: alice.ID, DisplayName: alice.DisplayName},
+			TargetClientId: bob.ID,
+			SDP:            "v=0...",
+			Type:           "answer",
+		}})
+		room.router(context.Background(), alice, offerMsg(alice, bob.ID))
+
+		event, _ := readEvent(t, bob)
+		assert.Equal(t, EventAnswer, event)
+		event, _ = readEvent(t, bob)
+		assert.Equal(t, EventOffer, event)
+		assert.Empty(t, bob.send)
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		room := NewRoom("test-room", nil)
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+
+		room.router(context.Background(), alice, offerMsg(alice, bob.ID))
+		room.router(context.Background(), bob, offerMsg(bob, alice.ID))
+
+		event, _ := readEvent(t, alice)
+		assert.Equal(t, EventOffer, event)
+		event, _ = readEvent(t, bob)
+		assert.Equal(t, EventOffer, event)
+	})
+}
+
+func TestSignalingReachesScreensharers(t *testing.T) {
+	room := NewTestRoom("test-room", nil)
+	sender := newTestClientWithName("participant1", "Participant")
+	sharer := newTestClientWithName("sharer1", "Sharer")
+	room.addParticipant(sender)
+	room.addParticipant(sharer)
+	require.NoError(t, room.transitionRole(sharer, RoleTypeParticipant, RoleTypeScreenshare))
+	for len(sharer.send) > 0 {
+		<-sharer.send
+	}
+
+	room.router(context.Background(), sender, Message{Event: EventOffer, Payload: WebRTCOfferPayload{
+		ClientInfo:     ClientInfo{ClientId: sender.ID, DisplayName: sender.DisplayName},
+		TargetClientId: sharer.ID,
+		SDP:            "v=0...",
+		Type:           "offer",
+	}})
+
+	require.Len(t, sharer.send, 1, "Offer should reach a client that is sharing their screen")
+}
+
+func TestHandleVideoPause(t *testing.T) {
+	pauseMsg := func(event Event, sender *Client, target ClientIdType) Message {
+		return Message{Event: event, Payload: VideoPausePayload{
+			ClientInfo:     ClientInfo{ClientId: sender.ID, DisplayName: sender.DisplayName},
+			TargetClientId: target,
+		}}
+	}
+
+	for _, event := range []Event{EventPauseVideo, EventResumeVideo} {
+		t.Run(string(event)+" request reaches the target", func(t *testing.T) {
+			room := NewTestRoom("test-room", nil)
+			viewer := newTestClientWithName("viewer1", "Viewer")
+			sender := newTestClientWithName("sender1", "Sender")
+			room.addParticipant(viewer)
+			room.addParticipant(sender)
+
+			room.router(context.Background(), viewer, pauseMsg(event, viewer, sender.ID))
+
+			require.Len(t, sender.send, 1)
+			var msg struct {
+				Event   Event             `json:"event"`
+				Payload VideoPausePayload `json:"payload"`
+			}
+			require.NoError(t, json.Unmarshal(<-sender.send, &msg))
+			assert.Equal(t, event, msg.Event)
+			assert.Equal(t, viewer.ID, msg.Payload.ClientId)
+			assert.Equal(t, sender.ID, msg.Payload.TargetClientId)
+			assert.Empty(t, viewer.send, "Nothing should be echoed to the requester")
+		})
+	}
+
+	t.Run("sender identity is stamped from the connection", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		viewer := newTestClientWithName("viewer1", "Viewer")
+		sender := newTestClientWithName("sender1", "Sender")
+		room.addParticipant(viewer)
+		room.addParticipant(sender)
+
+		spoofed := pauseMsg(EventPauseVideo, viewer, sender.ID)
+		spoofed.Payload = VideoPausePayload{ClientInfo: ClientInfo{ClientId: "someone-else"}, TargetClientId: sender.ID}
+		room.router(context.Background(), viewer, spoofed)
+
+		var msg struct {
+			Payload VideoPausePayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-sender.send, &msg))
+		assert.Equal(t, viewer.ID, msg.Payload.ClientId)
+	})
+
+	t.Run("waiting clients cannot send pause requests", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		waiting := newTestClientWithName("waiting1", "Waiting")
+		sender := newTestClientWithName("sender1", "Sender")
+		room.addWaiting(waiting)
+		room.addParticipant(sender)
+
+		room.router(context.Background(), waiting, pauseMsg(EventPauseVideo, waiting, sender.ID))
+
+		assert.Empty(t, sender.send, "Pause requests require participant permission")
+	})
+}
+
+func TestSignalingOrder(t *testing.T) {
+	// received decodes every message waiting on a client's send channel
+	received := func(t *testing.T, c *Client) []wireMessage {
+		t.Helper()
+		var msgs []wireMessage
+		for len(c.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-c.send, &msg))
+			msgs = append(msgs, msg)
+		}
+		return msgs
+	}
+
+	t.Run("rapid candidates from concurrent senders arrive in send order", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		target := newTestClientWithName("target", "Target")
+		target.send = make(chan []byte, 200)
+		room.addParticipant(target)
+		senders := []*Client{newTestClientWithName("alice", "Alice"), newTestClientWithName("bob", "Bob")}
+		for _, s := range senders {
+			room.addParticipant(s)
+		}
+		received(t, target)
+
+		const candidates = 50
+		var wg sync.WaitGroup
+		for _, s := range senders {
+			wg.Add(1)
+			go func(sender *Client) {
+				defer wg.Done()
+				for n := range candidates {
+					room.router(context.Background(), sender, Message{Event: EventCandidate, Payload: WebRTCCandidatePayload{
+						ClientInfo:     sender.info(),
+						TargetClientId: target.ID,
+						Candidate:      fmt.Sprintf("candidate:%03d", n),
+					}})
+				}
+			}(s)
+		}
+		wg.Wait()
+
+		next := map[ClientIdType]int{}
+		for _, msg := range received(t, target) {
+			require.Equal(t, EventCandidate, msg.Event)
+			var p WebRTCCandidatePayload
+			require.NoError(t, json.Unmarshal(msg.Payload, &p))
+			assert.Equal(t, fmt.Sprintf("candidate:%03d", next[p.ClientId]), p.Candidate)
+			next[p.ClientId]++
+			assert.Equal(t, uint64(next[p.ClientId]), p.Seq, "Sequence numbers count up per route")
+		}
+		assert.Equal(t, map[ClientIdType]int{"alice": candidates, "bob": candidates}, next)
+	})
+
+	t.Run("batched candidates are flushed before later signaling", func(t *testing.T) {
+		fakeClock := testclock.NewFakeClock(time.Now())
+		config := DefaultRoomConfig()
+		config.Clock = fakeClock
+		config.CandidateBatchWindow = time.Second
+		room := NewRoomWithConfig("test-room", config, nil)
+		offerer := newTestClientWithName("offerer", "Offerer")
+		answerer := newTestClientWithName("answerer", "Answerer")
+		room.addParticipant(offerer)
+		room.addParticipant(answerer)
+		received(t, offerer)
+
+		for _, c := range []string{"candidate:1", "candidate:2"} {
+			room.router(context.Background(), answerer, Message{Event: EventCandidate, Payload: WebRTCCandidatePayload{
+				ClientInfo: answerer.info(), TargetClientId: offerer.ID, Candidate: c,
+			}})
+		}
+		room.router(context.Background(), answerer, Message{Event: EventAnswer, Payload: WebRTCAnswerPayload{
+			ClientInfo: answerer.info(), TargetClientId: offerer.ID, SDP: "v=0", Type: "answer",
+		}})
+
+		msgs := received(t, offerer)
+		require.Len(t, msgs, 2)
+		require.Equal(t, EventCandidateBatch, msgs[0].Event, "Earlier candidates are not overtaken")
+		var batch WebRTCCandidateBatchPayload
+		require.NoError(t, json.Unmarshal(msgs[0].Payload, &batch))
+		require.Len(t, batch.Candidates, 2)
+		assert.Equal(t, []uint64{1, 2}, []uint64{batch.Candidates[0].Seq, batch.Candidates[1].Seq})
+
+		require.Equal(t, EventAnswer, msgs[1].Event)
+		var answer WebRTCAnswerPayload
+		require.NoError(t, json.Unmarshal(msgs[1].Payload, &answer))
+		assert.Equal(t, uint64(3), answer.Seq)
+
+		fakeClock.Step(time.Second)
+		assert.Empty(t, received(t, offerer), "The flushed batch is not delivered again")
+	})
+
+	t.Run("sequences restart when a peer rejoins", func(t *testing.T) {
+		room := NewTestRoom("test-room", nil)
+		sender := newTestClientWithName("sender", "Sender")
+		target := newTestClientWithName("target", "Target")
+		room.addParticipant(sender)
+		room.addParticipant(target)
+		offer := Message{Event: EventOffer, Payload: WebRTCOfferPayload{
+			ClientInfo: sender.info(), TargetClientId: target.ID, SDP: "v=0", Type: "offer",
+		}}
+
+		room.router(context.Background(), sender, offer)
+		room.disconnectClient(target)
+		room.addParticipant(target)
+		received(t, target)
+		room.router(context.Background(), sender, offer)
+
+		msgs := received(t, target)
+		require.Len(t, msgs, 1)
+		var p WebRTCOfferPayload
+		require.NoError(t, json.Unmarshal(msgs[0].Payload, &p))
+		assert.Equal(t, uint64(1), p.Seq)
+	})
+}
+
+func TestSignalingSenderStamping(t *testing.T) {
+	setup := func() (*Room, *Client, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		mallory := newTestClientWithName("mallory", "Mallory")
+		alice := newTestClientWithName("alice", "Alice")
+		bob := newTestClientWithName("bob", "Bob")
+		room.addParticipant(mallory)
+		room.addParticipant(alice)
+		room.addParticipant(bob)
+		return room, mallory, alice, bob
+	}
+	spoofed := ClientInfo{ClientId: "alice", DisplayName: "Alice"}
+
+	// sender returns the sender of the signaling message target received.
+	sender := func(t *testing.T, target *Client) ClientInfo {
+		t.Helper()
+		require.Len(t, target.send, 1)
+		var msg struct {
+			Event   Event      `json:"event"`
+			Payload ClientInfo `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-target.send, &msg))
+		return msg.Payload
+	}
+
+	t.Run("an offer claiming another client's id shows the real sender", func(t *testing.T) {
+		room, mallory, _, bob := setup()
+
+		room.router(context.Background(), mallory, Message{Event: EventOffer, Payload: WebRTCOfferPayload{
+			ClientInfo:     spoofed,
+			TargetClientId: bob.ID,
+			SDP:            "v=0...",
+			Type:           "offer",
+		}})
+
+		assert.Equal(t, mallory.info(), sender(t, bob))
+	})
+
+	t.Run("answers, candidates and renegotiation are stamped too", func(t *testing.T) {
+		room, mallory, _, bob := setup()
+		mid, index := "0", 0
+
+		for _, msg := range []Message{
+			{Event: EventAnswer, Payload: WebRTCAnswerPayload{ClientInfo: spoofed, TargetClientId: bob.ID, SDP: "v=0...", Type: "answer"}},
+			{Event: EventCandidate, Payload: WebRTCCandidatePayload{ClientInfo: spoofed, TargetClientId: bob.ID, Candidate: "candidate:1", SDPMid: &mid, SDPMLineIndex: &index}},
+			{Event: EventRenegotiate, Payload: WebRTCRenegotiatePayload{ClientInfo: spoofed, TargetClientId: bob.ID, Reason: "camera"}},
+		} {
+			room.router(context.Background(), mallory, msg)
+			assert.Equal(t, mallory.info(), sender(t, bob), "%s should carry the real sender", msg.Event)
+		}
+	})
+
+	t.Run("batched candidates carry the real sender", func(t *testing.T) {
+		room, mallory, _, bob := setup()
+		room.config.CandidateBatchWindow = time.Hour
+		mid, index := "0", 0
+
+		for _, candidate := range []string{"candidate:1", "candidate:2"} {
+			room.router(context.Background(), mallory, Message{Event: EventCandidate, Payload: WebRTCCandidatePayload{
+				ClientInfo: spoofed, TargetClientId: bob.ID, Candidate: candidate, SDPMid: &mid, SDPMLineIndex: &index,
+			}})
+		}
+		room.mu.Lock()
+		room.flushCandidates(peerRoute{from: mallory.ID, to: bob.ID})
+		room.mu.Unlock()
+
+		require.Len(t, bob.send, 1)
+		var msg struct {
+			Payload WebRTCCandidateBatchPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-bob.send, &msg))
+		assert.Equal(t, mallory.info(), msg.Payload.ClientInfo)
+		for _, c := range msg.Payload.Candidates {
+			assert.Equal(t, mallory.info(), c.ClientInfo)
+		}
+		room.close()
+	})
+}
+
+func TestRemoteControl(t *testing.T) {
+	setup := func() (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		sharer := newTestClientWithName("sharer", "Sharer")
+		viewer := newTestClientWithName("viewer", "Viewer")
+		room.addScreenshare(sharer)
+		room.addParticipant(viewer)
+		return room, sharer, viewer
+	}
+
+	// received returns the event and payload c was sent, which must be one message.
+	received := func(t *testing.T, c *Client) (Event, json.RawMessage) {
+		t.Helper()
+		require.Len(t, c.send, 1)
+		var msg wireMessage
+		require.NoError(t, json.Unmarshal(<-c.send, &msg))
+		return msg.Event, msg.Payload
+	}
+
+	t.Run("a request reaches the sharer and the grant reaches the viewer", func(t *testing.T) {
+		room, sharer, viewer := setup()
+
+		room.router(context.Background(), viewer, Message{Event: EventRequestRemoteControl, Payload: RemoteControlPayload{
+			ClientInfo: ClientInfo{ClientId: "someone-else"}, TargetClientId: sharer.ID,
+		}})
+		event, raw := received(t, sharer)
+		assert.Equal(t, EventRequestRemoteControl, event)
+		var request RemoteControlPayload
+		require.NoError(t, json.Unmarshal(raw, &request))
+		assert.Equal(t, viewer.info(), request.ClientInfo, "The requester is stamped by the server")
+
+		room.router(context.Background(), sharer, Message{Event: EventGrantRemoteControl, Payload: RemoteControlPayload{TargetClientId: viewer.ID}})
+		event, raw = received(t, viewer)
+		assert.Equal(t, EventGrantRemoteControl, event)
+		var grant RemoteControlPayload
+		require.NoError(t, json.Unmarshal(raw, &grant))
+		assert.Equal(t, sharer.info(), grant.ClientInfo)
+		assert.Equal(t, viewer.ID, grant.TargetClientId)
+	})
+
+	t.Run("the sharer may deny the request", func(t *testing.T) {
+		room, sharer, viewer := setup()
+
+		room.router(context.Background(), sharer, Message{Event: EventDenyRemoteControl, Payload: RemoteControlPayload{TargetClientId: viewer.ID}})
+
+		event, _ := received(t, viewer)
+		assert.Equal(t, EventDenyRemoteControl, event)
+	})
+
+	t.Run("a request to a client that is not sharing is rejected", func(t *testing.T) {
+		room, _, viewer := setup()
+		other := newTestClientWithName("other", "Other")
+		room.addParticipant(other)
+
+		room.router(context.Background(), viewer, Message{Event: EventRequestRemoteControl, Payload: RemoteControlPayload{TargetClientId: other.ID}})
+
+		assert.Empty(t, other.send)
+		event, raw := received(t, viewer)
+		require.Equal(t, EventError, event)
+		var errPayload ErrorPayload
+		require.NoError(t, json.Unmarshal(raw, &errPayload))
+		assert.Equal(t, ErrorCodeNotSharing, errPayload.Code)
+	})
+
+	t.Run("only sharers may answer", func(t *testing.T) {
+		room, sharer, viewer := setup()
+
+		room.router(context.Background(), viewer, Message{Event: EventGrantRemoteControl, Payload: RemoteControlPayload{TargetClientId: sharer.ID}})
+
+		assert.Empty(t, sharer.send)
+	})
+
+	t.Run("the feature can be switched off", func(t *testing.T) {
+		room, sharer, viewer := setup()
+		features := DefaultRoomFeatures()
+		features.RemoteControlEnabled = false
+		room.features = features
+
+		room.router(context.Background(), viewer, Message{Event: EventRequestRemoteControl, Payload: RemoteControlPayload{TargetClientId: sharer.ID}})
+
+		assert.Empty(t, sharer.send)
+		event, raw := received(t, viewer)
+		require.Equal(t, EventError, event)
+		var errPayload ErrorPayload
+		require.NoError(t, json.Unmarshal(raw, &errPayload))
+		assert.Equal(t, ErrorCodeFeatureDisabled, errPayload.Code)
+	})
+
+	t.Run("a sharing host takes requests and answers them", func(t *testing.T) {
+		room, _, viewer := setup()
+		host := newTestClientWithName("host", "Host")
+		room.addHost(host)
+		room.addScreenshare(host)
+
+		room.router(context.Background(), viewer, Message{Event: EventRequestRemoteControl, Payload: RemoteControlPayload{TargetClientId: host.ID}})
+		event, _ := received(t, host)
+		assert.Equal(t, EventRequestRemoteControl, event)
+
+		room.router(context.Background(), host, Message{Event: EventGrantRemoteControl, Payload: RemoteControlPayload{TargetClientId: viewer.ID}})
+		event, _ = received(t, viewer)
+		assert.Equal(t, EventGrantRemoteControl, event)
+
+		room.router(context.Background(), host, Message{Event: EventDenyRemoteControl, Payload: RemoteControlPayload{TargetClientId: viewer.ID}})
+		event, _ = received(t, viewer)
+		assert.Equal(t, EventDenyRemoteControl, event)
+	})
+
+	t.Run("a host that is not sharing may not answer", func(t *testing.T) {
+		room, _, viewer := setup()
+		host := newTestClientWithName("host", "Host")
+		room.addHost(host)
+
+		room.router(context.Background(), host, Message{Event: EventGrantRemoteControl, Payload: RemoteControlPayload{TargetClientId: viewer.ID}})
+
+		assert.Empty(t, viewer.send)
+		event, raw := received(t, host)
+		require.Equal(t, EventError, event)
+		var errPayload ErrorPayload
+		require.NoError(t, json.Unmarshal(raw, &errPayload))
+		assert.Equal(t, ErrorCodeNotSharing, errPayload.Code)
+	})
+}
+
+func TestIsPresent(t *testing.T) {
+	setup := func() (*Room, *Client, *Client) {
+		room := NewTestRoom("test-room", nil)
+		alice := newTestClientWithName("alice", "Alice")
+		host := newTestClientWithName("host", "Host")
+		room.addParticipant(alice)
+		room.addHost(host)
+		return room, alice, host
+	}
+
+	// answer returns the presence answer c was sent, which must be its only message.
+	answer := func(t *testing.T, c *Client) IsPresentPayload {
+		t.Helper()
+		require.Len(t, c.send, 1)
+		var msg struct {
+			Event   Event            `json:"event"`
+			Payload IsPresentPayload `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-c.send, &msg))
+		require.Equal(t, EventIsPresent, msg.Event)
+		return msg.Payload
+	}
+
+	t.Run("a present peer is reported with its role", func(t *testing.T) {
+		room, alice, host := setup()
+
+		room.router(context.Background(), alice, Message{Event: EventIsPresent, Payload: IsPresentPayload{ClientId: host.ID}})
+
+		assert.Equal(t, IsPresentPayload{ClientId: host.ID, Present: true, Role: RoleTypeHost}, answer(t, alice))
+		assert.Empty(t, host.send, "The peer is not told it was asked about")
+	})
+
+	t.Run("departed and waiting clients are absent", func(t *testing.T) {
+		room, alice, _ := setup()
+		waiting := newTestClientWithName("waiting", "Waiting")
+		room.addWaiting(waiting)
+
+		for _, id := range []ClientIdType{"gone", waiting.ID} {
+			room.router(context.Background(), alice, Message{Event: EventIsPresent, Payload: IsPresentPayload{ClientId: id}})
+			assert.Equal(t, IsPresentPayload{ClientId: id}, answer(t, alice))
+		}
+	})
+
+	t.Run("a reply ignores answers filled in by the requester", func(t *testing.T) {
+		room, alice, _ := setup()
+
+		room.router(context.Background(), alice, Message{Event: EventIsPresent, Payload: IsPresentPayload{ClientId: "gone", Present: true, Role: RoleTypeHost}})
+
+		assert.False(t, answer(t, alice).Present)
+	})
+
+	t.Run("spectators cannot ask", func(t *testing.T) {
+		room, _, host := setup()
+		spectator := newTestClientWithName("spectator", "Spectator")
+		room.addSpectator(spectator)
+
+		room.router(context.Background(), spectator, Message{Event: EventIsPresent, Payload: IsPresentPayload{ClientId: host.ID}})
+
+		for len(spectator.send) > 0 {
+			var msg wireMessage
+			require.NoError(t, json.Unmarshal(<-spectator.send, &msg))
+			assert.NotEqual(t, EventIsPresent, msg.Event)
+		}
+	})
 }